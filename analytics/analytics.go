@@ -0,0 +1,161 @@
+// Package analytics rolls per-day listing facts up into the time series hosts see on
+// their stats dashboard.
+package analytics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Granularity is the width of one point of a series.
+type Granularity string
+
+const (
+	Day   Granularity = "day"
+	Week  Granularity = "week"
+	Month Granularity = "month"
+)
+
+// ParseGranularity parses a granularity query parameter. An empty string means Day.
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case "":
+		return Day, nil
+	case Day, Week, Month:
+		return g, nil
+	}
+	return "", fmt.Errorf("granularity must be one of day, week or month")
+}
+
+// Start returns the first day of the bucket containing t. Weeks start on Monday.
+func (g Granularity) Start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch g {
+	case Week:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case Month:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// DailyFacts are the raw counts of one day across the listings in scope. ListingNights is
+// the number of listings that existed that day, i.e. the nights that could have been sold.
+// UniqueViews counts distinct visitors per listing, so summed over days or listings it is
+// visitor-days rather than distinct people.
+type DailyFacts struct {
+	Day               time.Time
+	ListingNights     int
+	Views             int
+	UniqueViews       int
+	BookingRequests   int
+	ConfirmedBookings int
+	Cancellations     int
+	BookedNights      int
+	Revenue           float64
+}
+
+// Point is one bucket of a series.
+//
+// ConversionRate is booking requests per view, OccupancyRate is booked nights per
+// available night, ADR (average daily rate) is revenue per booked night and RevPAR is
+// revenue per available night. Rates are zero when their denominator is.
+type Point struct {
+	Start             time.Time `json:"start"`
+	Views             int       `json:"views"`
+	UniqueViews       int       `json:"unique_views"`
+	BookingRequests   int       `json:"booking_requests"`
+	ConfirmedBookings int       `json:"confirmed_bookings"`
+	Cancellations     int       `json:"cancellations"`
+	AvailableNights   int       `json:"available_nights"`
+	BookedNights      int       `json:"booked_nights"`
+	Revenue           float64   `json:"revenue"`
+	ConversionRate    float64   `json:"conversion_rate"`
+	OccupancyRate     float64   `json:"occupancy_rate"`
+	ADR               float64   `json:"adr"`
+	RevPAR            float64   `json:"revpar"`
+}
+
+// Series sums days into buckets of the given granularity. days must be sorted by day; the
+// returned points are in the same order.
+func Series(days []DailyFacts, g Granularity) []Point {
+	points := []Point{}
+	for _, d := range days {
+		start := g.Start(d.Day)
+		if len(points) == 0 || !points[len(points)-1].Start.Equal(start) {
+			points = append(points, Point{Start: start})
+		}
+		p := &points[len(points)-1]
+		p.Views += d.Views
+		p.UniqueViews += d.UniqueViews
+		p.BookingRequests += d.BookingRequests
+		p.ConfirmedBookings += d.ConfirmedBookings
+		p.Cancellations += d.Cancellations
+		p.AvailableNights += d.ListingNights
+		p.BookedNights += d.BookedNights
+		p.Revenue += d.Revenue
+	}
+
+	for i := range points {
+		p := &points[i]
+		p.Revenue = round(p.Revenue, 2)
+		p.ConversionRate = round(ratio(float64(p.BookingRequests), p.Views), 4)
+		p.OccupancyRate = round(ratio(float64(p.BookedNights), p.AvailableNights), 4)
+		p.ADR = round(ratio(p.Revenue, p.BookedNights), 2)
+		p.RevPAR = round(ratio(p.Revenue, p.AvailableNights), 2)
+	}
+	return points
+}
+
+func ratio(n float64, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return n / float64(d)
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+var csvHeader = []string{
+	"start", "views", "unique_views", "booking_requests", "confirmed_bookings", "cancellations",
+	"available_nights", "booked_nights", "revenue", "conversion_rate", "occupancy_rate",
+	"adr", "revpar",
+}
+
+// WriteCSV writes points as CSV with a header row. Dates are formatted as 2006-01-02.
+func WriteCSV(w io.Writer, points []Point) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range points {
+		record := []string{
+			p.Start.Format("2006-01-02"),
+			strconv.Itoa(p.Views),
+			strconv.Itoa(p.UniqueViews),
+			strconv.Itoa(p.BookingRequests),
+			strconv.Itoa(p.ConfirmedBookings),
+			strconv.Itoa(p.Cancellations),
+			strconv.Itoa(p.AvailableNights),
+			strconv.Itoa(p.BookedNights),
+			strconv.FormatFloat(p.Revenue, 'f', 2, 64),
+			strconv.FormatFloat(p.ConversionRate, 'f', 4, 64),
+			strconv.FormatFloat(p.OccupancyRate, 'f', 4, 64),
+			strconv.FormatFloat(p.ADR, 'f', 2, 64),
+			strconv.FormatFloat(p.RevPAR, 'f', 2, 64),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseGranularity(t *testing.T) {
+	g, err := ParseGranularity("")
+	require.NoError(t, err)
+	require.Equal(t, Day, g)
+
+	g, err = ParseGranularity("month")
+	require.NoError(t, err)
+	require.Equal(t, Month, g)
+
+	_, err = ParseGranularity("year")
+	require.Error(t, err)
+}
+
+func TestGranularityStart(t *testing.T) {
+	// Sunday 9 June 2024 belongs to the week starting Monday 3 June.
+	sunday := time.Date(2024, time.June, 9, 18, 30, 0, 0, time.UTC)
+	require.Equal(t, date(2024, time.June, 9), Day.Start(sunday))
+	require.Equal(t, date(2024, time.June, 3), Week.Start(sunday))
+	require.Equal(t, date(2024, time.June, 1), Month.Start(sunday))
+	require.Equal(t, date(2024, time.June, 10), Week.Start(date(2024, time.June, 10)))
+}
+
+func TestSeries(t *testing.T) {
+	days := []DailyFacts{
+		{Day: date(2024, time.May, 30), ListingNights: 2, Views: 40, BookingRequests: 2, ConfirmedBookings: 1, BookedNights: 1, Revenue: 100},
+		{Day: date(2024, time.May, 31), ListingNights: 2, Views: 60, BookingRequests: 1, Cancellations: 1, BookedNights: 2, Revenue: 250},
+		{Day: date(2024, time.June, 1), ListingNights: 2},
+	}
+
+	points := Series(days, Month)
+	require.Len(t, points, 2)
+
+	may := points[0]
+	require.Equal(t, date(2024, time.May, 1), may.Start)
+	require.Equal(t, 100, may.Views)
+	require.Equal(t, 3, may.BookingRequests)
+	require.Equal(t, 1, may.ConfirmedBookings)
+	require.Equal(t, 1, may.Cancellations)
+	require.Equal(t, 4, may.AvailableNights)
+	require.Equal(t, 3, may.BookedNights)
+	require.Equal(t, 350.0, may.Revenue)
+	require.Equal(t, 0.03, may.ConversionRate)
+	require.Equal(t, 0.75, may.OccupancyRate)
+	require.Equal(t, 116.67, may.ADR)
+	require.Equal(t, 87.5, may.RevPAR)
+
+	// A bucket without views or bookings has zero rates rather than NaN.
+	june := points[1]
+	require.Equal(t, date(2024, time.June, 1), june.Start)
+	require.Zero(t, june.ConversionRate)
+	require.Zero(t, june.OccupancyRate)
+	require.Zero(t, june.ADR)
+
+	require.Len(t, Series(days, Day), 3)
+	require.Empty(t, Series(nil, Week))
+}
+
+func TestWriteCSV(t *testing.T) {
+	points := Series([]DailyFacts{
+		{Day: date(2024, time.June, 3), ListingNights: 1, Views: 8, UniqueViews: 5, BookingRequests: 1, BookedNights: 1, Revenue: 120.5},
+	}, Day)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, points))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.True(t, strings.HasPrefix(lines[0], "start,views,unique_views,booking_requests,"))
+	require.Equal(t, "2024-06-03,8,5,1,0,0,1,1,120.50,0.1250,1.0000,120.50,120.50", lines[1])
+}
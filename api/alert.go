@@ -0,0 +1,417 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+)
+
+// defaultAlertPreferences apply to users who never saved their own.
+var defaultAlertPreferences = alertPreferencesResponse{
+	Frequency:     "daily",
+	PriceDrops:    true,
+	Availability:  true,
+	SavedSearches: true,
+}
+
+// currentUser loads the signed-in user. It writes the error response and returns false if
+// the request is not from a user.
+func (s *Server) currentUser(c *gin.Context) (db.User, bool) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return db.User{}, false
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return db.User{}, false
+	}
+	return user, true
+}
+
+type savedSearchRequest struct {
+	Name          string `json:"name" binding:"required,max=100"`
+	Keyword       string `json:"keyword" binding:"max=255"`
+	Location      string `json:"location" binding:"max=255"`
+	MinPrice      string `json:"min_price"`
+	MaxPrice      string `json:"max_price"`
+	AlertsEnabled *bool  `json:"alerts_enabled"`
+}
+
+type savedSearchResponse struct {
+	ID            int32     `json:"id"`
+	Name          string    `json:"name"`
+	Keyword       string    `json:"keyword"`
+	Location      string    `json:"location,omitempty"`
+	MinPrice      string    `json:"min_price,omitempty"`
+	MaxPrice      string    `json:"max_price,omitempty"`
+	AlertsEnabled bool      `json:"alerts_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newSavedSearchResponse(search db.SavedSearch) savedSearchResponse {
+	return savedSearchResponse{
+		ID:            search.ID,
+		Name:          search.Name,
+		Keyword:       search.Keyword,
+		Location:      search.Location.String,
+		MinPrice:      search.MinPrice.String,
+		MaxPrice:      search.MaxPrice.String,
+		AlertsEnabled: search.AlertsEnabled,
+		CreatedAt:     search.CreatedAt.Time,
+	}
+}
+
+var errInvalidPriceRange = errors.New("prices must not be negative and max_price must not be below min_price")
+
+// savedSearchFilters validates the price range of a saved search request and returns its
+// filters as stored. Empty filters are left unset.
+func savedSearchFilters(req savedSearchRequest) (location, minPrice, maxPrice sql.NullString, err error) {
+	location = sql.NullString{String: req.Location, Valid: req.Location != ""}
+
+	var minCents, maxCents int64
+	if req.MinPrice != "" {
+		if minCents, err = payment.ToCents(req.MinPrice); err != nil {
+			return
+		}
+		minPrice = sql.NullString{String: payment.FormatCents(minCents), Valid: true}
+	}
+	if req.MaxPrice != "" {
+		if maxCents, err = payment.ToCents(req.MaxPrice); err != nil {
+			return
+		}
+		maxPrice = sql.NullString{String: payment.FormatCents(maxCents), Valid: true}
+	}
+	if minCents < 0 || maxCents < 0 || (minPrice.Valid && maxPrice.Valid && maxCents < minCents) {
+		err = errInvalidPriceRange
+	}
+	return
+}
+
+// CreateSavedSearch saves a search for the signed-in user. Alerts for new listings that
+// match it are on unless alerts_enabled is false.
+func (s *Server) CreateSavedSearch(c *gin.Context) {
+	var req savedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	location, minPrice, maxPrice, err := savedSearchFilters(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	search, err := s.q.CreateSavedSearch(c, db.CreateSavedSearchParams{
+		UserID:        user.ID,
+		Name:          req.Name,
+		Keyword:       req.Keyword,
+		Location:      location,
+		MinPrice:      minPrice,
+		MaxPrice:      maxPrice,
+		AlertsEnabled: req.AlertsEnabled == nil || *req.AlertsEnabled,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, newSavedSearchResponse(search))
+}
+
+// GetSavedSearches returns the signed-in user's saved searches.
+func (s *Server) GetSavedSearches(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	searches, err := s.q.GetSavedSearches(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]savedSearchResponse, 0, len(searches))
+	for _, search := range searches {
+		response = append(response, newSavedSearchResponse(search))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateSavedSearch replaces the filters of one of the signed-in user's saved searches.
+func (s *Server) UpdateSavedSearch(c *gin.Context) {
+	searchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	var req savedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	location, minPrice, maxPrice, err := savedSearchFilters(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	search, err := s.q.UpdateSavedSearch(c, db.UpdateSavedSearchParams{
+		ID:            int32(searchID),
+		UserID:        user.ID,
+		Name:          req.Name,
+		Keyword:       req.Keyword,
+		Location:      location,
+		MinPrice:      minPrice,
+		MaxPrice:      maxPrice,
+		AlertsEnabled: req.AlertsEnabled == nil || *req.AlertsEnabled,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "saved search not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newSavedSearchResponse(search))
+}
+
+// DeleteSavedSearch deletes one of the signed-in user's saved searches. Alerts it already
+// produced are kept.
+func (s *Server) DeleteSavedSearch(c *gin.Context) {
+	searchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := s.q.DeleteSavedSearch(c, db.DeleteSavedSearchParams{
+		ID:     int32(searchID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saved search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "saved search deleted"})
+}
+
+type alertPreferencesRequest struct {
+	Frequency     string `json:"frequency" binding:"required,oneof=instant daily weekly never"`
+	PriceDrops    bool   `json:"price_drops"`
+	Availability  bool   `json:"availability"`
+	SavedSearches bool   `json:"saved_searches"`
+}
+
+type alertPreferencesResponse struct {
+	Frequency     string     `json:"frequency"`
+	PriceDrops    bool       `json:"price_drops"`
+	Availability  bool       `json:"availability"`
+	SavedSearches bool       `json:"saved_searches"`
+	LastDigestAt  *time.Time `json:"last_digest_at,omitempty"`
+}
+
+func newAlertPreferencesResponse(p db.AlertPreference) alertPreferencesResponse {
+	response := alertPreferencesResponse{
+		Frequency:     p.Frequency,
+		PriceDrops:    p.PriceDrops,
+		Availability:  p.Availability,
+		SavedSearches: p.SavedSearches,
+	}
+	if p.LastDigestAt.Valid {
+		response.LastDigestAt = &p.LastDigestAt.Time
+	}
+	return response
+}
+
+// GetAlertPreferences returns which alerts the signed-in user gets and how often they are
+// emailed.
+func (s *Server) GetAlertPreferences(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := s.q.GetAlertPreferences(c, user.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, defaultAlertPreferences)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newAlertPreferencesResponse(prefs))
+}
+
+// UpdateAlertPreferences sets which alerts the signed-in user gets and how often they are
+// emailed: instant, daily, weekly or never. Alerts always show in-app.
+func (s *Server) UpdateAlertPreferences(c *gin.Context) {
+	var req alertPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := s.q.UpsertAlertPreferences(c, db.UpsertAlertPreferencesParams{
+		UserID:        user.ID,
+		Frequency:     req.Frequency,
+		PriceDrops:    req.PriceDrops,
+		Availability:  req.Availability,
+		SavedSearches: req.SavedSearches,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newAlertPreferencesResponse(prefs))
+}
+
+type listingAlertResponse struct {
+	ID              int32     `json:"id"`
+	Kind            string    `json:"kind"`
+	Message         string    `json:"message"`
+	Read            bool      `json:"read"`
+	ListingID       int32     `json:"listing_id"`
+	Title           string    `json:"title"`
+	Price           string    `json:"price"`
+	Imagelink       []string  `json:"imagelink"`
+	SavedSearchID   int32     `json:"saved_search_id,omitempty"`
+	SavedSearchName string    `json:"saved_search_name,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GetListingAlerts returns the signed-in user's in-app listing alerts, newest first, or
+// only the unread ones with ?unread=true.
+func (s *Server) GetListingAlerts(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+	rows, err := s.q.GetUserListingAlerts(c, db.GetUserListingAlertsParams{
+		UserID:     user.ID,
+		UnreadOnly: unreadOnly,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	alerts := make([]listingAlertResponse, 0, len(rows))
+	for _, row := range rows {
+		alerts = append(alerts, listingAlertResponse{
+			ID:              row.ID,
+			Kind:            row.Kind,
+			Message:         row.Message,
+			Read:            row.Read,
+			ListingID:       row.ListingID,
+			Title:           row.Title,
+			Price:           row.Price,
+			Imagelink:       row.Imagelinks,
+			SavedSearchID:   row.SavedSearchID.Int32,
+			SavedSearchName: row.SavedSearchName.String,
+			CreatedAt:       row.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// MarkListingAlertRead marks one of the signed-in user's listing alerts as read.
+func (s *Server) MarkListingAlertRead(c *gin.Context) {
+	alertID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	updated, err := s.q.MarkListingAlertRead(c, db.MarkListingAlertReadParams{
+		ID:     int32(alertID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if updated == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "alert marked as read"})
+}
+
+// enqueueListingAlert enqueues a task that alerts the users following a listing about a
+// change to it. Failures are logged; the listing change itself has already been saved.
+func (s *Server) enqueueListingAlert(task *asynq.Task, err error) {
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		return
+	}
+	if _, err := s.client.Enqueue(task); err != nil {
+		log.Printf("Failed to enqueue task: %v", err)
+	}
+}
+
+// alertPriceDrop enqueues price-drop alerts if newPrice is below oldPrice.
+func (s *Server) alertPriceDrop(listingID int32, oldPrice, newPrice string) {
+	oldCents, err := payment.ToCents(oldPrice)
+	if err != nil {
+		return
+	}
+	newCents, err := payment.ToCents(newPrice)
+	if err != nil || newCents >= oldCents {
+		return
+	}
+	s.enqueueListingAlert(tasks.NewPriceDropAlertTask(listingID, payment.FormatCents(oldCents), payment.FormatCents(newCents)))
+}
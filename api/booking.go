@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,10 +13,44 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/payment"
 	"github.com/weldonkipchirchir/rental_listing/redisCache"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/team"
+	"github.com/weldonkipchirchir/rental_listing/workflow"
 )
 
+const (
+	bookingModeInstant = "instant"
+	bookingModeRequest = "request"
+)
+
+var errPaymentUsed = errors.New("payment is already used by another booking")
+
+// bookingApprovalWindow is how long a host has to approve a request-to-book booking.
+const bookingApprovalWindow = 24 * time.Hour
+
+// bookingTransitions lists the statuses a host can move a booking to from its current
+// status. Declined, cancelled, completed and expired bookings are final.
+var bookingTransitions = map[string][]string{
+	"pending":   {"confirmed", "declined", "cancelled"},
+	"confirmed": {"cancelled", "completed"},
+}
+
+// canMoveBooking reports whether a host can move a booking from one status to another.
+func canMoveBooking(from, to string) bool {
+	for _, next := range bookingTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 // CreateBooking handles the creation of a new booking.
+// Instant-book listings are confirmed once the payment has succeeded, request-to-book
+// listings only hold an authorized payment until the host approves the booking.
 func (s *Server) CreateBooking(c *gin.Context) {
 	email, ok := c.Get("email")
 	if !ok {
@@ -34,9 +69,9 @@ func (s *Server) CreateBooking(c *gin.Context) {
 		CheckInDate   time.Time `json:"check_in_date" binding:"required"`
 		CheckOutDate  time.Time `json:"check_out_date" binding:"required"`
 		TotalAmount   string    `json:"total_amount" binding:"required"`
-		Status        string    `json:"status" binding:"required"`
 		PaymentId     string    `json:"paymentId" binding:"required"`
-		PaymentMethod []string  `json:"paymentMethod" binding:"required"`
+		PaymentMethod []string  `json:"paymentMethod" binding:"required,min=1"`
+		Guests        int32     `json:"guests" binding:"omitempty,min=1"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -44,49 +79,230 @@ func (s *Server) CreateBooking(c *gin.Context) {
 		return
 	}
 
-	booking, err := s.q.CreateBooking(c, db.CreateBookingParams{
+	listing, err := s.q.GetListingByID(c, int32(req.ListingID))
+	if err == nil && !workflow.Visible(listing.Status) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := checkStayAvailable(c, s.q, listing.ID, 0, req.CheckInDate, req.CheckOutDate); err != nil {
+		if errors.Is(err, errStayUnavailable) {
+			c.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	amount, err := payment.ToCents(req.TotalAmount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// The total is always recomputed from the listing's price rules.
+	quote, err := s.quoteStay(c, listing.ID, listing.Price, req.CheckInDate, req.CheckOutDate)
+	if err != nil {
+		if isStayError(err) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if amount != quote.Total {
+		c.JSON(http.StatusConflict, gin.H{"error": "total amount does not match the current price", "quote": quote})
+		return
+	}
+
+	intent, err := s.payments.GetIntent(c, req.PaymentId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment not found"})
+		return
+	}
+
+	if intent.Amount != amount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment amount does not match the booking total"})
+		return
+	}
+
+	// A payment backs a single booking. The unique index on payments catches bookings
+	// racing this check.
+	used, err := s.q.PaymentTransactionExists(c, sql.NullString{String: intent.ID, Valid: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if used {
+		c.JSON(http.StatusConflict, errorResponse(errPaymentUsed))
+		return
+	}
+
+	arg := db.CreateBookingParams{
 		UserID:       user.ID,
-		ListingID:    int32(req.ListingID),
+		ListingID:    listing.ID,
 		CheckInDate:  req.CheckInDate,
 		CheckOutDate: req.CheckOutDate,
-		TotalAmount:  req.TotalAmount,
-	})
+		TotalAmount:  payment.FormatCents(quote.Total),
+	}
+	if req.Guests > 0 {
+		arg.Guests = sql.NullInt32{Int32: req.Guests, Valid: true}
+	}
+
+	var paymentStatus string
+	var paidAt sql.NullTime
+	switch {
+	case listing.BookingMode == bookingModeRequest && intent.Status == payment.StatusRequiresCapture:
+		paymentStatus = "authorized"
+		arg.Status = sql.NullString{String: "pending", Valid: true}
+		arg.ApprovalDeadline = sql.NullTime{Time: time.Now().Add(bookingApprovalWindow), Valid: true}
+	case listing.BookingMode == bookingModeInstant && intent.Status == payment.StatusSucceeded:
+		paymentStatus = "succeeded"
+		paidAt = sql.NullTime{Time: time.Now(), Valid: true}
+		arg.Status = sql.NullString{String: "confirmed", Valid: true}
+	default:
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "payment has not been completed"})
+		return
+	}
+
+	tx, err := s.db.BeginTx(c, nil)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
 
-	paymentMethod := req.PaymentMethod[0]
+	// The checks above are repeated with the listing locked, so two bookings of the same
+	// dates made at the same time cannot both pass them and a listing being deleted cannot
+	// gain a booking.
+	if err := q.LockListing(c, listing.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	visible, err := q.ListingVisible(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+		return
+	}
+	if err := checkStayAvailable(c, q, listing.ID, 0, req.CheckInDate, req.CheckOutDate); err != nil {
+		if errors.Is(err, errStayUnavailable) {
+			c.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 
-	paid_at := time.Now()
+	booking, err := q.CreateBooking(c, arg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
 
-	_, err = s.q.CreatePayment(c, db.CreatePaymentParams{
+	_, err = q.CreatePayment(c, db.CreatePaymentParams{
 		BookingID:     booking.ID,
 		Amount:        booking.TotalAmount,
-		Status:        sql.NullString{String: req.Status, Valid: true},
-		PaymentMethod: sql.NullString{String: paymentMethod, Valid: true},
-		TransactionID: sql.NullString{String: req.PaymentId, Valid: true},
-		PaidAt:        sql.NullTime{Time: paid_at, Valid: true},
+		Status:        sql.NullString{String: paymentStatus, Valid: true},
+		PaymentMethod: sql.NullString{String: req.PaymentMethod[0], Valid: true},
+		TransactionID: sql.NullString{String: intent.ID, Valid: true},
+		PaidAt:        paidAt,
 		UserID:        int32(user.ID),
 	})
 	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, errorResponse(errPaymentUsed))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-
-	arg := db.UpdateListingStatusParams{
-		ID:        int32(req.ListingID),
-		Available: sql.NullBool{Bool: false, Valid: true},
-	}
-	err = s.q.UpdateListingStatus(c, arg)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	if booking.Status.String == "confirmed" {
+		err = s.q.UpdateListingStatus(c, db.UpdateListingStatusParams{
+			ID:        listing.ID,
+			Available: sql.NullBool{Bool: false, Valid: true},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		s.refreshListingStats(booking.ListingID)
+	}
+	s.notifyBooking(notify.BookingCreated, booking.ID)
+	if paymentStatus == "succeeded" {
+		s.notifyBooking(notify.PaymentReceived, booking.ID)
+	}
+	s.publishBookingStatus(booking.ID, booking.Status.String)
+
 	c.JSON(http.StatusCreated, booking)
 }
 
+// notifyBooking queues the notifications of a booking event. Failures are logged and do
+// not fail the request.
+func (s *Server) notifyBooking(category string, bookingID int32) {
+	if err := tasks.EnqueueBookingEvent(s.client, category, bookingID); err != nil {
+		log.Printf("Failed to notify booking %d event: %v", bookingID, err)
+	}
+}
+
+// notifyModification is notifyBooking for an event about a modification of a booking.
+func (s *Server) notifyModification(category string, bookingID, modificationID int32) {
+	if err := tasks.EnqueueModificationEvent(s.client, category, bookingID, modificationID); err != nil {
+		log.Printf("Failed to notify booking %d modification %d event: %v", bookingID, modificationID, err)
+	}
+}
+
+// settleBookingPayments captures (on approval) or cancels (on decline) the authorized
+// payments of a request-to-book booking.
+func (s *Server) settleBookingPayments(ctx context.Context, q *db.Queries, bookingID int32, capture bool) error {
+	payments, err := q.GetPaymentsByBookingID(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range payments {
+		if p.Status.String != "authorized" || !p.TransactionID.Valid {
+			continue
+		}
+
+		status := "cancelled"
+		if capture {
+			if _, err := s.payments.Capture(ctx, p.TransactionID.String, fmt.Sprintf("payment-%d-capture", p.ID)); err != nil {
+				return err
+			}
+			status = "succeeded"
+		} else if _, err := s.payments.Cancel(ctx, p.TransactionID.String); err != nil {
+			return err
+		}
+
+		err := q.UpdatePaymentStatus(ctx, db.UpdatePaymentStatusParams{
+			ID:     p.ID,
+			Status: sql.NullString{String: status, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type GetUserBookingsResponse struct {
 	ID            int32  `json:"id"`
 	AdminID       int32  `json:"admin_id"`
@@ -164,6 +380,13 @@ type GetBookingsByAdminResponse struct {
 	TotalAmount  string `json:"total_amount"`
 	Status       string `json:"status"`
 	CreatedAt    string `json:"created_at"`
+	// Guest summarises the published reviews other hosts have written about the guest.
+	Guest guestReputation `json:"guest_reputation"`
+}
+
+type guestReputation struct {
+	TotalReviews  int64   `json:"total_reviews"`
+	AverageRating float64 `json:"average_rating"`
 }
 
 func (s *Server) GetBookingsByAdminID(c *gin.Context) {
@@ -179,7 +402,10 @@ func (s *Server) GetBookingsByAdminID(c *gin.Context) {
 		return
 	}
 
-	rows, err := s.q.GetBookingsByAdminID(c, admin.ID)
+	rows, err := s.q.GetBookingsByAdminID(c, db.GetBookingsByAdminIDParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.ViewBookings),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -201,6 +427,10 @@ func (s *Server) GetBookingsByAdminID(c *gin.Context) {
 			TotalAmount:  row.TotalAmount,
 			Status:       row.Status.String,
 			CreatedAt:    row.CreatedAt.Time.UTC().Format("2006-01-02"),
+			Guest: guestReputation{
+				TotalReviews:  row.GuestReviewCount,
+				AverageRating: row.GuestRating,
+			},
 		}
 	}
 
@@ -229,8 +459,9 @@ func (s *Server) GetBookingByAdminIDAndID(c *gin.Context) {
 	}
 
 	arg := db.GetBookingsByAdminIDByIDParams{
-		ID:      int32(bookingID),
 		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.ViewBookings),
+		ID:      int32(bookingID),
 	}
 
 	booking, err := s.q.GetBookingsByAdminIDByID(c, arg)
@@ -281,6 +512,7 @@ func (s *Server) GetBookingsByListingID(c *gin.Context) {
 	arg := db.GetBookingsByListingIDParams{
 		ListingID: int32(listingID),
 		AdminID:   admin.ID,
+		Roles:     team.RolesWith(team.ViewBookings),
 	}
 
 	rows, err := s.q.GetBookingsByListingID(c, arg)
@@ -353,7 +585,7 @@ func (s *Server) UpdateBookingStatus(c *gin.Context) {
 	}
 
 	type updateRequest struct {
-		Status string `json:"status,omitempty" binding:"omitempty,min=3"`
+		Status string `json:"status" binding:"required,oneof=pending confirmed declined cancelled completed"`
 	}
 
 	var req updateRequest
@@ -362,30 +594,107 @@ func (s *Server) UpdateBookingStatus(c *gin.Context) {
 		return
 	}
 
-	arg := db.UpdateBookingStatusByIDAndAdminIDParams{
-		ID:      int32(bookingID),
+	arg1 := db.GetBookingsByAdminIDByIDParams{
 		AdminID: admin.ID,
-		Status:  sql.NullString{String: req.Status, Valid: true},
+		Roles:   team.RolesWith(team.ApproveBookings),
+		ID:      int32(bookingID),
+	}
+	booking, err := s.q.GetBookingsByAdminIDByID(c, arg1)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch booking: %v", err)})
+		return
+	}
+	if !canMoveBooking(booking.Status.String, req.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot move a %s booking to %s", booking.Status.String, req.Status)})
+		return
+	}
+
+	if req.Status == "confirmed" && booking.ApprovalDeadline.Valid && time.Now().After(booking.ApprovalDeadline.Time) {
+		c.JSON(http.StatusConflict, gin.H{"error": "approval deadline has passed"})
+		return
 	}
 
-	// Update booking status in DB
-	err = s.q.UpdateBookingStatusByIDAndAdminID(c, arg)
+	tx, err := s.db.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
+
+	arg := db.UpdateBookingStatusByIDAndAdminIDParams{
+		Status:     sql.NullString{String: req.Status, Valid: true},
+		ID:         int32(bookingID),
+		AdminID:    admin.ID,
+		Roles:      team.RolesWith(team.ApproveBookings),
+		FromStatus: booking.Status,
+	}
+
+	// The booking is updated first and stays locked until the payment is settled, so the
+	// expiry jobs cannot settle it at the same time.
+	updated, err := q.UpdateBookingStatusByIDAndAdminID(c, arg)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update booking status: %v", err)})
 		return
 	}
+	if updated == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking status has changed, reload it and try again"})
+		return
+	}
 
-	arg1 := db.GetBookingsByAdminIDByIDParams{
-		ID:      int32(bookingID),
-		AdminID: admin.ID,
+	// Approving or declining a booking request settles its authorized payment.
+	switch req.Status {
+	case "confirmed":
+		if err := s.settleBookingPayments(c, q, booking.ID, true); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to capture payment: %v", err)})
+			return
+		}
+	case "declined", "cancelled":
+		if err := s.settleBookingPayments(c, q, booking.ID, false); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to release payment: %v", err)})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	switch req.Status {
+	case "confirmed":
+		err = s.q.UpdateListingStatus(c, db.UpdateListingStatusParams{
+			ID:        booking.ListingID,
+			Available: sql.NullBool{Bool: false, Valid: true},
+		})
+	case "declined", "cancelled", "completed":
+		err = s.q.ReopenListingIfFree(c, booking.ListingID)
 	}
-	// Fetch updated booking details from DB
-	_, err = s.q.GetBookingsByAdminIDByID(c, arg1)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch updated booking: %v", err)})
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	switch req.Status {
+	case "confirmed":
+		// Only pending bookings are confirmed, and accepting a request captures its payment.
+		s.notifyBooking(notify.BookingAccepted, booking.ID)
+		s.notifyBooking(notify.PaymentReceived, booking.ID)
+	case "declined":
+		s.notifyBooking(notify.BookingDeclined, booking.ID)
+	case "cancelled":
+		s.notifyBooking(notify.BookingCancelled, booking.ID)
+	case "completed":
+		s.notifyBooking(notify.BookingCompleted, booking.ID)
+		s.notifyBooking(notify.ReviewRequest, booking.ID)
+	}
+	s.refreshListingStats(booking.ListingID)
+	s.publishBookingStatus(booking.ID, req.Status)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Booking status updated successfully"})
 }
 
@@ -410,6 +719,19 @@ func (s *Server) DeleteBooking(c *gin.Context) {
 		return
 	}
 
+	booking, err := s.q.GetUserBookingByID(c, db.GetUserBookingByIDParams{
+		ID:     int32(bookingID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	arg := db.DeleteUserBookingParams{
 		ID:     int32(bookingID),
 		UserID: user.ID,
@@ -421,17 +743,7 @@ func (s *Server) DeleteBooking(c *gin.Context) {
 		return
 	}
 
-	list, err := s.q.GetListingByID(c, int32(bookingID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	arg1 := db.UpdateListingStatusParams{
-		ID:        int32(list.ID),
-		Available: sql.NullBool{Bool: true, Valid: true},
-	}
-	err = s.q.UpdateListingStatus(c, arg1)
+	err = s.q.ReopenListingIfFree(c, booking.ListingID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -461,32 +773,49 @@ func (s *Server) updateCancelledBooking(c *gin.Context) {
 		return
 	}
 
+	booking, err := s.q.GetUserBookingByID(c, db.GetUserBookingByIDParams{
+		ID:     int32(bookingID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	arg := db.UpdateBookingStatusByIDAndUserIDParams{
 		ID:     int32(bookingID),
 		UserID: user.ID,
 	}
 
-	err = s.q.UpdateBookingStatusByIDAndUserID(c, arg)
+	rows, err := s.q.UpdateBookingStatusByIDAndUserID(c, arg)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	list, err := s.q.GetListingByID(c, int32(bookingID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+	if rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s booking cannot be cancelled", booking.Status.String)})
 		return
 	}
 
-	arg1 := db.UpdateListingStatusParams{
-		ID:        int32(list.ID),
-		Available: sql.NullBool{Bool: true, Valid: true},
+	// Release the card hold of a request-to-book booking that was never approved.
+	if err := s.settleBookingPayments(c, s.q, int32(bookingID), false); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to release payment: %v", err)})
+		return
 	}
-	err = s.q.UpdateListingStatus(c, arg1)
+
+	err = s.q.ReopenListingIfFree(c, booking.ListingID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	s.refreshListingStats(booking.ListingID)
+	s.notifyBooking(notify.BookingCancelled, booking.ID)
+	s.publishBookingStatus(booking.ID, "cancelled")
+
 	c.JSON(http.StatusOK, gin.H{"message": "Booking updated successfully"})
 }
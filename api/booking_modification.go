@@ -0,0 +1,523 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/pricing"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+type bookingModificationResponse struct {
+	Modification db.BookingModification `json:"modification"`
+	Quote        pricing.Quote          `json:"quote"`
+	ClientSecret string                 `json:"client_secret,omitempty"`
+}
+
+// CreateBookingModification lets a guest ask to change the dates or the guest count of a
+// confirmed booking. The stay is re-quoted with the listing's price rules; when the new stay costs
+// more, a payment for the difference is authorized now and captured once the host approves.
+func (s *Server) CreateBookingModification(c *gin.Context) {
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return
+	}
+
+	var req struct {
+		CheckInDate  time.Time `json:"check_in_date" binding:"required"`
+		CheckOutDate time.Time `json:"check_out_date" binding:"required"`
+		Guests       int32     `json:"guests" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	booking, err := s.q.GetUserBookingByID(c, db.GetUserBookingByIDParams{
+		ID:     int32(bookingID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if booking.Status.String != "confirmed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "only confirmed bookings can be modified"})
+		return
+	}
+
+	if req.CheckInDate.Before(time.Now().Truncate(24 * time.Hour)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "check-in date must not be in the past"})
+		return
+	}
+
+	_, err = s.q.GetPendingBookingModification(c, booking.ID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "booking already has a pending modification"})
+		return
+	} else if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := checkStayAvailable(c, s.q, booking.ListingID, booking.ID, req.CheckInDate, req.CheckOutDate); err != nil {
+		if errors.Is(err, errStayUnavailable) {
+			c.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	listing, err := s.q.GetListingByID(c, booking.ListingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	quote, err := s.quoteStay(c, listing.ID, listing.Price, req.CheckInDate, req.CheckOutDate)
+	if err != nil {
+		if isStayError(err) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	previous, err := payment.ToCents(booking.TotalAmount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	difference := quote.Total - previous
+
+	var intent *payment.Intent
+	if difference > 0 {
+		intent, err = s.payments.CreateIntent(c, difference, payment.CaptureManual)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to create payment: %v", err)})
+			return
+		}
+	}
+
+	arg := db.CreateBookingModificationParams{
+		BookingID:            booking.ID,
+		UserID:               user.ID,
+		CheckInDate:          req.CheckInDate,
+		CheckOutDate:         req.CheckOutDate,
+		Guests:               req.Guests,
+		PreviousCheckInDate:  booking.CheckInDate,
+		PreviousCheckOutDate: booking.CheckOutDate,
+		PreviousGuests:       booking.Guests,
+		PreviousAmount:       booking.TotalAmount,
+		NewAmount:            payment.FormatCents(quote.Total),
+		PriceDifference:      payment.FormatCents(difference),
+	}
+	if intent != nil {
+		arg.PaymentIntentID = sql.NullString{String: intent.ID, Valid: true}
+	}
+
+	modification, err := s.q.CreateBookingModification(c, arg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	s.notifyModification(notify.ModificationRequested, booking.ID, modification.ID)
+
+	res := bookingModificationResponse{Modification: modification, Quote: quote}
+	if intent != nil {
+		res.ClientSecret = intent.ClientSecret
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+// GetUserBookingModifications lists the modification history of one of the user's bookings.
+func (s *Server) GetUserBookingModifications(c *gin.Context) {
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return
+	}
+
+	_, err = s.q.GetUserBookingByID(c, db.GetUserBookingByIDParams{
+		ID:     int32(bookingID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	s.listBookingModifications(c, int32(bookingID))
+}
+
+// GetAdminBookingModifications lists the modification history of a booking on one of the
+// admin's listings.
+func (s *Server) GetAdminBookingModifications(c *gin.Context) {
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admin only"})
+		return
+	}
+
+	_, err = s.q.GetBookingsByAdminIDByID(c, db.GetBookingsByAdminIDByIDParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.ViewBookings),
+		ID:      int32(bookingID),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	s.listBookingModifications(c, int32(bookingID))
+}
+
+func (s *Server) listBookingModifications(c *gin.Context, bookingID int32) {
+	modifications, err := s.q.GetBookingModificationsByBookingID(c, bookingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if modifications == nil {
+		modifications = []db.BookingModification{}
+	}
+	c.JSON(http.StatusOK, modifications)
+}
+
+// ResolveBookingModification lets the host approve or decline a pending modification.
+// Approving captures the additional payment or refunds the difference and moves the
+// booking to the new stay; declining releases any additional payment hold.
+func (s *Server) ResolveBookingModification(c *gin.Context) {
+	modificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid modification ID"})
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admin only"})
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required,oneof=approved declined"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	modification, err := s.q.GetBookingModificationForAdmin(c, db.GetBookingModificationForAdminParams{
+		ID:      int32(modificationID),
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.ApproveBookings),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "modification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if modification.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("modification is already %s", modification.Status)})
+		return
+	}
+
+	newAmount, err := payment.ToCents(modification.NewAmount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	previousAmount, err := payment.ToCents(modification.PreviousAmount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	difference := newAmount - previousAmount
+
+	if req.Status == "approved" {
+		err := checkStayAvailable(c, s.q, modification.ListingID, modification.BookingID, modification.CheckInDate, modification.CheckOutDate)
+		if err != nil {
+			if errors.Is(err, errStayUnavailable) {
+				c.JSON(http.StatusConflict, errorResponse(err))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	tx, err := s.db.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
+
+	// Claiming the modification locks it until the payment is settled, so a second
+	// request to resolve it waits and then finds it resolved.
+	claimed, err := q.ResolveBookingModification(c, db.ResolveBookingModificationParams{
+		ID:     modification.ID,
+		Status: req.Status,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if claimed == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "modification is already resolved"})
+		return
+	}
+
+	if req.Status == "declined" {
+		if difference > 0 && modification.PaymentIntentID.Valid {
+			if _, err := s.payments.Cancel(c, modification.PaymentIntentID.String); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to release payment: %v", err)})
+				return
+			}
+		}
+	} else {
+		moved, err := q.UpdateBookingStay(c, db.UpdateBookingStayParams{
+			ID:           modification.BookingID,
+			CheckInDate:  modification.CheckInDate,
+			CheckOutDate: modification.CheckOutDate,
+			Guests:       modification.Guests,
+			TotalAmount:  modification.NewAmount,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if moved == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "booking is no longer confirmed"})
+			return
+		}
+
+		// The payment is settled last, so only recording it can fail after the money moved.
+		switch {
+		case difference > 0:
+			err = s.chargeModification(c, q, modification, difference)
+		case difference < 0:
+			err = s.refundModification(c, q, modification, -difference)
+		}
+		if err != nil {
+			c.JSON(http.StatusPaymentRequired, errorResponse(err))
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if req.Status == "approved" {
+		s.refreshListingStats(modification.ListingID)
+		s.notifyModification(notify.ModificationApproved, modification.BookingID, modification.ID)
+	} else {
+		s.notifyModification(notify.ModificationDeclined, modification.BookingID, modification.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Booking modification %s", req.Status)})
+}
+
+var errStayUnavailable = errors.New("listing is not available for the requested dates")
+
+// checkStayAvailable returns errStayUnavailable when another active booking of the listing,
+// or a manual or imported blocked date range, overlaps the stay.
+func checkStayAvailable(ctx context.Context, q *db.Queries, listingID, bookingID int32, checkIn, checkOut time.Time) error {
+	bookings, err := q.CountOverlappingBookings(ctx, db.CountOverlappingBookingsParams{
+		ListingID:    listingID,
+		ID:           bookingID,
+		CheckOutDate: checkOut,
+		CheckInDate:  checkIn,
+	})
+	if err != nil {
+		return err
+	}
+
+	blocked, err := q.CountOverlappingBlockedDates(ctx, db.CountOverlappingBlockedDatesParams{
+		ListingID:    listingID,
+		CheckOutDate: checkOut,
+		CheckInDate:  checkIn,
+	})
+	if err != nil {
+		return err
+	}
+
+	if bookings > 0 || blocked > 0 {
+		return errStayUnavailable
+	}
+	return nil
+}
+
+// chargeModification captures the additional payment the guest authorized for the modification.
+// The capture is keyed on the modification, so if recording it fails and the host approves
+// again, the intent a previous attempt captured is recorded without being captured twice.
+func (s *Server) chargeModification(ctx context.Context, q *db.Queries, m db.GetBookingModificationForAdminRow, amount int64) error {
+	if !m.PaymentIntentID.Valid {
+		return errors.New("modification has no additional payment")
+	}
+
+	intent, err := s.payments.GetIntent(ctx, m.PaymentIntentID.String)
+	if err != nil {
+		return err
+	}
+	if (intent.Status != payment.StatusRequiresCapture && intent.Status != payment.StatusSucceeded) || intent.Amount != amount {
+		return errors.New("additional payment has not been authorized")
+	}
+
+	if _, err := s.payments.Capture(ctx, intent.ID, fmt.Sprintf("modification-%d-capture", m.ID)); err != nil {
+		return err
+	}
+
+	_, err = q.CreatePayment(ctx, db.CreatePaymentParams{
+		BookingID:     m.BookingID,
+		Amount:        payment.FormatCents(amount),
+		Status:        sql.NullString{String: "succeeded", Valid: true},
+		TransactionID: sql.NullString{String: intent.ID, Valid: true},
+		PaidAt:        sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:        m.UserID,
+	})
+	return err
+}
+
+// refundModification refunds the difference to the guest from what is left of the booking's
+// captured payments. Each refund is keyed on the modification and the payment, so when the
+// transaction recording them rolls back, approving again repeats the same refunds instead
+// of sending new ones.
+func (s *Server) refundModification(ctx context.Context, q *db.Queries, m db.GetBookingModificationForAdminRow, amount int64) error {
+	payments, err := q.GetPaymentsByBookingID(ctx, m.BookingID)
+	if err != nil {
+		return err
+	}
+
+	remaining := amount
+	for _, p := range payments {
+		if remaining == 0 {
+			break
+		}
+		if p.Status.String != "succeeded" || !p.TransactionID.Valid {
+			continue
+		}
+
+		paid, err := payment.ToCents(p.Amount)
+		if err != nil {
+			continue
+		}
+		refunded, err := payment.ToCents(p.RefundedAmount)
+		if err != nil || paid <= refunded {
+			continue
+		}
+		refundAmount := min(paid-refunded, remaining)
+
+		// Recording the refund first locks the payment, so concurrent refunds cannot both
+		// draw on the same rest of it.
+		recorded, err := q.AddPaymentRefund(ctx, db.AddPaymentRefundParams{
+			ID:     p.ID,
+			Amount: payment.FormatCents(refundAmount),
+		})
+		if err != nil {
+			return err
+		}
+		if recorded == 0 {
+			return fmt.Errorf("payment %d has less left to refund than expected", p.ID)
+		}
+
+		refund, err := s.payments.Refund(ctx, p.TransactionID.String, refundAmount, fmt.Sprintf("modification-%d-refund-%d", m.ID, p.ID))
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreatePayment(ctx, db.CreatePaymentParams{
+			BookingID:     m.BookingID,
+			Amount:        payment.FormatCents(-refundAmount),
+			Status:        sql.NullString{String: "refunded", Valid: true},
+			TransactionID: sql.NullString{String: refund.ID, Valid: true},
+			PaidAt:        sql.NullTime{Time: time.Now(), Valid: true},
+			UserID:        m.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		remaining -= refundAmount
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("could not refund %s of the difference", payment.FormatCents(remaining))
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/ical"
+	"github.com/weldonkipchirchir/rental_listing/safehttp"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+const calendarProdID = "-//Rental Listing//Listing Calendar//EN"
+
+// ExportListingCalendar serves the listing's booked and blocked ranges as an iCal feed for
+// other platforms to import. The feed is public but only reachable with the listing's secret
+// token. Dates imported from other calendars are left out so platforms do not echo each
+// other's blocks back.
+func (s *Server) ExportListingCalendar(c *gin.Context) {
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	token, err := s.q.GetListingCalendarToken(c, int32(listingID))
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == sql.ErrNoRows || subtle.ConstantTimeCompare([]byte(token), []byte(c.Query("token"))) != 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return
+	}
+
+	listing, err := s.q.GetListingByID(c, int32(listingID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	since := time.Now().AddDate(0, -1, 0)
+	bookings, err := s.q.GetCalendarBookings(c, db.GetCalendarBookingsParams{
+		ListingID: listing.ID,
+		Since:     since,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	blocks, err := s.q.GetBlockedDatesByListingID(c, db.GetBlockedDatesByListingIDParams{
+		ListingID: listing.ID,
+		Since:     since,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	cal := ical.Calendar{ProdID: calendarProdID, Name: listing.Title}
+	for _, b := range bookings {
+		cal.Events = append(cal.Events, ical.Event{
+			UID:     fmt.Sprintf("booking-%d@rental-listing", b.ID),
+			Summary: "Booked",
+			Start:   b.CheckInDate,
+			End:     b.CheckOutDate,
+		})
+	}
+	for _, b := range blocks {
+		if b.FeedID.Valid {
+			continue
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:     fmt.Sprintf("block-%d@rental-listing", b.ID),
+			Summary: "Not available",
+			Start:   b.StartDate,
+			End:     b.EndDate,
+		})
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="listing-%d.ics"`, listing.ID))
+	c.Status(http.StatusOK)
+	if err := ical.Encode(c.Writer, cal, time.Now()); err != nil {
+		c.Error(err)
+	}
+}
+
+// RotateCalendarToken creates or replaces the secret token of the listing's iCal export and
+// returns the feed URL. Rotating the token revokes the previous URL.
+func (s *Server) RotateCalendarToken(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	token, err := util.SecureToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = s.q.UpsertListingCalendarToken(c, db.UpsertListingCalendarTokenParams{
+		ListingID: listing.ID,
+		Token:     token,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	feedURL := fmt.Sprintf("%s://%s/api/listing/%d/calendar.ics?token=%s", scheme, c.Request.Host, listing.ID, token)
+
+	c.JSON(http.StatusOK, gin.H{"url": feedURL})
+}
+
+type createCalendarFeedRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	URL  string `json:"url" binding:"required,url"`
+}
+
+// CreateCalendarFeed registers an external iCal URL whose events are imported as blocked dates.
+func (s *Server) CreateCalendarFeed(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	var req createCalendarFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	u, err := safehttp.CheckURL(c, req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid calendar url: %v", err)})
+		return
+	}
+
+	feed, err := s.q.CreateCalendarFeed(c, db.CreateCalendarFeedParams{
+		ListingID: listing.ID,
+		Name:      req.Name,
+		Url:       u.String(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+func (s *Server) GetCalendarFeeds(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	feeds, err := s.q.GetCalendarFeedsByListingID(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if feeds == nil {
+		feeds = []db.CalendarFeed{}
+	}
+	c.JSON(http.StatusOK, feeds)
+}
+
+// DeleteCalendarFeed removes an external calendar together with the dates it blocked.
+func (s *Server) DeleteCalendarFeed(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	feedID, err := strconv.Atoi(c.Param("feed_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return
+	}
+
+	rows, err := s.q.DeleteCalendarFeed(c, db.DeleteCalendarFeedParams{
+		ID:        int32(feedID),
+		ListingID: listing.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar feed not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed deleted successfully"})
+}
+
+type createBlockedDateRequest struct {
+	StartDate time.Time `json:"start_date" binding:"required"`
+	EndDate   time.Time `json:"end_date" binding:"required"`
+	Summary   string    `json:"summary" binding:"max=255"`
+}
+
+// CreateBlockedDate blocks a date range of the listing by hand. EndDate is exclusive.
+func (s *Server) CreateBlockedDate(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	var req createBlockedDateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !req.EndDate.After(req.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end date must be after start date"})
+		return
+	}
+
+	block, err := s.q.CreateBlockedDate(c, db.CreateBlockedDateParams{
+		ListingID: listing.ID,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Summary:   sql.NullString{String: req.Summary, Valid: req.Summary != ""},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, block)
+}
+
+// GetBlockedDates lists the upcoming manual and imported blocked ranges of the listing.
+func (s *Server) GetBlockedDates(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	blocks, err := s.q.GetBlockedDatesByListingID(c, db.GetBlockedDatesByListingIDParams{
+		ListingID: listing.ID,
+		Since:     time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if blocks == nil {
+		blocks = []db.BlockedDate{}
+	}
+	c.JSON(http.StatusOK, blocks)
+}
+
+// DeleteBlockedDate removes a manual block. Imported blocks are managed by their feed.
+func (s *Server) DeleteBlockedDate(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	blockID, err := strconv.Atoi(c.Param("block_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block ID"})
+		return
+	}
+
+	rows, err := s.q.DeleteManualBlockedDate(c, db.DeleteManualBlockedDateParams{
+		ID:        int32(blockID),
+		ListingID: listing.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "blocked date not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Blocked date deleted successfully"})
+}
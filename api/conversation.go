@@ -0,0 +1,615 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/messaging"
+	"github.com/weldonkipchirchir/rental_listing/storage"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+const (
+	defaultMessagesLimit = 50
+	maxMessagesLimit     = 100
+)
+
+type openConversationRequest struct {
+	// Exactly one of ListingID, for a question before booking, and BookingID is set.
+	ListingID int32 `json:"listing_id"`
+	BookingID int32 `json:"booking_id"`
+}
+
+type messageResponse struct {
+	ID             int32  `json:"id"`
+	ConversationID int32  `json:"conversation_id"`
+	SenderRole     string `json:"sender_role"`
+	SenderAdminID  int32  `json:"sender_admin_id,omitempty"`
+	Body           string `json:"body"`
+	// Attachments are the names of the attached files, which each side fetches from its
+	// conversation's attachments endpoint.
+	Attachments []string  `json:"attachments"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newMessageResponse(m db.Message) messageResponse {
+	rsp := messageResponse{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		SenderRole:     m.SenderRole,
+		SenderAdminID:  m.SenderAdminID.Int32,
+		Body:           m.Body,
+		Attachments:    make([]string, 0, len(m.Attachments)),
+		CreatedAt:      m.CreatedAt,
+	}
+	for _, key := range m.Attachments {
+		rsp.Attachments = append(rsp.Attachments, messaging.AttachmentName(key))
+	}
+	return rsp
+}
+
+type readMarkersResponse struct {
+	GuestLastReadID int32 `json:"guest_last_read_id"`
+	HostLastReadID  int32 `json:"host_last_read_id"`
+}
+
+// OpenConversation opens the signed-in user's conversation about one of their bookings,
+// or about a published listing before booking it. An open conversation is returned as is.
+func (s *Server) OpenConversation(c *gin.Context) {
+	var req openConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if (req.ListingID == 0) == (req.BookingID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either listing_id or booking_id is required"})
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if req.BookingID != 0 {
+		if _, err := s.q.GetUserBookingByID(c, db.GetUserBookingByIDParams{ID: req.BookingID, UserID: user.ID}); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		conversation, err := s.q.CreateBookingConversation(c, req.BookingID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusOK, conversation)
+		return
+	}
+
+	listing, err := s.q.GetListingByID(c, req.ListingID)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == sql.ErrNoRows || listing.Status != "published" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+		return
+	}
+
+	conversation, err := s.q.CreateInquiryConversation(c, db.CreateInquiryConversationParams{
+		ListingID: req.ListingID,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, conversation)
+}
+
+// OpenHostConversation opens the conversation about a booking of a listing the admin may
+// message guests for, or returns the one already open.
+func (s *Server) OpenHostConversation(c *gin.Context) {
+	var req struct {
+		BookingID int32 `json:"booking_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	_, err := s.q.GetBookingsByAdminIDByID(c, db.GetBookingsByAdminIDByIDParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.Messaging),
+		ID:      req.BookingID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	conversation, err := s.q.CreateBookingConversation(c, req.BookingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// GetConversations lists the signed-in user's conversations, most recently active first,
+// with the number of host messages they have not read.
+func (s *Server) GetConversations(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	conversations, err := s.q.GetUserConversations(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if conversations == nil {
+		conversations = []db.GetUserConversationsRow{}
+	}
+
+	c.JSON(http.StatusOK, conversations)
+}
+
+// GetHostConversations lists the conversations the admin can answer as a host, most
+// recently active first, with the number of guest messages no host has read.
+func (s *Server) GetHostConversations(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	conversations, err := s.q.GetAdminConversations(c, db.GetAdminConversationsParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.Messaging),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if conversations == nil {
+		conversations = []db.GetAdminConversationsRow{}
+	}
+
+	c.JSON(http.StatusOK, conversations)
+}
+
+// guestConversation loads the conversation in the :id path parameter for the signed-in
+// user. It writes the error response and returns false if it is not theirs.
+func (s *Server) guestConversation(c *gin.Context) (db.Conversation, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return db.Conversation{}, false
+	}
+	user, ok := s.currentUser(c)
+	if !ok {
+		return db.Conversation{}, false
+	}
+
+	conversation, err := s.q.GetConversationForUser(c, db.GetConversationForUserParams{
+		ID:     int32(id),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return db.Conversation{}, false
+	}
+	return conversation, true
+}
+
+// hostConversation loads the conversation in the :id path parameter for the signed-in
+// admin. It writes the error response and returns false if the admin cannot message
+// guests about its listing.
+func (s *Server) hostConversation(c *gin.Context) (db.Conversation, db.Admin, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return db.Conversation{}, db.Admin{}, false
+	}
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return db.Conversation{}, db.Admin{}, false
+	}
+
+	conversation, err := s.q.GetConversationForAdmin(c, db.GetConversationForAdminParams{
+		ID:      int32(id),
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.Messaging),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return db.Conversation{}, db.Admin{}, false
+	}
+	return conversation, admin, true
+}
+
+// GetConversationMessages returns a page of the user's conversation, newest first. Pass
+// the oldest message ID received as before to get the previous page.
+func (s *Server) GetConversationMessages(c *gin.Context) {
+	conversation, ok := s.guestConversation(c)
+	if !ok {
+		return
+	}
+	s.listMessages(c, conversation)
+}
+
+// GetHostConversationMessages is GetConversationMessages for hosts.
+func (s *Server) GetHostConversationMessages(c *gin.Context) {
+	conversation, _, ok := s.hostConversation(c)
+	if !ok {
+		return
+	}
+	s.listMessages(c, conversation)
+}
+
+func (s *Server) listMessages(c *gin.Context, conversation db.Conversation) {
+	arg := db.GetMessagesParams{
+		ConversationID: conversation.ID,
+		Limit:          defaultMessagesLimit,
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMessagesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxMessagesLimit)})
+			return
+		}
+		arg.Limit = int32(n)
+	}
+	if v := c.Query("before"); v != "" {
+		before, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+			return
+		}
+		arg.Before = sql.NullInt32{Int32: int32(before), Valid: true}
+	}
+
+	messages, err := s.q.GetMessages(c, arg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]messageResponse, 0, len(messages))
+	for _, m := range messages {
+		rsp = append(rsp, newMessageResponse(m))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":           rsp,
+		"guest_last_read_id": conversation.GuestLastReadID,
+		"host_last_read_id":  conversation.HostLastReadID,
+	})
+}
+
+type sendMessageRequest struct {
+	Body string `json:"body" form:"body"`
+}
+
+// SendMessage posts a message from the user to their conversation. Attachments are sent
+// as multipart files in the "attachments" field.
+func (s *Server) SendMessage(c *gin.Context) {
+	conversation, ok := s.guestConversation(c)
+	if !ok {
+		return
+	}
+	s.sendMessage(c, conversation, messaging.Guest, sql.NullInt32{})
+}
+
+// SendHostMessage is SendMessage for hosts. The message is sent on behalf of all hosts of
+// the listing; the sender is recorded.
+func (s *Server) SendHostMessage(c *gin.Context) {
+	conversation, admin, ok := s.hostConversation(c)
+	if !ok {
+		return
+	}
+	s.sendMessage(c, conversation, messaging.Host, sql.NullInt32{Int32: admin.ID, Valid: true})
+}
+
+func (s *Server) sendMessage(c *gin.Context, conversation db.Conversation, side string, senderAdminID sql.NullInt32) {
+	var req sendMessageRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	body := strings.TrimSpace(req.Body)
+
+	var files []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		files = form.File["attachments"]
+	}
+	if err := messaging.Validate(body, len(files)); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	keys, err := s.storeAttachments(c, conversation.ID, files)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, messaging.ErrAttachmentType) || errors.Is(err, messaging.ErrAttachmentTooLarge) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, errorResponse(err))
+		return
+	}
+
+	message, err := s.q.CreateMessage(c, db.CreateMessageParams{
+		ConversationID: conversation.ID,
+		SenderRole:     side,
+		SenderAdminID:  senderAdminID,
+		Body:           body,
+		Attachments:    keys,
+	})
+	if err != nil {
+		s.deleteAttachments(keys)
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := newMessageResponse(message)
+	s.publishConversationEvent(conversation, messaging.EventMessage, rsp)
+
+	c.JSON(http.StatusCreated, rsp)
+}
+
+// storeAttachments checks and stores the attached files and returns their storage keys.
+func (s *Server) storeAttachments(ctx context.Context, conversationID int32, files []*multipart.FileHeader) ([]string, error) {
+	keys := make([]string, 0, len(files))
+	for _, file := range files {
+		key, err := s.storeAttachment(ctx, conversationID, file)
+		if err != nil {
+			s.deleteAttachments(keys)
+			return nil, fmt.Errorf("%s: %w", file.Filename, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// storeAttachment stores one attached file under a new key named after its content type.
+func (s *Server) storeAttachment(ctx context.Context, conversationID int32, file *multipart.FileHeader) (string, error) {
+	if file.Size > messaging.MaxAttachmentBytes {
+		return "", messaging.ErrAttachmentTooLarge
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, messaging.MaxAttachmentBytes+1))
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	ext, err := messaging.AttachmentExtension(data)
+	if err != nil {
+		return "", err
+	}
+	key := messaging.AttachmentKey(conversationID, uuid.New().String(), ext)
+	if _, err := s.images.Put(ctx, key, data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// deleteAttachments removes attachments stored for a message that was not saved.
+func (s *Server) deleteAttachments(keys []string) {
+	for _, key := range keys {
+		if err := s.images.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete attachment %s: %v", key, err)
+		}
+	}
+}
+
+// GetConversationAttachment sends a file attached to a message in the user's
+// conversation. Attachments are only ever served to the conversation's guest and hosts.
+func (s *Server) GetConversationAttachment(c *gin.Context) {
+	conversation, ok := s.guestConversation(c)
+	if !ok {
+		return
+	}
+	s.sendAttachment(c, conversation)
+}
+
+// GetHostConversationAttachment is GetConversationAttachment for hosts.
+func (s *Server) GetHostConversationAttachment(c *gin.Context) {
+	conversation, _, ok := s.hostConversation(c)
+	if !ok {
+		return
+	}
+	s.sendAttachment(c, conversation)
+}
+
+func (s *Server) sendAttachment(c *gin.Context, conversation db.Conversation) {
+	key := messaging.AttachmentKey(conversation.ID, c.Param("name"), "")
+	attached, err := s.q.ConversationHasAttachment(c, db.ConversationHasAttachmentParams{
+		ConversationID: conversation.ID,
+		Key:            key,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !attached {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+
+	direct, ok := s.images.(storage.DirectStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "attachments cannot be read from the image store"})
+		return
+	}
+	data, err := direct.Get(c, key, messaging.MaxAttachmentBytes)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read attachment"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, storage.ContentType(key), data)
+}
+
+// MarkConversationRead records that the user has read their conversation up to the
+// latest message.
+func (s *Server) MarkConversationRead(c *gin.Context) {
+	conversation, ok := s.guestConversation(c)
+	if !ok {
+		return
+	}
+	s.markConversationRead(c, conversation, messaging.Guest)
+}
+
+// MarkHostConversationRead is MarkConversationRead for hosts.
+func (s *Server) MarkHostConversationRead(c *gin.Context) {
+	conversation, _, ok := s.hostConversation(c)
+	if !ok {
+		return
+	}
+	s.markConversationRead(c, conversation, messaging.Host)
+}
+
+func (s *Server) markConversationRead(c *gin.Context, conversation db.Conversation, side string) {
+	markers, err := s.q.MarkConversationRead(c, db.MarkConversationReadParams{
+		Reader: side,
+		ID:     conversation.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := readMarkersResponse(markers)
+	if markers.GuestLastReadID != conversation.GuestLastReadID || markers.HostLastReadID != conversation.HostLastReadID {
+		s.publishConversationEvent(conversation, messaging.EventRead, rsp)
+	}
+
+	c.JSON(http.StatusOK, rsp)
+}
+
+// publishConversationEvent pushes an event to the guest and every host of the
+// conversation. Clients that miss it see the change when they next load the
+// conversation, so failures are only logged.
+func (s *Server) publishConversationEvent(conversation db.Conversation, eventType string, data any) {
+	ctx := context.Background()
+	e, err := messaging.NewEvent(eventType, conversation.ID, data)
+	if err != nil {
+		log.Printf("Failed to encode %s event for conversation %d: %v", eventType, conversation.ID, err)
+		return
+	}
+
+	hosts, err := s.q.GetConversationHosts(ctx, db.GetConversationHostsParams{
+		ID:    conversation.ID,
+		Roles: team.RolesWith(team.Messaging),
+	})
+	if err != nil {
+		log.Printf("Failed to load hosts of conversation %d: %v", conversation.ID, err)
+	}
+
+	channels := []string{messaging.Channel(messaging.Guest, conversation.UserID)}
+	for _, adminID := range hosts {
+		channels = append(channels, messaging.Channel(messaging.Host, adminID))
+	}
+	if err := s.messages.Publish(ctx, e, channels...); err != nil {
+		log.Printf("Failed to publish %s event for conversation %d: %v", eventType, conversation.ID, err)
+	}
+}
+
+// StreamConversationEvents pushes new messages and read receipts in all of the user's
+// conversations as server-sent events.
+func (s *Server) StreamConversationEvents(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	s.streamConversationEvents(c, messaging.Channel(messaging.Guest, user.ID))
+}
+
+// StreamHostConversationEvents is StreamConversationEvents for hosts. It covers every
+// conversation the admin can answer.
+func (s *Server) StreamHostConversationEvents(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	s.streamConversationEvents(c, messaging.Channel(messaging.Host, admin.ID))
+}
+
+func (s *Server) streamConversationEvents(c *gin.Context, channel string) {
+	ctx, cancel := s.streamContext(c)
+	defer cancel()
+
+	events, err := s.messages.Subscribe(ctx, channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(messaging.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(e.Type, e)
+			return true
+		case <-heartbeat.C:
+			// Comment lines keep the connection open and are ignored by EventSource.
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/workflow"
 )
 
 type createFavoriteRequest struct {
@@ -34,6 +35,9 @@ func (s *Server) CreateFavorite(c *gin.Context) {
 	}
 
 	listing, err := s.q.GetListingByID(c, req.ListingID)
+	if err == nil && !workflow.Visible(listing.Status) {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, errorResponse(err))
 		return
@@ -77,19 +81,14 @@ func (s *Server) GetAllFavorites(c *gin.Context) {
 		return
 	}
 
-	favorites, err := s.q.GetFavorite(c, user.ID)
+	favorites, err := s.q.GetFavoriteListings(c, user.ID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, errorResponse(err))
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	var favoritesList []getAllListings
-	for _, favorite := range favorites {
-		listing, err := s.q.GetListingByID(c, favorite.ListingID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
+	for _, listing := range favorites {
 		favoritesList = append(favoritesList, getAllListings{
 			ID:          listing.ID,
 			AdminID:     listing.AdminID,
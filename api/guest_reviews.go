@@ -0,0 +1,146 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+type createGuestReviewRequest struct {
+	Rating        int32  `json:"rating" binding:"required,min=1,max=5"`
+	Cleanliness   int32  `json:"cleanliness" binding:"required,min=1,max=5"`
+	Communication int32  `json:"communication" binding:"required,min=1,max=5"`
+	HouseRules    int32  `json:"house_rules" binding:"required,min=1,max=5"`
+	Comment       string `json:"comment" binding:"required,max=2000"`
+}
+
+// CreateGuestReview lets a host review the guest of a completed stay at one of their
+// listings. Like listing reviews it is written once per booking within tasks.ReviewWindow
+// of check-out and stays hidden until the guest has reviewed the stay or the window closes.
+func (s *Server) CreateGuestReview(c *gin.Context) {
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	var req createGuestReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admin only"})
+		return
+	}
+
+	booking, err := s.q.GetBookingsByAdminIDByID(c, db.GetBookingsByAdminIDByIDParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.ApproveBookings),
+		ID:      int32(bookingID),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if booking.Status.String != "completed" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only guests of completed stays can be reviewed"})
+		return
+	}
+	if time.Since(booking.CheckOutDate) > tasks.ReviewWindow {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the review window for this stay has closed"})
+		return
+	}
+
+	_, err = s.q.GetGuestReviewByBookingID(c, booking.ID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "this guest has already been reviewed"})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = s.q.CreateGuestReview(c, db.CreateGuestReviewParams{
+		BookingID:           booking.ID,
+		AdminID:             admin.ID,
+		UserID:              booking.UserID,
+		Rating:              req.Rating,
+		CleanlinessRating:   req.Cleanliness,
+		CommunicationRating: req.Communication,
+		HouseRulesRating:    req.HouseRules,
+		Comment:             req.Comment,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := s.q.PublishBookingReviews(c, sql.NullInt32{Int32: booking.ID, Valid: true}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// The guest's review of the listing may have been published above.
+	s.refreshListingStats(booking.ListingID)
+
+	review, err := s.q.GetGuestReviewByBookingID(c, booking.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// GetUserGuestReviews returns the published reviews hosts have written about the user.
+func (s *Server) GetUserGuestReviews(c *gin.Context) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return
+	}
+
+	reviews, err := s.q.GetGuestReviewsByUserID(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	reputation, err := s.q.GetGuestReputation(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if reviews == nil {
+		reviews = []db.GetGuestReviewsByUserIDRow{}
+	}
+	c.JSON(http.StatusOK, gin.H{"summary": reputation, "reviews": reviews})
+}
@@ -0,0 +1,425 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/imaging"
+	"github.com/weldonkipchirchir/rental_listing/storage"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+)
+
+// uploadStagingDir held uploaded images until they were processed, before uploads were
+// stored in the image store. The cleanup task still removes what is left in it.
+const uploadStagingDir = "assets/uploads"
+
+// maxListingImages is how many images a listing can have.
+const maxListingImages = 30
+
+// imageFormats returns the formats image variants are offered in. Variants are encoded as
+// JPEG, so WebP is only offered by stores that convert them on delivery.
+func imageFormats(images storage.ImageStore) []string {
+	if _, ok := images.(storage.Transcoder); ok {
+		return []string{"jpeg", "webp"}
+	}
+	return []string{"jpeg"}
+}
+
+// readImageUploads reads the uploaded image files, rejecting the request if any of them
+// is not an image that can be processed.
+func readImageUploads(files []*multipart.FileHeader) ([][]byte, error) {
+	uploads := make([][]byte, 0, len(files))
+	for _, file := range files {
+		if file.Size > imaging.DefaultLimits.MaxBytes {
+			return nil, fmt.Errorf("%s: %w", file.Filename, imaging.ErrTooLarge)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, imaging.DefaultLimits.MaxBytes+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := imaging.Validate(data, imaging.DefaultLimits); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Filename, err)
+		}
+		uploads = append(uploads, data)
+	}
+	return uploads, nil
+}
+
+// checkImageLimit responds with an error and returns false if adding n images would take
+// the listing over maxListingImages.
+func (s *Server) checkImageLimit(c *gin.Context, listingID int32, n int) bool {
+	existing, err := s.q.GetListingImages(c, listingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if len(existing)+n > maxListingImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a listing can have at most %d images", maxListingImages)})
+		return false
+	}
+	return true
+}
+
+// stageListingImages stores validated uploads in the image store and queues them for
+// processing, so any worker can read them. The images are added to the listing once
+// processed.
+func (s *Server) stageListingImages(ctx context.Context, listingID int32, uploads [][]byte) ([]db.ListingImage, error) {
+	// The client's file name is never used in the key.
+	keys := make([]string, len(uploads))
+	for i := range uploads {
+		keys[i] = fmt.Sprintf("uploads/listings/%d/%s", listingID, uuid.New().String())
+	}
+	// Uploads that are stored but never added to the listing are deleted by the cleanup
+	// task.
+	if err := s.q.RecordOrphanedImages(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	images := make([]db.ListingImage, 0, len(uploads))
+	for i, data := range uploads {
+		if _, err := s.images.Put(ctx, keys[i], data); err != nil {
+			return nil, err
+		}
+
+		img, err := s.q.CreateUploadedListingImage(ctx, db.CreateUploadedListingImageParams{
+			ListingID: listingID,
+			SourceKey: sql.NullString{String: keys[i], Valid: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+
+		task, err := tasks.NewProcessListingImageTask(img.ID)
+		if err != nil {
+			log.Printf("Failed to create task: %v", err)
+			continue
+		}
+		if _, err := s.client.Enqueue(task); err != nil {
+			log.Printf("Failed to enqueue task: %v", err)
+		}
+	}
+	return images, nil
+}
+
+type listingImageVariantResponse struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+	Url    string `json:"url"`
+}
+
+type listingImageResponse struct {
+	ID       int32                         `json:"id"`
+	Status   string                        `json:"status"`
+	Position int32                         `json:"position"`
+	Caption  string                        `json:"caption"`
+	Room     string                        `json:"room,omitempty"`
+	IsCover  bool                          `json:"is_cover"`
+	Url      string                        `json:"url,omitempty"`
+	Width    int32                         `json:"width,omitempty"`
+	Height   int32                         `json:"height,omitempty"`
+	BlurHash string                        `json:"blurhash,omitempty"`
+	Error    string                        `json:"error,omitempty"`
+	Variants []listingImageVariantResponse `json:"variants"`
+}
+
+func newListingImageResponses(images []db.ListingImage, variants []db.ListingImageVariant) []listingImageResponse {
+	byImage := make(map[int32][]listingImageVariantResponse)
+	for _, v := range variants {
+		byImage[v.ImageID] = append(byImage[v.ImageID], listingImageVariantResponse{
+			Name:   v.Name,
+			Format: v.Format,
+			Width:  v.Width,
+			Height: v.Height,
+			Url:    v.Url,
+		})
+	}
+
+	rsp := make([]listingImageResponse, len(images))
+	for i, img := range images {
+		rsp[i] = listingImageResponse{
+			ID:       img.ID,
+			Status:   img.Status,
+			Position: img.Position,
+			Caption:  img.Caption,
+			Room:     img.Room.String,
+			IsCover:  img.IsCover,
+			Url:      img.Url.String,
+			Width:    img.Width.Int32,
+			Height:   img.Height.Int32,
+			BlurHash: img.Blurhash.String,
+			Error:    img.Error.String,
+			Variants: byImage[img.ID],
+		}
+		if rsp[i].Variants == nil {
+			rsp[i].Variants = []listingImageVariantResponse{}
+		}
+	}
+	return rsp
+}
+
+// GetListingImages returns a listing's images with their processing status, sizes and
+// blurhash placeholders.
+func (s *Server) GetListingImages(c *gin.Context) {
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	images, err := s.q.GetListingImages(c, int32(listingID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	variants, err := s.q.GetListingImageVariants(c, int32(listingID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newListingImageResponses(images, variants))
+}
+
+// AddListingImages adds uploaded images to the end of one of the admin's listings.
+func (s *Server) AddListingImages(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+		return
+	}
+	files := form.File["images"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one image is required"})
+		return
+	}
+
+	if !s.checkImageLimit(c, listing.ID, len(files)) {
+		return
+	}
+
+	uploads, err := readImageUploads(files)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	images, err := s.stageListingImages(c, listing.ID, uploads)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload images"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newListingImageResponses(images, nil))
+}
+
+type updateListingImageRequest struct {
+	Caption *string `json:"caption" binding:"omitempty,max=200"`
+	// Room tags the room shown; an empty room clears the tag.
+	Room *string `json:"room" binding:"omitempty,oneof=living_room bedroom kitchen bathroom dining_room workspace exterior pool view other"`
+}
+
+// UpdateListingImage sets the caption or room of one of the admin's listing images.
+func (s *Server) UpdateListingImage(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	var req updateListingImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	arg := db.UpdateListingImageParams{
+		ID:        int32(imageID),
+		ListingID: listing.ID,
+	}
+	if req.Caption != nil {
+		arg.Caption = sql.NullString{String: *req.Caption, Valid: true}
+	}
+	if req.Room != nil {
+		arg.Room = sql.NullString{String: *req.Room, Valid: true}
+	}
+
+	img, err := s.q.UpdateListingImage(c, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newListingImageResponses([]db.ListingImage{img}, nil)[0])
+}
+
+// DeleteListingImage removes an image from one of the admin's listings. Its stored files
+// are deleted by the cleanup task.
+func (s *Server) DeleteListingImage(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	rows, err := s.q.DeleteListingImage(c, db.DeleteListingImageParams{
+		ID:        int32(imageID),
+		ListingID: listing.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		return
+	}
+
+	if err := s.q.SyncListingImageLinks(c, listing.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+type reorderListingImagesRequest struct {
+	ImageIDs []int32 `json:"image_ids" binding:"required"`
+}
+
+// ReorderListingImages puts the images of one of the admin's listings in the given order,
+// which must name each of them once.
+func (s *Server) ReorderListingImages(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	var req reorderListingImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	images, err := s.q.GetListingImages(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !sameImages(images, req.ImageIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list each of the listing's images once"})
+		return
+	}
+
+	if _, err := s.q.ReorderListingImages(c, db.ReorderListingImagesParams{
+		Ids:       req.ImageIDs,
+		ListingID: listing.ID,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := s.q.SyncListingImageLinks(c, listing.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Images reordered successfully"})
+}
+
+// sameImages reports whether ids names each of images exactly once.
+func sameImages(images []db.ListingImage, ids []int32) bool {
+	if len(images) != len(ids) {
+		return false
+	}
+	seen := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	for _, img := range images {
+		if !seen[img.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// SetListingImageCover makes a processed image the cover of one of the admin's listings,
+// which is shown first.
+func (s *Server) SetListingImageCover(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	img, err := s.q.GetListingImage(c, int32(imageID))
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == sql.ErrNoRows || img.ListingID != listing.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		return
+	}
+	if img.Status != "ready" {
+		c.JSON(http.StatusConflict, gin.H{"error": "only processed images can be the cover"})
+		return
+	}
+
+	if _, err := s.q.SetListingImageCover(c, db.SetListingImageCoverParams{
+		ID:        img.ID,
+		ListingID: listing.ID,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := s.q.SyncListingImageLinks(c, listing.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cover image updated successfully"})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+type deletedListingResponse struct {
+	db.GetDeletedListingsRow
+	// PurgeAfter is when the listing is removed for good, or nil for listings with
+	// bookings, which are kept.
+	PurgeAfter *time.Time `json:"purge_after"`
+}
+
+// GetDeletedListings lists the admin's deleted listings and when each will be purged.
+func (s *Server) GetDeletedListings(c *gin.Context) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized admins only"})
+		return
+	}
+
+	rows, err := s.q.GetDeletedListings(c, db.GetDeletedListingsParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.EditListings),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	listings := make([]deletedListingResponse, 0, len(rows))
+	for _, row := range rows {
+		listing := deletedListingResponse{GetDeletedListingsRow: row}
+		if !row.HasBookings {
+			purgeAfter := row.DeletedAt.Time.Add(tasks.DefaultListingRetention)
+			listing.PurgeAfter = &purgeAfter
+		}
+		listings = append(listings, listing)
+	}
+
+	c.JSON(http.StatusOK, listings)
+}
+
+// RestoreListing brings back a listing the admin deleted, as a draft. Listings removed by
+// a moderator can only be restored by a super-admin.
+func (s *Server) RestoreListing(c *gin.Context) {
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized admins only"})
+		return
+	}
+
+	rows, err := s.q.RestoreListing(c, db.RestoreListingParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.EditListings),
+		ID:      int32(listingID),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deleted listing not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "listing restored as a draft"})
+}
@@ -0,0 +1,401 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/workflow"
+)
+
+func revisionContent(r db.ListingRevision) workflow.Content {
+	return workflow.Content{
+		Title:       r.Title,
+		Description: r.Description.String,
+		Price:       r.Price,
+		Location:    r.Location.String,
+		BookingMode: r.BookingMode,
+	}
+}
+
+// recordListingRevision records the listing's content as a new revision if it differs
+// from the latest one.
+func (s *Server) recordListingRevision(ctx context.Context, listingID, adminID int32, note string) error {
+	revisions, err := s.q.GetListingRevisions(ctx, listingID)
+	if err != nil {
+		return err
+	}
+	if len(revisions) > 0 {
+		listing, err := s.q.GetListingByID(ctx, listingID)
+		if err != nil {
+			return err
+		}
+		current := workflow.Content{
+			Title:       listing.Title,
+			Description: listing.Description.String,
+			Price:       listing.Price,
+			Location:    listing.Location.String,
+			BookingMode: listing.BookingMode,
+		}
+		if len(workflow.Diff(revisionContent(revisions[0]), current)) == 0 {
+			return nil
+		}
+	}
+
+	_, err = s.q.CreateListingRevision(ctx, db.CreateListingRevisionParams{
+		AdminID:   sql.NullInt32{Int32: adminID, Valid: true},
+		Note:      note,
+		ListingID: listingID,
+	})
+	return err
+}
+
+// listingChecklist returns the publishing checklist of one of the admin's listings.
+func (s *Server) listingChecklist(ctx context.Context, listing db.GetListingsByAdminIDRow) ([]workflow.Item, error) {
+	images, err := s.q.GetListingImages(ctx, listing.ID)
+	if err != nil {
+		return nil, err
+	}
+	photos := 0
+	for _, img := range images {
+		if img.Status == "ready" {
+			photos++
+		}
+	}
+
+	return workflow.Checklist(workflow.Content{
+		Title:       listing.Title,
+		Description: listing.Description.String,
+		Price:       listing.Price,
+		Location:    listing.Location.String,
+	}, photos), nil
+}
+
+// GetListingChecklist returns what one of the admin's listings still needs before it can
+// be published.
+func (s *Server) GetListingChecklist(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	items, err := s.listingChecklist(c, listing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   listing.Status,
+		"complete": workflow.Complete(items),
+		"items":    items,
+	})
+}
+
+type changeListingLifecycleRequest struct {
+	Status string `json:"status" binding:"required,oneof=published paused archived draft"`
+}
+
+// ChangeListingLifecycle moves one of the admin's listings through its lifecycle: it
+// submits a draft for publication, pauses or resumes a published listing, archives it or
+// takes it back to draft. Drafts must pass the checklist, and go to review first when
+// listings need approval.
+func (s *Server) ChangeListingLifecycle(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	var req changeListingLifecycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	next, err := workflow.Next(listing.Status, req.Status, s.listingApproval)
+	if err != nil {
+		c.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+
+	if workflow.NeedsChecklist(listing.Status, next) {
+		items, err := s.listingChecklist(c, listing)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if !workflow.Complete(items) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": workflow.ErrIncomplete.Error(), "items": items})
+			return
+		}
+	}
+
+	if !s.transitionListing(c, listing.ID, listing.Status, next, sql.NullString{}) {
+		return
+	}
+	if next == workflow.Published {
+		s.alertListingPublished(listing.ID, listing.Available.Bool, listing.PublishedAt.Valid)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": next})
+}
+
+// transitionListing moves a listing from one state to another, writing the error response
+// and returning false if that fails or the listing changed state meanwhile.
+func (s *Server) transitionListing(c *gin.Context, listingID int32, from, to string, note sql.NullString) bool {
+	rows, err := s.q.TransitionListing(c, db.TransitionListingParams{
+		Status:     to,
+		ReviewNote: note,
+		ID:         listingID,
+		FromStatus: from,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "listing status changed, reload and try again"})
+		return false
+	}
+	return true
+}
+
+// alertListingPublished tells guests about a listing that has become visible: saved
+// searches are matched the first time it is published, and favorites hear when it is
+// back after a pause.
+func (s *Server) alertListingPublished(listingID int32, available, publishedBefore bool) {
+	if !available {
+		return
+	}
+	if publishedBefore {
+		s.enqueueListingAlert(tasks.NewListingAvailableAlertTask(listingID))
+		return
+	}
+	s.enqueueListingAlert(tasks.NewListingMatchAlertTask(listingID))
+}
+
+type listingRevisionResponse struct {
+	Revision    int32             `json:"revision"`
+	AdminID     int32             `json:"admin_id,omitempty"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Price       string            `json:"price"`
+	Location    string            `json:"location"`
+	BookingMode string            `json:"booking_mode"`
+	Note        string            `json:"note,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Changes     []workflow.Change `json:"changes"`
+}
+
+// newListingRevisionResponse describes a revision with its changes from previous, which
+// is nil for the first revision.
+func newListingRevisionResponse(r db.ListingRevision, previous *db.ListingRevision) listingRevisionResponse {
+	rsp := listingRevisionResponse{
+		Revision:    r.Revision,
+		AdminID:     r.AdminID.Int32,
+		Title:       r.Title,
+		Description: r.Description.String,
+		Price:       r.Price,
+		Location:    r.Location.String,
+		BookingMode: r.BookingMode,
+		Note:        r.Note,
+		CreatedAt:   r.CreatedAt,
+		Changes:     []workflow.Change{},
+	}
+	if previous != nil {
+		rsp.Changes = workflow.Diff(revisionContent(*previous), revisionContent(r))
+	}
+	return rsp
+}
+
+// GetListingRevisions lists the revisions of one of the admin's listings, newest first,
+// each with what it changed.
+func (s *Server) GetListingRevisions(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := s.q.GetListingRevisions(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]listingRevisionResponse, len(revisions))
+	for i, r := range revisions {
+		var previous *db.ListingRevision
+		if i+1 < len(revisions) {
+			previous = &revisions[i+1]
+		}
+		rsp[i] = newListingRevisionResponse(r, previous)
+	}
+	c.JSON(http.StatusOK, rsp)
+}
+
+// listingRevision loads the revision in the :revision path parameter, writing the error
+// response and returning false if there is none.
+func (s *Server) listingRevision(c *gin.Context, listingID int32) (db.ListingRevision, bool) {
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return db.ListingRevision{}, false
+	}
+
+	r, err := s.q.GetListingRevision(c, db.GetListingRevisionParams{
+		ListingID: listingID,
+		Revision:  int32(revision),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return db.ListingRevision{}, false
+	}
+	return r, true
+}
+
+// GetListingRevision returns one revision of one of the admin's listings with its
+// changes from the revision before.
+func (s *Server) GetListingRevision(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+	r, ok := s.listingRevision(c, listing.ID)
+	if !ok {
+		return
+	}
+
+	var previous *db.ListingRevision
+	if r.Revision > 1 {
+		p, err := s.q.GetListingRevision(c, db.GetListingRevisionParams{
+			ListingID: listing.ID,
+			Revision:  r.Revision - 1,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err == nil {
+			previous = &p
+		}
+	}
+
+	c.JSON(http.StatusOK, newListingRevisionResponse(r, previous))
+}
+
+// RollbackListingRevision puts the content of an earlier revision back on one of the
+// admin's listings, recording it as a new revision.
+func (s *Server) RollbackListingRevision(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+	r, ok := s.listingRevision(c, listing.ID)
+	if !ok {
+		return
+	}
+
+	if _, err := s.q.RestoreListingRevision(c, db.RestoreListingRevisionParams{
+		ID:       listing.ID,
+		Revision: r.Revision,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := s.recordListingRevision(c, listing.ID, listing.AdminID, fmt.Sprintf("Rolled back to revision %d", r.Revision)); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if workflow.Visible(listing.Status) {
+		s.alertPriceDrop(listing.ID, listing.Price, r.Price)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Listing rolled back to revision %d", r.Revision)})
+}
+
+// GetListingReviewQueue lists the listings awaiting approval, oldest submission first.
+func (s *Server) GetListingReviewQueue(c *gin.Context) {
+	if _, ok := s.superAdmin(c); !ok {
+		return
+	}
+
+	listings, err := s.q.GetListingsPendingReview(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if listings == nil {
+		listings = []db.GetListingsPendingReviewRow{}
+	}
+	c.JSON(http.StatusOK, listings)
+}
+
+type reviewListingRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve reject"`
+	// Note tells the host what to fix; it is required to reject a listing.
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// ReviewListing approves a listing awaiting review, publishing it, or rejects it back to
+// draft with a note for the host.
+func (s *Server) ReviewListing(c *gin.Context) {
+	if _, ok := s.superAdmin(c); !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	var req reviewListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	approve := req.Action == "approve"
+	if !approve && req.Note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required to reject a listing"})
+		return
+	}
+
+	listing, err := s.q.GetListingByID(c, int32(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	next, err := workflow.Review(listing.Status, approve)
+	if err != nil {
+		c.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+
+	note := sql.NullString{String: req.Note, Valid: req.Note != ""}
+	if !s.transitionListing(c, listing.ID, listing.Status, next, note) {
+		return
+	}
+	if next == workflow.Published {
+		s.alertListingPublished(listing.ID, listing.Available.Bool, listing.PublishedAt.Valid)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": next})
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+)
+
+type reportContentRequest struct {
+	ReviewID  int32  `json:"review_id"`
+	ListingID int32  `json:"listing_id"`
+	Reason    string `json:"reason" binding:"required,oneof=spam offensive inappropriate fake other"`
+	Details   string `json:"details" binding:"max=1000"`
+}
+
+// ReportUserContent lets a guest report a review or a listing to the moderators.
+func (s *Server) ReportUserContent(c *gin.Context) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return
+	}
+
+	s.reportContent(c, db.CreateContentReportParams{
+		ReporterUserID: sql.NullInt32{Int32: user.ID, Valid: true},
+	})
+}
+
+// ReportAdminContent lets a host report a review or a listing to the moderators.
+func (s *Server) ReportAdminContent(c *gin.Context) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admin only"})
+		return
+	}
+
+	s.reportContent(c, db.CreateContentReportParams{
+		ReporterAdminID: sql.NullInt32{Int32: admin.ID, Valid: true},
+	})
+}
+
+// reportContent files a report from the reporter set in arg.
+func (s *Server) reportContent(c *gin.Context, arg db.CreateContentReportParams) {
+	var req reportContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if (req.ReviewID == 0) == (req.ListingID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "report either a review_id or a listing_id"})
+		return
+	}
+
+	if req.ReviewID != 0 {
+		if _, err := s.q.GetReviewForModeration(c, req.ReviewID); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		arg.ReviewID = sql.NullInt32{Int32: req.ReviewID, Valid: true}
+	} else {
+		if _, err := s.q.GetListingByID(c, req.ListingID); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		arg.ListingID = sql.NullInt32{Int32: req.ListingID, Valid: true}
+	}
+
+	arg.Reason = req.Reason
+	arg.Details = sql.NullString{String: req.Details, Valid: req.Details != ""}
+
+	report, err := s.q.CreateContentReport(c, arg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// superAdmin authenticates the request as a super-admin, writing the error response
+// itself when it is not.
+func (s *Server) superAdmin(c *gin.Context) (db.Admin, bool) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return db.Admin{}, false
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admin only"})
+		return db.Admin{}, false
+	}
+
+	isSuper, err := s.q.IsSuperAdmin(c, admin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.Admin{}, false
+	}
+	if !isSuper {
+		c.JSON(http.StatusForbidden, gin.H{"error": "super admins only"})
+		return db.Admin{}, false
+	}
+
+	return admin, true
+}
+
+// GetModerationQueue lists the reviews flagged by the content filter or reported by users,
+// and the reported listings, most reported first.
+func (s *Server) GetModerationQueue(c *gin.Context) {
+	if _, ok := s.superAdmin(c); !ok {
+		return
+	}
+
+	reviews, err := s.q.GetReviewModerationQueue(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	listings, err := s.q.GetListingModerationQueue(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if reviews == nil {
+		reviews = []db.GetReviewModerationQueueRow{}
+	}
+	if listings == nil {
+		listings = []db.GetListingModerationQueueRow{}
+	}
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews, "listings": listings})
+}
+
+// GetContentReports lists the open reports, oldest first.
+func (s *Server) GetContentReports(c *gin.Context) {
+	if _, ok := s.superAdmin(c); !ok {
+		return
+	}
+
+	reports, err := s.q.GetOpenContentReports(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if reports == nil {
+		reports = []db.ContentReport{}
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+type moderateReviewRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve hide delete"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ModerateReview approves, hides or deletes a review and resolves its open reports.
+// Approving a review the content filter flagged makes it visible again.
+func (s *Server) ModerateReview(c *gin.Context) {
+	admin, ok := s.superAdmin(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var req moderateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	review, err := s.q.GetReviewForModeration(c, int32(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var rows int64
+	switch req.Action {
+	case "delete":
+		rows, err = s.q.ModeratorDeleteReview(c, int32(id))
+	case "approve", "hide":
+		status := "visible"
+		if req.Action == "hide" {
+			status = "hidden"
+		}
+		rows, err = s.q.SetReviewModerationStatus(c, db.SetReviewModerationStatusParams{
+			ID:               int32(id),
+			ModerationStatus: status,
+			ModerationReason: sql.NullString{String: req.Reason, Valid: req.Reason != ""},
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
+		return
+	}
+
+	// Reports of a deleted review are deleted with it.
+	if req.Action != "delete" {
+		err = s.q.ResolveReviewReports(c, db.ResolveReviewReportsParams{
+			ReviewID:   sql.NullInt32{Int32: int32(id), Valid: true},
+			Resolution: sql.NullString{String: req.Action, Valid: true},
+			ResolvedBy: sql.NullInt32{Int32: admin.ID, Valid: true},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	s.refreshListingStats(review.ListingID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review moderated successfully"})
+}
+
+type moderateListingRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve delete"`
+}
+
+// ModerateListing dismisses the reports of a listing or deletes it.
+func (s *Server) ModerateListing(c *gin.Context) {
+	admin, ok := s.superAdmin(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	var req moderateListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if req.Action == "delete" {
+		// The listing is archived so its bookings and payments are kept; its reports are
+		// resolved below.
+		rows, err := s.q.ModeratorDeleteListing(c, db.ModeratorDeleteListingParams{
+			ID:        int32(id),
+			DeletedBy: sql.NullInt32{Int32: admin.ID, Valid: true},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if rows == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+	}
+
+	err = s.q.ResolveListingReports(c, db.ResolveListingReportsParams{
+		ListingID:  sql.NullInt32{Int32: int32(id), Valid: true},
+		Resolution: sql.NullString{String: req.Action, Valid: true},
+		ResolvedBy: sql.NullInt32{Int32: admin.ID, Valid: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if req.Action == "delete" {
+		c.JSON(http.StatusOK, gin.H{"message": "Listing deleted successfully"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Listing reports dismissed"})
+}
+
+// RestoreModeratedListing brings back a deleted listing as a draft, including listings
+// removed by a moderator.
+func (s *Server) RestoreModeratedListing(c *gin.Context) {
+	if _, ok := s.superAdmin(c); !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	rows, err := s.q.ModeratorRestoreListing(c, int32(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deleted listing not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Listing restored as a draft"})
+}
+
+// enqueueReviewModeration queues a new review for the content filter. Failures are logged
+// and do not fail the request; the review stays pending and is queued again by the
+// periodic requeue task.
+func (s *Server) enqueueReviewModeration(reviewID int32) {
+	task, err := tasks.NewModerateReviewTask(reviewID)
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		return
+	}
+	if _, err := s.client.Enqueue(task); err != nil {
+		log.Printf("Failed to enqueue task: %v", err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+	"github.com/weldonkipchirchir/rental_listing/team"
 )
 
 type createNotificationRequest struct {
@@ -37,7 +39,26 @@ func (s *Server) CreateNotification(c *gin.Context) {
 		return
 	}
 
-	_, err = s.q.CreateNotification(c, db.CreateNotificationParams{
+	// Team members can only message guests about bookings they are allowed to handle.
+	booking, err := s.q.GetBookingsByAdminIDByID(c, db.GetBookingsByAdminIDByIDParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.Messaging),
+		ID:      req.BookingID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if booking.UserID != req.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the user did not make this booking"})
+		return
+	}
+
+	notification, err := s.q.CreateNotification(c, db.CreateNotificationParams{
 		UserID:        sql.NullInt32{Int32: req.UserID, Valid: true},
 		Message:       req.Message,
 		SenderAdminID: sql.NullInt32{Int32: admin.ID, Valid: true},
@@ -50,6 +71,7 @@ func (s *Server) CreateNotification(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	s.publishNotification(notification)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Notification created successfully"})
 }
@@ -81,7 +103,7 @@ func (s *Server) CreateAdminNotification(c *gin.Context) {
 		return
 	}
 
-	_, err = s.q.CreateAdminNotification(c, db.CreateAdminNotificationParams{
+	notification, err := s.q.CreateAdminNotification(c, db.CreateAdminNotificationParams{
 		AdminID:      sql.NullInt32{Int32: req.AdminID, Valid: true},
 		Subject:      sql.NullString{String: req.Subject, Valid: true},
 		SenderUserID: sql.NullInt32{Int32: user.ID, Valid: true},
@@ -94,6 +116,7 @@ func (s *Server) CreateAdminNotification(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	s.publishNotification(notification)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Notification created successfully"})
 }
@@ -108,6 +131,7 @@ type getAllNotificationsResponse struct {
 	BookingID int32     `json:"booking_id" binding:"required"`
 	Read      bool      `json:"read" binding:"required"`
 	CreatedAt time.Time `json:"created_at"`
+	Category  string    `json:"category,omitempty"`
 }
 
 func (s *Server) GetNotifications(c *gin.Context) {
@@ -134,13 +158,14 @@ func (s *Server) GetNotifications(c *gin.Context) {
 		response = append(response, getAllNotificationsResponse{
 			ID:        notification.ID,
 			UserID:    notification.UserID.Int32,
-			AdminID:   notification.ID_2,
+			AdminID:   notification.ID_2.Int32,
 			Message:   notification.Message,
 			Read:      notification.Read.Bool,
 			Subject:   notification.Subject.String,
-			Email:     notification.Email,
+			Email:     notification.Email.String,
 			BookingID: notification.BookingID,
 			CreatedAt: notification.CreatedAt.Time,
+			Category:  notification.Category.String,
 		})
 	}
 
@@ -157,6 +182,7 @@ type getAllAdminNotificationsResponse struct {
 	BookingID int32     `json:"booking_id" binding:"required"`
 	Read      bool      `json:"read" binding:"required"`
 	CreatedAt time.Time `json:"created_at"`
+	Category  string    `json:"category,omitempty"`
 }
 
 func (s *Server) GetAdminNotifications(c *gin.Context) {
@@ -183,13 +209,14 @@ func (s *Server) GetAdminNotifications(c *gin.Context) {
 		response = append(response, getAllAdminNotificationsResponse{
 			ID:        notification.ID,
 			AdminID:   notification.AdminID.Int32,
-			UserID:    notification.ID_2,
+			UserID:    notification.ID_2.Int32,
 			Message:   notification.Message,
 			Read:      notification.Read.Bool,
 			Subject:   notification.Subject.String,
-			Email:     notification.Email,
+			Email:     notification.Email.String,
 			BookingID: notification.BookingID,
 			CreatedAt: notification.CreatedAt.Time,
+			Category:  notification.Category.String,
 		})
 	}
 
@@ -247,6 +274,7 @@ func (s *Server) UpdateNotification(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	s.publishUnreadCount(realtime.User(user.ID))
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notification updated successfully"})
 }
@@ -287,6 +315,7 @@ func (s *Server) UpdateAdminNotification(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	s.publishUnreadCount(realtime.Admin(admin.ID))
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notification updated successfully"})
 }
@@ -299,12 +328,18 @@ func (s *Server) DeleteNotification(c *gin.Context) {
 		return
 	}
 
-	err = s.q.DeleteNotification(c, int32(id))
+	deleted, err := s.q.DeleteNotification(c, int32(id))
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	if deleted.UserID.Valid {
+		s.publishUnreadCount(realtime.User(deleted.UserID.Int32))
+	}
+	if deleted.AdminID.Valid {
+		s.publishUnreadCount(realtime.Admin(deleted.AdminID.Int32))
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted successfully"})
 }
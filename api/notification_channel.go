@@ -0,0 +1,309 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+)
+
+// notificationSettingsRequest sets the phone number text messages go to and the quiet
+// hours, as "HH:MM" in time_zone, during which text messages and push notifications wait.
+// Empty values clear them.
+type notificationSettingsRequest struct {
+	Phone           string `json:"phone"`
+	QuietHoursStart string `json:"quiet_hours_start"`
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+	TimeZone        string `json:"time_zone"`
+}
+
+type notificationSettingsResponse struct {
+	Phone           string `json:"phone,omitempty"`
+	QuietHoursStart string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string `json:"quiet_hours_end,omitempty"`
+	TimeZone        string `json:"time_zone"`
+}
+
+// notificationSettings is a validated notificationSettingsRequest.
+type notificationSettings struct {
+	phone           sql.NullString
+	quietHoursStart sql.NullInt32
+	quietHoursEnd   sql.NullInt32
+	timeZone        string
+}
+
+// bindNotificationSettings reads and validates the settings of a request. It writes the
+// error response and returns false if they are invalid.
+func bindNotificationSettings(c *gin.Context) (notificationSettings, bool) {
+	var req notificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return notificationSettings{}, false
+	}
+
+	settings := notificationSettings{timeZone: "UTC"}
+	if req.Phone != "" {
+		if !notify.ValidPhone(req.Phone) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "phone must be in international format, such as +254712345678"})
+			return notificationSettings{}, false
+		}
+		settings.phone = sql.NullString{String: req.Phone, Valid: true}
+	}
+	if (req.QuietHoursStart == "") != (req.QuietHoursEnd == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quiet hours need both a start and an end"})
+		return notificationSettings{}, false
+	}
+	if req.QuietHoursStart != "" {
+		start, err := notify.ParseClock(req.QuietHoursStart)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return notificationSettings{}, false
+		}
+		end, err := notify.ParseClock(req.QuietHoursEnd)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return notificationSettings{}, false
+		}
+		settings.quietHoursStart = sql.NullInt32{Int32: int32(start), Valid: true}
+		settings.quietHoursEnd = sql.NullInt32{Int32: int32(end), Valid: true}
+	}
+	if req.TimeZone != "" {
+		if _, err := time.LoadLocation(req.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown time zone"})
+			return notificationSettings{}, false
+		}
+		settings.timeZone = req.TimeZone
+	}
+	return settings, true
+}
+
+func newNotificationSettingsResponse(s db.NotificationSetting) notificationSettingsResponse {
+	rsp := notificationSettingsResponse{Phone: s.Phone.String, TimeZone: s.TimeZone}
+	if s.QuietHoursStart.Valid && s.QuietHoursEnd.Valid {
+		rsp.QuietHoursStart = notify.FormatClock(int(s.QuietHoursStart.Int32))
+		rsp.QuietHoursEnd = notify.FormatClock(int(s.QuietHoursEnd.Int32))
+	}
+	return rsp
+}
+
+// GetUserNotificationSettings returns the signed-in user's phone number and quiet hours.
+func (s *Server) GetUserNotificationSettings(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	settings, err := s.q.GetUserNotificationSettings(c, sql.NullInt32{Int32: user.ID, Valid: true})
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == sql.ErrNoRows {
+		settings.TimeZone = "UTC"
+	}
+	c.JSON(http.StatusOK, newNotificationSettingsResponse(settings))
+}
+
+func (s *Server) UpdateUserNotificationSettings(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	req, ok := bindNotificationSettings(c)
+	if !ok {
+		return
+	}
+
+	settings, err := s.q.SetUserNotificationSettings(c, db.SetUserNotificationSettingsParams{
+		UserID:          sql.NullInt32{Int32: user.ID, Valid: true},
+		Phone:           req.phone,
+		QuietHoursStart: req.quietHoursStart,
+		QuietHoursEnd:   req.quietHoursEnd,
+		TimeZone:        req.timeZone,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, newNotificationSettingsResponse(settings))
+}
+
+// GetAdminNotificationSettings returns the signed-in admin's phone number and quiet hours.
+func (s *Server) GetAdminNotificationSettings(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	settings, err := s.q.GetAdminNotificationSettings(c, sql.NullInt32{Int32: admin.ID, Valid: true})
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == sql.ErrNoRows {
+		settings.TimeZone = "UTC"
+	}
+	c.JSON(http.StatusOK, newNotificationSettingsResponse(settings))
+}
+
+func (s *Server) UpdateAdminNotificationSettings(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	req, ok := bindNotificationSettings(c)
+	if !ok {
+		return
+	}
+
+	settings, err := s.q.SetAdminNotificationSettings(c, db.SetAdminNotificationSettingsParams{
+		AdminID:         sql.NullInt32{Int32: admin.ID, Valid: true},
+		Phone:           req.phone,
+		QuietHoursStart: req.quietHoursStart,
+		QuietHoursEnd:   req.quietHoursEnd,
+		TimeZone:        req.timeZone,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, newNotificationSettingsResponse(settings))
+}
+
+// GetPushKey returns the VAPID public key browsers subscribe to push notifications with.
+func (s *Server) GetPushKey(c *gin.Context) {
+	if s.pushKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "push notifications are not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": s.pushKey})
+}
+
+type pushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url,max=2048"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required,max=255"`
+		Auth   string `json:"auth" binding:"required,max=255"`
+	} `json:"keys" binding:"required"`
+}
+
+var errPushEndpoint = errors.New("push endpoint must be an https URL")
+
+// bindPushSubscription reads the subscription a browser's PushManager.subscribe returned.
+// It writes the error response and returns false if it is invalid.
+func (s *Server) bindPushSubscription(c *gin.Context) (pushSubscriptionRequest, bool) {
+	if s.pushKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "push notifications are not enabled"})
+		return pushSubscriptionRequest{}, false
+	}
+
+	var req pushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return pushSubscriptionRequest{}, false
+	}
+	if endpoint, err := url.Parse(req.Endpoint); err != nil || endpoint.Scheme != "https" {
+		c.JSON(http.StatusBadRequest, errorResponse(errPushEndpoint))
+		return pushSubscriptionRequest{}, false
+	}
+	return req, true
+}
+
+// CreateUserPushSubscription sends the signed-in user's push notifications to a browser.
+func (s *Server) CreateUserPushSubscription(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	req, ok := s.bindPushSubscription(c)
+	if !ok {
+		return
+	}
+
+	sub, err := s.q.CreateUserPushSubscription(c, db.CreateUserPushSubscriptionParams{
+		UserID:   sql.NullInt32{Int32: user.ID, Valid: true},
+		Endpoint: req.Endpoint,
+		P256dh:   req.Keys.P256dh,
+		Auth:     req.Keys.Auth,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": sub.ID})
+}
+
+// DeleteUserPushSubscription stops push notifications to the browser with the endpoint
+// given in the query.
+func (s *Server) DeleteUserPushSubscription(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	endpoint := c.Query("endpoint")
+	if endpoint == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "endpoint is required"})
+		return
+	}
+	err := s.q.DeleteUserPushSubscription(c, db.DeleteUserPushSubscriptionParams{
+		Endpoint: endpoint,
+		UserID:   sql.NullInt32{Int32: user.ID, Valid: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Push subscription deleted successfully"})
+}
+
+// CreateAdminPushSubscription sends the signed-in admin's push notifications to a browser.
+func (s *Server) CreateAdminPushSubscription(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	req, ok := s.bindPushSubscription(c)
+	if !ok {
+		return
+	}
+
+	sub, err := s.q.CreateAdminPushSubscription(c, db.CreateAdminPushSubscriptionParams{
+		AdminID:  sql.NullInt32{Int32: admin.ID, Valid: true},
+		Endpoint: req.Endpoint,
+		P256dh:   req.Keys.P256dh,
+		Auth:     req.Keys.Auth,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": sub.ID})
+}
+
+func (s *Server) DeleteAdminPushSubscription(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	endpoint := c.Query("endpoint")
+	if endpoint == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "endpoint is required"})
+		return
+	}
+	err := s.q.DeleteAdminPushSubscription(c, db.DeleteAdminPushSubscriptionParams{
+		Endpoint: endpoint,
+		AdminID:  sql.NullInt32{Int32: admin.ID, Valid: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Push subscription deleted successfully"})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+)
+
+type updateNotificationPreferencesRequest struct {
+	Preferences []notify.Preference `json:"preferences" binding:"required,min=1,dive"`
+}
+
+// bindNotificationPreferences reads and validates the preferences of a request. It writes
+// the error response and returns false if they are invalid.
+func bindNotificationPreferences(c *gin.Context) ([]notify.Preference, bool) {
+	var req updateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, false
+	}
+	for _, p := range req.Preferences {
+		if err := p.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return nil, false
+		}
+	}
+	return req.Preferences, true
+}
+
+// GetUserNotificationPreferences lists, for every category of booking event and every
+// channel, whether the signed-in user is notified.
+func (s *Server) GetUserNotificationPreferences(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	rows, err := s.q.GetUserNotificationPreferences(c, sql.NullInt32{Int32: user.ID, Valid: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	prefs := make([]notify.Preference, 0, len(rows))
+	for _, row := range rows {
+		prefs = append(prefs, notify.Preference(row))
+	}
+	c.JSON(http.StatusOK, notify.All(prefs))
+}
+
+// UpdateUserNotificationPreferences turns channels on or off for the signed-in user.
+// Categories and channels that are left out keep their setting.
+func (s *Server) UpdateUserNotificationPreferences(c *gin.Context) {
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+	prefs, ok := bindNotificationPreferences(c)
+	if !ok {
+		return
+	}
+
+	for _, p := range prefs {
+		err := s.q.SetUserNotificationPreference(c, db.SetUserNotificationPreferenceParams{
+			UserID:   sql.NullInt32{Int32: user.ID, Valid: true},
+			Category: p.Category,
+			Channel:  p.Channel,
+			Enabled:  p.Enabled,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	s.GetUserNotificationPreferences(c)
+}
+
+// GetAdminNotificationPreferences lists, for every category of booking event and every
+// channel, whether the signed-in admin is notified.
+func (s *Server) GetAdminNotificationPreferences(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	rows, err := s.q.GetAdminNotificationPreferences(c, sql.NullInt32{Int32: admin.ID, Valid: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	prefs := make([]notify.Preference, 0, len(rows))
+	for _, row := range rows {
+		prefs = append(prefs, notify.Preference(row))
+	}
+	c.JSON(http.StatusOK, notify.All(prefs))
+}
+
+// UpdateAdminNotificationPreferences turns channels on or off for the signed-in admin.
+func (s *Server) UpdateAdminNotificationPreferences(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	prefs, ok := bindNotificationPreferences(c)
+	if !ok {
+		return
+	}
+
+	for _, p := range prefs {
+		err := s.q.SetAdminNotificationPreference(c, db.SetAdminNotificationPreferenceParams{
+			AdminID:  sql.NullInt32{Int32: admin.ID, Valid: true},
+			Category: p.Category,
+			Channel:  p.Channel,
+			Enabled:  p.Enabled,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	s.GetAdminNotificationPreferences(c)
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+// StreamNotifications pushes new notifications, unread count changes and booking status
+// changes to the signed-in user or admin as server-sent events. The current unread count
+// is sent first. A client that reconnects with the Last-Event-ID header, or the
+// last_event_id query parameter, first receives the events it missed.
+func (s *Server) StreamNotifications(c *gin.Context) {
+	r, ok := s.streamRecipient(c)
+	if !ok {
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	ctx, cancel := s.streamContext(c)
+	defer cancel()
+
+	events, err := s.live.Subscribe(ctx, r, lastEventID)
+	if err != nil {
+		if errors.Is(err, realtime.ErrInvalidEventID) {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	unread, err := s.unreadCount(ctx, r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	current, err := realtime.NewEvent(realtime.EventUnreadCount, realtime.UnreadCount{Unread: unread})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if err := realtime.WriteRetry(c.Writer, realtime.RetryInterval); err != nil {
+		return
+	}
+	if err := realtime.WriteEvent(c.Writer, current); err != nil {
+		return
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(realtime.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			// The events end when the client disconnects or the server shuts down. The
+			// client reconnects after the retry interval, to this or another server.
+			if !ok {
+				return false
+			}
+			return realtime.WriteEvent(w, e) == nil
+		case <-heartbeat.C:
+			return realtime.WriteHeartbeat(w) == nil
+		}
+	})
+}
+
+// streamRecipient finds the signed-in user or admin. Users and admins sign in with the
+// same kind of token, so someone with both accounts picks one with ?as=user or ?as=admin;
+// by default the user account is used if there is one.
+func (s *Server) streamRecipient(c *gin.Context) (realtime.Recipient, bool) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return realtime.Recipient{}, false
+	}
+
+	as := c.Query("as")
+	if as != "" && as != realtime.KindUser && as != realtime.KindAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "as must be user or admin"})
+		return realtime.Recipient{}, false
+	}
+
+	if as != realtime.KindAdmin {
+		user, err := s.q.GetUser(c, email.(string))
+		if err == nil {
+			return realtime.User(user.ID), true
+		}
+		if err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return realtime.Recipient{}, false
+		}
+		if as == realtime.KindUser {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+			return realtime.Recipient{}, false
+		}
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users and admins only"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return realtime.Recipient{}, false
+	}
+	return realtime.Admin(admin.ID), true
+}
+
+func (s *Server) unreadCount(ctx context.Context, r realtime.Recipient) (int64, error) {
+	id := sql.NullInt32{Int32: r.ID, Valid: true}
+	if r.Kind == realtime.KindAdmin {
+		return s.q.CountUnreadNotificationsByAdminID(ctx, id)
+	}
+	return s.q.CountUnreadNotificationsByUserID(ctx, id)
+}
+
+// publishLive pushes an event to the open streams of r. Clients that miss it see the
+// change when they next load the data, so failures are only logged.
+func (s *Server) publishLive(r realtime.Recipient, eventType string, data any) {
+	if err := s.live.Publish(context.Background(), r, eventType, data); err != nil {
+		log.Printf("Failed to publish %s event to %s: %v", eventType, r, err)
+	}
+}
+
+// publishUnreadCount pushes the current number of unread notifications of r.
+func (s *Server) publishUnreadCount(r realtime.Recipient) {
+	unread, err := s.unreadCount(context.Background(), r)
+	if err != nil {
+		log.Printf("Failed to count unread notifications of %s: %v", r, err)
+		return
+	}
+	s.publishLive(r, realtime.EventUnreadCount, realtime.UnreadCount{Unread: unread})
+}
+
+// publishNotification pushes a new notification and the new unread count to its
+// recipient.
+func (s *Server) publishNotification(n db.Notification) {
+	r := realtime.User(n.UserID.Int32)
+	if n.AdminID.Valid {
+		r = realtime.Admin(n.AdminID.Int32)
+	}
+	s.publishLive(r, realtime.EventNotification, n)
+	s.publishUnreadCount(r)
+}
+
+// publishBookingStatus pushes the new status of a booking to the guest and the hosts who
+// can see the listing's bookings.
+func (s *Server) publishBookingStatus(bookingID int32, status string) {
+	ctx := context.Background()
+	parties, err := s.q.GetBookingParties(ctx, bookingID)
+	if err != nil {
+		log.Printf("Failed to load booking %d parties: %v", bookingID, err)
+		return
+	}
+	hosts, err := s.q.GetListingHosts(ctx, db.GetListingHostsParams{
+		ID:    parties.ListingID,
+		Roles: team.RolesWith(team.ViewBookings),
+	})
+	if err != nil {
+		log.Printf("Failed to load hosts of listing %d: %v", parties.ListingID, err)
+	}
+
+	data := realtime.BookingStatus{
+		BookingID: parties.ID,
+		ListingID: parties.ListingID,
+		Title:     parties.Title,
+		Status:    status,
+	}
+	s.publishLive(realtime.User(parties.UserID), realtime.EventBookingStatus, data)
+	for _, adminID := range hosts {
+		s.publishLive(realtime.Admin(adminID), realtime.EventBookingStatus, data)
+	}
+}
@@ -0,0 +1,510 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+// currentAdmin loads the signed-in admin. It writes the error response and returns false
+// if the request is not from an admin.
+func (s *Server) currentAdmin(c *gin.Context) (db.Admin, bool) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return db.Admin{}, false
+	}
+
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admins only"})
+		return db.Admin{}, false
+	}
+	return admin, true
+}
+
+// organizationMember loads the admin's membership of an organisation. It writes the error
+// response and returns false if the admin is not a member.
+func (s *Server) organizationMember(c *gin.Context, organizationID, adminID int32) (db.OrganizationMember, bool) {
+	member, err := s.q.GetOrganizationMember(c, db.GetOrganizationMemberParams{
+		OrganizationID: organizationID,
+		AdminID:        adminID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return db.OrganizationMember{}, false
+	}
+	return member, true
+}
+
+// organizationPermission checks that the admin's role in an organisation grants p. It
+// writes the error response and returns false otherwise.
+func (s *Server) organizationPermission(c *gin.Context, organizationID, adminID int32, p team.Permission) bool {
+	member, ok := s.organizationMember(c, organizationID, adminID)
+	if !ok {
+		return false
+	}
+	if !team.Allows(member.Role, p) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("the %s role does not allow this", member.Role)})
+		return false
+	}
+	return true
+}
+
+// organizationParam parses the :id path parameter as an organisation ID.
+func organizationParam(c *gin.Context) (int32, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, false
+	}
+	return int32(id), true
+}
+
+type createOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// CreateOrganization creates an organisation with the signed-in admin as its owner.
+func (s *Server) CreateOrganization(c *gin.Context) {
+	var req createOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	tx, err := s.db.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
+
+	organization, err := q.CreateOrganization(c, db.CreateOrganizationParams{
+		Name:      req.Name,
+		CreatedBy: sql.NullInt32{Int32: admin.ID, Valid: true},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := q.AddOrganizationMember(c, db.AddOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		AdminID:        admin.ID,
+		Role:           team.Owner,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+// GetOrganizations lists the organisations the signed-in admin belongs to and their role
+// in each.
+func (s *Server) GetOrganizations(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	organizations, err := s.q.GetAdminOrganizations(c, admin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if organizations == nil {
+		organizations = []db.GetAdminOrganizationsRow{}
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+// GetOrganizationMembers lists the members of one of the admin's organisations.
+func (s *Server) GetOrganizationMembers(c *gin.Context) {
+	organizationID, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if _, ok := s.organizationMember(c, organizationID, admin.ID); !ok {
+		return
+	}
+
+	members, err := s.q.GetOrganizationMembers(c, organizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+type inviteOrganizationMemberRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+	Role  string `json:"role" binding:"required,oneof=owner manager cleaner accountant"`
+}
+
+// InviteOrganizationMember emails an invitation to join the organisation with a role.
+func (s *Server) InviteOrganizationMember(c *gin.Context) {
+	organizationID, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+
+	var req inviteOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if !s.organizationPermission(c, organizationID, admin.ID, team.ManageTeam) {
+		return
+	}
+
+	organization, err := s.q.GetOrganization(c, organizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	token, err := team.NewInvitationToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	invitation, err := s.q.CreateOrganizationInvitation(c, db.CreateOrganizationInvitationParams{
+		OrganizationID: organizationID,
+		Email:          strings.ToLower(req.Email),
+		Role:           req.Role,
+		Token:          token,
+		InvitedBy:      sql.NullInt32{Int32: admin.ID, Valid: true},
+		ExpiresAt:      time.Now().Add(team.InvitationTTL),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	task, err := tasks.NewTeamInvitationEmailTask(tasks.TeamInvitationEmailPayload{
+		ToEmail:          invitation.Email,
+		InvitedBy:        admin.Username,
+		OrganizationName: organization.Name,
+		Role:             invitation.Role,
+		InvitationLink:   fmt.Sprintf("http://localhost:3000/invitations/%s", invitation.Token),
+		ExpiresAt:        invitation.ExpiresAt.Format("2006-01-02"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if _, err := s.client.Enqueue(task); err != nil {
+		log.Printf("Failed to enqueue invitation email for organization %d: %v", organizationID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         invitation.ID,
+		"email":      invitation.Email,
+		"role":       invitation.Role,
+		"expires_at": invitation.ExpiresAt,
+	})
+}
+
+// GetOrganizationInvitations lists the organisation's invitations that have not been
+// accepted yet.
+func (s *Server) GetOrganizationInvitations(c *gin.Context) {
+	organizationID, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if !s.organizationPermission(c, organizationID, admin.ID, team.ManageTeam) {
+		return
+	}
+
+	invitations, err := s.q.GetOrganizationInvitations(c, organizationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if invitations == nil {
+		invitations = []db.GetOrganizationInvitationsRow{}
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// DeleteOrganizationInvitation withdraws an invitation that has not been accepted.
+func (s *Server) DeleteOrganizationInvitation(c *gin.Context) {
+	organizationID, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	invitationID, err := strconv.Atoi(c.Param("invitation"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if !s.organizationPermission(c, organizationID, admin.ID, team.ManageTeam) {
+		return
+	}
+
+	rows, err := s.q.DeleteOrganizationInvitation(c, db.DeleteOrganizationInvitationParams{
+		ID:             int32(invitationID),
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "invitation withdrawn"})
+}
+
+// AcceptOrganizationInvitation adds the signed-in admin to the organisation they were
+// invited to. The invitation must have been sent to the admin's email address.
+func (s *Server) AcceptOrganizationInvitation(c *gin.Context) {
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	invitation, err := s.q.GetOrganizationInvitationByToken(c, c.Param("token"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !strings.EqualFold(invitation.Email, admin.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the invitation was sent to another email address"})
+		return
+	}
+
+	tx, err := s.db.BeginTx(c, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	defer tx.Rollback()
+	q := s.q.WithTx(tx)
+
+	rows, err := q.AcceptOrganizationInvitation(c, invitation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusGone, gin.H{"error": "the invitation has expired or was already accepted"})
+		return
+	}
+	if err := q.AddOrganizationMember(c, db.AddOrganizationMemberParams{
+		OrganizationID: invitation.OrganizationID,
+		AdminID:        admin.ID,
+		Role:           invitation.Role,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": invitation.OrganizationID})
+}
+
+type updateOrganizationMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner manager cleaner accountant"`
+}
+
+// UpdateOrganizationMember changes a member's role. The last owner cannot be demoted.
+func (s *Server) UpdateOrganizationMember(c *gin.Context) {
+	organizationID, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.Atoi(c.Param("admin"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	var req updateOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if !s.organizationPermission(c, organizationID, admin.ID, team.ManageTeam) {
+		return
+	}
+	if _, ok := s.organizationMember(c, organizationID, int32(memberID)); !ok {
+		return
+	}
+
+	rows, err := s.q.UpdateOrganizationMemberRole(c, db.UpdateOrganizationMemberRoleParams{
+		Role:           req.Role,
+		OrganizationID: organizationID,
+		AdminID:        int32(memberID),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "an organization needs at least one owner"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"admin_id": memberID, "role": req.Role})
+}
+
+// RemoveOrganizationMember removes a member from the organisation. Members can always
+// leave; removing someone else needs the manage team permission. The last owner cannot
+// be removed.
+func (s *Server) RemoveOrganizationMember(c *gin.Context) {
+	organizationID, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.Atoi(c.Param("admin"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+	if int32(memberID) != admin.ID && !s.organizationPermission(c, organizationID, admin.ID, team.ManageTeam) {
+		return
+	}
+	if _, ok := s.organizationMember(c, organizationID, int32(memberID)); !ok {
+		return
+	}
+
+	rows, err := s.q.DeleteOrganizationMember(c, db.DeleteOrganizationMemberParams{
+		OrganizationID: organizationID,
+		AdminID:        int32(memberID),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "an organization needs at least one owner"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "member removed"})
+}
+
+type setListingOrganizationRequest struct {
+	// OrganizationID is zero to make the listing the signed-in admin's own.
+	OrganizationID int32 `json:"organization_id"`
+}
+
+// SetListingOrganization moves a listing into one of the admin's organisations, or out of
+// its organisation to the admin. Taking a listing out of an organisation needs the manage
+// team permission there.
+func (s *Server) SetListingOrganization(c *gin.Context) {
+	var req setListingOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+	admin, ok := s.currentAdmin(c)
+	if !ok {
+		return
+	}
+
+	if listing.OrganizationID.Int32 == req.OrganizationID {
+		c.JSON(http.StatusOK, gin.H{"organization_id": req.OrganizationID})
+		return
+	}
+	if listing.OrganizationID.Valid && !s.organizationPermission(c, listing.OrganizationID.Int32, admin.ID, team.ManageTeam) {
+		return
+	}
+
+	// The admin of record stays the same inside an organisation and becomes the signed-in
+	// admin when the listing leaves it.
+	adminID := listing.AdminID
+	if req.OrganizationID != 0 {
+		if !s.organizationPermission(c, req.OrganizationID, admin.ID, team.EditListings) {
+			return
+		}
+	} else {
+		adminID = admin.ID
+	}
+
+	rows, err := s.q.SetListingOrganization(c, db.SetListingOrganizationParams{
+		OrganizationID: sql.NullInt32{Int32: req.OrganizationID, Valid: req.OrganizationID != 0},
+		AdminID:        adminID,
+		ID:             listing.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": req.OrganizationID})
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"strconv"
@@ -12,44 +11,70 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/stripe/stripe-go/v72"
-	"github.com/stripe/stripe-go/v72/paymentintent"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/team"
+	"github.com/weldonkipchirchir/rental_listing/workflow"
 )
 
 type paymentReq struct {
-	TotalAmount string `json:"total_amount"`
+	TotalAmount  string     `json:"total_amount"`
+	ListingID    int32      `json:"listing_id"`
+	CheckInDate  *time.Time `json:"check_in_date"`
+	CheckOutDate *time.Time `json:"check_out_date"`
 }
 
 func (s *Server) HandleCreatePaymentIntent(c *gin.Context) {
 
 	var req paymentReq
 
-	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
-
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	priceFloat, err := strconv.ParseFloat(req.TotalAmount, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
+	var payAmount int64
+	quoted := false
+
+	// Request-to-book listings only authorize the card; the host's approval captures it.
+	capture := payment.CaptureAutomatic
+	if req.ListingID != 0 {
+		listing, err := s.q.GetListingByID(c, req.ListingID)
+		if err != nil || !workflow.Visible(listing.Status) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+		if listing.BookingMode == bookingModeRequest {
+			capture = payment.CaptureManual
+		}
 
-	// Convert to cents and round off
-	payAmount := int64(math.Round(priceFloat * 100))
+		// With the stay dates the amount is computed from the listing's price rules.
+		if req.CheckInDate != nil && req.CheckOutDate != nil {
+			quote, err := s.quoteStay(c, listing.ID, listing.Price, *req.CheckInDate, *req.CheckOutDate)
+			if err != nil {
+				if isStayError(err) {
+					c.JSON(http.StatusBadRequest, errorResponse(err))
+					return
+				}
+				c.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			payAmount = quote.Total
+			quoted = true
+		}
+	}
 
-	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(payAmount),
-		Currency: stripe.String("usd"),
-		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
-			Enabled: stripe.Bool(true),
-		},
+	if !quoted {
+		// Convert to cents and round off
+		amount, err := payment.ToCents(req.TotalAmount)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		payAmount = amount
 	}
 
-	pi, err := paymentintent.New(params)
+	pi, err := s.payments.CreateIntent(c, payAmount, capture)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -174,7 +199,10 @@ func (s *Server) GetPaymentsByAdminID(c *gin.Context) {
 		return
 	}
 
-	payments, err := s.q.GetPaymentsByAdminID(c, admin.ID)
+	payments, err := s.q.GetPaymentsByAdminID(c, db.GetPaymentsByAdminIDParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.Payouts),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
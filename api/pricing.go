@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/pricing"
+)
+
+// maxPriceCalendarDays bounds the range served by the price calendar.
+const maxPriceCalendarDays = 366
+
+type priceRuleRequest struct {
+	Name            string     `json:"name" binding:"required,max=100"`
+	Kind            string     `json:"kind" binding:"required,oneof=override season weekday last_minute early_bird"`
+	StartDate       *time.Time `json:"start_date"`
+	EndDate         *time.Time `json:"end_date"`
+	DaysOfWeek      []int32    `json:"days_of_week" binding:"omitempty,dive,min=0,max=6"`
+	NightlyPrice    string     `json:"nightly_price"`
+	MinStay         int32      `json:"min_stay" binding:"omitempty,min=1"`
+	MaxStay         int32      `json:"max_stay" binding:"omitempty,min=1"`
+	DiscountPercent float64    `json:"discount_percent" binding:"omitempty,gt=0,max=100"`
+	LeadDays        int32      `json:"lead_days" binding:"omitempty,min=0"`
+	Priority        int32      `json:"priority"`
+}
+
+type priceRuleResponse struct {
+	ID              int32   `json:"id"`
+	ListingID       int32   `json:"listing_id"`
+	Name            string  `json:"name"`
+	Kind            string  `json:"kind"`
+	StartDate       string  `json:"start_date,omitempty"`
+	EndDate         string  `json:"end_date,omitempty"`
+	DaysOfWeek      []int32 `json:"days_of_week,omitempty"`
+	NightlyPrice    string  `json:"nightly_price,omitempty"`
+	MinStay         int32   `json:"min_stay,omitempty"`
+	MaxStay         int32   `json:"max_stay,omitempty"`
+	DiscountPercent string  `json:"discount_percent,omitempty"`
+	LeadDays        int32   `json:"lead_days,omitempty"`
+	Priority        int32   `json:"priority"`
+}
+
+func newPriceRuleResponse(r db.ListingPriceRule) priceRuleResponse {
+	res := priceRuleResponse{
+		ID:              r.ID,
+		ListingID:       r.ListingID,
+		Name:            r.Name,
+		Kind:            r.Kind,
+		DaysOfWeek:      r.DaysOfWeek,
+		NightlyPrice:    r.NightlyPrice.String,
+		MinStay:         r.MinStay.Int32,
+		MaxStay:         r.MaxStay.Int32,
+		DiscountPercent: r.DiscountPercent.String,
+		LeadDays:        r.LeadDays.Int32,
+		Priority:        r.Priority,
+	}
+	if r.StartDate.Valid {
+		res.StartDate = r.StartDate.Time.Format("2006-01-02")
+	}
+	if r.EndDate.Valid {
+		res.EndDate = r.EndDate.Time.Format("2006-01-02")
+	}
+	return res
+}
+
+// toRule converts the request into a pricing rule so it can be validated.
+func (req priceRuleRequest) toRule() (pricing.Rule, error) {
+	rule := pricing.Rule{
+		Kind:            req.Kind,
+		MinStay:         int(req.MinStay),
+		MaxStay:         int(req.MaxStay),
+		DiscountPercent: req.DiscountPercent,
+		LeadDays:        int(req.LeadDays),
+		Priority:        int(req.Priority),
+	}
+	if req.StartDate != nil {
+		rule.StartDate = *req.StartDate
+	}
+	if req.EndDate != nil {
+		rule.EndDate = *req.EndDate
+	}
+	for _, d := range req.DaysOfWeek {
+		rule.DaysOfWeek = append(rule.DaysOfWeek, time.Weekday(d))
+	}
+	if req.NightlyPrice != "" {
+		price, err := payment.ToCents(req.NightlyPrice)
+		if err != nil {
+			return pricing.Rule{}, err
+		}
+		rule.NightlyPrice = price
+	}
+	return rule, rule.Validate()
+}
+
+// newPriceRuleParams maps a validated rule onto the columns shared by create and update.
+func newPriceRuleParams(req priceRuleRequest, rule pricing.Rule) db.CreateListingPriceRuleParams {
+	arg := db.CreateListingPriceRuleParams{
+		Name:       req.Name,
+		Kind:       req.Kind,
+		DaysOfWeek: req.DaysOfWeek,
+		MinStay:    sql.NullInt32{Int32: req.MinStay, Valid: req.MinStay > 0},
+		MaxStay:    sql.NullInt32{Int32: req.MaxStay, Valid: req.MaxStay > 0},
+		Priority:   req.Priority,
+	}
+	if req.StartDate != nil {
+		arg.StartDate = sql.NullTime{Time: *req.StartDate, Valid: true}
+	}
+	if req.EndDate != nil {
+		arg.EndDate = sql.NullTime{Time: *req.EndDate, Valid: true}
+	}
+	if rule.NightlyPrice > 0 {
+		arg.NightlyPrice = sql.NullString{String: payment.FormatCents(rule.NightlyPrice), Valid: true}
+	}
+	if req.DiscountPercent > 0 {
+		arg.DiscountPercent = sql.NullString{String: strconv.FormatFloat(req.DiscountPercent, 'f', 2, 64), Valid: true}
+	}
+	if req.Kind == pricing.KindLastMinute || req.Kind == pricing.KindEarlyBird {
+		arg.LeadDays = sql.NullInt32{Int32: req.LeadDays, Valid: true}
+	}
+	return arg
+}
+
+// CreateListingPriceRule adds a price rule to one of the admin's listings.
+func (s *Server) CreateListingPriceRule(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	var req priceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rule, err := req.toRule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	arg := newPriceRuleParams(req, rule)
+	arg.ListingID = listing.ID
+
+	created, err := s.q.CreateListingPriceRule(c, arg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, newPriceRuleResponse(created))
+}
+
+// GetListingPriceRules lists the price rules of one of the admin's listings.
+func (s *Server) GetListingPriceRules(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	rows, err := s.q.GetListingPriceRules(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rules := make([]priceRuleResponse, len(rows))
+	for i, row := range rows {
+		rules[i] = newPriceRuleResponse(row)
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// UpdateListingPriceRule replaces a price rule of one of the admin's listings.
+func (s *Server) UpdateListingPriceRule(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req priceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rule, err := req.toRule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	p := newPriceRuleParams(req, rule)
+	updated, err := s.q.UpdateListingPriceRule(c, db.UpdateListingPriceRuleParams{
+		ID:              int32(ruleID),
+		ListingID:       listing.ID,
+		Name:            p.Name,
+		Kind:            p.Kind,
+		StartDate:       p.StartDate,
+		EndDate:         p.EndDate,
+		DaysOfWeek:      p.DaysOfWeek,
+		NightlyPrice:    p.NightlyPrice,
+		MinStay:         p.MinStay,
+		MaxStay:         p.MaxStay,
+		DiscountPercent: p.DiscountPercent,
+		LeadDays:        p.LeadDays,
+		Priority:        p.Priority,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "price rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newPriceRuleResponse(updated))
+}
+
+// DeleteListingPriceRule removes a price rule from one of the admin's listings.
+func (s *Server) DeleteListingPriceRule(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+
+	ruleID, err := strconv.Atoi(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	rows, err := s.q.DeleteListingPriceRule(c, db.DeleteListingPriceRuleParams{
+		ID:        int32(ruleID),
+		ListingID: listing.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "price rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Price rule deleted successfully"})
+}
+
+type priceCalendarDay struct {
+	Date      string `json:"date"`
+	Price     string `json:"price"`
+	MinStay   int    `json:"min_stay,omitempty"`
+	MaxStay   int    `json:"max_stay,omitempty"`
+	Available bool   `json:"available"`
+}
+
+// GetListingPriceCalendar returns the nightly price, stay limits and availability of each
+// day in [from, to). Both default to the next 30 days.
+func (s *Server) GetListingPriceCalendar(c *gin.Context) {
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2006-01-02"})
+			return
+		}
+	}
+	to := from.AddDate(0, 0, 30)
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2006-01-02"})
+			return
+		}
+	}
+	if !to.After(from) || pricing.Nights(from, to) > maxPriceCalendarDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from and at most a year later"})
+		return
+	}
+
+	listing, err := s.q.GetListingByID(c, int32(listingID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	base, err := payment.ToCents(listing.Price)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rules, err := s.listingPriceRules(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	unavailable, err := s.unavailableDays(c, listing.ID, from)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	days := pricing.Calendar(base, rules, from, to)
+	res := make([]priceCalendarDay, len(days))
+	for i, d := range days {
+		date := d.Date.Format("2006-01-02")
+		res[i] = priceCalendarDay{
+			Date:      date,
+			Price:     payment.FormatCents(d.Price),
+			MinStay:   d.MinStay,
+			MaxStay:   d.MaxStay,
+			Available: !unavailable[date],
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// unavailableDays returns the booked or blocked nights of the listing from `since` onwards.
+func (s *Server) unavailableDays(ctx context.Context, listingID int32, since time.Time) (map[string]bool, error) {
+	days := map[string]bool{}
+	mark := func(start, end time.Time) {
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			days[d.Format("2006-01-02")] = true
+		}
+	}
+
+	bookings, err := s.q.GetCalendarBookings(ctx, db.GetCalendarBookingsParams{ListingID: listingID, Since: since})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bookings {
+		mark(b.CheckInDate, b.CheckOutDate)
+	}
+
+	blocks, err := s.q.GetBlockedDatesByListingID(ctx, db.GetBlockedDatesByListingIDParams{ListingID: listingID, Since: since})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		mark(b.StartDate, b.EndDate)
+	}
+
+	return days, nil
+}
+
+// listingPriceRules loads the listing's price rules for the pricing package.
+func (s *Server) listingPriceRules(ctx context.Context, listingID int32) ([]pricing.Rule, error) {
+	rows, err := s.q.GetListingPriceRules(ctx, listingID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]pricing.Rule, 0, len(rows))
+	for _, row := range rows {
+		rule := pricing.Rule{
+			ID:         row.ID,
+			Kind:       row.Kind,
+			StartDate:  row.StartDate.Time,
+			EndDate:    row.EndDate.Time,
+			MinStay:    int(row.MinStay.Int32),
+			MaxStay:    int(row.MaxStay.Int32),
+			LeadDays:   int(row.LeadDays.Int32),
+			Priority:   int(row.Priority),
+			DaysOfWeek: make([]time.Weekday, len(row.DaysOfWeek)),
+		}
+		for i, d := range row.DaysOfWeek {
+			rule.DaysOfWeek[i] = time.Weekday(d)
+		}
+		if row.NightlyPrice.Valid {
+			if rule.NightlyPrice, err = payment.ToCents(row.NightlyPrice.String); err != nil {
+				return nil, err
+			}
+		}
+		if row.DiscountPercent.Valid {
+			if rule.DiscountPercent, err = strconv.ParseFloat(row.DiscountPercent.String, 64); err != nil {
+				return nil, err
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// quoteStay prices a stay at the listing booked now, applying the listing's price rules.
+func (s *Server) quoteStay(ctx context.Context, listingID int32, price string, checkIn, checkOut time.Time) (pricing.Quote, error) {
+	base, err := payment.ToCents(price)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+
+	rules, err := s.listingPriceRules(ctx, listingID)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+
+	return pricing.QuoteStay(base, rules, checkIn, checkOut, time.Now())
+}
+
+// isStayError reports whether the quote failed because of the requested dates rather than
+// an internal error.
+func isStayError(err error) bool {
+	return errors.Is(err, pricing.ErrInvalidStay) || errors.Is(err, pricing.ErrStayTooShort) || errors.Is(err, pricing.ErrStayTooLong)
+}
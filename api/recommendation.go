@@ -0,0 +1,193 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+const (
+	similarListingsLimit = 6
+	defaultFeedLimit     = 20
+	maxFeedLimit         = 50
+)
+
+// Sources of a recommended listing.
+const (
+	sourceSimilar      = "similar"
+	sourcePersonalised = "personalised"
+	sourcePopular      = "popular"
+)
+
+type recommendedListingResponse struct {
+	ID          int32     `json:"id"`
+	AdminID     int32     `json:"admin_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Price       string    `json:"price"`
+	Location    string    `json:"location"`
+	Available   bool      `json:"available"`
+	Imagelink   []string  `json:"imagelink"`
+	CreatedAt   time.Time `json:"created_at"`
+	Source      string    `json:"source"`
+}
+
+// fillWithPopular appends popular listings to recommendations until there are limit of
+// them, skipping listings already recommended and the listing with ID exclude.
+func fillWithPopular(recommendations []recommendedListingResponse, popular []db.GetPopularListingsRow, exclude int32, limit int) []recommendedListingResponse {
+	seen := map[int32]bool{exclude: true}
+	for _, r := range recommendations {
+		seen[r.ID] = true
+	}
+
+	for _, row := range popular {
+		if len(recommendations) >= limit {
+			break
+		}
+		if seen[row.ID] {
+			continue
+		}
+		seen[row.ID] = true
+		recommendations = append(recommendations, recommendedListingResponse{
+			ID:          row.ID,
+			AdminID:     row.AdminID,
+			Title:       row.Title,
+			Description: row.Description.String,
+			Price:       row.Price,
+			Location:    row.Location.String,
+			Available:   row.Available.Bool,
+			Imagelink:   row.Imagelinks,
+			CreatedAt:   row.CreatedAt.Time,
+			Source:      sourcePopular,
+		})
+	}
+	return recommendations
+}
+
+// similarListings returns the listings recommended on a listing's page, topped up with
+// popular listings while the model knows too few similar ones.
+func (s *Server) similarListings(c *gin.Context, listingID int32) ([]recommendedListingResponse, error) {
+	rows, err := s.q.GetSimilarListings(c, db.GetSimilarListingsParams{
+		ListingID: listingID,
+		Limit:     similarListingsLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	similar := make([]recommendedListingResponse, 0, similarListingsLimit)
+	for _, row := range rows {
+		similar = append(similar, recommendedListingResponse{
+			ID:          row.ID,
+			AdminID:     row.AdminID,
+			Title:       row.Title,
+			Description: row.Description.String,
+			Price:       row.Price,
+			Location:    row.Location.String,
+			Available:   row.Available.Bool,
+			Imagelink:   row.Imagelinks,
+			CreatedAt:   row.CreatedAt.Time,
+			Source:      sourceSimilar,
+		})
+	}
+	if len(similar) == similarListingsLimit {
+		return similar, nil
+	}
+
+	popular, err := s.q.GetPopularListings(c, db.GetPopularListingsParams{
+		Limit: int32(2 * similarListingsLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fillWithPopular(similar, popular, listingID, similarListingsLimit), nil
+}
+
+func feedLimit(c *gin.Context) (int, bool) {
+	limit := defaultFeedLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxFeedLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+			return 0, false
+		}
+		limit = n
+	}
+	return limit, true
+}
+
+// GetListingFeed returns the signed-in user's home feed: listings similar to the ones they
+// favorited, booked or reviewed well, followed by popular listings they have not seen,
+// which is all a new user gets.
+func (s *Server) GetListingFeed(c *gin.Context) {
+	limit, ok := feedLimit(c)
+	if !ok {
+		return
+	}
+
+	user, ok := s.currentUser(c)
+	if !ok {
+		return
+	}
+
+	rows, err := s.q.GetRecommendedListings(c, db.GetRecommendedListingsParams{
+		UserID: user.ID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	feed := make([]recommendedListingResponse, 0, limit)
+	for _, row := range rows {
+		feed = append(feed, recommendedListingResponse{
+			ID:          row.ID,
+			AdminID:     row.AdminID,
+			Title:       row.Title,
+			Description: row.Description.String,
+			Price:       row.Price,
+			Location:    row.Location.String,
+			Available:   row.Available.Bool,
+			Imagelink:   row.Imagelinks,
+			CreatedAt:   row.CreatedAt.Time,
+			Source:      sourcePersonalised,
+		})
+	}
+
+	if len(feed) < limit {
+		popular, err := s.q.GetPopularListings(c, db.GetPopularListingsParams{
+			UserID: user.ID,
+			Limit:  int32(2 * limit),
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		feed = fillWithPopular(feed, popular, 0, limit)
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// GetPopularListings returns the most popular available listings, for visitors who are
+// not signed in.
+func (s *Server) GetPopularListings(c *gin.Context) {
+	limit, ok := feedLimit(c)
+	if !ok {
+		return
+	}
+
+	popular, err := s.q.GetPopularListings(c, db.GetPopularListingsParams{
+		Limit: int32(limit),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, fillWithPopular(make([]recommendedListingResponse, 0, limit), popular, 0, limit))
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"strconv"
@@ -8,14 +9,26 @@ import (
 
 	"github.com/gin-gonic/gin"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/moderation"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/team"
 )
 
 type createReviewRequest struct {
-	ListingID int32  `json:"listingId" binding:"required"`
-	Rating    int32  `json:"rating" binding:"required"`
-	Comment   string `json:"comment" binding:"required"`
+	BookingID     int32  `json:"booking_id" binding:"required"`
+	Rating        int32  `json:"rating" binding:"required,min=1,max=5"`
+	Cleanliness   int32  `json:"cleanliness" binding:"required,min=1,max=5"`
+	Accuracy      int32  `json:"accuracy" binding:"required,min=1,max=5"`
+	Location      int32  `json:"location" binding:"required,min=1,max=5"`
+	Value         int32  `json:"value" binding:"required,min=1,max=5"`
+	Communication int32  `json:"communication" binding:"required,min=1,max=5"`
+	Comment       string `json:"comment" binding:"required,max=2000"`
 }
 
+// CreateReview lets a guest review a completed stay. Each booking can be reviewed once,
+// within tasks.ReviewWindow of check-out. The review stays hidden until the content
+// filter has passed it, and until the host has reviewed the guest too or the window
+// closes.
 func (s *Server) CreateReview(c *gin.Context) {
 	var request createReviewRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -35,25 +48,223 @@ func (s *Server) CreateReview(c *gin.Context) {
 		return
 	}
 
-	_, err = s.q.GetListingByID(c, request.ListingID)
+	booking, err := s.q.GetUserBookingByID(c, db.GetUserBookingByIDParams{
+		ID:     request.BookingID,
+		UserID: user.ID,
+	})
 	if err != nil {
-		c.JSON(http.StatusNotFound, errorResponse(err))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if booking.Status.String != "completed" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only completed stays can be reviewed"})
+		return
+	}
+	if time.Since(booking.CheckOutDate) > tasks.ReviewWindow {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the review window for this stay has closed"})
+		return
+	}
+
+	_, err = s.q.GetReviewByBookingID(c, sql.NullInt32{Int32: booking.ID, Valid: true})
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "this stay has already been reviewed"})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	_, err = s.q.CreateReview(c, db.CreateReviewParams{
-		UserID:    user.ID,
-		ListingID: request.ListingID,
-		Rating:    request.Rating,
-		Comment:   sql.NullString{String: request.Comment, Valid: true},
+	review, err := s.q.CreateReview(c, db.CreateReviewParams{
+		UserID:              user.ID,
+		ListingID:           booking.ListingID,
+		BookingID:           sql.NullInt32{Int32: booking.ID, Valid: true},
+		Rating:              request.Rating,
+		CleanlinessRating:   sql.NullInt32{Int32: request.Cleanliness, Valid: true},
+		AccuracyRating:      sql.NullInt32{Int32: request.Accuracy, Valid: true},
+		LocationRating:      sql.NullInt32{Int32: request.Location, Valid: true},
+		ValueRating:         sql.NullInt32{Int32: request.Value, Valid: true},
+		CommunicationRating: sql.NullInt32{Int32: request.Communication, Valid: true},
+		Comment:             sql.NullString{String: request.Comment, Valid: true},
+		CommentFingerprint:  sql.NullString{String: moderation.Fingerprint(request.Comment), Valid: true},
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	s.enqueueReviewModeration(review.ID)
+	s.refreshListingStats(review.ListingID)
+
+	if err := s.q.PublishBookingReviews(c, review.BookingID); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	review, err = s.q.GetReviewByBookingID(c, review.BookingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+type ratingBreakdown struct {
+	Cleanliness   float64 `json:"cleanliness"`
+	Accuracy      float64 `json:"accuracy"`
+	Location      float64 `json:"location"`
+	Value         float64 `json:"value"`
+	Communication float64 `json:"communication"`
+}
+
+type ratingSummary struct {
+	TotalReviews  int64            `json:"total_reviews"`
+	AverageRating float64          `json:"average_rating"`
+	Categories    ratingBreakdown  `json:"categories"`
+	Distribution  map[string]int64 `json:"distribution"`
+}
+
+func newRatingSummary(row db.GetListingRatingSummaryRow) ratingSummary {
+	return ratingSummary{
+		TotalReviews:  row.TotalReviews,
+		AverageRating: row.AverageRating,
+		Categories: ratingBreakdown{
+			Cleanliness:   row.Cleanliness,
+			Accuracy:      row.Accuracy,
+			Location:      row.Location,
+			Value:         row.Value,
+			Communication: row.Communication,
+		},
+		Distribution: map[string]int64{
+			"5": row.FiveStar,
+			"4": row.FourStar,
+			"3": row.ThreeStar,
+			"2": row.TwoStar,
+			"1": row.OneStar,
+		},
+	}
+}
+
+func newListingReview(row db.GetListingReviewsRow) Review {
+	review := Review{
+		ID:            row.ID,
+		Username:      row.Username,
+		Rating:        row.Rating,
+		Cleanliness:   row.CleanlinessRating.Int32,
+		Accuracy:      row.AccuracyRating.Int32,
+		Location:      row.LocationRating.Int32,
+		Value:         row.ValueRating.Int32,
+		Communication: row.CommunicationRating.Int32,
+		Comment:       row.Comment.String,
+		HostReply:     row.HostReply.String,
+		CreatedAt:     row.CreatedAt.Time.String(),
+	}
+	if row.HostRepliedAt.Valid {
+		review.HostRepliedAt = row.HostRepliedAt.Time.String()
+	}
+	return review
+}
+
+// listingReviews loads the public reviews of a listing together with their rating summary.
+func (s *Server) listingReviews(ctx context.Context, listingID int32) ([]Review, ratingSummary, error) {
+	rows, err := s.q.GetListingReviews(ctx, listingID)
+	if err != nil {
+		return nil, ratingSummary{}, err
+	}
+
+	summary, err := s.q.GetListingRatingSummary(ctx, listingID)
+	if err != nil {
+		return nil, ratingSummary{}, err
+	}
+
+	reviews := make([]Review, len(rows))
+	for i, row := range rows {
+		reviews[i] = newListingReview(row)
+	}
+	return reviews, newRatingSummary(summary), nil
+}
+
+// GetListingReviews returns the reviews of a listing with the average overall and
+// category ratings.
+func (s *Server) GetListingReviews(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	if _, err := s.q.GetListingByID(c, int32(id)); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	reviews, summary, err := s.listingReviews(c, int32(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summary, "reviews": reviews})
+}
+
+type replyToReviewRequest struct {
+	Reply string `json:"reply" binding:"required,max=2000"`
+}
 
+// ReplyToReview publishes the host's public reply to a published review of one of their
+// listings. Replying again replaces the previous reply; hidden and unpublished reviews
+// are not found.
+func (s *Server) ReplyToReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var req replyToReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Review created successfully"})
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized admin only"})
+		return
+	}
 
+	review, err := s.q.ReplyToReview(c, db.ReplyToReviewParams{
+		ID:        int32(id),
+		HostReply: sql.NullString{String: req.Reply, Valid: true},
+		AdminID:   admin.ID,
+		Roles:     team.RolesWith(team.Messaging),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
 }
 
 type getReviews struct {
@@ -136,6 +347,7 @@ func (s *Server) GetReviewsByListing(c *gin.Context) {
 	arg := db.GetReviewsByListingIDParams{
 		ListingID: req.ListingID,
 		AdminID:   admin.ID,
+		Roles:     team.RolesWith(team.ViewListings),
 	}
 
 	reviews, err := s.q.GetReviewsByListingID(c, arg)
@@ -188,11 +400,15 @@ func (s *Server) DeleteReview(c *gin.Context) {
 		UserID: user.ID,
 	}
 
-	err = s.q.DeleteReview(c, arg)
+	deleted, err := s.q.DeleteReview(c, arg)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	if deleted == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "published reviews of a stay cannot be deleted"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
 }
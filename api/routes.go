@@ -1,8 +1,11 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/weldonkipchirchir/rental_listing/middleware"
+	"github.com/weldonkipchirchir/rental_listing/storage"
 )
 
 func (server *Server) initAdminRoutes(router *gin.Engine) {
@@ -28,8 +31,10 @@ func (server *Server) initUserRoutes(router *gin.Engine) {
 func (server *Server) initListingRoutes(router *gin.Engine) {
 	router.GET("/api/listing/", server.GetAllListings)
 	router.GET("/api/listing/search", server.SearchListings)
+	router.GET("/api/listing/popular", server.GetPopularListings)
 	authRoutes := router.Group("/").Use(middleware.Authentication())
 	authRoutes.GET("/api/listing/user", server.GetListings)
+	authRoutes.GET("/api/listing/feed", server.GetListingFeed)
 	authRoutes.POST("/api/listing/create", server.CreateListing)
 	authRoutes.GET("/api/listing/:id", server.GetListingByID)
 	authRoutes.GET("/api/listing/admin/listing", server.GetAdminListings)
@@ -37,8 +42,44 @@ func (server *Server) initListingRoutes(router *gin.Engine) {
 	authRoutes.GET("/api/listing/admin/listing/:id", server.GetListingsByAdminID)
 	authRoutes.PUT("/api/listing/admin/listing/update/:id", server.UpdateListing)
 	authRoutes.DELETE("/api/listing/admin/listing/:id", server.deleteListing)
+	authRoutes.GET("/api/listing/admin/listing/deleted", server.GetDeletedListings)
+	authRoutes.POST("/api/listing/admin/listing/:id/restore", server.RestoreListing)
 	router.GET("/api/listings/:id/views", server.IncrementListingViews)
 	authRoutes.PUT("/api/listing/listing/status/:id", server.UpdateListingStatus)
+
+	authRoutes.GET("/api/listing/admin/listing/:id/checklist", server.GetListingChecklist)
+	authRoutes.PUT("/api/listing/admin/listing/:id/lifecycle", server.ChangeListingLifecycle)
+	authRoutes.GET("/api/listing/admin/listing/:id/revisions", server.GetListingRevisions)
+	authRoutes.GET("/api/listing/admin/listing/:id/revisions/:revision", server.GetListingRevision)
+	authRoutes.POST("/api/listing/admin/listing/:id/revisions/:revision/rollback", server.RollbackListingRevision)
+	authRoutes.GET("/api/admin/listings/review", server.GetListingReviewQueue)
+	authRoutes.PUT("/api/admin/listings/:id/review", server.ReviewListing)
+
+	router.GET("/api/listing/:id/calendar.ics", server.ExportListingCalendar)
+	authRoutes.POST("/api/listing/admin/listing/:id/calendar/token", server.RotateCalendarToken)
+	authRoutes.POST("/api/listing/admin/listing/:id/calendar/feeds", server.CreateCalendarFeed)
+	authRoutes.GET("/api/listing/admin/listing/:id/calendar/feeds", server.GetCalendarFeeds)
+	authRoutes.DELETE("/api/listing/admin/listing/:id/calendar/feeds/:feed_id", server.DeleteCalendarFeed)
+	authRoutes.POST("/api/listing/admin/listing/:id/calendar/blocks", server.CreateBlockedDate)
+	authRoutes.GET("/api/listing/admin/listing/:id/calendar/blocks", server.GetBlockedDates)
+	authRoutes.DELETE("/api/listing/admin/listing/:id/calendar/blocks/:block_id", server.DeleteBlockedDate)
+
+	router.GET("/api/listing/:id/reviews", server.GetListingReviews)
+	router.GET("/api/listing/:id/images", server.GetListingImages)
+	authRoutes.POST("/api/listing/admin/listing/:id/images", server.AddListingImages)
+	authRoutes.PUT("/api/listing/admin/listing/:id/images/order", server.ReorderListingImages)
+	authRoutes.PATCH("/api/listing/admin/listing/:id/images/:image_id", server.UpdateListingImage)
+	authRoutes.DELETE("/api/listing/admin/listing/:id/images/:image_id", server.DeleteListingImage)
+	authRoutes.PUT("/api/listing/admin/listing/:id/images/:image_id/cover", server.SetListingImageCover)
+	authRoutes.POST("/api/listing/admin/listing/:id/uploads", server.CreateUploadSession)
+	authRoutes.GET("/api/listing/admin/listing/:id/uploads/:session_id", server.GetUploadSession)
+	authRoutes.POST("/api/listing/admin/listing/:id/uploads/:session_id/complete", server.CompleteUploadSession)
+
+	router.GET("/api/listing/:id/price-calendar", server.GetListingPriceCalendar)
+	authRoutes.POST("/api/listing/admin/listing/:id/pricing", server.CreateListingPriceRule)
+	authRoutes.GET("/api/listing/admin/listing/:id/pricing", server.GetListingPriceRules)
+	authRoutes.PUT("/api/listing/admin/listing/:id/pricing/:rule_id", server.UpdateListingPriceRule)
+	authRoutes.DELETE("/api/listing/admin/listing/:id/pricing/:rule_id", server.DeleteListingPriceRule)
 }
 
 func (s *Server) initBookingRoutes(router *gin.Engine) {
@@ -52,6 +93,11 @@ func (s *Server) initBookingRoutes(router *gin.Engine) {
 	authRoutes.PUT("/admin/bookings/:id", s.UpdateBookingStatus)
 	authRoutes.PUT("/user/bookings/:id", s.updateCancelledBooking)
 	authRoutes.GET("/admin/bookings/listing/:id", s.GetBookingsByListingID)
+
+	authRoutes.POST("/user/bookings/:id/modifications", s.CreateBookingModification)
+	authRoutes.GET("/user/bookings/:id/modifications", s.GetUserBookingModifications)
+	authRoutes.GET("/admin/bookings/:id/modifications", s.GetAdminBookingModifications)
+	authRoutes.PUT("/admin/modifications/:id", s.ResolveBookingModification)
 }
 
 func (s *Server) initFavoriteRoutes(router *gin.Engine) {
@@ -60,6 +106,29 @@ func (s *Server) initFavoriteRoutes(router *gin.Engine) {
 	authRoutes.GET("/user/favorites", s.GetAllFavorites)
 	authRoutes.DELETE("/user/favorite/:id", s.DeleteFavorite)
 	authRoutes.GET("/favorite/search", s.SearchFavorite)
+
+	router.GET("/api/wishlists/shared/:token", s.GetSharedWishlist)
+	authRoutes.POST("/user/wishlists", s.CreateWishlist)
+	authRoutes.GET("/user/wishlists", s.GetUserWishlists)
+	authRoutes.GET("/user/wishlists/:id", s.GetWishlist)
+	authRoutes.PUT("/user/wishlists/:id", s.UpdateWishlist)
+	authRoutes.DELETE("/user/wishlists/:id", s.DeleteWishlist)
+	authRoutes.POST("/user/wishlists/:id/items", s.AddWishlistItem)
+	authRoutes.PUT("/user/wishlists/:id/items/:item_id", s.UpdateWishlistItem)
+	authRoutes.DELETE("/user/wishlists/:id/items/:item_id", s.DeleteWishlistItem)
+	authRoutes.POST("/user/wishlists/:id/share", s.ShareWishlist)
+	authRoutes.DELETE("/user/wishlists/:id/share", s.UnshareWishlist)
+	authRoutes.POST("/user/wishlists/:id/collaborators", s.AddWishlistCollaborator)
+	authRoutes.DELETE("/user/wishlists/:id/collaborators/:user_id", s.RemoveWishlistCollaborator)
+
+	authRoutes.POST("/user/saved-searches", s.CreateSavedSearch)
+	authRoutes.GET("/user/saved-searches", s.GetSavedSearches)
+	authRoutes.PUT("/user/saved-searches/:id", s.UpdateSavedSearch)
+	authRoutes.DELETE("/user/saved-searches/:id", s.DeleteSavedSearch)
+	authRoutes.GET("/user/alerts", s.GetListingAlerts)
+	authRoutes.PUT("/user/alerts/:id/read", s.MarkListingAlertRead)
+	authRoutes.GET("/user/alert-preferences", s.GetAlertPreferences)
+	authRoutes.PUT("/user/alert-preferences", s.UpdateAlertPreferences)
 }
 
 func (s *Server) initReviewRoutes(router *gin.Engine) {
@@ -68,6 +137,21 @@ func (s *Server) initReviewRoutes(router *gin.Engine) {
 	authRoutes.GET("/user/review/:id", s.GetReviewByID)
 	authRoutes.GET("/user/review/listing", s.GetReviewsByListing)
 	authRoutes.DELETE("/user/review/:id", s.DeleteReview)
+	authRoutes.PUT("/admin/reviews/:id/reply", s.ReplyToReview)
+	authRoutes.POST("/admin/bookings/:id/guest-review", s.CreateGuestReview)
+	authRoutes.GET("/user/guest-reviews", s.GetUserGuestReviews)
+}
+
+func (s *Server) initModerationRoutes(router *gin.Engine) {
+	authRoutes := router.Group("/api").Use(middleware.Authentication())
+	authRoutes.POST("/user/reports", s.ReportUserContent)
+	authRoutes.POST("/admin/reports", s.ReportAdminContent)
+
+	authRoutes.GET("/admin/moderation/queue", s.GetModerationQueue)
+	authRoutes.GET("/admin/moderation/reports", s.GetContentReports)
+	authRoutes.PUT("/admin/moderation/reviews/:id", s.ModerateReview)
+	authRoutes.PUT("/admin/moderation/listings/:id", s.ModerateListing)
+	authRoutes.POST("/admin/moderation/listings/:id/restore", s.RestoreModeratedListing)
 }
 
 func (s *Server) initNotificationRoutes(router *gin.Engine) {
@@ -84,6 +168,20 @@ func (s *Server) initNotificationRoutes(router *gin.Engine) {
 	authRoutes.GET("/user/notification/unread", s.GetUserUnreadNotifications)
 	authRoutes.GET("/admin/notification/unread", s.GetAdminUnreadNotifications)
 	authRoutes.DELETE("/user/notification/:id", s.DeleteNotification)
+	authRoutes.GET("/notifications/stream", s.StreamNotifications)
+	authRoutes.GET("/user/notification-preferences", s.GetUserNotificationPreferences)
+	authRoutes.PUT("/user/notification-preferences", s.UpdateUserNotificationPreferences)
+	authRoutes.GET("/admin/notification-preferences", s.GetAdminNotificationPreferences)
+	authRoutes.PUT("/admin/notification-preferences", s.UpdateAdminNotificationPreferences)
+	authRoutes.GET("/user/notification-settings", s.GetUserNotificationSettings)
+	authRoutes.PUT("/user/notification-settings", s.UpdateUserNotificationSettings)
+	authRoutes.GET("/admin/notification-settings", s.GetAdminNotificationSettings)
+	authRoutes.PUT("/admin/notification-settings", s.UpdateAdminNotificationSettings)
+	authRoutes.POST("/user/push-subscriptions", s.CreateUserPushSubscription)
+	authRoutes.DELETE("/user/push-subscriptions", s.DeleteUserPushSubscription)
+	authRoutes.POST("/admin/push-subscriptions", s.CreateAdminPushSubscription)
+	authRoutes.DELETE("/admin/push-subscriptions", s.DeleteAdminPushSubscription)
+	router.GET("/api/notifications/push-key", s.GetPushKey)
 }
 
 func (server *Server) initVerifyRoutes(router *gin.Engine) {
@@ -102,4 +200,52 @@ func (s *Server) initPaymentRoutes(router *gin.Engine) {
 func (s *Server) initStatsRoutes(router *gin.Engine) {
 	authRoutes := router.Group("/api").Use(middleware.Authentication())
 	authRoutes.GET("/admin/stats", s.GetAllStats)
+	authRoutes.GET("/admin/stats/timeseries", s.GetStatsTimeseries)
+}
+
+// initMediaRoutes serves stored images from disk when the local image store is in use,
+// and receives direct uploads to presigned URLs.
+// Other stores serve their objects themselves.
+func (s *Server) initMediaRoutes(router *gin.Engine) {
+	local, ok := s.images.(*storage.Local)
+	if !ok {
+		return
+	}
+	media := gin.WrapH(http.StripPrefix("/media", local))
+	router.GET("/media/*key", media)
+	router.HEAD("/media/*key", media)
+	router.PUT("/media/*key", media)
+}
+
+func (s *Server) initOrganizationRoutes(router *gin.Engine) {
+	authRoutes := router.Group("/").Use(middleware.Authentication())
+	authRoutes.POST("/api/organizations", s.CreateOrganization)
+	authRoutes.GET("/api/organizations", s.GetOrganizations)
+	authRoutes.GET("/api/organizations/:id/members", s.GetOrganizationMembers)
+	authRoutes.PUT("/api/organizations/:id/members/:admin", s.UpdateOrganizationMember)
+	authRoutes.DELETE("/api/organizations/:id/members/:admin", s.RemoveOrganizationMember)
+	authRoutes.POST("/api/organizations/:id/invitations", s.InviteOrganizationMember)
+	authRoutes.GET("/api/organizations/:id/invitations", s.GetOrganizationInvitations)
+	authRoutes.DELETE("/api/organizations/:id/invitations/:invitation", s.DeleteOrganizationInvitation)
+	authRoutes.POST("/api/organization-invitations/:token/accept", s.AcceptOrganizationInvitation)
+	authRoutes.PUT("/api/listing/admin/listing/:id/organization", s.SetListingOrganization)
+}
+
+func (s *Server) initConversationRoutes(router *gin.Engine) {
+	authRoutes := router.Group("/").Use(middleware.Authentication())
+	authRoutes.POST("/api/conversations", s.OpenConversation)
+	authRoutes.GET("/api/conversations", s.GetConversations)
+	authRoutes.GET("/api/conversations/stream", s.StreamConversationEvents)
+	authRoutes.GET("/api/conversations/:id/messages", s.GetConversationMessages)
+	authRoutes.POST("/api/conversations/:id/messages", s.SendMessage)
+	authRoutes.POST("/api/conversations/:id/read", s.MarkConversationRead)
+	authRoutes.GET("/api/conversations/:id/attachments/:name", s.GetConversationAttachment)
+
+	authRoutes.POST("/api/admin/conversations", s.OpenHostConversation)
+	authRoutes.GET("/api/admin/conversations", s.GetHostConversations)
+	authRoutes.GET("/api/admin/conversations/stream", s.StreamHostConversationEvents)
+	authRoutes.GET("/api/admin/conversations/:id/messages", s.GetHostConversationMessages)
+	authRoutes.POST("/api/admin/conversations/:id/messages", s.SendHostMessage)
+	authRoutes.POST("/api/admin/conversations/:id/read", s.MarkHostConversationRead)
+	authRoutes.GET("/api/admin/conversations/:id/attachments/:name", s.GetHostConversationAttachment)
 }
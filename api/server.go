@@ -6,16 +6,30 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/hibiken/asynq"
+	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/imaging"
+	"github.com/weldonkipchirchir/rental_listing/mail"
+	"github.com/weldonkipchirchir/rental_listing/messaging"
 	"github.com/weldonkipchirchir/rental_listing/middleware"
+	"github.com/weldonkipchirchir/rental_listing/moderation"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+	"github.com/weldonkipchirchir/rental_listing/recommend"
+	"github.com/weldonkipchirchir/rental_listing/safehttp"
+	"github.com/weldonkipchirchir/rental_listing/storage"
 	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/views"
 )
 
 type Server struct {
@@ -23,8 +37,23 @@ type Server struct {
 	db         *sql.DB
 	q          *db.Queries
 	client     *asynq.Client
+	scheduler  *asynq.Scheduler
 	redis      *redis.Client
+	views      *views.RedisBuffer
+	messages   *messaging.RedisBroker
+	live       *realtime.Hub
+	payments   payment.Gateway
+	images     storage.ImageStore
 	httpServer *http.Server
+	// pushKey is the VAPID public key browsers subscribe to push notifications with. It is
+	// empty if push notifications are off.
+	pushKey string
+	// streams is done when the server shuts down, which ends the open event streams.
+	streams     context.Context
+	stopStreams context.CancelFunc
+	// listingApproval makes listings wait for a super-admin's approval before they are
+	// first published.
+	listingApproval bool
 }
 
 func NewServer() (*Server, error) {
@@ -38,7 +67,7 @@ func NewServer() (*Server, error) {
 		cors.Config{
 			AllowOrigins:     []string{"http://localhost:3000", "http://172.23.32.1:3000"},
 			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
+			AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Last-Event-ID"},
 			ExposeHeaders:    []string{"Content-Length"},
 			AllowCredentials: true,
 			MaxAge:           12 * time.Hour,
@@ -55,10 +84,21 @@ func NewServer() (*Server, error) {
 		return nil, err
 	}
 
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env file loaded: %v", err)
+	}
+
 	queries := db.New(dbInstance)
 	server := &Server{
-		db: dbInstance,
-		q:  queries,
+		db:       dbInstance,
+		q:        queries,
+		payments: payment.NewStripeGateway(os.Getenv("STRIPE_SECRET_KEY")),
+	}
+	if approval := os.Getenv("LISTING_APPROVAL_REQUIRED"); approval != "" {
+		server.listingApproval, err = strconv.ParseBool(approval)
+		if err != nil {
+			return nil, fmt.Errorf("LISTING_APPROVAL_REQUIRED: %w", err)
+		}
 	}
 
 	const redisAddr = "localhost:6379"
@@ -74,11 +114,81 @@ func NewServer() (*Server, error) {
 			DB:       0,
 		})
 	server.redis = redisClient
+	server.views = views.NewRedisBuffer(redisClient, views.DefaultWindow)
+	server.messages = messaging.NewRedisBroker(redisClient)
+	server.live = realtime.NewHub(redisClient)
+	server.streams, server.stopStreams = context.WithCancel(context.Background())
 
 	// Initialize task handlers
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(tasks.TypeVerificationEmail, tasks.HandleVerificationEmailTask)
 	mux.HandleFunc(tasks.TypeForgotPasswordEmail, tasks.HandleForgotPasswordEmailTask)
+	mux.HandleFunc(tasks.TypeTeamInvitationEmail, tasks.HandleTeamInvitationEmailTask)
+
+	notifyConfig := notify.ConfigFromEnv(os.Getenv)
+	notifiers, err := notify.NewNotifiers(notifyConfig, mail.NewEmailSender("Rental Listing", "weldonkipchirchir23@gmail.com", "bnylvpwgejjngcne"), &http.Client{Timeout: 30 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	server.pushKey = notifyConfig.VAPIDPublicKey
+
+	bookingNotifications := tasks.NewBookingNotifications(queries, client, server.live, notifiers, time.Now)
+	mux.HandleFunc(tasks.TypeBookingEvent, bookingNotifications.HandleBookingEventTask)
+	mux.HandleFunc(tasks.TypeCheckInReminders, bookingNotifications.HandleCheckInRemindersTask)
+	mux.HandleFunc(tasks.TypeNotificationDelivery, bookingNotifications.HandleNotificationDeliveryTask)
+
+	lifecycle := tasks.NewBookingLifecycle(queries, client, server.payments, server.live, time.Now, tasks.DefaultPendingHoldWindow)
+	mux.HandleFunc(tasks.TypeExpirePendingBookings, lifecycle.HandleExpirePendingBookingsTask)
+	mux.HandleFunc(tasks.TypeCompleteBookings, lifecycle.HandleCompleteBookingsTask)
+
+	calendarSync := tasks.NewCalendarSync(db.NewStore(dbInstance), safehttp.NewClient(30*time.Second), time.Now)
+	mux.HandleFunc(tasks.TypeSyncCalendars, calendarSync.HandleSyncCalendarsTask)
+
+	reviewPublisher := tasks.NewReviewPublisher(queries, time.Now)
+	mux.HandleFunc(tasks.TypePublishReviews, reviewPublisher.HandlePublishReviewsTask)
+
+	words, err := loadWordList(os.Getenv("MODERATION_WORDS_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	reviewModerator := tasks.NewReviewModerator(queries, client, moderation.Chain{
+		words,
+		moderation.LinkFilter{},
+		moderation.PhoneFilter{},
+		moderation.NewDuplicateFilter(tasks.NewReviewFingerprints(queries)),
+	}, time.Now)
+	mux.HandleFunc(tasks.TypeModerateReview, reviewModerator.HandleModerateReviewTask)
+	mux.HandleFunc(tasks.TypeRequeuePendingReviews, reviewModerator.HandleRequeuePendingReviewsTask)
+
+	statsRefresher := tasks.NewStatsRefresher(queries)
+	mux.HandleFunc(tasks.TypeRefreshListingStats, statsRefresher.HandleRefreshListingStatsTask)
+	mux.HandleFunc(tasks.TypeRefreshAllStats, statsRefresher.HandleRefreshAllStatsTask)
+
+	viewFlusher := tasks.NewViewFlusher(server.views, queries)
+	mux.HandleFunc(tasks.TypeFlushListingViews, viewFlusher.HandleFlushListingViewsTask)
+
+	listingAlerts := tasks.NewListingAlerts(queries, mail.NewEmailSender("Rental Listing", "weldonkipchirchir23@gmail.com", "bnylvpwgejjngcne"), time.Now)
+	mux.HandleFunc(tasks.TypePriceDropAlert, listingAlerts.HandlePriceDropAlertTask)
+	mux.HandleFunc(tasks.TypeListingAvailableAlert, listingAlerts.HandleListingAvailableAlertTask)
+	mux.HandleFunc(tasks.TypeListingMatchAlert, listingAlerts.HandleListingMatchAlertTask)
+	mux.HandleFunc(tasks.TypeSendAlertDigests, listingAlerts.HandleSendAlertDigestsTask)
+
+	imageConfig := storage.ConfigFromEnv(os.Getenv)
+	server.images, err = storage.New(imageConfig)
+	if err != nil {
+		return nil, err
+	}
+	imageProcessor := tasks.NewImageProcessor(queries, server.images, imaging.DefaultLimits, imaging.DefaultSizes, imageFormats(server.images))
+	mux.HandleFunc(tasks.TypeProcessListingImage, imageProcessor.HandleProcessListingImageTask)
+
+	imageCleanup := tasks.NewImageCleanup(queries, server.images, uploadStagingDir, time.Now, tasks.DefaultOrphanGracePeriod)
+	mux.HandleFunc(tasks.TypeCleanupImages, imageCleanup.HandleCleanupImagesTask)
+
+	listingPurger := tasks.NewListingPurger(queries, time.Now, tasks.DefaultListingRetention)
+	mux.HandleFunc(tasks.TypePurgeListings, listingPurger.HandlePurgeListingsTask)
+
+	recommendationTrainer := tasks.NewRecommendationTrainer(queries, time.Now, recommend.DefaultNeighbors)
+	mux.HandleFunc(tasks.TypeTrainRecommendations, recommendationTrainer.HandleTrainRecommendationsTask)
 
 	// Run Asynq background worker
 
@@ -99,16 +209,64 @@ func NewServer() (*Server, error) {
 		log.Println("Asynq server started successfully")
 	}()
 
+	// Register periodic booking lifecycle, check-in reminder, calendar sync, review publishing and moderation, stats, view flush,
+	// alert digest, recommendation training, image cleanup and listing purge tasks
+	scheduler := asynq.NewScheduler(asynq.RedisClientOpt{Addr: redisAddr}, nil)
+	if _, err := scheduler.Register("@every 5m", tasks.NewExpirePendingBookingsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 1h", tasks.NewCompleteBookingsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 1h", tasks.NewCheckInRemindersTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 30m", tasks.NewSyncCalendarsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 1h", tasks.NewPublishReviewsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 5m", tasks.NewRequeuePendingReviewsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 6h", tasks.NewRefreshAllStatsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 1m", tasks.NewFlushListingViewsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 5m", tasks.NewSendAlertDigestsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 6h", tasks.NewTrainRecommendationsTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 1h", tasks.NewCleanupImagesTask()); err != nil {
+		return nil, err
+	}
+	if _, err := scheduler.Register("@every 24h", tasks.NewPurgeListingsTask()); err != nil {
+		return nil, err
+	}
+	if err := scheduler.Start(); err != nil {
+		return nil, err
+	}
+	server.scheduler = scheduler
+
 	server.initAdminRoutes(router)
 	server.initUserRoutes(router)
 	server.initListingRoutes(router)
 	server.initBookingRoutes(router)
 	server.initFavoriteRoutes(router)
 	server.initReviewRoutes(router)
+	server.initModerationRoutes(router)
 	server.initNotificationRoutes(router)
 	server.initVerifyRoutes(router)
 	server.initPaymentRoutes(router)
 	server.initStatsRoutes(router)
+	server.initMediaRoutes(router)
+	server.initOrganizationRoutes(router)
+	server.initConversationRoutes(router)
 
 	server.router = router
 
@@ -124,6 +282,34 @@ func (server *Server) Start(address string) error {
 	return server.httpServer.ListenAndServe()
 }
 
+// Shutdown stops the scheduler and ends the open event streams, which would otherwise
+// keep their connections busy until ctx expires, before shutting down the HTTP server.
 func (server *Server) Shutdown(ctx context.Context) error {
+	server.scheduler.Shutdown()
+	server.stopStreams()
 	return server.httpServer.Shutdown(ctx)
 }
+
+// streamContext returns the context of a long-lived response, which is done when the
+// client disconnects or the server shuts down.
+func (server *Server) streamContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	stop := context.AfterFunc(server.streams, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// loadWordList reads the moderation word list from path, or returns the built-in list
+// when path is empty.
+func loadWordList(path string) (*moderation.WordList, error) {
+	if path == "" {
+		return moderation.DefaultWordList(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read moderation word list: %w", err)
+	}
+	return moderation.ParseWordList(string(data)), nil
+}
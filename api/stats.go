@@ -1,23 +1,37 @@
 package api
 
 import (
+	"database/sql"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/weldonkipchirchir/rental_listing/analytics"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+	"github.com/weldonkipchirchir/rental_listing/team"
 )
 
+// maxTimeseriesDays bounds the range of a stats time series.
+const maxTimeseriesDays = 731
+
 // Stat represents a statistics entity.
 type Stat struct {
-	ID            int32    `json:"id"`
-	Title         string   `json:"title"`
-	TotalViews    int32    `json:"total_views"`
-	TotalBookings int64    `json:"total_bookings"`
-	AverageRating *float64 `json:"average_rating"`
-	Income        *float64 `json:"income"`
+	ID            int32      `json:"id"`
+	Title         string     `json:"title"`
+	TotalViews    int32      `json:"total_views"`
+	UniqueViews   int32      `json:"unique_views"`
+	TotalBookings int32      `json:"total_bookings"`
+	AverageRating float64    `json:"average_rating"`
+	ReviewCount   int32      `json:"review_count"`
+	Income        float64    `json:"income"`
+	UpdatedAt     *time.Time `json:"updated_at"`
 }
 
-// GetAllStats retrieves all stat entries.
+// GetAllStats returns the aggregates of the admin's listings from the stats table.
 func (s *Server) GetAllStats(c *gin.Context) {
 	email, ok := c.Get("email")
 	if !ok {
@@ -30,7 +44,10 @@ func (s *Server) GetAllStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := s.q.Statistics(c, admin.ID)
+	stats, err := s.q.GetAdminListingStats(c, db.GetAdminListingStatsParams{
+		AdminID: admin.ID,
+		Roles:   team.RolesWith(team.ViewListings),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -38,43 +55,157 @@ func (s *Server) GetAllStats(c *gin.Context) {
 
 	statistics := make([]Stat, 0, len(stats))
 	for _, stat := range stats {
-		var avgRating *float64
-		if stat.AverageRating != nil {
-			switch v := stat.AverageRating.(type) {
-			case float64:
-				avgRating = &v
-			case []uint8:
-				f, err := strconv.ParseFloat(string(v), 64)
-				if err == nil {
-					avgRating = &f
-				}
-			}
-		}
-		if avgRating == nil {
-			defaultValue := 0.0
-			avgRating = &defaultValue
-		}
-		var income *float64
-		if stat.TotalConfirmedAmount != nil {
-			switch v := stat.TotalConfirmedAmount.(type) {
-			case float64:
-				income = &v
-			case []uint8:
-				f, err := strconv.ParseFloat(string(v), 64)
-				if err == nil {
-					income = &f
-				}
-			}
+		var updatedAt *time.Time
+		if stat.UpdatedAt.Valid {
+			updatedAt = &stat.UpdatedAt.Time
 		}
 		statistics = append(statistics, Stat{
 			ID:            stat.ID,
 			Title:         stat.Title,
-			TotalViews:    stat.TotalViews.Int32,
+			TotalViews:    stat.TotalViews,
+			UniqueViews:   stat.UniqueViews,
 			TotalBookings: stat.TotalBookings,
-			AverageRating: avgRating,
-			Income:        income,
+			AverageRating: stat.AverageRating,
+			ReviewCount:   stat.ReviewCount,
+			Income:        stat.Revenue,
+			UpdatedAt:     updatedAt,
 		})
 	}
 
 	c.JSON(http.StatusOK, statistics)
 }
+
+type statsTimeseriesResponse struct {
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Granularity string            `json:"granularity"`
+	ListingID   *int32            `json:"listing_id,omitempty"`
+	Series      []analytics.Point `json:"series"`
+}
+
+// GetStatsTimeseries returns the admin's views, bookings, occupancy, ADR, RevPAR and revenue
+// per day, week or month over [from, to), across all their listings or the one given by
+// listing_id. from and to default to the last 30 days; format=csv returns the series as a
+// CSV download.
+func (s *Server) GetStatsTimeseries(c *gin.Context) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+	admin, err := s.q.GetAdmin(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unauthorized admins only"})
+		return
+	}
+
+	granularity, err := analytics.ParseGranularity(c.Query("granularity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	to := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2006-01-02"})
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -30)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2006-01-02"})
+			return
+		}
+	}
+	if !to.After(from) || to.Sub(from) > maxTimeseriesDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from and at most two years later"})
+		return
+	}
+
+	arg := db.GetAdminDailyStatsParams{
+		FromDate: from,
+		ToDate:   to,
+		AdminID:  admin.ID,
+		Roles:    team.RolesWith(team.ViewListings),
+	}
+	var listingID *int32
+	if v := c.Query("listing_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+			return
+		}
+		listing, err := s.q.GetListingsByAdminID(c, db.GetListingsByAdminIDParams{
+			AdminID: admin.ID,
+			Roles:   arg.Roles,
+			ID:      int32(id),
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		listingID = &listing.ID
+		arg.ListingID = sql.NullInt32{Int32: listing.ID, Valid: true}
+	}
+
+	rows, err := s.q.GetAdminDailyStats(c, arg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	days := make([]analytics.DailyFacts, len(rows))
+	for i, row := range rows {
+		days[i] = analytics.DailyFacts{
+			Day:               row.Day,
+			ListingNights:     int(row.ListingNights),
+			Views:             int(row.Views),
+			UniqueViews:       int(row.UniqueViews),
+			BookingRequests:   int(row.BookingRequests),
+			ConfirmedBookings: int(row.ConfirmedBookings),
+			Cancellations:     int(row.Cancellations),
+			BookedNights:      int(row.BookedNights),
+			Revenue:           row.Revenue,
+		}
+	}
+	series := analytics.Series(days, granularity)
+
+	if c.Query("format") == "csv" {
+		filename := fmt.Sprintf("stats-%s-%s.csv", from.Format("20060102"), to.Format("20060102"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+		if err := analytics.WriteCSV(c.Writer, series); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, statsTimeseriesResponse{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		Granularity: string(granularity),
+		ListingID:   listingID,
+		Series:      series,
+	})
+}
+
+// refreshListingStats queues a recompute of the listing's stats after one of its bookings
+// or reviews changed. Failures are logged and do not fail the request; the periodic
+// recompute catches up.
+func (s *Server) refreshListingStats(listingID int32) {
+	task, err := tasks.NewRefreshListingStatsTask(listingID)
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		return
+	}
+	if _, err := s.client.Enqueue(task); err != nil {
+		log.Printf("Failed to enqueue task: %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/imaging"
+	"github.com/weldonkipchirchir/rental_listing/storage"
+	"github.com/weldonkipchirchir/rental_listing/tasks"
+)
+
+const (
+	// uploadSessionLifetime is how long files can be uploaded to a session. It is no
+	// longer than the orphan grace period, so uploads are not cleaned up while the
+	// session is open.
+	uploadSessionLifetime = tasks.DefaultOrphanGracePeriod
+	// uploadURLExpiry is how long an issued upload URL is valid. Polling the session
+	// issues fresh URLs for files still to be uploaded.
+	uploadURLExpiry = time.Hour
+)
+
+// directStore responds with an error and returns false if the image store cannot take
+// uploads directly from clients.
+func (s *Server) directStore(c *gin.Context) (storage.DirectStore, bool) {
+	direct, ok := s.images.(storage.DirectStore)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "direct uploads are not supported by the image store"})
+		return nil, false
+	}
+	return direct, true
+}
+
+type uploadFileRequest struct {
+	Filename string `json:"filename" binding:"required,max=255"`
+	Size     int64  `json:"size" binding:"required,min=1"`
+}
+
+type createUploadSessionRequest struct {
+	Files []uploadFileRequest `json:"files" binding:"required,min=1,dive"`
+}
+
+type directUploadResponse struct {
+	Method    string            `json:"method"`
+	Url       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type uploadSessionFileResponse struct {
+	Position    int32                 `json:"position"`
+	Filename    string                `json:"filename"`
+	Size        int64                 `json:"size"`
+	Status      string                `json:"status"`
+	Error       string                `json:"error,omitempty"`
+	ImageID     int32                 `json:"image_id,omitempty"`
+	ImageStatus string                `json:"image_status,omitempty"`
+	ImageUrl    string                `json:"image_url,omitempty"`
+	Upload      *directUploadResponse `json:"upload,omitempty"`
+}
+
+type uploadSessionResponse struct {
+	ID        int32 `json:"id"`
+	ListingID int32 `json:"listing_id"`
+	// Status is uploading while files are still to be uploaded, processing while
+	// attached images are being processed, then done, or expired.
+	Status    string                      `json:"status"`
+	ExpiresAt time.Time                   `json:"expires_at"`
+	Files     []uploadSessionFileResponse `json:"files"`
+}
+
+// newUploadSessionResponse describes the progress of a session, issuing fresh upload
+// URLs for the files still to be uploaded while it is open.
+func newUploadSessionResponse(ctx context.Context, direct storage.DirectStore, session db.UploadSession, files []db.GetUploadSessionFilesRow) (uploadSessionResponse, error) {
+	expired := !time.Now().Before(session.ExpiresAt)
+	rsp := uploadSessionResponse{
+		ID:        session.ID,
+		ListingID: session.ListingID,
+		Status:    "done",
+		ExpiresAt: session.ExpiresAt,
+		Files:     make([]uploadSessionFileResponse, len(files)),
+	}
+
+	for i, f := range files {
+		file := uploadSessionFileResponse{
+			Position:    f.Position,
+			Filename:    f.Filename,
+			Size:        f.Size,
+			Status:      f.Status,
+			Error:       f.Error.String,
+			ImageID:     f.ImageID.Int32,
+			ImageStatus: f.ImageStatus.String,
+			ImageUrl:    f.ImageUrl.String,
+		}
+		if f.ImageError.Valid {
+			file.Error = f.ImageError.String
+		}
+
+		switch {
+		case f.Status == "waiting":
+			rsp.Status = "uploading"
+			if !expired {
+				upload, err := direct.PresignUpload(ctx, f.ObjectKey, imaging.DefaultLimits.MaxBytes, min(uploadURLExpiry, time.Until(session.ExpiresAt)))
+				if err != nil {
+					return uploadSessionResponse{}, err
+				}
+				file.Upload = &directUploadResponse{
+					Method:    upload.Method,
+					Url:       upload.URL,
+					Headers:   upload.Headers,
+					Fields:    upload.Fields,
+					ExpiresAt: upload.ExpiresAt,
+				}
+			}
+		case f.ImageStatus.String == "pending" && rsp.Status == "done":
+			rsp.Status = "processing"
+		}
+		rsp.Files[i] = file
+	}
+
+	if expired && rsp.Status == "uploading" {
+		rsp.Status = "expired"
+	}
+	return rsp, nil
+}
+
+// CreateUploadSession starts a direct upload of images to one of the admin's listings.
+// Each file is uploaded straight to the image store with the returned upload, then the
+// session is completed to add the uploaded files to the listing.
+func (s *Server) CreateUploadSession(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+	direct, ok := s.directStore(c)
+	if !ok {
+		return
+	}
+
+	var req createUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	for _, file := range req.Files {
+		if file.Size > imaging.DefaultLimits.MaxBytes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %v", file.Filename, imaging.ErrTooLarge)})
+			return
+		}
+	}
+	if !s.checkImageLimit(c, listing.ID, len(req.Files)) {
+		return
+	}
+
+	session, err := s.q.CreateUploadSession(c, db.CreateUploadSessionParams{
+		ListingID: listing.ID,
+		AdminID:   listing.AdminID,
+		ExpiresAt: time.Now().Add(uploadSessionLifetime),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	keys := make([]string, len(req.Files))
+	for i, file := range req.Files {
+		// The client's file name is never used in the key.
+		keys[i] = fmt.Sprintf("uploads/%d/%s", session.ID, uuid.New().String())
+		if _, err := s.q.CreateUploadSessionFile(c, db.CreateUploadSessionFileParams{
+			SessionID: session.ID,
+			Position:  int32(i),
+			Filename:  file.Filename,
+			Size:      file.Size,
+			ObjectKey: keys[i],
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	// Uploads that are never completed are deleted by the cleanup task.
+	if err := s.q.RecordOrphanedImages(c, keys); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	s.respondUploadSession(c, http.StatusCreated, direct, session)
+}
+
+// uploadSession responds with an error and returns false unless the session in the URL
+// belongs to listingID.
+func (s *Server) uploadSession(c *gin.Context, listingID int32) (db.UploadSession, bool) {
+	sessionID, err := strconv.Atoi(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload session ID"})
+		return db.UploadSession{}, false
+	}
+
+	session, err := s.q.GetUploadSession(c, db.GetUploadSessionParams{
+		ID:        int32(sessionID),
+		ListingID: listingID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "upload session not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return db.UploadSession{}, false
+	}
+	return session, true
+}
+
+// GetUploadSession returns the progress of an upload session, with fresh upload URLs for
+// the files still to be uploaded so an interrupted upload can be resumed.
+func (s *Server) GetUploadSession(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+	direct, ok := s.directStore(c)
+	if !ok {
+		return
+	}
+	session, ok := s.uploadSession(c, listing.ID)
+	if !ok {
+		return
+	}
+
+	s.respondUploadSession(c, http.StatusOK, direct, session)
+}
+
+func (s *Server) respondUploadSession(c *gin.Context, status int, direct storage.DirectStore, session db.UploadSession) {
+	files, err := s.q.GetUploadSessionFiles(c, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp, err := newUploadSessionResponse(c, direct, session, files)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(status, rsp)
+}
+
+type completeUploadSessionRequest struct {
+	// Positions limits completion to these files; by default every uploaded file is
+	// added.
+	Positions []int32 `json:"positions"`
+}
+
+// CompleteUploadSession checks the files uploaded to a session and adds them to the
+// listing for processing. Files not uploaded yet stay waiting, so the session can be
+// completed again once they are.
+func (s *Server) CompleteUploadSession(c *gin.Context) {
+	listing, ok := s.adminListing(c)
+	if !ok {
+		return
+	}
+	direct, ok := s.directStore(c)
+	if !ok {
+		return
+	}
+	session, ok := s.uploadSession(c, listing.ID)
+	if !ok {
+		return
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "upload session has expired"})
+		return
+	}
+
+	var req completeUploadSessionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+	selected := make(map[int32]bool, len(req.Positions))
+	for _, position := range req.Positions {
+		selected[position] = true
+	}
+
+	files, err := s.q.GetUploadSessionFiles(c, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	existing, err := s.q.GetListingImages(c, listing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	room := maxListingImages - len(existing)
+
+	for _, file := range files {
+		if file.Status != "waiting" || (len(selected) > 0 && !selected[file.Position]) {
+			continue
+		}
+
+		size, err := direct.Stat(c, file.ObjectKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to check uploaded files"})
+			return
+		}
+
+		var reason string
+		switch {
+		case size > imaging.DefaultLimits.MaxBytes:
+			reason = imaging.ErrTooLarge.Error()
+		case room <= 0:
+			reason = fmt.Sprintf("a listing can have at most %d images", maxListingImages)
+		}
+		if reason != "" {
+			if err := s.rejectUpload(c, direct, file, reason); err != nil {
+				c.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			continue
+		}
+
+		attached, err := s.attachUpload(c, listing.ID, file)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if attached {
+			room--
+		}
+	}
+
+	s.respondUploadSession(c, http.StatusOK, direct, session)
+}
+
+// rejectUpload marks an uploaded file that cannot be added to the listing rejected and
+// deletes it.
+func (s *Server) rejectUpload(ctx context.Context, direct storage.DirectStore, file db.GetUploadSessionFilesRow, reason string) error {
+	if err := s.q.RejectUploadSessionFile(ctx, db.RejectUploadSessionFileParams{
+		SessionID: file.SessionID,
+		Position:  file.Position,
+		Error:     sql.NullString{String: reason, Valid: true},
+	}); err != nil {
+		return err
+	}
+	if err := direct.Delete(ctx, file.ObjectKey); err != nil {
+		// The cleanup task deletes it later.
+		log.Printf("Failed to delete rejected upload %s: %v", file.ObjectKey, err)
+	}
+	return nil
+}
+
+// attachUpload adds an uploaded file to the listing as a new image and queues it for
+// processing. It returns false if a concurrent completion attached the file first.
+func (s *Server) attachUpload(ctx context.Context, listingID int32, file db.GetUploadSessionFilesRow) (bool, error) {
+	img, err := s.q.CreateUploadedListingImage(ctx, db.CreateUploadedListingImageParams{
+		ListingID: listingID,
+		SourceKey: sql.NullString{String: file.ObjectKey, Valid: true},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := s.q.AttachUploadSessionFile(ctx, db.AttachUploadSessionFileParams{
+		SessionID: file.SessionID,
+		Position:  file.Position,
+		ImageID:   sql.NullInt32{Int32: img.ID, Valid: true},
+	})
+	if err == nil && rows == 0 {
+		_, err = s.q.DeleteListingImage(ctx, db.DeleteListingImageParams{ID: img.ID, ListingID: listingID})
+		return false, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	task, err := tasks.NewProcessListingImageTask(img.ID)
+	if err != nil {
+		log.Printf("Failed to create task: %v", err)
+		return true, nil
+	}
+	if _, err := s.client.Enqueue(task); err != nil {
+		log.Printf("Failed to enqueue task: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,617 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/util"
+	"github.com/weldonkipchirchir/rental_listing/workflow"
+)
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+type wishlistResponse struct {
+	ID            int32                  `json:"id"`
+	Name          string                 `json:"name"`
+	OwnerID       int32                  `json:"owner_id"`
+	OwnerUsername string                 `json:"owner_username"`
+	IsOwner       bool                   `json:"is_owner"`
+	ShareURL      string                 `json:"share_url,omitempty"`
+	ItemCount     int64                  `json:"item_count"`
+	Items         []wishlistItemResponse `json:"items,omitempty"`
+	Collaborators []wishlistCollaborator `json:"collaborators,omitempty"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+type wishlistItemResponse struct {
+	ID           int32    `json:"id"`
+	ListingID    int32    `json:"listing_id"`
+	Title        string   `json:"title"`
+	Price        string   `json:"price"`
+	Location     string   `json:"location"`
+	Available    bool     `json:"available"`
+	Imagelink    []string `json:"imagelink"`
+	Note         string   `json:"note,omitempty"`
+	CheckInDate  string   `json:"check_in_date,omitempty"`
+	CheckOutDate string   `json:"check_out_date,omitempty"`
+	AddedBy      string   `json:"added_by"`
+}
+
+type wishlistCollaborator struct {
+	UserID   int32  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+func formatOptionalDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format("2006-01-02")
+}
+
+func toNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func wishlistShareURL(c *gin.Context, token sql.NullString) string {
+	if !token.Valid {
+		return ""
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/wishlists/shared/%s", scheme, c.Request.Host, token.String)
+}
+
+// wishlistItems loads the items of a wishlist with their listings in one query.
+func (s *Server) wishlistItems(c *gin.Context, wishlistID int32) ([]wishlistItemResponse, error) {
+	rows, err := s.q.GetWishlistItems(c, wishlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]wishlistItemResponse, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, wishlistItemResponse{
+			ID:           row.ID,
+			ListingID:    row.ListingID,
+			Title:        row.Title,
+			Price:        row.Price,
+			Location:     row.Location.String,
+			Available:    row.Available.Bool,
+			Imagelink:    row.Imagelinks,
+			Note:         row.Note.String,
+			CheckInDate:  formatOptionalDate(row.CheckInDate),
+			CheckOutDate: formatOptionalDate(row.CheckOutDate),
+			AddedBy:      row.AddedByUsername,
+		})
+	}
+	return items, nil
+}
+
+// userWishlist loads the wishlist in the :id parameter for the signed-in user, who must own
+// it or, unless ownerOnly is set, collaborate on it. It writes the error response and
+// returns false otherwise.
+func (s *Server) userWishlist(c *gin.Context, ownerOnly bool) (db.User, db.GetWishlistForUserRow, bool) {
+	wishlistID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return db.User{}, db.GetWishlistForUserRow{}, false
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return db.User{}, db.GetWishlistForUserRow{}, false
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return db.User{}, db.GetWishlistForUserRow{}, false
+	}
+
+	wishlist, err := s.q.GetWishlistForUser(c, db.GetWishlistForUserParams{
+		ID:     int32(wishlistID),
+		UserID: user.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wishlist not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return db.User{}, db.GetWishlistForUserRow{}, false
+	}
+
+	if ownerOnly && wishlist.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the owner can change this wishlist"})
+		return db.User{}, db.GetWishlistForUserRow{}, false
+	}
+
+	return user, wishlist, true
+}
+
+type wishlistRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// CreateWishlist creates a named wishlist for the signed-in user.
+func (s *Server) CreateWishlist(c *gin.Context) {
+	var req wishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return
+	}
+
+	wishlist, err := s.q.CreateWishlist(c, db.CreateWishlistParams{
+		UserID: user.ID,
+		Name:   req.Name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "you already have a wishlist with this name"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, wishlistResponse{
+		ID:            wishlist.ID,
+		Name:          wishlist.Name,
+		OwnerID:       user.ID,
+		OwnerUsername: user.Username,
+		IsOwner:       true,
+		UpdatedAt:     wishlist.UpdatedAt,
+	})
+}
+
+// GetUserWishlists returns the wishlists the signed-in user owns or collaborates on.
+func (s *Server) GetUserWishlists(c *gin.Context) {
+	email, ok := c.Get("email")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is not found"})
+		return
+	}
+
+	user, err := s.q.GetUser(c, email.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorized users only"})
+		return
+	}
+
+	rows, err := s.q.GetUserWishlists(c, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	wishlists := make([]wishlistResponse, 0, len(rows))
+	for _, row := range rows {
+		response := wishlistResponse{
+			ID:            row.ID,
+			Name:          row.Name,
+			OwnerID:       row.UserID,
+			OwnerUsername: row.OwnerUsername,
+			IsOwner:       row.UserID == user.ID,
+			ItemCount:     row.ItemCount,
+			UpdatedAt:     row.UpdatedAt,
+		}
+		if response.IsOwner {
+			response.ShareURL = wishlistShareURL(c, row.ShareToken)
+		}
+		wishlists = append(wishlists, response)
+	}
+
+	c.JSON(http.StatusOK, wishlists)
+}
+
+// GetWishlist returns a wishlist with its items and collaborators.
+func (s *Server) GetWishlist(c *gin.Context) {
+	user, wishlist, ok := s.userWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	items, err := s.wishlistItems(c, wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rows, err := s.q.GetWishlistCollaborators(c, wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	collaborators := make([]wishlistCollaborator, 0, len(rows))
+	for _, row := range rows {
+		collaborators = append(collaborators, wishlistCollaborator{UserID: row.ID, Username: row.Username})
+	}
+
+	response := wishlistResponse{
+		ID:            wishlist.ID,
+		Name:          wishlist.Name,
+		OwnerID:       wishlist.UserID,
+		OwnerUsername: wishlist.OwnerUsername,
+		IsOwner:       wishlist.UserID == user.ID,
+		ItemCount:     int64(len(items)),
+		Items:         items,
+		Collaborators: collaborators,
+		UpdatedAt:     wishlist.UpdatedAt,
+	}
+	if response.IsOwner {
+		response.ShareURL = wishlistShareURL(c, wishlist.ShareToken)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateWishlist renames a wishlist.
+func (s *Server) UpdateWishlist(c *gin.Context) {
+	var req wishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, wishlist, ok := s.userWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	updated, err := s.q.UpdateWishlist(c, db.UpdateWishlistParams{
+		ID:     wishlist.ID,
+		UserID: user.ID,
+		Name:   req.Name,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "you already have a wishlist with this name"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlistResponse{
+		ID:            updated.ID,
+		Name:          updated.Name,
+		OwnerID:       user.ID,
+		OwnerUsername: user.Username,
+		IsOwner:       true,
+		ShareURL:      wishlistShareURL(c, updated.ShareToken),
+		UpdatedAt:     updated.UpdatedAt,
+	})
+}
+
+// DeleteWishlist deletes a wishlist and its items.
+func (s *Server) DeleteWishlist(c *gin.Context) {
+	user, wishlist, ok := s.userWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	if _, err := s.q.DeleteWishlist(c, db.DeleteWishlistParams{ID: wishlist.ID, UserID: user.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist deleted successfully"})
+}
+
+type wishlistItemRequest struct {
+	Note         string     `json:"note" binding:"max=1000"`
+	CheckInDate  *time.Time `json:"check_in_date"`
+	CheckOutDate *time.Time `json:"check_out_date"`
+}
+
+func (r wishlistItemRequest) validDates() bool {
+	if r.CheckInDate == nil && r.CheckOutDate == nil {
+		return true
+	}
+	return r.CheckInDate != nil && r.CheckOutDate != nil && r.CheckOutDate.After(*r.CheckInDate)
+}
+
+// AddWishlistItem adds a listing to a wishlist, with an optional note and planned dates.
+// The owner and collaborators can add items.
+func (s *Server) AddWishlistItem(c *gin.Context) {
+	var req struct {
+		ListingID int32 `json:"listing_id" binding:"required"`
+		wishlistItemRequest
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !req.validDates() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "check_in_date and check_out_date must be set together, with check-out after check-in"})
+		return
+	}
+
+	user, wishlist, ok := s.userWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	listing, err := s.q.GetListingByID(c, req.ListingID)
+	if err == nil && !workflow.Visible(listing.Status) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	item, err := s.q.CreateWishlistItem(c, db.CreateWishlistItemParams{
+		WishlistID:   wishlist.ID,
+		ListingID:    listing.ID,
+		AddedBy:      user.ID,
+		Note:         sql.NullString{String: req.Note, Valid: req.Note != ""},
+		CheckInDate:  toNullTime(req.CheckInDate),
+		CheckOutDate: toNullTime(req.CheckOutDate),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this listing is already in the wishlist"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateWishlistItem replaces the note and planned dates of a wishlist item.
+func (s *Server) UpdateWishlistItem(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req wishlistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !req.validDates() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "check_in_date and check_out_date must be set together, with check-out after check-in"})
+		return
+	}
+
+	_, wishlist, ok := s.userWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	item, err := s.q.UpdateWishlistItem(c, db.UpdateWishlistItemParams{
+		ID:           int32(itemID),
+		WishlistID:   wishlist.ID,
+		Note:         sql.NullString{String: req.Note, Valid: req.Note != ""},
+		CheckInDate:  toNullTime(req.CheckInDate),
+		CheckOutDate: toNullTime(req.CheckOutDate),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wishlist item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteWishlistItem removes an item from a wishlist.
+func (s *Server) DeleteWishlistItem(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	_, wishlist, ok := s.userWishlist(c, false)
+	if !ok {
+		return
+	}
+
+	rows, err := s.q.DeleteWishlistItem(c, db.DeleteWishlistItemParams{
+		ID:         int32(itemID),
+		WishlistID: wishlist.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "wishlist item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist item deleted successfully"})
+}
+
+// ShareWishlist creates a new read-only link to the wishlist, revoking any previous one.
+func (s *Server) ShareWishlist(c *gin.Context) {
+	user, wishlist, ok := s.userWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	token, err := util.SecureToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	shareToken := sql.NullString{String: token, Valid: true}
+	_, err = s.q.SetWishlistShareToken(c, db.SetWishlistShareTokenParams{
+		ID:         wishlist.ID,
+		UserID:     user.ID,
+		ShareToken: shareToken,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": wishlistShareURL(c, shareToken)})
+}
+
+// UnshareWishlist revokes the wishlist's read-only link.
+func (s *Server) UnshareWishlist(c *gin.Context) {
+	user, wishlist, ok := s.userWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	_, err := s.q.SetWishlistShareToken(c, db.SetWishlistShareTokenParams{
+		ID:     wishlist.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist is no longer shared"})
+}
+
+// GetSharedWishlist returns a shared wishlist and its items to anyone with the link.
+func (s *Server) GetSharedWishlist(c *gin.Context) {
+	wishlist, err := s.q.GetWishlistByShareToken(c, c.Param("token"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wishlist not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	items, err := s.wishlistItems(c, wishlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlistResponse{
+		ID:            wishlist.ID,
+		Name:          wishlist.Name,
+		OwnerID:       wishlist.UserID,
+		OwnerUsername: wishlist.OwnerUsername,
+		ItemCount:     int64(len(items)),
+		Items:         items,
+		UpdatedAt:     wishlist.UpdatedAt,
+	})
+}
+
+// AddWishlistCollaborator invites a user by email to edit the wishlist.
+func (s *Server) AddWishlistCollaborator(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, wishlist, ok := s.userWishlist(c, true)
+	if !ok {
+		return
+	}
+
+	invitee, err := s.q.GetUser(c, req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if invitee.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you already own this wishlist"})
+		return
+	}
+
+	err = s.q.AddWishlistCollaborator(c, db.AddWishlistCollaboratorParams{
+		WishlistID: wishlist.ID,
+		UserID:     invitee.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlistCollaborator{UserID: invitee.ID, Username: invitee.Username})
+}
+
+// RemoveWishlistCollaborator removes a collaborator from the wishlist. The owner can remove
+// anyone; a collaborator can only remove themselves.
+func (s *Server) RemoveWishlistCollaborator(c *gin.Context) {
+	collaboratorID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, wishlist, ok := s.userWishlist(c, false)
+	if !ok {
+		return
+	}
+	if wishlist.UserID != user.ID && int32(collaboratorID) != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the owner can remove other collaborators"})
+		return
+	}
+
+	rows, err := s.q.RemoveWishlistCollaborator(c, db.RemoveWishlistCollaboratorParams{
+		WishlistID: wishlist.ID,
+		UserID:     int32(collaboratorID),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collaborator not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator removed successfully"})
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bookings_check_out_date;
+DROP INDEX IF EXISTS idx_bookings_status;
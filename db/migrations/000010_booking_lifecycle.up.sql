@@ -0,0 +1,2 @@
+CREATE INDEX idx_bookings_status ON bookings(status);
+CREATE INDEX idx_bookings_check_out_date ON bookings(check_out_date);
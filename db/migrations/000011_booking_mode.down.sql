@@ -0,0 +1,6 @@
+ALTER TABLE bookings
+DROP COLUMN approval_deadline;
+
+ALTER TABLE listings
+DROP CONSTRAINT chk_listings_booking_mode,
+DROP COLUMN booking_mode;
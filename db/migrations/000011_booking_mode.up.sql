@@ -0,0 +1,6 @@
+ALTER TABLE listings
+ADD COLUMN booking_mode VARCHAR(20) NOT NULL DEFAULT 'instant',
+ADD CONSTRAINT chk_listings_booking_mode CHECK (booking_mode IN ('instant', 'request'));
+
+ALTER TABLE bookings
+ADD COLUMN approval_deadline TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS booking_modifications;
+
+ALTER TABLE bookings DROP COLUMN guests;
@@ -0,0 +1,24 @@
+ALTER TABLE bookings ADD COLUMN guests INT NOT NULL DEFAULT 1;
+
+CREATE TABLE booking_modifications (
+    id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL,
+    user_id INT NOT NULL,
+    check_in_date DATE NOT NULL,
+    check_out_date DATE NOT NULL,
+    guests INT NOT NULL,
+    previous_check_in_date DATE NOT NULL,
+    previous_check_out_date DATE NOT NULL,
+    previous_guests INT NOT NULL,
+    previous_amount DECIMAL(10, 2) NOT NULL,
+    new_amount DECIMAL(10, 2) NOT NULL,
+    price_difference DECIMAL(10, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    payment_intent_id VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_booking_modifications_booking_id ON booking_modifications(booking_id);
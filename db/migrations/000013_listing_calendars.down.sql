@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS blocked_dates;
+DROP TABLE IF EXISTS calendar_feeds;
+DROP TABLE IF EXISTS listing_calendar_tokens;
@@ -0,0 +1,35 @@
+CREATE TABLE listing_calendar_tokens (
+    listing_id INT PRIMARY KEY,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (listing_id) REFERENCES listings(id) ON DELETE CASCADE
+);
+
+CREATE TABLE calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    listing_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    url TEXT NOT NULL,
+    last_synced_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (listing_id) REFERENCES listings(id) ON DELETE CASCADE
+);
+
+CREATE TABLE blocked_dates (
+    id SERIAL PRIMARY KEY,
+    listing_id INT NOT NULL,
+    feed_id INT,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    summary VARCHAR(255),
+    uid VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (listing_id) REFERENCES listings(id) ON DELETE CASCADE,
+    FOREIGN KEY (feed_id) REFERENCES calendar_feeds(id) ON DELETE CASCADE,
+    CONSTRAINT chk_blocked_dates_range CHECK (end_date > start_date)
+);
+
+CREATE INDEX idx_calendar_feeds_listing_id ON calendar_feeds(listing_id);
+CREATE INDEX idx_blocked_dates_listing_id ON blocked_dates(listing_id, start_date);
+CREATE INDEX idx_blocked_dates_feed_id ON blocked_dates(feed_id);
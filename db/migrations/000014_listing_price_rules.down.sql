@@ -0,0 +1 @@
+DROP TABLE IF EXISTS listing_price_rules;
@@ -0,0 +1,22 @@
+CREATE TABLE listing_price_rules (
+    id SERIAL PRIMARY KEY,
+    listing_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    start_date DATE,
+    end_date DATE,
+    days_of_week INT[],
+    nightly_price DECIMAL(10, 2),
+    min_stay INT,
+    max_stay INT,
+    discount_percent DECIMAL(5, 2),
+    lead_days INT,
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (listing_id) REFERENCES listings(id) ON DELETE CASCADE,
+    CONSTRAINT chk_listing_price_rules_kind CHECK (kind IN ('override', 'season', 'weekday', 'last_minute', 'early_bird')),
+    CONSTRAINT chk_listing_price_rules_dates CHECK (start_date IS NULL OR end_date IS NULL OR end_date >= start_date),
+    CONSTRAINT chk_listing_price_rules_discount CHECK (discount_percent IS NULL OR (discount_percent > 0 AND discount_percent <= 100))
+);
+
+CREATE INDEX idx_listing_price_rules_listing_id ON listing_price_rules(listing_id);
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_reviews_listing_id;
+
+ALTER TABLE reviews
+    DROP CONSTRAINT IF EXISTS chk_reviews_ratings,
+    DROP CONSTRAINT IF EXISTS uq_reviews_booking_id,
+    DROP CONSTRAINT IF EXISTS fk_reviews_booking_id;
+
+ALTER TABLE reviews
+    DROP COLUMN booking_id,
+    DROP COLUMN cleanliness_rating,
+    DROP COLUMN accuracy_rating,
+    DROP COLUMN location_rating,
+    DROP COLUMN value_rating,
+    DROP COLUMN communication_rating,
+    DROP COLUMN host_reply,
+    DROP COLUMN host_replied_at;
@@ -0,0 +1,26 @@
+ALTER TABLE reviews
+    ADD COLUMN booking_id INT,
+    ADD COLUMN cleanliness_rating INT,
+    ADD COLUMN accuracy_rating INT,
+    ADD COLUMN location_rating INT,
+    ADD COLUMN value_rating INT,
+    ADD COLUMN communication_rating INT,
+    ADD COLUMN host_reply TEXT,
+    ADD COLUMN host_replied_at TIMESTAMP;
+
+ALTER TABLE reviews
+    ADD CONSTRAINT fk_reviews_booking_id FOREIGN KEY (booking_id) REFERENCES bookings(id) ON DELETE CASCADE,
+    ADD CONSTRAINT uq_reviews_booking_id UNIQUE (booking_id);
+
+-- Reviews written before ratings were validated are left as they are.
+ALTER TABLE reviews
+    ADD CONSTRAINT chk_reviews_ratings CHECK (
+        rating BETWEEN 1 AND 5
+        AND (cleanliness_rating IS NULL OR cleanliness_rating BETWEEN 1 AND 5)
+        AND (accuracy_rating IS NULL OR accuracy_rating BETWEEN 1 AND 5)
+        AND (location_rating IS NULL OR location_rating BETWEEN 1 AND 5)
+        AND (value_rating IS NULL OR value_rating BETWEEN 1 AND 5)
+        AND (communication_rating IS NULL OR communication_rating BETWEEN 1 AND 5)
+    ) NOT VALID;
+
+CREATE INDEX idx_reviews_listing_id ON reviews(listing_id);
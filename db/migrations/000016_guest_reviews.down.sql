@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS guest_reviews;
+
+ALTER TABLE reviews DROP COLUMN published_at;
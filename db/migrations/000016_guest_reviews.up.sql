@@ -0,0 +1,20 @@
+ALTER TABLE reviews ADD COLUMN published_at TIMESTAMP;
+
+-- Reviews written before double-blind publication are already public.
+UPDATE reviews SET published_at = COALESCE(created_at, NOW());
+
+CREATE TABLE guest_reviews (
+    id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL UNIQUE REFERENCES bookings(id) ON DELETE CASCADE,
+    admin_id INT NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    cleanliness_rating INT NOT NULL CHECK (cleanliness_rating BETWEEN 1 AND 5),
+    communication_rating INT NOT NULL CHECK (communication_rating BETWEEN 1 AND 5),
+    house_rules_rating INT NOT NULL CHECK (house_rules_rating BETWEEN 1 AND 5),
+    comment TEXT NOT NULL,
+    published_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_guest_reviews_user_id ON guest_reviews(user_id);
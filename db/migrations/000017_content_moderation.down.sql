@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS content_reports;
+
+DROP INDEX IF EXISTS idx_reviews_comment_fingerprint;
+
+ALTER TABLE reviews
+    DROP COLUMN moderation_status,
+    DROP COLUMN moderation_reason,
+    DROP COLUMN comment_fingerprint;
+
+DROP TABLE IF EXISTS super_admins;
@@ -0,0 +1,34 @@
+-- Super admins moderate reported content. Grant the role with
+-- INSERT INTO super_admins (admin_id) VALUES (<admin id>);
+CREATE TABLE super_admins (
+    admin_id INT PRIMARY KEY REFERENCES admins(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE reviews
+    ADD COLUMN moderation_status VARCHAR(20) NOT NULL DEFAULT 'visible'
+        CHECK (moderation_status IN ('visible', 'flagged', 'hidden')),
+    ADD COLUMN moderation_reason TEXT,
+    ADD COLUMN comment_fingerprint VARCHAR(64);
+
+CREATE INDEX idx_reviews_comment_fingerprint ON reviews(comment_fingerprint);
+
+CREATE TABLE content_reports (
+    id SERIAL PRIMARY KEY,
+    reporter_user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    reporter_admin_id INT REFERENCES admins(id) ON DELETE CASCADE,
+    review_id INT REFERENCES reviews(id) ON DELETE CASCADE,
+    listing_id INT REFERENCES listings(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('spam', 'offensive', 'inappropriate', 'fake', 'other')),
+    details TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolution VARCHAR(20),
+    resolved_by INT REFERENCES admins(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (num_nonnulls(reporter_user_id, reporter_admin_id) = 1),
+    CHECK (num_nonnulls(review_id, listing_id) = 1)
+);
+
+CREATE INDEX idx_content_reports_review_id ON content_reports(review_id) WHERE status = 'open';
+CREATE INDEX idx_content_reports_listing_id ON content_reports(listing_id) WHERE status = 'open';
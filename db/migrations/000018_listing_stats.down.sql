@@ -0,0 +1,12 @@
+DELETE FROM stats;
+
+CREATE INDEX idx_stats_listing_id ON stats(listing_id);
+
+ALTER TABLE stats
+    DROP CONSTRAINT uq_stats_listing_id,
+    DROP COLUMN review_count,
+    DROP COLUMN revenue,
+    DROP COLUMN updated_at,
+    ALTER COLUMN total_views DROP NOT NULL,
+    ALTER COLUMN total_bookings DROP NOT NULL,
+    ALTER COLUMN average_rating DROP NOT NULL;
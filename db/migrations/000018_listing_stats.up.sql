@@ -0,0 +1,28 @@
+-- The stats table was never written to, so it is rebuilt as one row of aggregates per listing.
+DELETE FROM stats;
+
+ALTER TABLE stats
+    ALTER COLUMN total_views SET NOT NULL,
+    ALTER COLUMN total_bookings SET NOT NULL,
+    ALTER COLUMN average_rating SET NOT NULL,
+    ADD COLUMN review_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN revenue DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD CONSTRAINT uq_stats_listing_id UNIQUE (listing_id);
+
+DROP INDEX IF EXISTS idx_stats_listing_id;
+
+INSERT INTO stats (listing_id, admin_id, total_views, total_bookings, revenue, average_rating, review_count)
+SELECT
+    l.id,
+    l.admin_id,
+    COALESCE(l.total_views, 0),
+    (SELECT COUNT(*) FROM bookings b
+     WHERE b.listing_id = l.id AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL),
+    (SELECT COALESCE(SUM(b.total_amount), 0) FROM bookings b
+     WHERE b.listing_id = l.id AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL),
+    (SELECT COALESCE(ROUND(AVG(r.rating)::numeric, 2), 0) FROM reviews r
+     WHERE r.listing_id = l.id AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'),
+    (SELECT COUNT(*) FROM reviews r
+     WHERE r.listing_id = l.id AND r.published_at IS NOT NULL AND r.moderation_status = 'visible')
+FROM listings l;
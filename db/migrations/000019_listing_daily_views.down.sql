@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bookings_listing_created_at;
+DROP TABLE IF EXISTS listing_daily_views;
@@ -0,0 +1,10 @@
+-- Views per listing and day, for the time-series analytics. listings.total_views and
+-- stats.total_views keep the lifetime count.
+CREATE TABLE listing_daily_views (
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    views INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (listing_id, day)
+);
+
+CREATE INDEX idx_bookings_listing_created_at ON bookings(listing_id, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE stats DROP COLUMN unique_views;
+
+ALTER TABLE listing_daily_views DROP COLUMN unique_views;
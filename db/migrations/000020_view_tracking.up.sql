@@ -0,0 +1,5 @@
+-- Listing views are buffered in Redis and flushed in batches. unique_views counts distinct
+-- visitors per day; the lifetime figure in stats is the sum of the daily ones.
+ALTER TABLE listing_daily_views ADD COLUMN unique_views INT NOT NULL DEFAULT 0;
+
+ALTER TABLE stats ADD COLUMN unique_views INT NOT NULL DEFAULT 0;
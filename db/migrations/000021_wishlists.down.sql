@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS wishlist_collaborators;
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS wishlists;
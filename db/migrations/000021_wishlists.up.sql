@@ -0,0 +1,33 @@
+-- Named wishlists of listings. A wishlist is shared read-only through its share_token and
+-- edited by its owner and the users invited as collaborators.
+CREATE TABLE wishlists (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    share_token VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE wishlist_items (
+    id SERIAL PRIMARY KEY,
+    wishlist_id INT NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    added_by INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    note TEXT,
+    check_in_date DATE,
+    check_out_date DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (wishlist_id, listing_id),
+    CHECK (check_out_date > check_in_date)
+);
+
+CREATE TABLE wishlist_collaborators (
+    wishlist_id INT NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (wishlist_id, user_id)
+);
+
+CREATE INDEX idx_wishlist_collaborators_user_id ON wishlist_collaborators(user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS listing_alerts;
+DROP TABLE IF EXISTS alert_preferences;
+DROP TABLE IF EXISTS saved_searches;
@@ -0,0 +1,44 @@
+-- Searches a user saved to be alerted about new listings that match them. Empty filters
+-- match everything.
+CREATE TABLE saved_searches (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    keyword VARCHAR(255) NOT NULL DEFAULT '',
+    location VARCHAR(255),
+    min_price DECIMAL(10, 2),
+    max_price DECIMAL(10, 2),
+    alerts_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (max_price >= min_price)
+);
+
+CREATE INDEX idx_saved_searches_user_id ON saved_searches(user_id);
+
+-- Which alerts a user gets and how often they are emailed. Users without a row get the
+-- column defaults. Alerts always show in-app; frequency only controls the digest email.
+CREATE TABLE alert_preferences (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    frequency VARCHAR(10) NOT NULL DEFAULT 'daily' CHECK (frequency IN ('instant', 'daily', 'weekly', 'never')),
+    price_drops BOOLEAN NOT NULL DEFAULT TRUE,
+    availability BOOLEAN NOT NULL DEFAULT TRUE,
+    saved_searches BOOLEAN NOT NULL DEFAULT TRUE,
+    last_digest_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- In-app alerts about listings. Alerts not yet emailed are sent in the user's next digest.
+CREATE TABLE listing_alerts (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    saved_search_id INT REFERENCES saved_searches(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('price_drop', 'available', 'new_match')),
+    message TEXT NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    emailed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_listing_alerts_user_id_created_at ON listing_alerts(user_id, created_at DESC);
+CREATE INDEX idx_listing_alerts_not_emailed ON listing_alerts(user_id) WHERE emailed_at IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS listing_similarities;
+DROP VIEW IF EXISTS listing_interactions;
@@ -0,0 +1,28 @@
+-- How strongly each user showed interest in each listing. Confirmed stays count most,
+-- then favorites, wishlist saves and good reviews.
+CREATE VIEW listing_interactions AS
+SELECT i.user_id, i.listing_id, SUM(i.weight)::float8 AS weight
+FROM (
+    SELECT f.user_id, f.listing_id, 3 AS weight FROM favorites f
+    UNION ALL
+    SELECT w.added_by, w.listing_id, 2 FROM wishlist_items w
+    UNION ALL
+    SELECT b.user_id, b.listing_id, 5 FROM bookings b
+    WHERE b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL
+    UNION ALL
+    SELECT r.user_id, r.listing_id, r.rating - 2 FROM reviews r
+    WHERE r.rating >= 4 AND r.moderation_status = 'visible'
+) i
+GROUP BY i.user_id, i.listing_id;
+
+-- Similar listings as trained by the recommendation task. Rows not refreshed by the
+-- latest run are deleted at the end of it.
+CREATE TABLE listing_similarities (
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    similar_listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    score FLOAT8 NOT NULL,
+    trained_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (listing_id, similar_listing_id)
+);
+
+CREATE INDEX idx_listing_similarities_similar_listing_id ON listing_similarities(similar_listing_id);
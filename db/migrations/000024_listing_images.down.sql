@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS listing_image_variants;
+DROP TABLE IF EXISTS listing_images;
@@ -0,0 +1,37 @@
+-- Listing photos. Uploads are staged on local disk and processed in the background;
+-- url is the large JPEG variant, and listings.imageLinks is kept in sync with the ready
+-- images for existing readers.
+CREATE TABLE listing_images (
+    id SERIAL PRIMARY KEY,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    staged_path TEXT,
+    url TEXT,
+    width INT,
+    height INT,
+    blurhash VARCHAR(64),
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP
+);
+
+CREATE INDEX idx_listing_images_listing_id ON listing_images(listing_id);
+
+-- The sizes and formats generated for each image. object_key identifies the object in
+-- the image store.
+CREATE TABLE listing_image_variants (
+    image_id INT NOT NULL REFERENCES listing_images(id) ON DELETE CASCADE,
+    name VARCHAR(20) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    object_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    PRIMARY KEY (image_id, name, format)
+);
+
+-- Images uploaded before processing existed are kept as they are.
+INSERT INTO listing_images (listing_id, status, url, processed_at)
+SELECT l.id, 'ready', t.link, NOW()
+FROM listings l, unnest(l.imageLinks) WITH ORDINALITY AS t(link, n)
+ORDER BY l.id, t.n;
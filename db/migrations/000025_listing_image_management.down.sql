@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS orphaned_images;
+
+ALTER TABLE listing_images
+    DROP COLUMN IF EXISTS is_cover,
+    DROP COLUMN IF EXISTS room,
+    DROP COLUMN IF EXISTS caption,
+    DROP COLUMN IF EXISTS position;
@@ -0,0 +1,26 @@
+-- Hosts arrange their photos: position orders them, and the cover, if set, is shown first.
+ALTER TABLE listing_images
+    ADD COLUMN position INT NOT NULL DEFAULT 0,
+    ADD COLUMN caption VARCHAR(200) NOT NULL DEFAULT '',
+    ADD COLUMN room VARCHAR(30) CHECK (room IN (
+        'living_room', 'bedroom', 'kitchen', 'bathroom', 'dining_room',
+        'workspace', 'exterior', 'pool', 'view', 'other'
+    )),
+    ADD COLUMN is_cover BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE listing_images i
+SET position = o.n
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY listing_id ORDER BY id) - 1 AS n
+    FROM listing_images
+) o
+WHERE i.id = o.id;
+
+-- Stored objects that may no longer be referenced, deleted from the image store by the
+-- cleanup task once they are old enough and no variant uses them.
+CREATE TABLE orphaned_images (
+    object_key TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_listing_image_variants_object_key ON listing_image_variants(object_key);
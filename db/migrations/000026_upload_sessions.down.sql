@@ -0,0 +1,4 @@
+ALTER TABLE listing_images DROP COLUMN IF EXISTS source_key;
+
+DROP TABLE IF EXISTS upload_session_files;
+DROP TABLE IF EXISTS upload_sessions;
@@ -0,0 +1,27 @@
+-- Direct uploads: the client uploads each file straight to the image store under
+-- object_key, then completes the session, which attaches the uploaded files to the
+-- listing for processing.
+CREATE TABLE upload_sessions (
+    id SERIAL PRIMARY KEY,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    admin_id INT NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_upload_sessions_listing_id ON upload_sessions(listing_id);
+
+CREATE TABLE upload_session_files (
+    session_id INT NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    object_key TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'attached', 'rejected')),
+    image_id INT REFERENCES listing_images(id) ON DELETE SET NULL,
+    error TEXT,
+    PRIMARY KEY (session_id, position)
+);
+
+-- The uploaded original of an image uploaded directly, deleted once processed.
+ALTER TABLE listing_images ADD COLUMN source_key TEXT;
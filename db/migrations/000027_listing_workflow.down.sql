@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS listing_revisions;
+
+DROP INDEX IF EXISTS idx_listings_status;
+
+ALTER TABLE listings
+    DROP COLUMN IF EXISTS published_at,
+    DROP COLUMN IF EXISTS submitted_at,
+    DROP COLUMN IF EXISTS review_note,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,33 @@
+-- Listings start as drafts and are only visible to guests once published. Listings that
+-- already exist were published when they were created.
+ALTER TABLE listings
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'pending_review', 'published', 'paused', 'archived')),
+    ADD COLUMN review_note TEXT,
+    ADD COLUMN submitted_at TIMESTAMP,
+    ADD COLUMN published_at TIMESTAMP;
+
+UPDATE listings SET published_at = COALESCE(created_at, NOW());
+
+ALTER TABLE listings ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE INDEX idx_listings_status ON listings(status);
+
+-- The content of a listing after each edit, numbered from 1 per listing.
+CREATE TABLE listing_revisions (
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    admin_id INT REFERENCES admins(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    price DECIMAL(10, 2) NOT NULL,
+    location VARCHAR(255),
+    booking_mode VARCHAR(20) NOT NULL,
+    note VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (listing_id, revision)
+);
+
+INSERT INTO listing_revisions (listing_id, revision, admin_id, title, description, price, location, booking_mode, note)
+SELECT id, 1, admin_id, title, description, price, location, booking_mode, 'Existing listing'
+FROM listings;
@@ -0,0 +1,10 @@
+ALTER TABLE bookings
+    DROP CONSTRAINT bookings_listing_id_fkey,
+    ADD CONSTRAINT bookings_listing_id_fkey FOREIGN KEY (listing_id) REFERENCES listings(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_listings_deleted_at;
+
+ALTER TABLE listings
+    DROP CONSTRAINT IF EXISTS listings_deleted_archived,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
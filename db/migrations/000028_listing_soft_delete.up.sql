@@ -0,0 +1,14 @@
+-- Deleted listings are archived and kept until the purge job removes them after the
+-- retention period. deleted_by is the host or moderator who deleted the listing.
+ALTER TABLE listings
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN deleted_by INT REFERENCES admins(id) ON DELETE SET NULL,
+    ADD CONSTRAINT listings_deleted_archived CHECK (deleted_at IS NULL OR status = 'archived');
+
+CREATE INDEX idx_listings_deleted_at ON listings(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Bookings, and the payments that belong to them, outlive their listing: a listing with
+-- bookings can no longer be deleted outright.
+ALTER TABLE bookings
+    DROP CONSTRAINT bookings_listing_id_fkey,
+    ADD CONSTRAINT bookings_listing_id_fkey FOREIGN KEY (listing_id) REFERENCES listings(id) ON DELETE RESTRICT;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_listings_organization_id;
+
+ALTER TABLE listings DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,38 @@
+-- Organisations let several hosts manage listings together. Each member has a role that
+-- decides what they can do with the organisation's listings.
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_by INT REFERENCES admins(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+    organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    admin_id INT NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'manager', 'cleaner', 'accountant')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, admin_id)
+);
+
+CREATE INDEX idx_organization_members_admin_id ON organization_members(admin_id);
+
+CREATE TABLE organization_invitations (
+    id SERIAL PRIMARY KEY,
+    organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'manager', 'cleaner', 'accountant')),
+    token VARCHAR(64) NOT NULL UNIQUE,
+    invited_by INT REFERENCES admins(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_organization_invitations_organization_id ON organization_invitations(organization_id);
+
+-- A listing with an organisation belongs to it rather than to admin_id, who stays the
+-- host of record.
+ALTER TABLE listings ADD COLUMN organization_id INT REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_listings_organization_id ON listings(organization_id);
@@ -0,0 +1,61 @@
+-- Conversations between a guest and the hosts of a listing, either about a booking or
+-- before booking. Each side's read marker is the last message it has read.
+CREATE TABLE conversations (
+    id SERIAL PRIMARY KEY,
+    listing_id INT NOT NULL REFERENCES listings(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id INT REFERENCES bookings(id) ON DELETE CASCADE,
+    guest_last_read_id INT NOT NULL DEFAULT 0,
+    host_last_read_id INT NOT NULL DEFAULT 0,
+    last_message_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX conversations_booking_id_key ON conversations(booking_id) WHERE booking_id IS NOT NULL;
+CREATE UNIQUE INDEX conversations_inquiry_key ON conversations(listing_id, user_id) WHERE booking_id IS NULL;
+CREATE INDEX idx_conversations_user_id ON conversations(user_id);
+CREATE INDEX idx_conversations_listing_id ON conversations(listing_id);
+
+-- Attachments are storage keys. The host who sent a message is kept for the team's
+-- benefit; the guest is always the conversation's user.
+CREATE TABLE messages (
+    id SERIAL PRIMARY KEY,
+    conversation_id INT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_role VARCHAR(10) NOT NULL CHECK (sender_role IN ('guest', 'host')),
+    sender_admin_id INT REFERENCES admins(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    attachments TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_messages_conversation_id ON messages(conversation_id, id);
+
+-- Carry over the messages guests and hosts sent each other as notifications.
+INSERT INTO conversations (listing_id, user_id, booking_id, created_at)
+SELECT b.listing_id, b.user_id, b.id, MIN(n.created_at)
+FROM notifications n
+JOIN bookings b ON b.id = n.booking_id
+WHERE n.sender_admin_id IS NOT NULL OR n.sender_user_id IS NOT NULL
+GROUP BY b.id;
+
+INSERT INTO messages (conversation_id, sender_role, sender_admin_id, body, created_at)
+SELECT c.id,
+    CASE WHEN n.sender_user_id IS NOT NULL THEN 'guest' ELSE 'host' END,
+    n.sender_admin_id,
+    CASE WHEN COALESCE(n.subject, '') = '' THEN n.message ELSE n.subject || E'\n\n' || n.message END,
+    COALESCE(n.created_at, CURRENT_TIMESTAMP)
+FROM notifications n
+JOIN conversations c ON c.booking_id = n.booking_id
+WHERE n.sender_admin_id IS NOT NULL OR n.sender_user_id IS NOT NULL
+ORDER BY n.created_at, n.id;
+
+UPDATE conversations c
+SET guest_last_read_id = m.last_id,
+    host_last_read_id = m.last_id,
+    last_message_at = m.last_at
+FROM (
+    SELECT conversation_id, MAX(id) AS last_id, MAX(created_at) AS last_at
+    FROM messages
+    GROUP BY conversation_id
+) m
+WHERE m.conversation_id = c.id;
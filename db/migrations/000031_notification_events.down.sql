@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS booking_reminders;
+DROP TABLE IF EXISTS notification_preferences;
+DROP INDEX IF EXISTS notifications_event_key;
+ALTER TABLE notifications DROP COLUMN IF EXISTS category;
@@ -0,0 +1,30 @@
+-- Notifications the platform sends on its own about a booking event have a category and
+-- no sender. Each recipient gets at most one per booking and category.
+ALTER TABLE notifications ADD COLUMN category VARCHAR(50);
+
+CREATE UNIQUE INDEX notifications_event_key
+    ON notifications(booking_id, category, COALESCE(user_id, 0), COALESCE(admin_id, 0))
+    WHERE category IS NOT NULL;
+
+-- The channels users and admins set for each category. Channels without a row are on.
+CREATE TABLE notification_preferences (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    admin_id INT REFERENCES admins(id) ON DELETE CASCADE,
+    category VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((user_id IS NULL) <> (admin_id IS NULL))
+);
+
+CREATE UNIQUE INDEX notification_preferences_user_key
+    ON notification_preferences(user_id, category, channel) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX notification_preferences_admin_key
+    ON notification_preferences(admin_id, category, channel) WHERE admin_id IS NOT NULL;
+
+-- Bookings whose guest has been reminded of the upcoming check-in.
+CREATE TABLE booking_reminders (
+    booking_id INT PRIMARY KEY REFERENCES bookings(id) ON DELETE CASCADE,
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
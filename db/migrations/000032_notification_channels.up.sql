@@ -0,0 +1,35 @@
+-- The phone number users and admins get text messages on, and the quiet hours during
+-- which text messages and push notifications wait. Quiet hours are minutes after
+-- midnight in time_zone.
+CREATE TABLE notification_settings (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    admin_id INT REFERENCES admins(id) ON DELETE CASCADE,
+    phone VARCHAR(20),
+    quiet_hours_start INT CHECK (quiet_hours_start BETWEEN 0 AND 1439),
+    quiet_hours_end INT CHECK (quiet_hours_end BETWEEN 0 AND 1439),
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((user_id IS NULL) <> (admin_id IS NULL)),
+    CHECK ((quiet_hours_start IS NULL) = (quiet_hours_end IS NULL))
+);
+
+CREATE UNIQUE INDEX notification_settings_user_key
+    ON notification_settings(user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX notification_settings_admin_key
+    ON notification_settings(admin_id) WHERE admin_id IS NOT NULL;
+
+-- Browsers subscribed to web push. A browser belongs to whoever subscribed it last.
+CREATE TABLE push_subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    admin_id INT REFERENCES admins(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh VARCHAR(255) NOT NULL,
+    auth VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((user_id IS NULL) <> (admin_id IS NULL))
+);
+
+CREATE INDEX idx_push_subscriptions_user_id ON push_subscriptions(user_id);
+CREATE INDEX idx_push_subscriptions_admin_id ON push_subscriptions(admin_id);
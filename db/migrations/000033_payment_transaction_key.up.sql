@@ -0,0 +1,12 @@
+-- A payment intent backs a single booking. Payments recorded before this was enforced
+-- keep the intent only on the first payment that used it.
+UPDATE payments p
+SET transaction_id = NULL
+WHERE transaction_id IS NOT NULL
+    AND EXISTS (
+        SELECT 1 FROM payments earlier
+        WHERE earlier.transaction_id = p.transaction_id AND earlier.id < p.id
+    );
+
+CREATE UNIQUE INDEX payments_transaction_id_key
+    ON payments(transaction_id) WHERE transaction_id IS NOT NULL;
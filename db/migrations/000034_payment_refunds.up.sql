@@ -0,0 +1,3 @@
+-- How much of a captured payment has been refunded, so later refunds only draw on the
+-- rest of it.
+ALTER TABLE payments ADD COLUMN refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+UPDATE reviews SET moderation_status = 'visible' WHERE moderation_status = 'pending';
+ALTER TABLE reviews DROP CONSTRAINT reviews_moderation_status_check;
+ALTER TABLE reviews ADD CONSTRAINT reviews_moderation_status_check
+    CHECK (moderation_status IN ('visible', 'flagged', 'hidden'));
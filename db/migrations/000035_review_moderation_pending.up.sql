@@ -0,0 +1,4 @@
+-- New reviews are pending until the content filter has screened them.
+ALTER TABLE reviews DROP CONSTRAINT reviews_moderation_status_check;
+ALTER TABLE reviews ADD CONSTRAINT reviews_moderation_status_check
+    CHECK (moderation_status IN ('pending', 'visible', 'flagged', 'hidden'));
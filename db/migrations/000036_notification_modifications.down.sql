@@ -0,0 +1,8 @@
+DELETE FROM notifications WHERE modification_id IS NOT NULL;
+
+DROP INDEX IF EXISTS notifications_event_key;
+CREATE UNIQUE INDEX notifications_event_key
+    ON notifications(booking_id, category, COALESCE(user_id, 0), COALESCE(admin_id, 0))
+    WHERE category IS NOT NULL;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS modification_id;
@@ -0,0 +1,8 @@
+-- A booking can be modified more than once, so notifications about a modification are
+-- kept apart from those about the booking's other modifications.
+ALTER TABLE notifications ADD COLUMN modification_id INT REFERENCES booking_modifications(id) ON DELETE CASCADE;
+
+DROP INDEX notifications_event_key;
+CREATE UNIQUE INDEX notifications_event_key
+    ON notifications(booking_id, category, COALESCE(modification_id, 0), COALESCE(user_id, 0), COALESCE(admin_id, 0))
+    WHERE category IS NOT NULL;
@@ -9,7 +9,116 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+const claimCheckInReminders = `-- name: ClaimCheckInReminders :many
+INSERT INTO booking_reminders (booking_id)
+SELECT b.id
+FROM bookings b
+WHERE b.status = 'confirmed' AND b.deleted_at IS NULL
+    AND b.check_in_date BETWEEN $1::date AND $2::date
+ON CONFLICT DO NOTHING
+RETURNING booking_id
+`
+
+type ClaimCheckInRemindersParams struct {
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+}
+
+// Records that the guests of the confirmed bookings checking in between from and to are
+// being reminded, and returns those bookings. Each booking is returned once.
+func (q *Queries) ClaimCheckInReminders(ctx context.Context, arg ClaimCheckInRemindersParams) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, claimCheckInReminders, arg.FromDate, arg.ToDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var booking_id int32
+		if err := rows.Scan(&booking_id); err != nil {
+			return nil, err
+		}
+		items = append(items, booking_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeFinishedBookings = `-- name: CompleteFinishedBookings :many
+WITH completed AS (
+    UPDATE bookings
+    SET status = 'completed'
+    WHERE status = 'confirmed'
+      AND deleted_at IS NULL
+      AND check_out_date < $1::timestamp
+    RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+), reopened AS (
+    UPDATE listings l
+    SET available = TRUE
+    WHERE l.id IN (SELECT listing_id FROM completed)
+      AND NOT EXISTS (
+          SELECT 1 FROM bookings b
+          WHERE b.listing_id = l.id
+            AND b.status IN ('pending', 'confirmed')
+            AND b.deleted_at IS NULL
+            AND b.id NOT IN (SELECT id FROM completed)
+      )
 )
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+FROM completed
+`
+
+type CompleteFinishedBookingsRow struct {
+	ID           int32          `json:"id"`
+	UserID       int32          `json:"user_id"`
+	ListingID    int32          `json:"listing_id"`
+	CheckInDate  time.Time      `json:"check_in_date"`
+	CheckOutDate time.Time      `json:"check_out_date"`
+	TotalAmount  string         `json:"total_amount"`
+	Status       sql.NullString `json:"status"`
+	CreatedAt    sql.NullTime   `json:"created_at"`
+}
+
+func (q *Queries) CompleteFinishedBookings(ctx context.Context, now time.Time) ([]CompleteFinishedBookingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, completeFinishedBookings, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CompleteFinishedBookingsRow
+	for rows.Next() {
+		var i CompleteFinishedBookingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ListingID,
+			&i.CheckInDate,
+			&i.CheckOutDate,
+			&i.TotalAmount,
+			&i.Status,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
 const countBookingsByUserID = `-- name: CountBookingsByUserID :one
 SELECT COUNT(*)
@@ -24,29 +133,64 @@ func (q *Queries) CountBookingsByUserID(ctx context.Context, userID int32) (int6
 	return count, err
 }
 
-const createBooking = `-- name: CreateBooking :one
-INSERT INTO bookings (user_id, listing_id, check_in_date, check_out_date, total_amount)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+const countOverlappingBookings = `-- name: CountOverlappingBookings :one
+SELECT COUNT(*)
+FROM bookings
+WHERE listing_id = $1
+  AND id <> $2
+  AND deleted_at IS NULL
+  AND status IN ('pending', 'confirmed')
+  AND check_in_date < $3
+  AND check_out_date > $4
 `
 
-type CreateBookingParams struct {
-	UserID       int32     `json:"user_id"`
+type CountOverlappingBookingsParams struct {
 	ListingID    int32     `json:"listing_id"`
-	CheckInDate  time.Time `json:"check_in_date"`
+	ID           int32     `json:"id"`
 	CheckOutDate time.Time `json:"check_out_date"`
-	TotalAmount  string    `json:"total_amount"`
+	CheckInDate  time.Time `json:"check_in_date"`
+}
+
+func (q *Queries) CountOverlappingBookings(ctx context.Context, arg CountOverlappingBookingsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOverlappingBookings,
+		arg.ListingID,
+		arg.ID,
+		arg.CheckOutDate,
+		arg.CheckInDate,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createBooking = `-- name: CreateBooking :one
+INSERT INTO bookings (user_id, listing_id, check_in_date, check_out_date, total_amount, status, approval_deadline, guests)
+VALUES ($1, $2, $3, $4, $5, COALESCE($6, 'pending'), $7, COALESCE($8, 1))
+RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, approval_deadline, guests, created_at
+`
+
+type CreateBookingParams struct {
+	UserID           int32          `json:"user_id"`
+	ListingID        int32          `json:"listing_id"`
+	CheckInDate      time.Time      `json:"check_in_date"`
+	CheckOutDate     time.Time      `json:"check_out_date"`
+	TotalAmount      string         `json:"total_amount"`
+	Status           sql.NullString `json:"status"`
+	ApprovalDeadline sql.NullTime   `json:"approval_deadline"`
+	Guests           sql.NullInt32  `json:"guests"`
 }
 
 type CreateBookingRow struct {
-	ID           int32          `json:"id"`
-	UserID       int32          `json:"user_id"`
-	ListingID    int32          `json:"listing_id"`
-	CheckInDate  time.Time      `json:"check_in_date"`
-	CheckOutDate time.Time      `json:"check_out_date"`
-	TotalAmount  string         `json:"total_amount"`
-	Status       sql.NullString `json:"status"`
-	CreatedAt    sql.NullTime   `json:"created_at"`
+	ID               int32          `json:"id"`
+	UserID           int32          `json:"user_id"`
+	ListingID        int32          `json:"listing_id"`
+	CheckInDate      time.Time      `json:"check_in_date"`
+	CheckOutDate     time.Time      `json:"check_out_date"`
+	TotalAmount      string         `json:"total_amount"`
+	Status           sql.NullString `json:"status"`
+	ApprovalDeadline sql.NullTime   `json:"approval_deadline"`
+	Guests           int32          `json:"guests"`
+	CreatedAt        sql.NullTime   `json:"created_at"`
 }
 
 func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (CreateBookingRow, error) {
@@ -56,6 +200,9 @@ func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (C
 		arg.CheckInDate,
 		arg.CheckOutDate,
 		arg.TotalAmount,
+		arg.Status,
+		arg.ApprovalDeadline,
+		arg.Guests,
 	)
 	var i CreateBookingRow
 	err := row.Scan(
@@ -66,6 +213,8 @@ func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (C
 		&i.CheckOutDate,
 		&i.TotalAmount,
 		&i.Status,
+		&i.ApprovalDeadline,
+		&i.Guests,
 		&i.CreatedAt,
 	)
 	return i, err
@@ -87,6 +236,215 @@ func (q *Queries) DeleteUserBooking(ctx context.Context, arg DeleteUserBookingPa
 	return err
 }
 
+const expireUnapprovedBookings = `-- name: ExpireUnapprovedBookings :many
+-- Bookings with a payment that is still authorized are left pending until the hold is
+-- released.
+WITH expired AS (
+    UPDATE bookings
+    SET status = 'expired'
+    WHERE status = 'pending'
+      AND deleted_at IS NULL
+      AND approval_deadline < $1::timestamp
+      AND NOT EXISTS (
+          SELECT 1 FROM payments p
+          WHERE p.booking_id = bookings.id AND p.status = 'authorized'
+      )
+    RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+), reopened AS (
+    UPDATE listings l
+    SET available = TRUE
+    WHERE l.id IN (SELECT listing_id FROM expired)
+      AND NOT EXISTS (
+          SELECT 1 FROM bookings b
+          WHERE b.listing_id = l.id
+            AND b.status IN ('pending', 'confirmed')
+            AND b.deleted_at IS NULL
+            AND b.id NOT IN (SELECT id FROM expired)
+      )
+)
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+FROM expired
+`
+
+type ExpireUnapprovedBookingsRow struct {
+	ID           int32          `json:"id"`
+	UserID       int32          `json:"user_id"`
+	ListingID    int32          `json:"listing_id"`
+	CheckInDate  time.Time      `json:"check_in_date"`
+	CheckOutDate time.Time      `json:"check_out_date"`
+	TotalAmount  string         `json:"total_amount"`
+	Status       sql.NullString `json:"status"`
+	CreatedAt    sql.NullTime   `json:"created_at"`
+}
+
+// Bookings with a payment that is still authorized are left pending until the hold is
+// released.
+func (q *Queries) ExpireUnapprovedBookings(ctx context.Context, now time.Time) ([]ExpireUnapprovedBookingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, expireUnapprovedBookings, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpireUnapprovedBookingsRow
+	for rows.Next() {
+		var i ExpireUnapprovedBookingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ListingID,
+			&i.CheckInDate,
+			&i.CheckOutDate,
+			&i.TotalAmount,
+			&i.Status,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const expireUnpaidPendingBookings = `-- name: ExpireUnpaidPendingBookings :many
+-- Listings no other booking holds are re-opened in the same statement, so a failure
+-- between the two cannot leave a listing closed.
+WITH expired AS (
+    UPDATE bookings b
+    SET status = 'expired'
+    WHERE b.status = 'pending'
+      AND b.deleted_at IS NULL
+      AND b.created_at < $1::timestamp
+      AND NOT EXISTS (
+          SELECT 1 FROM payments p
+          WHERE p.booking_id = b.id AND p.status IN ('succeeded', 'paid', 'authorized')
+      )
+    RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+), reopened AS (
+    UPDATE listings l
+    SET available = TRUE
+    WHERE l.id IN (SELECT listing_id FROM expired)
+      AND NOT EXISTS (
+          SELECT 1 FROM bookings b
+          WHERE b.listing_id = l.id
+            AND b.status IN ('pending', 'confirmed')
+            AND b.deleted_at IS NULL
+            AND b.id NOT IN (SELECT id FROM expired)
+      )
+)
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+FROM expired
+`
+
+type ExpireUnpaidPendingBookingsRow struct {
+	ID           int32          `json:"id"`
+	UserID       int32          `json:"user_id"`
+	ListingID    int32          `json:"listing_id"`
+	CheckInDate  time.Time      `json:"check_in_date"`
+	CheckOutDate time.Time      `json:"check_out_date"`
+	TotalAmount  string         `json:"total_amount"`
+	Status       sql.NullString `json:"status"`
+	CreatedAt    sql.NullTime   `json:"created_at"`
+}
+
+// Listings no other booking holds are re-opened in the same statement, so a failure
+// between the two cannot leave a listing closed.
+func (q *Queries) ExpireUnpaidPendingBookings(ctx context.Context, cutoff time.Time) ([]ExpireUnpaidPendingBookingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, expireUnpaidPendingBookings, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExpireUnpaidPendingBookingsRow
+	for rows.Next() {
+		var i ExpireUnpaidPendingBookingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ListingID,
+			&i.CheckInDate,
+			&i.CheckOutDate,
+			&i.TotalAmount,
+			&i.Status,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getBookingParties = `-- name: GetBookingParties :one
+SELECT
+    b.id,
+    b.listing_id,
+    l.title,
+    b.check_in_date,
+    b.check_out_date,
+    b.status,
+    b.total_amount,
+    b.approval_deadline,
+    b.user_id,
+    u.username AS user_username,
+    u.email AS user_email,
+    a.username AS admin_username,
+    a.email AS admin_email
+FROM bookings b
+JOIN listings l ON b.listing_id = l.id
+JOIN users u ON b.user_id = u.id
+JOIN admins a ON l.admin_id = a.id
+WHERE b.id = $1
+`
+
+type GetBookingPartiesRow struct {
+	ID               int32          `json:"id"`
+	ListingID        int32          `json:"listing_id"`
+	Title            string         `json:"title"`
+	CheckInDate      time.Time      `json:"check_in_date"`
+	CheckOutDate     time.Time      `json:"check_out_date"`
+	Status           sql.NullString `json:"status"`
+	TotalAmount      string         `json:"total_amount"`
+	ApprovalDeadline sql.NullTime   `json:"approval_deadline"`
+	UserID           int32          `json:"user_id"`
+	UserUsername     string         `json:"user_username"`
+	UserEmail        string         `json:"user_email"`
+	AdminUsername    string         `json:"admin_username"`
+	AdminEmail       string         `json:"admin_email"`
+}
+
+func (q *Queries) GetBookingParties(ctx context.Context, id int32) (GetBookingPartiesRow, error) {
+	row := q.db.QueryRowContext(ctx, getBookingParties, id)
+	var i GetBookingPartiesRow
+	err := row.Scan(
+		&i.ID,
+		&i.ListingID,
+		&i.Title,
+		&i.CheckInDate,
+		&i.CheckOutDate,
+		&i.Status,
+		&i.TotalAmount,
+		&i.ApprovalDeadline,
+		&i.UserID,
+		&i.UserUsername,
+		&i.UserEmail,
+		&i.AdminUsername,
+		&i.AdminEmail,
+	)
+	return i, err
+}
+
 const getBookingsByAdminID = `-- name: GetBookingsByAdminID :many
 SELECT 
     b.id, 
@@ -100,7 +458,9 @@ SELECT
     b.check_out_date, 
     b.total_amount, 
     b.status, 
-    b.created_at
+    b.created_at,
+    (SELECT COUNT(*) FROM guest_reviews g WHERE g.user_id = b.user_id AND g.published_at IS NOT NULL) AS guest_review_count,
+    (SELECT COALESCE(ROUND(AVG(g.rating)::numeric, 2), 0)::float8 FROM guest_reviews g WHERE g.user_id = b.user_id AND g.published_at IS NOT NULL) AS guest_rating
 FROM 
     bookings b
 JOIN 
@@ -108,28 +468,39 @@ JOIN
 JOIN 
     users u ON b.user_id = u.id  
 WHERE 
-    l.admin_id = $1 AND (b.status = 'completed' OR b.status = 'confirmed' OR b.status = 'pending')
+    ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
+    AND (b.status = 'completed' OR b.status = 'confirmed' OR b.status = 'pending')
 ORDER BY 
     b.created_at DESC
 `
 
+type GetBookingsByAdminIDParams struct {
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
+}
+
 type GetBookingsByAdminIDRow struct {
-	ID           int32          `json:"id"`
-	Title        string         `json:"title"`
-	Location     sql.NullString `json:"location"`
-	UserID       int32          `json:"user_id"`
-	UserUsername string         `json:"user_username"`
-	UserEmail    string         `json:"user_email"`
-	ListingID    int32          `json:"listing_id"`
-	CheckInDate  time.Time      `json:"check_in_date"`
-	CheckOutDate time.Time      `json:"check_out_date"`
-	TotalAmount  string         `json:"total_amount"`
-	Status       sql.NullString `json:"status"`
-	CreatedAt    sql.NullTime   `json:"created_at"`
+	ID               int32          `json:"id"`
+	Title            string         `json:"title"`
+	Location         sql.NullString `json:"location"`
+	UserID           int32          `json:"user_id"`
+	UserUsername     string         `json:"user_username"`
+	UserEmail        string         `json:"user_email"`
+	ListingID        int32          `json:"listing_id"`
+	CheckInDate      time.Time      `json:"check_in_date"`
+	CheckOutDate     time.Time      `json:"check_out_date"`
+	TotalAmount      string         `json:"total_amount"`
+	Status           sql.NullString `json:"status"`
+	CreatedAt        sql.NullTime   `json:"created_at"`
+	GuestReviewCount int64          `json:"guest_review_count"`
+	GuestRating      float64        `json:"guest_rating"`
 }
 
-func (q *Queries) GetBookingsByAdminID(ctx context.Context, adminID int32) ([]GetBookingsByAdminIDRow, error) {
-	rows, err := q.db.QueryContext(ctx, getBookingsByAdminID, adminID)
+func (q *Queries) GetBookingsByAdminID(ctx context.Context, arg GetBookingsByAdminIDParams) ([]GetBookingsByAdminIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBookingsByAdminID, arg.AdminID, pq.Array(arg.Roles))
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +521,8 @@ func (q *Queries) GetBookingsByAdminID(ctx context.Context, adminID int32) ([]Ge
 			&i.TotalAmount,
 			&i.Status,
 			&i.CreatedAt,
+			&i.GuestReviewCount,
+			&i.GuestRating,
 		); err != nil {
 			return nil, err
 		}
@@ -165,31 +538,37 @@ func (q *Queries) GetBookingsByAdminID(ctx context.Context, adminID int32) ([]Ge
 }
 
 const getBookingsByAdminIDByID = `-- name: GetBookingsByAdminIDByID :one
-SELECT b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.created_at
+SELECT b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.approval_deadline, b.created_at
 FROM bookings b
 JOIN listings l ON b.listing_id = l.id
-WHERE l.admin_id = $1 AND b.id = $2
+WHERE ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
+    AND b.id = $3
 ORDER BY b.created_at DESC
 `
 
 type GetBookingsByAdminIDByIDParams struct {
-	AdminID int32 `json:"admin_id"`
-	ID      int32 `json:"id"`
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
+	ID      int32    `json:"id"`
 }
 
 type GetBookingsByAdminIDByIDRow struct {
-	ID           int32          `json:"id"`
-	UserID       int32          `json:"user_id"`
-	ListingID    int32          `json:"listing_id"`
-	CheckInDate  time.Time      `json:"check_in_date"`
-	CheckOutDate time.Time      `json:"check_out_date"`
-	TotalAmount  string         `json:"total_amount"`
-	Status       sql.NullString `json:"status"`
-	CreatedAt    sql.NullTime   `json:"created_at"`
+	ID               int32          `json:"id"`
+	UserID           int32          `json:"user_id"`
+	ListingID        int32          `json:"listing_id"`
+	CheckInDate      time.Time      `json:"check_in_date"`
+	CheckOutDate     time.Time      `json:"check_out_date"`
+	TotalAmount      string         `json:"total_amount"`
+	Status           sql.NullString `json:"status"`
+	ApprovalDeadline sql.NullTime   `json:"approval_deadline"`
+	CreatedAt        sql.NullTime   `json:"created_at"`
 }
 
 func (q *Queries) GetBookingsByAdminIDByID(ctx context.Context, arg GetBookingsByAdminIDByIDParams) (GetBookingsByAdminIDByIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getBookingsByAdminIDByID, arg.AdminID, arg.ID)
+	row := q.db.QueryRowContext(ctx, getBookingsByAdminIDByID, arg.AdminID, pq.Array(arg.Roles), arg.ID)
 	var i GetBookingsByAdminIDByIDRow
 	err := row.Scan(
 		&i.ID,
@@ -199,6 +578,7 @@ func (q *Queries) GetBookingsByAdminIDByID(ctx context.Context, arg GetBookingsB
 		&i.CheckOutDate,
 		&i.TotalAmount,
 		&i.Status,
+		&i.ApprovalDeadline,
 		&i.CreatedAt,
 	)
 	return i, err
@@ -208,13 +588,18 @@ const getBookingsByListingID = `-- name: GetBookingsByListingID :many
 SELECT b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.created_at
 FROM bookings b
 JOIN listings l ON b.listing_id = l.id
-WHERE b.listing_id = $1 AND l.admin_id = $2
+WHERE b.listing_id = $1
+    AND ((l.organization_id IS NULL AND l.admin_id = $2)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $2 AND m.role = ANY($3::text[])))
 ORDER BY b.created_at DESC
 `
 
 type GetBookingsByListingIDParams struct {
-	ListingID int32 `json:"listing_id"`
-	AdminID   int32 `json:"admin_id"`
+	ListingID int32    `json:"listing_id"`
+	AdminID   int32    `json:"admin_id"`
+	Roles     []string `json:"roles"`
 }
 
 type GetBookingsByListingIDRow struct {
@@ -229,7 +614,7 @@ type GetBookingsByListingIDRow struct {
 }
 
 func (q *Queries) GetBookingsByListingID(ctx context.Context, arg GetBookingsByListingIDParams) ([]GetBookingsByListingIDRow, error) {
-	rows, err := q.db.QueryContext(ctx, getBookingsByListingID, arg.ListingID, arg.AdminID)
+	rows, err := q.db.QueryContext(ctx, getBookingsByListingID, arg.ListingID, arg.AdminID, pq.Array(arg.Roles))
 	if err != nil {
 		return nil, err
 	}
@@ -320,8 +705,43 @@ func (q *Queries) GetBookingsByUserID(ctx context.Context, userID int32) ([]GetB
 	return items, nil
 }
 
+const getUnapprovedBookings = `-- name: GetUnapprovedBookings :many
+-- Returns the pending bookings whose approval deadline has passed. Their payment holds are
+-- released before ExpireUnapprovedBookings expires them.
+SELECT id
+FROM bookings
+WHERE status = 'pending'
+  AND deleted_at IS NULL
+  AND approval_deadline < $1::timestamp
+`
+
+// Returns the pending bookings whose approval deadline has passed. Their payment holds are
+// released before ExpireUnapprovedBookings expires them.
+func (q *Queries) GetUnapprovedBookings(ctx context.Context, now time.Time) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getUnapprovedBookings, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserBookingByID = `-- name: GetUserBookingByID :one
-SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, guests, created_at
 FROM bookings
 WHERE id = $1 AND user_id = $2
 `
@@ -339,6 +759,7 @@ type GetUserBookingByIDRow struct {
 	CheckOutDate time.Time      `json:"check_out_date"`
 	TotalAmount  string         `json:"total_amount"`
 	Status       sql.NullString `json:"status"`
+	Guests       int32          `json:"guests"`
 	CreatedAt    sql.NullTime   `json:"created_at"`
 }
 
@@ -353,34 +774,52 @@ func (q *Queries) GetUserBookingByID(ctx context.Context, arg GetUserBookingByID
 		&i.CheckOutDate,
 		&i.TotalAmount,
 		&i.Status,
+		&i.Guests,
 		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const updateBookingStatusByIDAndAdminID = `-- name: UpdateBookingStatusByIDAndAdminID :exec
+const updateBookingStatusByIDAndAdminID = `-- name: UpdateBookingStatusByIDAndAdminID :execrows
 UPDATE bookings b
 SET status = COALESCE($1, status)
 FROM listings l
-WHERE b.listing_id = l.id AND b.id = $2 AND l.admin_id = $3
-RETURNING b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.created_at
+WHERE b.listing_id = l.id AND b.id = $2
+    AND ((l.organization_id IS NULL AND l.admin_id = $3)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $3 AND m.role = ANY($4::text[])))
+    AND b.status = $5
 `
 
 type UpdateBookingStatusByIDAndAdminIDParams struct {
-	Status  sql.NullString `json:"status"`
-	ID      int32          `json:"id"`
-	AdminID int32          `json:"admin_id"`
+	Status     sql.NullString `json:"status"`
+	ID         int32          `json:"id"`
+	AdminID    int32          `json:"admin_id"`
+	Roles      []string       `json:"roles"`
+	FromStatus sql.NullString `json:"from_status"`
 }
 
-func (q *Queries) UpdateBookingStatusByIDAndAdminID(ctx context.Context, arg UpdateBookingStatusByIDAndAdminIDParams) error {
-	_, err := q.db.ExecContext(ctx, updateBookingStatusByIDAndAdminID, arg.Status, arg.ID, arg.AdminID)
-	return err
+// Moves the booking on only if it still has from_status, so a booking the expiry jobs or
+// the guest changed in the meantime is left alone.
+func (q *Queries) UpdateBookingStatusByIDAndAdminID(ctx context.Context, arg UpdateBookingStatusByIDAndAdminIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateBookingStatusByIDAndAdminID,
+		arg.Status,
+		arg.ID,
+		arg.AdminID,
+		pq.Array(arg.Roles),
+		arg.FromStatus,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const updateBookingStatusByIDAndUserID = `-- name: UpdateBookingStatusByIDAndUserID :exec
+const updateBookingStatusByIDAndUserID = `-- name: UpdateBookingStatusByIDAndUserID :execrows
 UPDATE bookings
 SET status = 'cancelled'
-WHERE id = $1 AND user_id = $2
+WHERE id = $1 AND user_id = $2 AND status IN ('pending', 'confirmed')
 `
 
 type UpdateBookingStatusByIDAndUserIDParams struct {
@@ -388,7 +827,43 @@ type UpdateBookingStatusByIDAndUserIDParams struct {
 	UserID int32 `json:"user_id"`
 }
 
-func (q *Queries) UpdateBookingStatusByIDAndUserID(ctx context.Context, arg UpdateBookingStatusByIDAndUserIDParams) error {
-	_, err := q.db.ExecContext(ctx, updateBookingStatusByIDAndUserID, arg.ID, arg.UserID)
-	return err
+// Only bookings that are still pending or confirmed can be cancelled.
+func (q *Queries) UpdateBookingStatusByIDAndUserID(ctx context.Context, arg UpdateBookingStatusByIDAndUserIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateBookingStatusByIDAndUserID, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateBookingStay = `-- name: UpdateBookingStay :execrows
+UPDATE bookings
+SET check_in_date = $2,
+    check_out_date = $3,
+    guests = $4,
+    total_amount = $5
+WHERE id = $1 AND status = 'confirmed'
+`
+
+type UpdateBookingStayParams struct {
+	ID           int32     `json:"id"`
+	CheckInDate  time.Time `json:"check_in_date"`
+	CheckOutDate time.Time `json:"check_out_date"`
+	Guests       int32     `json:"guests"`
+	TotalAmount  string    `json:"total_amount"`
+}
+
+// Only confirmed bookings are moved to a new stay.
+func (q *Queries) UpdateBookingStay(ctx context.Context, arg UpdateBookingStayParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateBookingStay,
+		arg.ID,
+		arg.CheckInDate,
+		arg.CheckOutDate,
+		arg.Guests,
+		arg.TotalAmount,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
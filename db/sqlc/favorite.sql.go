@@ -131,6 +131,60 @@ func (q *Queries) GetFavoriteByListingID(ctx context.Context, arg GetFavoriteByL
 	return i, err
 }
 
+const getFavoriteListings = `-- name: GetFavoriteListings :many
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at
+FROM favorites f
+JOIN listings l ON l.id = f.listing_id
+WHERE f.user_id = $1 AND l.status = 'published' AND l.deleted_at IS NULL
+ORDER BY f.created_at DESC
+`
+
+type GetFavoriteListingsRow struct {
+	ID          int32          `json:"id"`
+	AdminID     int32          `json:"admin_id"`
+	Title       string         `json:"title"`
+	Description sql.NullString `json:"description"`
+	Price       string         `json:"price"`
+	Location    sql.NullString `json:"location"`
+	Available   sql.NullBool   `json:"available"`
+	Imagelinks  []string       `json:"imagelinks"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+}
+
+// Listings guests can no longer see are left out.
+func (q *Queries) GetFavoriteListings(ctx context.Context, userID int32) ([]GetFavoriteListingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFavoriteListings, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFavoriteListingsRow
+	for rows.Next() {
+		var i GetFavoriteListingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AdminID,
+			&i.Title,
+			&i.Description,
+			&i.Price,
+			&i.Location,
+			&i.Available,
+			pq.Array(&i.Imagelinks),
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getListingFavoriteByUser = `-- name: GetListingFavoriteByUser :one
 SELECT id, user_id, listing_id, created_at
 FROM favorites
@@ -160,7 +214,7 @@ FROM favorites f
 JOIN listings l ON f.listing_id = l.id
 WHERE 
     (l.title ILIKE '%' || $1 || '%' OR l.description ILIKE '%' || $1 || '%') 
-    AND l.available = TRUE AND f.user_id = $2
+    AND l.available = TRUE AND l.status = 'published' AND f.user_id = $2
 ORDER BY f.created_at DESC
 `
 
@@ -8,37 +8,43 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 const createListing = `-- name: CreateListing :one
-INSERT INTO listings (admin_id, title, description, price, location, available, imageLinks)
-VALUES ($1, $2, $3, $4, $5, $6, $7::text[]
+INSERT INTO listings (admin_id, title, description, price, location, available, imageLinks, booking_mode, organization_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7::text[], COALESCE($8, 'instant'), $9
 )
-RETURNING id, admin_id, title, description, price, location, available, imageLinks, created_at
+RETURNING id, admin_id, title, description, price, location, available, imageLinks, booking_mode, created_at, status, organization_id
 `
 
 type CreateListingParams struct {
-	AdminID     int32          `json:"admin_id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	Price       string         `json:"price"`
-	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Column7     []string       `json:"column_7"`
+	AdminID        int32          `json:"admin_id"`
+	Title          string         `json:"title"`
+	Description    sql.NullString `json:"description"`
+	Price          string         `json:"price"`
+	Location       sql.NullString `json:"location"`
+	Available      sql.NullBool   `json:"available"`
+	Column7        []string       `json:"column_7"`
+	BookingMode    sql.NullString `json:"booking_mode"`
+	OrganizationID sql.NullInt32  `json:"organization_id"`
 }
 
 type CreateListingRow struct {
-	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	Price       string         `json:"price"`
-	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Imagelinks  []string       `json:"imagelinks"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
+	ID             int32          `json:"id"`
+	AdminID        int32          `json:"admin_id"`
+	Title          string         `json:"title"`
+	Description    sql.NullString `json:"description"`
+	Price          string         `json:"price"`
+	Location       sql.NullString `json:"location"`
+	Available      sql.NullBool   `json:"available"`
+	Imagelinks     []string       `json:"imagelinks"`
+	BookingMode    string         `json:"booking_mode"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	Status         string         `json:"status"`
+	OrganizationID sql.NullInt32  `json:"organization_id"`
 }
 
 func (q *Queries) CreateListing(ctx context.Context, arg CreateListingParams) (CreateListingRow, error) {
@@ -50,6 +56,8 @@ func (q *Queries) CreateListing(ctx context.Context, arg CreateListingParams) (C
 		arg.Location,
 		arg.Available,
 		pq.Array(arg.Column7),
+		arg.BookingMode,
+		arg.OrganizationID,
 	)
 	var i CreateListingRow
 	err := row.Scan(
@@ -61,47 +69,48 @@ func (q *Queries) CreateListing(ctx context.Context, arg CreateListingParams) (C
 		&i.Location,
 		&i.Available,
 		pq.Array(&i.Imagelinks),
+		&i.BookingMode,
 		&i.CreatedAt,
+		&i.Status,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
-const deleteListing = `-- name: DeleteListing :exec
-DELETE FROM listings
-WHERE id = $1 AND admin_id = $2
+const getAdminListings = `-- name: GetAdminListings :many
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at, l.status, l.organization_id
+FROM listings l
+WHERE ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
+    AND l.deleted_at IS NULL
+ORDER BY l.created_at DESC
 `
 
-type DeleteListingParams struct {
-	ID      int32 `json:"id"`
-	AdminID int32 `json:"admin_id"`
+type GetAdminListingsParams struct {
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
 }
 
-func (q *Queries) DeleteListing(ctx context.Context, arg DeleteListingParams) error {
-	_, err := q.db.ExecContext(ctx, deleteListing, arg.ID, arg.AdminID)
-	return err
-}
-
-const getAdminListings = `-- name: GetAdminListings :many
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE admin_id = $1
-ORDER BY created_at DESC
-`
-
 type GetAdminListingsRow struct {
-	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	Price       string         `json:"price"`
-	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Imagelinks  []string       `json:"imagelinks"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
+	ID             int32          `json:"id"`
+	AdminID        int32          `json:"admin_id"`
+	Title          string         `json:"title"`
+	Description    sql.NullString `json:"description"`
+	Price          string         `json:"price"`
+	Location       sql.NullString `json:"location"`
+	Available      sql.NullBool   `json:"available"`
+	Imagelinks     []string       `json:"imagelinks"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	Status         string         `json:"status"`
+	OrganizationID sql.NullInt32  `json:"organization_id"`
 }
 
-func (q *Queries) GetAdminListings(ctx context.Context, adminID int32) ([]GetAdminListingsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getAdminListings, adminID)
+// Returns the listings the admin owns, and those of organisations where the admin has
+// one of roles.
+func (q *Queries) GetAdminListings(ctx context.Context, arg GetAdminListingsParams) ([]GetAdminListingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAdminListings, arg.AdminID, pq.Array(arg.Roles))
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +128,8 @@ func (q *Queries) GetAdminListings(ctx context.Context, adminID int32) ([]GetAdm
 			&i.Available,
 			pq.Array(&i.Imagelinks),
 			&i.CreatedAt,
+			&i.Status,
+			&i.OrganizationID,
 		); err != nil {
 			return nil, err
 		}
@@ -133,22 +144,88 @@ func (q *Queries) GetAdminListings(ctx context.Context, adminID int32) ([]GetAdm
 	return items, nil
 }
 
-const getListingByID = `-- name: GetListingByID :one
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE id = $1
+const getDeletedListings = `-- name: GetDeletedListings :many
+SELECT l.id, l.title, l.price, l.location, l.deleted_at,
+    COALESCE(l.deleted_by = l.admin_id, FALSE)::bool AS restorable,
+    EXISTS (SELECT 1 FROM bookings b WHERE b.listing_id = l.id)::bool AS has_bookings
+FROM listings l
+WHERE ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
+    AND l.deleted_at IS NOT NULL
+ORDER BY l.deleted_at DESC
 `
 
-type GetListingByIDRow struct {
+type GetDeletedListingsParams struct {
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
+}
+
+type GetDeletedListingsRow struct {
 	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
 	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
 	Price       string         `json:"price"`
 	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Imagelinks  []string       `json:"imagelinks"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
+	DeletedAt   sql.NullTime   `json:"deleted_at"`
+	Restorable  bool           `json:"restorable"`
+	HasBookings bool           `json:"has_bookings"`
+}
+
+// Returns the deleted listings the admin owns or can act for through roles, most recent
+// first. Only listings deleted by their host can be restored; listings with bookings are
+// never purged.
+func (q *Queries) GetDeletedListings(ctx context.Context, arg GetDeletedListingsParams) ([]GetDeletedListingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDeletedListings, arg.AdminID, pq.Array(arg.Roles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDeletedListingsRow
+	for rows.Next() {
+		var i GetDeletedListingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Price,
+			&i.Location,
+			&i.DeletedAt,
+			&i.Restorable,
+			&i.HasBookings,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getListingByID = `-- name: GetListingByID :one
+SELECT id, admin_id, title, description, price, location, available, imageLinks, booking_mode, created_at, status, published_at, organization_id
+FROM listings
+WHERE id = $1
+`
+
+type GetListingByIDRow struct {
+	ID             int32          `json:"id"`
+	AdminID        int32          `json:"admin_id"`
+	Title          string         `json:"title"`
+	Description    sql.NullString `json:"description"`
+	Price          string         `json:"price"`
+	Location       sql.NullString `json:"location"`
+	Available      sql.NullBool   `json:"available"`
+	Imagelinks     []string       `json:"imagelinks"`
+	BookingMode    string         `json:"booking_mode"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	Status         string         `json:"status"`
+	PublishedAt    sql.NullTime   `json:"published_at"`
+	OrganizationID sql.NullInt32  `json:"organization_id"`
 }
 
 func (q *Queries) GetListingByID(ctx context.Context, id int32) (GetListingByIDRow, error) {
@@ -163,28 +240,128 @@ func (q *Queries) GetListingByID(ctx context.Context, id int32) (GetListingByIDR
 		&i.Location,
 		&i.Available,
 		pq.Array(&i.Imagelinks),
+		&i.BookingMode,
 		&i.CreatedAt,
+		&i.Status,
+		&i.PublishedAt,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
+const getListingHostContacts = `-- name: GetListingHostContacts :many
+SELECT a.id, a.username, a.email
+FROM admins a
+WHERE a.id IN (
+    SELECT l.admin_id
+    FROM listings l
+    WHERE l.id = $1 AND l.organization_id IS NULL
+    UNION
+    SELECT m.admin_id
+    FROM listings l
+    JOIN organization_members m ON m.organization_id = l.organization_id
+    WHERE l.id = $1 AND m.role = ANY($2::text[]))
+ORDER BY a.id
+`
+
+type GetListingHostContactsParams struct {
+	ID    int32    `json:"id"`
+	Roles []string `json:"roles"`
+}
+
+type GetListingHostContactsRow struct {
+	ID       int32  `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// Returns the hosts of a listing, as GetListingHosts does, with their contact details.
+func (q *Queries) GetListingHostContacts(ctx context.Context, arg GetListingHostContactsParams) ([]GetListingHostContactsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getListingHostContacts, arg.ID, pq.Array(arg.Roles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetListingHostContactsRow
+	for rows.Next() {
+		var i GetListingHostContactsRow
+		if err := rows.Scan(&i.ID, &i.Username, &i.Email); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getListingHosts = `-- name: GetListingHosts :many
+SELECT l.admin_id
+FROM listings l
+WHERE l.id = $1 AND l.organization_id IS NULL
+UNION
+SELECT m.admin_id
+FROM listings l
+JOIN organization_members m ON m.organization_id = l.organization_id
+WHERE l.id = $1 AND m.role = ANY($2::text[])
+`
+
+type GetListingHostsParams struct {
+	ID    int32    `json:"id"`
+	Roles []string `json:"roles"`
+}
+
+// Returns the admins who host a listing: its admin if it is personal, or the members of
+// its organisation with one of roles.
+func (q *Queries) GetListingHosts(ctx context.Context, arg GetListingHostsParams) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getListingHosts, arg.ID, pq.Array(arg.Roles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var admin_id int32
+		if err := rows.Scan(&admin_id); err != nil {
+			return nil, err
+		}
+		items = append(items, admin_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getListings = `-- name: GetListings :many
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE available = TRUE
-ORDER BY created_at DESC
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at,
+    COALESCE(s.average_rating, 0)::float8 AS average_rating,
+    COALESCE(s.review_count, 0)::int AS review_count
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
+WHERE l.available = TRUE AND l.status = 'published'
+ORDER BY l.created_at DESC
 `
 
 type GetListingsRow struct {
-	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	Price       string         `json:"price"`
-	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Imagelinks  []string       `json:"imagelinks"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
+	ID            int32          `json:"id"`
+	AdminID       int32          `json:"admin_id"`
+	Title         string         `json:"title"`
+	Description   sql.NullString `json:"description"`
+	Price         string         `json:"price"`
+	Location      sql.NullString `json:"location"`
+	Available     sql.NullBool   `json:"available"`
+	Imagelinks    []string       `json:"imagelinks"`
+	CreatedAt     sql.NullTime   `json:"created_at"`
+	AverageRating float64        `json:"average_rating"`
+	ReviewCount   int32          `json:"review_count"`
 }
 
 func (q *Queries) GetListings(ctx context.Context) ([]GetListingsRow, error) {
@@ -206,6 +383,8 @@ func (q *Queries) GetListings(ctx context.Context) ([]GetListingsRow, error) {
 			&i.Available,
 			pq.Array(&i.Imagelinks),
 			&i.CreatedAt,
+			&i.AverageRating,
+			&i.ReviewCount,
 		); err != nil {
 			return nil, err
 		}
@@ -221,30 +400,41 @@ func (q *Queries) GetListings(ctx context.Context) ([]GetListingsRow, error) {
 }
 
 const getListingsByAdminID = `-- name: GetListingsByAdminID :one
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE admin_id = $1 AND id = $2
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at, l.status, l.review_note, l.published_at, l.organization_id
+FROM listings l
+WHERE ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
+    AND l.id = $3 AND l.deleted_at IS NULL
 `
 
 type GetListingsByAdminIDParams struct {
-	AdminID int32 `json:"admin_id"`
-	ID      int32 `json:"id"`
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
+	ID      int32    `json:"id"`
 }
 
 type GetListingsByAdminIDRow struct {
-	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	Price       string         `json:"price"`
-	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Imagelinks  []string       `json:"imagelinks"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
+	ID             int32          `json:"id"`
+	AdminID        int32          `json:"admin_id"`
+	Title          string         `json:"title"`
+	Description    sql.NullString `json:"description"`
+	Price          string         `json:"price"`
+	Location       sql.NullString `json:"location"`
+	Available      sql.NullBool   `json:"available"`
+	Imagelinks     []string       `json:"imagelinks"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	Status         string         `json:"status"`
+	ReviewNote     sql.NullString `json:"review_note"`
+	PublishedAt    sql.NullTime   `json:"published_at"`
+	OrganizationID sql.NullInt32  `json:"organization_id"`
 }
 
+// Returns a listing the admin owns, or that belongs to an organisation where the admin
+// has one of roles.
 func (q *Queries) GetListingsByAdminID(ctx context.Context, arg GetListingsByAdminIDParams) (GetListingsByAdminIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getListingsByAdminID, arg.AdminID, arg.ID)
+	row := q.db.QueryRowContext(ctx, getListingsByAdminID, arg.AdminID, pq.Array(arg.Roles), arg.ID)
 	var i GetListingsByAdminIDRow
 	err := row.Scan(
 		&i.ID,
@@ -256,15 +446,111 @@ func (q *Queries) GetListingsByAdminID(ctx context.Context, arg GetListingsByAdm
 		&i.Available,
 		pq.Array(&i.Imagelinks),
 		&i.CreatedAt,
+		&i.Status,
+		&i.ReviewNote,
+		&i.PublishedAt,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
+const getListingsPendingReview = `-- name: GetListingsPendingReview :many
+SELECT l.id, l.admin_id, a.username AS admin_username, l.title, l.description, l.price, l.location, l.submitted_at,
+    (SELECT COUNT(*) FROM listing_images i WHERE i.listing_id = l.id AND i.status = 'ready')::int AS photos
+FROM listings l
+JOIN admins a ON a.id = l.admin_id
+WHERE l.status = 'pending_review'
+ORDER BY l.submitted_at, l.id
+`
+
+type GetListingsPendingReviewRow struct {
+	ID            int32          `json:"id"`
+	AdminID       int32          `json:"admin_id"`
+	AdminUsername string         `json:"admin_username"`
+	Title         string         `json:"title"`
+	Description   sql.NullString `json:"description"`
+	Price         string         `json:"price"`
+	Location      sql.NullString `json:"location"`
+	SubmittedAt   sql.NullTime   `json:"submitted_at"`
+	Photos        int32          `json:"photos"`
+}
+
+func (q *Queries) GetListingsPendingReview(ctx context.Context) ([]GetListingsPendingReviewRow, error) {
+	rows, err := q.db.QueryContext(ctx, getListingsPendingReview)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetListingsPendingReviewRow
+	for rows.Next() {
+		var i GetListingsPendingReviewRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AdminID,
+			&i.AdminUsername,
+			&i.Title,
+			&i.Description,
+			&i.Price,
+			&i.Location,
+			&i.SubmittedAt,
+			&i.Photos,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPurgeableListings = `-- name: GetPurgeableListings :many
+SELECT l.id
+FROM listings l
+WHERE l.deleted_at < $1
+    AND NOT EXISTS (SELECT 1 FROM bookings b WHERE b.listing_id = l.id)
+ORDER BY l.deleted_at
+LIMIT $2
+`
+
+type GetPurgeableListingsParams struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	Limit     int32     `json:"limit"`
+}
+
+// Returns listings deleted before deleted_at that have no bookings, oldest first.
+func (q *Queries) GetPurgeableListings(ctx context.Context, arg GetPurgeableListingsParams) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getPurgeableListings, arg.DeletedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listingActiveBookingCount = `-- name: ListingActiveBookingCount :one
-SELECT COUNT(*) AS confirmed_count
+SELECT COUNT(*) AS active_count
 FROM bookings b
 JOIN listings l ON b.listing_id = l.id
-WHERE b.listing_id = $1 AND b.status = 'confirmed' AND l.admin_id = $2
+WHERE b.listing_id = $1 AND b.status IN ('pending', 'confirmed') AND l.admin_id = $2
 `
 
 type ListingActiveBookingCountParams struct {
@@ -272,32 +558,123 @@ type ListingActiveBookingCountParams struct {
 	AdminID   int32 `json:"admin_id"`
 }
 
+// Counts the pending and confirmed bookings of the listing.
 func (q *Queries) ListingActiveBookingCount(ctx context.Context, arg ListingActiveBookingCountParams) (int64, error) {
 	row := q.db.QueryRowContext(ctx, listingActiveBookingCount, arg.ListingID, arg.AdminID)
-	var confirmed_count int64
-	err := row.Scan(&confirmed_count)
-	return confirmed_count, err
+	var active_count int64
+	err := row.Scan(&active_count)
+	return active_count, err
+}
+
+const listingVisible = `-- name: ListingVisible :one
+SELECT EXISTS (
+    SELECT 1 FROM listings
+    WHERE id = $1 AND status = 'published' AND deleted_at IS NULL
+)
+`
+
+// Reports whether guests can see the listing.
+func (q *Queries) ListingVisible(ctx context.Context, id int32) (bool, error) {
+	row := q.db.QueryRowContext(ctx, listingVisible, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const lockListing = `-- name: LockListing :exec
+SELECT id FROM listings
+WHERE id = $1
+FOR UPDATE
+`
+
+// Locks the listing until the end of the transaction, so bookings of it are made one at
+// a time.
+func (q *Queries) LockListing(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, lockListing, id)
+	return err
+}
+
+const purgeListing = `-- name: PurgeListing :execrows
+DELETE FROM listings l
+WHERE l.id = $1 AND l.deleted_at IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM bookings b WHERE b.listing_id = l.id)
+`
+
+// Permanently removes a deleted listing unless it has gained bookings.
+func (q *Queries) PurgeListing(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeListing, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const reopenListingIfFree = `-- name: ReopenListingIfFree :exec
+UPDATE listings l
+SET available = TRUE
+WHERE l.id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM bookings b
+      WHERE b.listing_id = l.id
+        AND b.status IN ('pending', 'confirmed')
+        AND b.deleted_at IS NULL
+  )
+`
+
+func (q *Queries) ReopenListingIfFree(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, reopenListingIfFree, id)
+	return err
+}
+
+const restoreListing = `-- name: RestoreListing :execrows
+UPDATE listings l
+SET status = 'draft', review_note = NULL, deleted_at = NULL, deleted_by = NULL
+WHERE ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
+    AND l.id = $3 AND l.deleted_at IS NOT NULL AND l.deleted_by = l.admin_id
+`
+
+type RestoreListingParams struct {
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
+	ID      int32    `json:"id"`
+}
+
+// Brings back a listing its host deleted as a draft, which must be published again.
+func (q *Queries) RestoreListing(ctx context.Context, arg RestoreListingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreListing, arg.AdminID, pq.Array(arg.Roles), arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const searchListings = `-- name: SearchListings :many
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at,
+    COALESCE(s.average_rating, 0)::float8 AS average_rating,
+    COALESCE(s.review_count, 0)::int AS review_count
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
 WHERE 
-    (title ILIKE '%' || $1 || '%' OR description ILIKE '%' || $1 || '%') 
-    AND available = TRUE
-ORDER BY created_at DESC
+    (l.title ILIKE '%' || $1 || '%' OR l.description ILIKE '%' || $1 || '%') 
+    AND l.available = TRUE AND l.status = 'published'
+ORDER BY l.created_at DESC
 `
 
 type SearchListingsRow struct {
-	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
-	Title       string         `json:"title"`
-	Description sql.NullString `json:"description"`
-	Price       string         `json:"price"`
-	Location    sql.NullString `json:"location"`
-	Available   sql.NullBool   `json:"available"`
-	Imagelinks  []string       `json:"imagelinks"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
+	ID            int32          `json:"id"`
+	AdminID       int32          `json:"admin_id"`
+	Title         string         `json:"title"`
+	Description   sql.NullString `json:"description"`
+	Price         string         `json:"price"`
+	Location      sql.NullString `json:"location"`
+	Available     sql.NullBool   `json:"available"`
+	Imagelinks    []string       `json:"imagelinks"`
+	CreatedAt     sql.NullTime   `json:"created_at"`
+	AverageRating float64        `json:"average_rating"`
+	ReviewCount   int32          `json:"review_count"`
 }
 
 func (q *Queries) SearchListings(ctx context.Context, dollar_1 sql.NullString) ([]SearchListingsRow, error) {
@@ -319,6 +696,8 @@ func (q *Queries) SearchListings(ctx context.Context, dollar_1 sql.NullString) (
 			&i.Available,
 			pq.Array(&i.Imagelinks),
 			&i.CreatedAt,
+			&i.AverageRating,
+			&i.ReviewCount,
 		); err != nil {
 			return nil, err
 		}
@@ -333,71 +712,77 @@ func (q *Queries) SearchListings(ctx context.Context, dollar_1 sql.NullString) (
 	return items, nil
 }
 
-const statistics = `-- name: Statistics :many
-SELECT
-    l.id,
-    l.title,
-    l.total_views,
-    COUNT(DISTINCT b.id) AS total_bookings,
-    CASE 
-        WHEN COUNT(r.id) > 0 THEN ROUND(AVG(r.rating)::numeric, 2)
-        ELSE NULL
-    END AS average_rating,
-    COALESCE(SUM(CASE 
-        WHEN b.status IN ('confirmed', 'completed') THEN b.total_amount 
-        ELSE 0 
-    END), 0) AS total_confirmed_amount
-FROM
-    listings l
-LEFT JOIN
-    bookings b ON l.id = b.listing_id
-LEFT JOIN
-    reviews r ON l.id = r.listing_id
-WHERE
-    l.admin_id = $1
-GROUP BY
-    l.id, l.title, l.total_views
-ORDER BY
-    l.total_views DESC
+const setListingOrganization = `-- name: SetListingOrganization :execrows
+UPDATE listings
+SET organization_id = $1, admin_id = $2
+WHERE id = $3 AND deleted_at IS NULL
 `
 
-type StatisticsRow struct {
-	ID                   int32         `json:"id"`
-	Title                string        `json:"title"`
-	TotalViews           sql.NullInt32 `json:"total_views"`
-	TotalBookings        int64         `json:"total_bookings"`
-	AverageRating        interface{}   `json:"average_rating"`
-	TotalConfirmedAmount interface{}   `json:"total_confirmed_amount"`
+type SetListingOrganizationParams struct {
+	OrganizationID sql.NullInt32 `json:"organization_id"`
+	AdminID        int32         `json:"admin_id"`
+	ID             int32         `json:"id"`
 }
 
-func (q *Queries) Statistics(ctx context.Context, adminID int32) ([]StatisticsRow, error) {
-	rows, err := q.db.QueryContext(ctx, statistics, adminID)
+// Moves a listing into an organisation, or out of it to admin_id.
+func (q *Queries) SetListingOrganization(ctx context.Context, arg SetListingOrganizationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setListingOrganization, arg.OrganizationID, arg.AdminID, arg.ID)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
-	var items []StatisticsRow
-	for rows.Next() {
-		var i StatisticsRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.Title,
-			&i.TotalViews,
-			&i.TotalBookings,
-			&i.AverageRating,
-			&i.TotalConfirmedAmount,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+	return result.RowsAffected()
+}
+
+const softDeleteListing = `-- name: SoftDeleteListing :execrows
+UPDATE listings
+SET status = 'archived', deleted_at = NOW(), deleted_by = admin_id
+WHERE id = $1 AND admin_id = $2 AND deleted_at IS NULL
+`
+
+type SoftDeleteListingParams struct {
+	ID      int32 `json:"id"`
+	AdminID int32 `json:"admin_id"`
+}
+
+// Archives a listing and marks it deleted by its host. Its bookings, payments and reviews
+// are kept.
+func (q *Queries) SoftDeleteListing(ctx context.Context, arg SoftDeleteListingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteListing, arg.ID, arg.AdminID)
+	if err != nil {
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return result.RowsAffected()
+}
+
+const transitionListing = `-- name: TransitionListing :execrows
+UPDATE listings
+SET status = $1,
+    review_note = $2,
+    submitted_at = CASE WHEN $1 = 'pending_review' THEN NOW() ELSE submitted_at END,
+    published_at = CASE WHEN $1 = 'published' THEN COALESCE(published_at, NOW()) ELSE published_at END
+WHERE id = $3 AND status = $4 AND deleted_at IS NULL
+`
+
+type TransitionListingParams struct {
+	Status     string         `json:"status"`
+	ReviewNote sql.NullString `json:"review_note"`
+	ID         int32          `json:"id"`
+	FromStatus string         `json:"from_status"`
+}
+
+// Moves a listing to status unless it has left from_status meanwhile. The first
+// publication sets published_at.
+func (q *Queries) TransitionListing(ctx context.Context, arg TransitionListingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, transitionListing,
+		arg.Status,
+		arg.ReviewNote,
+		arg.ID,
+		arg.FromStatus,
+	)
+	if err != nil {
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected()
 }
 
 const updateListing = `-- name: UpdateListing :exec
@@ -408,9 +793,10 @@ SET
     price = COALESCE($3, price),
     location = COALESCE($4, location),
     available = COALESCE($5, available),
-    imageLinks = COALESCE($6, imageLinks)
-WHERE id = $7 AND admin_id = $8
-RETURNING id, admin_id, title, description, price, location, created_at, available, total_views, imagelinks
+    imageLinks = COALESCE($6, imageLinks),
+    booking_mode = COALESCE($7, booking_mode)
+WHERE id = $8 AND admin_id = $9
+RETURNING id, admin_id, title, description, price, location, created_at, available, total_views, imagelinks, booking_mode, status, review_note, submitted_at, published_at, deleted_at, deleted_by, organization_id
 `
 
 type UpdateListingParams struct {
@@ -420,6 +806,7 @@ type UpdateListingParams struct {
 	Location    sql.NullString `json:"location"`
 	Available   sql.NullBool   `json:"available"`
 	Imagelinks  []string       `json:"imagelinks"`
+	BookingMode sql.NullString `json:"booking_mode"`
 	ID          int32          `json:"id"`
 	AdminID     int32          `json:"admin_id"`
 }
@@ -432,6 +819,7 @@ func (q *Queries) UpdateListing(ctx context.Context, arg UpdateListingParams) er
 		arg.Location,
 		arg.Available,
 		pq.Array(arg.Imagelinks),
+		arg.BookingMode,
 		arg.ID,
 		arg.AdminID,
 	)
@@ -453,14 +841,3 @@ func (q *Queries) UpdateListingStatus(ctx context.Context, arg UpdateListingStat
 	_, err := q.db.ExecContext(ctx, updateListingStatus, arg.ID, arg.Available)
 	return err
 }
-
-const updateTotalViews = `-- name: UpdateTotalViews :exec
-UPDATE listings
-SET total_views = total_views + 1
-WHERE id = $1
-`
-
-func (q *Queries) UpdateTotalViews(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, updateTotalViews, id)
-	return err
-}
@@ -28,16 +28,99 @@ type AdminVerifyEmail struct {
 	ExpiredAt  time.Time `json:"expired_at"`
 }
 
+type AlertPreference struct {
+	UserID        int32        `json:"user_id"`
+	Frequency     string       `json:"frequency"`
+	PriceDrops    bool         `json:"price_drops"`
+	Availability  bool         `json:"availability"`
+	SavedSearches bool         `json:"saved_searches"`
+	LastDigestAt  sql.NullTime `json:"last_digest_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+type BlockedDate struct {
+	ID        int32          `json:"id"`
+	ListingID int32          `json:"listing_id"`
+	FeedID    sql.NullInt32  `json:"feed_id"`
+	StartDate time.Time      `json:"start_date"`
+	EndDate   time.Time      `json:"end_date"`
+	Summary   sql.NullString `json:"summary"`
+	Uid       sql.NullString `json:"uid"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+}
+
 type Booking struct {
+	ID               int32          `json:"id"`
+	UserID           int32          `json:"user_id"`
+	ListingID        int32          `json:"listing_id"`
+	CheckInDate      time.Time      `json:"check_in_date"`
+	CheckOutDate     time.Time      `json:"check_out_date"`
+	TotalAmount      string         `json:"total_amount"`
+	Status           sql.NullString `json:"status"`
+	CreatedAt        sql.NullTime   `json:"created_at"`
+	DeletedAt        sql.NullTime   `json:"deleted_at"`
+	ApprovalDeadline sql.NullTime   `json:"approval_deadline"`
+	Guests           int32          `json:"guests"`
+}
+
+type BookingModification struct {
+	ID                   int32          `json:"id"`
+	BookingID            int32          `json:"booking_id"`
+	UserID               int32          `json:"user_id"`
+	CheckInDate          time.Time      `json:"check_in_date"`
+	CheckOutDate         time.Time      `json:"check_out_date"`
+	Guests               int32          `json:"guests"`
+	PreviousCheckInDate  time.Time      `json:"previous_check_in_date"`
+	PreviousCheckOutDate time.Time      `json:"previous_check_out_date"`
+	PreviousGuests       int32          `json:"previous_guests"`
+	PreviousAmount       string         `json:"previous_amount"`
+	NewAmount            string         `json:"new_amount"`
+	PriceDifference      string         `json:"price_difference"`
+	Status               string         `json:"status"`
+	PaymentIntentID      sql.NullString `json:"payment_intent_id"`
+	CreatedAt            sql.NullTime   `json:"created_at"`
+	ResolvedAt           sql.NullTime   `json:"resolved_at"`
+}
+
+type BookingReminder struct {
+	BookingID int32     `json:"booking_id"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+type CalendarFeed struct {
 	ID           int32          `json:"id"`
-	UserID       int32          `json:"user_id"`
 	ListingID    int32          `json:"listing_id"`
-	CheckInDate  time.Time      `json:"check_in_date"`
-	CheckOutDate time.Time      `json:"check_out_date"`
-	TotalAmount  string         `json:"total_amount"`
-	Status       sql.NullString `json:"status"`
+	Name         string         `json:"name"`
+	Url          string         `json:"url"`
+	LastSyncedAt sql.NullTime   `json:"last_synced_at"`
+	LastError    sql.NullString `json:"last_error"`
 	CreatedAt    sql.NullTime   `json:"created_at"`
-	DeletedAt    sql.NullTime   `json:"deleted_at"`
+}
+
+type ContentReport struct {
+	ID              int32          `json:"id"`
+	ReporterUserID  sql.NullInt32  `json:"reporter_user_id"`
+	ReporterAdminID sql.NullInt32  `json:"reporter_admin_id"`
+	ReviewID        sql.NullInt32  `json:"review_id"`
+	ListingID       sql.NullInt32  `json:"listing_id"`
+	Reason          string         `json:"reason"`
+	Details         sql.NullString `json:"details"`
+	Status          string         `json:"status"`
+	Resolution      sql.NullString `json:"resolution"`
+	ResolvedBy      sql.NullInt32  `json:"resolved_by"`
+	ResolvedAt      sql.NullTime   `json:"resolved_at"`
+	CreatedAt       sql.NullTime   `json:"created_at"`
+}
+
+type Conversation struct {
+	ID              int32         `json:"id"`
+	ListingID       int32         `json:"listing_id"`
+	UserID          int32         `json:"user_id"`
+	BookingID       sql.NullInt32 `json:"booking_id"`
+	GuestLastReadID int32         `json:"guest_last_read_id"`
+	HostLastReadID  int32         `json:"host_last_read_id"`
+	LastMessageAt   sql.NullTime  `json:"last_message_at"`
+	CreatedAt       time.Time     `json:"created_at"`
 }
 
 type Favorite struct {
@@ -47,64 +130,310 @@ type Favorite struct {
 	CreatedAt sql.NullTime `json:"created_at"`
 }
 
+type GuestReview struct {
+	ID                  int32        `json:"id"`
+	BookingID           int32        `json:"booking_id"`
+	AdminID             int32        `json:"admin_id"`
+	UserID              int32        `json:"user_id"`
+	Rating              int32        `json:"rating"`
+	CleanlinessRating   int32        `json:"cleanliness_rating"`
+	CommunicationRating int32        `json:"communication_rating"`
+	HouseRulesRating    int32        `json:"house_rules_rating"`
+	Comment             string       `json:"comment"`
+	PublishedAt         sql.NullTime `json:"published_at"`
+	CreatedAt           sql.NullTime `json:"created_at"`
+}
+
 type Listing struct {
+	ID             int32          `json:"id"`
+	AdminID        int32          `json:"admin_id"`
+	Title          string         `json:"title"`
+	Description    sql.NullString `json:"description"`
+	Price          string         `json:"price"`
+	Location       sql.NullString `json:"location"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	Available      sql.NullBool   `json:"available"`
+	TotalViews     sql.NullInt32  `json:"total_views"`
+	Imagelinks     []string       `json:"imagelinks"`
+	BookingMode    string         `json:"booking_mode"`
+	Status         string         `json:"status"`
+	ReviewNote     sql.NullString `json:"review_note"`
+	SubmittedAt    sql.NullTime   `json:"submitted_at"`
+	PublishedAt    sql.NullTime   `json:"published_at"`
+	DeletedAt      sql.NullTime   `json:"deleted_at"`
+	DeletedBy      sql.NullInt32  `json:"deleted_by"`
+	OrganizationID sql.NullInt32  `json:"organization_id"`
+}
+
+type ListingAlert struct {
+	ID            int32         `json:"id"`
+	UserID        int32         `json:"user_id"`
+	ListingID     int32         `json:"listing_id"`
+	SavedSearchID sql.NullInt32 `json:"saved_search_id"`
+	Kind          string        `json:"kind"`
+	Message       string        `json:"message"`
+	Read          bool          `json:"read"`
+	EmailedAt     sql.NullTime  `json:"emailed_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type ListingCalendarToken struct {
+	ListingID int32        `json:"listing_id"`
+	Token     string       `json:"token"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
+type ListingDailyView struct {
+	ListingID   int32     `json:"listing_id"`
+	Day         time.Time `json:"day"`
+	Views       int32     `json:"views"`
+	UniqueViews int32     `json:"unique_views"`
+}
+
+type ListingImage struct {
 	ID          int32          `json:"id"`
-	AdminID     int32          `json:"admin_id"`
+	ListingID   int32          `json:"listing_id"`
+	Status      string         `json:"status"`
+	StagedPath  sql.NullString `json:"staged_path"`
+	Url         sql.NullString `json:"url"`
+	Width       sql.NullInt32  `json:"width"`
+	Height      sql.NullInt32  `json:"height"`
+	Blurhash    sql.NullString `json:"blurhash"`
+	Error       sql.NullString `json:"error"`
+	CreatedAt   time.Time      `json:"created_at"`
+	ProcessedAt sql.NullTime   `json:"processed_at"`
+	Position    int32          `json:"position"`
+	Caption     string         `json:"caption"`
+	Room        sql.NullString `json:"room"`
+	IsCover     bool           `json:"is_cover"`
+	SourceKey   sql.NullString `json:"source_key"`
+}
+
+type ListingImageVariant struct {
+	ImageID   int32  `json:"image_id"`
+	Name      string `json:"name"`
+	Format    string `json:"format"`
+	Width     int32  `json:"width"`
+	Height    int32  `json:"height"`
+	ObjectKey string `json:"object_key"`
+	Url       string `json:"url"`
+}
+
+type ListingInteraction struct {
+	UserID    int32   `json:"user_id"`
+	ListingID int32   `json:"listing_id"`
+	Weight    float64 `json:"weight"`
+}
+
+type ListingPriceRule struct {
+	ID              int32          `json:"id"`
+	ListingID       int32          `json:"listing_id"`
+	Name            string         `json:"name"`
+	Kind            string         `json:"kind"`
+	StartDate       sql.NullTime   `json:"start_date"`
+	EndDate         sql.NullTime   `json:"end_date"`
+	DaysOfWeek      []int32        `json:"days_of_week"`
+	NightlyPrice    sql.NullString `json:"nightly_price"`
+	MinStay         sql.NullInt32  `json:"min_stay"`
+	MaxStay         sql.NullInt32  `json:"max_stay"`
+	DiscountPercent sql.NullString `json:"discount_percent"`
+	LeadDays        sql.NullInt32  `json:"lead_days"`
+	Priority        int32          `json:"priority"`
+	CreatedAt       sql.NullTime   `json:"created_at"`
+}
+
+type ListingRevision struct {
+	ListingID   int32          `json:"listing_id"`
+	Revision    int32          `json:"revision"`
+	AdminID     sql.NullInt32  `json:"admin_id"`
 	Title       string         `json:"title"`
 	Description sql.NullString `json:"description"`
 	Price       string         `json:"price"`
 	Location    sql.NullString `json:"location"`
-	CreatedAt   sql.NullTime   `json:"created_at"`
-	Available   sql.NullBool   `json:"available"`
-	TotalViews  sql.NullInt32  `json:"total_views"`
-	Imagelinks  []string       `json:"imagelinks"`
+	BookingMode string         `json:"booking_mode"`
+	Note        string         `json:"note"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type ListingSimilarity struct {
+	ListingID        int32     `json:"listing_id"`
+	SimilarListingID int32     `json:"similar_listing_id"`
+	Score            float64   `json:"score"`
+	TrainedAt        time.Time `json:"trained_at"`
+}
+
+type Message struct {
+	ID             int32         `json:"id"`
+	ConversationID int32         `json:"conversation_id"`
+	SenderRole     string        `json:"sender_role"`
+	SenderAdminID  sql.NullInt32 `json:"sender_admin_id"`
+	Body           string        `json:"body"`
+	Attachments    []string      `json:"attachments"`
+	CreatedAt      time.Time     `json:"created_at"`
 }
 
 type Notification struct {
-	ID            int32          `json:"id"`
-	UserID        sql.NullInt32  `json:"user_id"`
-	Message       string         `json:"message"`
-	Read          sql.NullBool   `json:"read"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
-	Subject       sql.NullString `json:"subject"`
-	BookingID     int32          `json:"booking_id"`
-	Email         sql.NullString `json:"email"`
-	AdminID       sql.NullInt32  `json:"admin_id"`
-	SenderAdminID sql.NullInt32  `json:"sender_admin_id"`
-	SenderUserID  sql.NullInt32  `json:"sender_user_id"`
+	ID             int32          `json:"id"`
+	UserID         sql.NullInt32  `json:"user_id"`
+	Message        string         `json:"message"`
+	Read           sql.NullBool   `json:"read"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	Subject        sql.NullString `json:"subject"`
+	BookingID      int32          `json:"booking_id"`
+	Email          sql.NullString `json:"email"`
+	AdminID        sql.NullInt32  `json:"admin_id"`
+	SenderAdminID  sql.NullInt32  `json:"sender_admin_id"`
+	SenderUserID   sql.NullInt32  `json:"sender_user_id"`
+	Category       sql.NullString `json:"category"`
+	ModificationID sql.NullInt32  `json:"modification_id"`
+}
+
+type NotificationPreference struct {
+	ID        int32         `json:"id"`
+	UserID    sql.NullInt32 `json:"user_id"`
+	AdminID   sql.NullInt32 `json:"admin_id"`
+	Category  string        `json:"category"`
+	Channel   string        `json:"channel"`
+	Enabled   bool          `json:"enabled"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type NotificationSetting struct {
+	ID              int32          `json:"id"`
+	UserID          sql.NullInt32  `json:"user_id"`
+	AdminID         sql.NullInt32  `json:"admin_id"`
+	Phone           sql.NullString `json:"phone"`
+	QuietHoursStart sql.NullInt32  `json:"quiet_hours_start"`
+	QuietHoursEnd   sql.NullInt32  `json:"quiet_hours_end"`
+	TimeZone        string         `json:"time_zone"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+type Organization struct {
+	ID        int32         `json:"id"`
+	Name      string        `json:"name"`
+	CreatedBy sql.NullInt32 `json:"created_by"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type OrganizationInvitation struct {
+	ID             int32         `json:"id"`
+	OrganizationID int32         `json:"organization_id"`
+	Email          string        `json:"email"`
+	Role           string        `json:"role"`
+	Token          string        `json:"token"`
+	InvitedBy      sql.NullInt32 `json:"invited_by"`
+	ExpiresAt      time.Time     `json:"expires_at"`
+	AcceptedAt     sql.NullTime  `json:"accepted_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int32     `json:"organization_id"`
+	AdminID        int32     `json:"admin_id"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OrphanedImage struct {
+	ObjectKey string    `json:"object_key"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Payment struct {
-	ID            int32          `json:"id"`
-	BookingID     int32          `json:"booking_id"`
-	Amount        string         `json:"amount"`
-	Status        sql.NullString `json:"status"`
-	PaymentMethod sql.NullString `json:"payment_method"`
-	TransactionID sql.NullString `json:"transaction_id"`
-	PaidAt        sql.NullTime   `json:"paid_at"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
-	UserID        int32          `json:"user_id"`
+	ID             int32          `json:"id"`
+	BookingID      int32          `json:"booking_id"`
+	Amount         string         `json:"amount"`
+	Status         sql.NullString `json:"status"`
+	PaymentMethod  sql.NullString `json:"payment_method"`
+	TransactionID  sql.NullString `json:"transaction_id"`
+	PaidAt         sql.NullTime   `json:"paid_at"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	UserID         int32          `json:"user_id"`
+	RefundedAmount string         `json:"refunded_amount"`
+}
+
+type PushSubscription struct {
+	ID        int32         `json:"id"`
+	UserID    sql.NullInt32 `json:"user_id"`
+	AdminID   sql.NullInt32 `json:"admin_id"`
+	Endpoint  string        `json:"endpoint"`
+	P256dh    string        `json:"p256dh"`
+	Auth      string        `json:"auth"`
+	CreatedAt time.Time     `json:"created_at"`
 }
 
 type Review struct {
-	ID        int32          `json:"id"`
-	UserID    int32          `json:"user_id"`
-	ListingID int32          `json:"listing_id"`
-	Rating    int32          `json:"rating"`
-	Comment   sql.NullString `json:"comment"`
-	CreatedAt sql.NullTime   `json:"created_at"`
+	ID                  int32          `json:"id"`
+	UserID              int32          `json:"user_id"`
+	ListingID           int32          `json:"listing_id"`
+	Rating              int32          `json:"rating"`
+	Comment             sql.NullString `json:"comment"`
+	CreatedAt           sql.NullTime   `json:"created_at"`
+	BookingID           sql.NullInt32  `json:"booking_id"`
+	CleanlinessRating   sql.NullInt32  `json:"cleanliness_rating"`
+	AccuracyRating      sql.NullInt32  `json:"accuracy_rating"`
+	LocationRating      sql.NullInt32  `json:"location_rating"`
+	ValueRating         sql.NullInt32  `json:"value_rating"`
+	CommunicationRating sql.NullInt32  `json:"communication_rating"`
+	HostReply           sql.NullString `json:"host_reply"`
+	HostRepliedAt       sql.NullTime   `json:"host_replied_at"`
+	PublishedAt         sql.NullTime   `json:"published_at"`
+	ModerationStatus    string         `json:"moderation_status"`
+	ModerationReason    sql.NullString `json:"moderation_reason"`
+	CommentFingerprint  sql.NullString `json:"comment_fingerprint"`
 }
 
-type Stat struct {
+type SavedSearch struct {
 	ID            int32          `json:"id"`
-	ListingID     int32          `json:"listing_id"`
-	AdminID       int32          `json:"admin_id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
+	UserID        int32          `json:"user_id"`
+	Name          string         `json:"name"`
+	Keyword       string         `json:"keyword"`
+	Location      sql.NullString `json:"location"`
+	MinPrice      sql.NullString `json:"min_price"`
+	MaxPrice      sql.NullString `json:"max_price"`
+	AlertsEnabled bool           `json:"alerts_enabled"`
 	CreatedAt     sql.NullTime   `json:"created_at"`
 }
 
+type Stat struct {
+	ID            int32        `json:"id"`
+	ListingID     int32        `json:"listing_id"`
+	AdminID       int32        `json:"admin_id"`
+	TotalViews    int32        `json:"total_views"`
+	TotalBookings int32        `json:"total_bookings"`
+	AverageRating string       `json:"average_rating"`
+	CreatedAt     sql.NullTime `json:"created_at"`
+	ReviewCount   int32        `json:"review_count"`
+	Revenue       string       `json:"revenue"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	UniqueViews   int32        `json:"unique_views"`
+}
+
+type SuperAdmin struct {
+	AdminID   int32        `json:"admin_id"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
+type UploadSession struct {
+	ID        int32     `json:"id"`
+	ListingID int32     `json:"listing_id"`
+	AdminID   int32     `json:"admin_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UploadSessionFile struct {
+	SessionID int32          `json:"session_id"`
+	Position  int32          `json:"position"`
+	Filename  string         `json:"filename"`
+	Size      int64          `json:"size"`
+	ObjectKey string         `json:"object_key"`
+	Status    string         `json:"status"`
+	ImageID   sql.NullInt32  `json:"image_id"`
+	Error     sql.NullString `json:"error"`
+}
+
 type User struct {
 	ID              int32        `json:"id"`
 	Username        string       `json:"username"`
@@ -123,3 +452,29 @@ type UserVerifyEmail struct {
 	CreatedAt  time.Time `json:"created_at"`
 	ExpiredAt  time.Time `json:"expired_at"`
 }
+
+type Wishlist struct {
+	ID         int32          `json:"id"`
+	UserID     int32          `json:"user_id"`
+	Name       string         `json:"name"`
+	ShareToken sql.NullString `json:"share_token"`
+	CreatedAt  sql.NullTime   `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+type WishlistCollaborator struct {
+	WishlistID int32        `json:"wishlist_id"`
+	UserID     int32        `json:"user_id"`
+	CreatedAt  sql.NullTime `json:"created_at"`
+}
+
+type WishlistItem struct {
+	ID           int32          `json:"id"`
+	WishlistID   int32          `json:"wishlist_id"`
+	ListingID    int32          `json:"listing_id"`
+	AddedBy      int32          `json:"added_by"`
+	Note         sql.NullString `json:"note"`
+	CheckInDate  sql.NullTime   `json:"check_in_date"`
+	CheckOutDate sql.NullTime   `json:"check_out_date"`
+	CreatedAt    sql.NullTime   `json:"created_at"`
+}
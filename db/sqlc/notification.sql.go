@@ -10,10 +10,36 @@ import (
 	"database/sql"
 )
 
+const countUnreadNotificationsByAdminID = `-- name: CountUnreadNotificationsByAdminID :one
+SELECT COUNT(*)
+FROM notifications
+WHERE admin_id = $1 AND read = FALSE
+`
+
+func (q *Queries) CountUnreadNotificationsByAdminID(ctx context.Context, adminID sql.NullInt32) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotificationsByAdminID, adminID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUnreadNotificationsByUserID = `-- name: CountUnreadNotificationsByUserID :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1 AND read = FALSE
+`
+
+func (q *Queries) CountUnreadNotificationsByUserID(ctx context.Context, userID sql.NullInt32) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotificationsByUserID, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createAdminNotification = `-- name: CreateAdminNotification :one
 INSERT INTO notifications (admin_id, subject, sender_user_id, email, booking_id, message)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, user_id, message, read, created_at, subject, booking_id, email, admin_id, sender_admin_id, sender_user_id
+RETURNING id, user_id, message, read, created_at, subject, booking_id, email, admin_id, sender_admin_id, sender_user_id, category, modification_id
 `
 
 type CreateAdminNotificationParams struct {
@@ -47,6 +73,43 @@ func (q *Queries) CreateAdminNotification(ctx context.Context, arg CreateAdminNo
 		&i.AdminID,
 		&i.SenderAdminID,
 		&i.SenderUserID,
+		&i.Category,
+		&i.ModificationID,
+	)
+	return i, err
+}
+
+const createAdminPushSubscription = `-- name: CreateAdminPushSubscription :one
+INSERT INTO push_subscriptions (admin_id, endpoint, p256dh, auth)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint)
+DO UPDATE SET admin_id = EXCLUDED.admin_id, user_id = NULL, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth
+RETURNING id, user_id, admin_id, endpoint, p256dh, auth, created_at
+`
+
+type CreateAdminPushSubscriptionParams struct {
+	AdminID  sql.NullInt32 `json:"admin_id"`
+	Endpoint string        `json:"endpoint"`
+	P256dh   string        `json:"p256dh"`
+	Auth     string        `json:"auth"`
+}
+
+func (q *Queries) CreateAdminPushSubscription(ctx context.Context, arg CreateAdminPushSubscriptionParams) (PushSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createAdminPushSubscription,
+		arg.AdminID,
+		arg.Endpoint,
+		arg.P256dh,
+		arg.Auth,
+	)
+	var i PushSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.AdminID,
+		&i.Endpoint,
+		&i.P256dh,
+		&i.Auth,
+		&i.CreatedAt,
 	)
 	return i, err
 }
@@ -54,7 +117,7 @@ func (q *Queries) CreateAdminNotification(ctx context.Context, arg CreateAdminNo
 const createNotification = `-- name: CreateNotification :one
 INSERT INTO notifications (user_id, subject, sender_admin_id, email, booking_id, message)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, user_id, message, read, created_at, subject, booking_id, email, admin_id, sender_admin_id, sender_user_id
+RETURNING id, user_id, message, read, created_at, subject, booking_id, email, admin_id, sender_admin_id, sender_user_id, category, modification_id
 `
 
 type CreateNotificationParams struct {
@@ -88,20 +151,248 @@ func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotification
 		&i.AdminID,
 		&i.SenderAdminID,
 		&i.SenderUserID,
+		&i.Category,
+		&i.ModificationID,
 	)
 	return i, err
 }
 
-const deleteNotification = `-- name: DeleteNotification :exec
+const createEventNotification = `-- name: CreateEventNotification :one
+INSERT INTO notifications (user_id, admin_id, category, booking_id, modification_id, subject, message)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT DO NOTHING
+RETURNING id, user_id, message, read, created_at, subject, booking_id, email, admin_id, sender_admin_id, sender_user_id, category, modification_id
+`
+
+type CreateEventNotificationParams struct {
+	UserID         sql.NullInt32  `json:"user_id"`
+	AdminID        sql.NullInt32  `json:"admin_id"`
+	Category       sql.NullString `json:"category"`
+	BookingID      int32          `json:"booking_id"`
+	ModificationID sql.NullInt32  `json:"modification_id"`
+	Subject        sql.NullString `json:"subject"`
+	Message        string         `json:"message"`
+}
+
+// Creates the notification of a booking event for a user or an admin. Nothing is returned
+// if they already have the notification of this category for the booking, or for the
+// modification if the event is about one.
+func (q *Queries) CreateEventNotification(ctx context.Context, arg CreateEventNotificationParams) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, createEventNotification,
+		arg.UserID,
+		arg.AdminID,
+		arg.Category,
+		arg.BookingID,
+		arg.ModificationID,
+		arg.Subject,
+		arg.Message,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Message,
+		&i.Read,
+		&i.CreatedAt,
+		&i.Subject,
+		&i.BookingID,
+		&i.Email,
+		&i.AdminID,
+		&i.SenderAdminID,
+		&i.SenderUserID,
+		&i.Category,
+		&i.ModificationID,
+	)
+	return i, err
+}
+
+const createUserPushSubscription = `-- name: CreateUserPushSubscription :one
+INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint)
+DO UPDATE SET user_id = EXCLUDED.user_id, admin_id = NULL, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth
+RETURNING id, user_id, admin_id, endpoint, p256dh, auth, created_at
+`
+
+type CreateUserPushSubscriptionParams struct {
+	UserID   sql.NullInt32 `json:"user_id"`
+	Endpoint string        `json:"endpoint"`
+	P256dh   string        `json:"p256dh"`
+	Auth     string        `json:"auth"`
+}
+
+func (q *Queries) CreateUserPushSubscription(ctx context.Context, arg CreateUserPushSubscriptionParams) (PushSubscription, error) {
+	row := q.db.QueryRowContext(ctx, createUserPushSubscription,
+		arg.UserID,
+		arg.Endpoint,
+		arg.P256dh,
+		arg.Auth,
+	)
+	var i PushSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.AdminID,
+		&i.Endpoint,
+		&i.P256dh,
+		&i.Auth,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAdminPushSubscription = `-- name: DeleteAdminPushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = $1 AND admin_id = $2
+`
+
+type DeleteAdminPushSubscriptionParams struct {
+	Endpoint string        `json:"endpoint"`
+	AdminID  sql.NullInt32 `json:"admin_id"`
+}
+
+func (q *Queries) DeleteAdminPushSubscription(ctx context.Context, arg DeleteAdminPushSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, deleteAdminPushSubscription, arg.Endpoint, arg.AdminID)
+	return err
+}
+
+const deleteNotification = `-- name: DeleteNotification :one
 DELETE FROM notifications
 WHERE id = $1
+RETURNING user_id, admin_id
+`
+
+type DeleteNotificationRow struct {
+	UserID  sql.NullInt32 `json:"user_id"`
+	AdminID sql.NullInt32 `json:"admin_id"`
+}
+
+func (q *Queries) DeleteNotification(ctx context.Context, id int32) (DeleteNotificationRow, error) {
+	row := q.db.QueryRowContext(ctx, deleteNotification, id)
+	var i DeleteNotificationRow
+	err := row.Scan(&i.UserID, &i.AdminID)
+	return i, err
+}
+
+const deletePushSubscription = `-- name: DeletePushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = $1
+`
+
+func (q *Queries) DeletePushSubscription(ctx context.Context, endpoint string) error {
+	_, err := q.db.ExecContext(ctx, deletePushSubscription, endpoint)
+	return err
+}
+
+const deleteUserPushSubscription = `-- name: DeleteUserPushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = $1 AND user_id = $2
 `
 
-func (q *Queries) DeleteNotification(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, deleteNotification, id)
+type DeleteUserPushSubscriptionParams struct {
+	Endpoint string        `json:"endpoint"`
+	UserID   sql.NullInt32 `json:"user_id"`
+}
+
+func (q *Queries) DeleteUserPushSubscription(ctx context.Context, arg DeleteUserPushSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserPushSubscription, arg.Endpoint, arg.UserID)
 	return err
 }
 
+const getAdminNotificationPreferences = `-- name: GetAdminNotificationPreferences :many
+SELECT category, channel, enabled
+FROM notification_preferences
+WHERE admin_id = $1
+ORDER BY category, channel
+`
+
+type GetAdminNotificationPreferencesRow struct {
+	Category string `json:"category"`
+	Channel  string `json:"channel"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (q *Queries) GetAdminNotificationPreferences(ctx context.Context, adminID sql.NullInt32) ([]GetAdminNotificationPreferencesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAdminNotificationPreferences, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAdminNotificationPreferencesRow
+	for rows.Next() {
+		var i GetAdminNotificationPreferencesRow
+		if err := rows.Scan(&i.Category, &i.Channel, &i.Enabled); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAdminNotificationSettings = `-- name: GetAdminNotificationSettings :one
+SELECT id, user_id, admin_id, phone, quiet_hours_start, quiet_hours_end, time_zone, updated_at FROM notification_settings
+WHERE admin_id = $1
+`
+
+func (q *Queries) GetAdminNotificationSettings(ctx context.Context, adminID sql.NullInt32) (NotificationSetting, error) {
+	row := q.db.QueryRowContext(ctx, getAdminNotificationSettings, adminID)
+	var i NotificationSetting
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.AdminID,
+		&i.Phone,
+		&i.QuietHoursStart,
+		&i.QuietHoursEnd,
+		&i.TimeZone,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getAdminPushSubscriptions = `-- name: GetAdminPushSubscriptions :many
+SELECT id, user_id, admin_id, endpoint, p256dh, auth, created_at FROM push_subscriptions
+WHERE admin_id = $1
+ORDER BY id
+`
+
+func (q *Queries) GetAdminPushSubscriptions(ctx context.Context, adminID sql.NullInt32) ([]PushSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, getAdminPushSubscriptions, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PushSubscription
+	for rows.Next() {
+		var i PushSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.AdminID,
+			&i.Endpoint,
+			&i.P256dh,
+			&i.Auth,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getNotificationByID = `-- name: GetNotificationByID :one
 SELECT id, user_id, message, read, email, created_at
 FROM notifications
@@ -137,9 +428,9 @@ func (q *Queries) GetNotificationByID(ctx context.Context, arg GetNotificationBy
 }
 
 const getNotificationsByAdminID = `-- name: GetNotificationsByAdminID :many
-SELECT n.id, n.admin_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at
+SELECT n.id, n.admin_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at, n.category
 FROM notifications n
-JOIN users a ON n.sender_user_id = a.id
+LEFT JOIN users a ON n.sender_user_id = a.id
 WHERE n.admin_id = $1
 ORDER BY n.created_at DESC
 `
@@ -147,15 +438,17 @@ ORDER BY n.created_at DESC
 type GetNotificationsByAdminIDRow struct {
 	ID        int32          `json:"id"`
 	AdminID   sql.NullInt32  `json:"admin_id"`
-	ID_2      int32          `json:"id_2"`
+	ID_2      sql.NullInt32  `json:"id_2"`
 	Subject   sql.NullString `json:"subject"`
 	BookingID int32          `json:"booking_id"`
-	Email     string         `json:"email"`
+	Email     sql.NullString `json:"email"`
 	Message   string         `json:"message"`
 	Read      sql.NullBool   `json:"read"`
 	CreatedAt sql.NullTime   `json:"created_at"`
+	Category  sql.NullString `json:"category"`
 }
 
+// Notifications about booking events have a category and no sender.
 func (q *Queries) GetNotificationsByAdminID(ctx context.Context, adminID sql.NullInt32) ([]GetNotificationsByAdminIDRow, error) {
 	rows, err := q.db.QueryContext(ctx, getNotificationsByAdminID, adminID)
 	if err != nil {
@@ -175,6 +468,7 @@ func (q *Queries) GetNotificationsByAdminID(ctx context.Context, adminID sql.Nul
 			&i.Message,
 			&i.Read,
 			&i.CreatedAt,
+			&i.Category,
 		); err != nil {
 			return nil, err
 		}
@@ -190,9 +484,9 @@ func (q *Queries) GetNotificationsByAdminID(ctx context.Context, adminID sql.Nul
 }
 
 const getNotificationsByUserID = `-- name: GetNotificationsByUserID :many
-SELECT n.id, n.user_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at
+SELECT n.id, n.user_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at, n.category
 FROM notifications n
-JOIN admins a ON n.sender_admin_id = a.id
+LEFT JOIN admins a ON n.sender_admin_id = a.id
 WHERE n.user_id = $1
 ORDER BY n.created_at DESC
 `
@@ -200,15 +494,17 @@ ORDER BY n.created_at DESC
 type GetNotificationsByUserIDRow struct {
 	ID        int32          `json:"id"`
 	UserID    sql.NullInt32  `json:"user_id"`
-	ID_2      int32          `json:"id_2"`
+	ID_2      sql.NullInt32  `json:"id_2"`
 	Subject   sql.NullString `json:"subject"`
 	BookingID int32          `json:"booking_id"`
-	Email     string         `json:"email"`
+	Email     sql.NullString `json:"email"`
 	Message   string         `json:"message"`
 	Read      sql.NullBool   `json:"read"`
 	CreatedAt sql.NullTime   `json:"created_at"`
+	Category  sql.NullString `json:"category"`
 }
 
+// Notifications about booking events have a category and no sender.
 func (q *Queries) GetNotificationsByUserID(ctx context.Context, userID sql.NullInt32) ([]GetNotificationsByUserIDRow, error) {
 	rows, err := q.db.QueryContext(ctx, getNotificationsByUserID, userID)
 	if err != nil {
@@ -228,6 +524,7 @@ func (q *Queries) GetNotificationsByUserID(ctx context.Context, userID sql.NullI
 			&i.Message,
 			&i.Read,
 			&i.CreatedAt,
+			&i.Category,
 		); err != nil {
 			return nil, err
 		}
@@ -344,6 +641,100 @@ func (q *Queries) GetSentNotificationsByUserID(ctx context.Context, senderUserID
 	return items, nil
 }
 
+const getUserNotificationPreferences = `-- name: GetUserNotificationPreferences :many
+SELECT category, channel, enabled
+FROM notification_preferences
+WHERE user_id = $1
+ORDER BY category, channel
+`
+
+type GetUserNotificationPreferencesRow struct {
+	Category string `json:"category"`
+	Channel  string `json:"channel"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (q *Queries) GetUserNotificationPreferences(ctx context.Context, userID sql.NullInt32) ([]GetUserNotificationPreferencesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserNotificationPreferences, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserNotificationPreferencesRow
+	for rows.Next() {
+		var i GetUserNotificationPreferencesRow
+		if err := rows.Scan(&i.Category, &i.Channel, &i.Enabled); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserNotificationSettings = `-- name: GetUserNotificationSettings :one
+SELECT id, user_id, admin_id, phone, quiet_hours_start, quiet_hours_end, time_zone, updated_at FROM notification_settings
+WHERE user_id = $1
+`
+
+func (q *Queries) GetUserNotificationSettings(ctx context.Context, userID sql.NullInt32) (NotificationSetting, error) {
+	row := q.db.QueryRowContext(ctx, getUserNotificationSettings, userID)
+	var i NotificationSetting
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.AdminID,
+		&i.Phone,
+		&i.QuietHoursStart,
+		&i.QuietHoursEnd,
+		&i.TimeZone,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserPushSubscriptions = `-- name: GetUserPushSubscriptions :many
+SELECT id, user_id, admin_id, endpoint, p256dh, auth, created_at FROM push_subscriptions
+WHERE user_id = $1
+ORDER BY id
+`
+
+func (q *Queries) GetUserPushSubscriptions(ctx context.Context, userID sql.NullInt32) ([]PushSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, getUserPushSubscriptions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PushSubscription
+	for rows.Next() {
+		var i PushSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.AdminID,
+			&i.Endpoint,
+			&i.P256dh,
+			&i.Auth,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUnreadNotificationsByAdminID = `-- name: GetUnreadNotificationsByAdminID :many
 SELECT id, admin_id, message, read, email, created_at
 FROM notifications
@@ -436,6 +827,140 @@ func (q *Queries) GetUnreadNotificationsByUserID(ctx context.Context, userID sql
 	return items, nil
 }
 
+const setAdminNotificationPreference = `-- name: SetAdminNotificationPreference :exec
+INSERT INTO notification_preferences (admin_id, category, channel, enabled)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (admin_id, category, channel) WHERE admin_id IS NOT NULL
+DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+`
+
+type SetAdminNotificationPreferenceParams struct {
+	AdminID  sql.NullInt32 `json:"admin_id"`
+	Category string        `json:"category"`
+	Channel  string        `json:"channel"`
+	Enabled  bool          `json:"enabled"`
+}
+
+func (q *Queries) SetAdminNotificationPreference(ctx context.Context, arg SetAdminNotificationPreferenceParams) error {
+	_, err := q.db.ExecContext(ctx, setAdminNotificationPreference,
+		arg.AdminID,
+		arg.Category,
+		arg.Channel,
+		arg.Enabled,
+	)
+	return err
+}
+
+const setAdminNotificationSettings = `-- name: SetAdminNotificationSettings :one
+INSERT INTO notification_settings (admin_id, phone, quiet_hours_start, quiet_hours_end, time_zone)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (admin_id) WHERE admin_id IS NOT NULL
+DO UPDATE SET
+    phone = EXCLUDED.phone,
+    quiet_hours_start = EXCLUDED.quiet_hours_start,
+    quiet_hours_end = EXCLUDED.quiet_hours_end,
+    time_zone = EXCLUDED.time_zone,
+    updated_at = NOW()
+RETURNING id, user_id, admin_id, phone, quiet_hours_start, quiet_hours_end, time_zone, updated_at
+`
+
+type SetAdminNotificationSettingsParams struct {
+	AdminID         sql.NullInt32  `json:"admin_id"`
+	Phone           sql.NullString `json:"phone"`
+	QuietHoursStart sql.NullInt32  `json:"quiet_hours_start"`
+	QuietHoursEnd   sql.NullInt32  `json:"quiet_hours_end"`
+	TimeZone        string         `json:"time_zone"`
+}
+
+func (q *Queries) SetAdminNotificationSettings(ctx context.Context, arg SetAdminNotificationSettingsParams) (NotificationSetting, error) {
+	row := q.db.QueryRowContext(ctx, setAdminNotificationSettings,
+		arg.AdminID,
+		arg.Phone,
+		arg.QuietHoursStart,
+		arg.QuietHoursEnd,
+		arg.TimeZone,
+	)
+	var i NotificationSetting
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.AdminID,
+		&i.Phone,
+		&i.QuietHoursStart,
+		&i.QuietHoursEnd,
+		&i.TimeZone,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setUserNotificationPreference = `-- name: SetUserNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, category, channel, enabled)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, category, channel) WHERE user_id IS NOT NULL
+DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+`
+
+type SetUserNotificationPreferenceParams struct {
+	UserID   sql.NullInt32 `json:"user_id"`
+	Category string        `json:"category"`
+	Channel  string        `json:"channel"`
+	Enabled  bool          `json:"enabled"`
+}
+
+func (q *Queries) SetUserNotificationPreference(ctx context.Context, arg SetUserNotificationPreferenceParams) error {
+	_, err := q.db.ExecContext(ctx, setUserNotificationPreference,
+		arg.UserID,
+		arg.Category,
+		arg.Channel,
+		arg.Enabled,
+	)
+	return err
+}
+
+const setUserNotificationSettings = `-- name: SetUserNotificationSettings :one
+INSERT INTO notification_settings (user_id, phone, quiet_hours_start, quiet_hours_end, time_zone)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) WHERE user_id IS NOT NULL
+DO UPDATE SET
+    phone = EXCLUDED.phone,
+    quiet_hours_start = EXCLUDED.quiet_hours_start,
+    quiet_hours_end = EXCLUDED.quiet_hours_end,
+    time_zone = EXCLUDED.time_zone,
+    updated_at = NOW()
+RETURNING id, user_id, admin_id, phone, quiet_hours_start, quiet_hours_end, time_zone, updated_at
+`
+
+type SetUserNotificationSettingsParams struct {
+	UserID          sql.NullInt32  `json:"user_id"`
+	Phone           sql.NullString `json:"phone"`
+	QuietHoursStart sql.NullInt32  `json:"quiet_hours_start"`
+	QuietHoursEnd   sql.NullInt32  `json:"quiet_hours_end"`
+	TimeZone        string         `json:"time_zone"`
+}
+
+func (q *Queries) SetUserNotificationSettings(ctx context.Context, arg SetUserNotificationSettingsParams) (NotificationSetting, error) {
+	row := q.db.QueryRowContext(ctx, setUserNotificationSettings,
+		arg.UserID,
+		arg.Phone,
+		arg.QuietHoursStart,
+		arg.QuietHoursEnd,
+		arg.TimeZone,
+	)
+	var i NotificationSetting
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.AdminID,
+		&i.Phone,
+		&i.QuietHoursStart,
+		&i.QuietHoursEnd,
+		&i.TimeZone,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateAdminNotificationReadStatus = `-- name: UpdateAdminNotificationReadStatus :exec
 UPDATE notifications
 SET read = COALESCE($3, read)
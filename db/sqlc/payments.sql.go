@@ -8,8 +8,33 @@ package db
 import (
 	"context"
 	"database/sql"
+
+	"github.com/lib/pq"
 )
 
+const addPaymentRefund = `-- name: AddPaymentRefund :execrows
+-- Records that amount of a payment was refunded. Nothing is recorded if it is more than
+-- the rest of the payment.
+UPDATE payments
+SET refunded_amount = refunded_amount + $1
+WHERE id = $2 AND refunded_amount + $1 <= payments.amount
+`
+
+type AddPaymentRefundParams struct {
+	Amount string `json:"amount"`
+	ID     int32  `json:"id"`
+}
+
+// Records that amount of a payment was refunded. Nothing is recorded if it is more than
+// the rest of the payment.
+func (q *Queries) AddPaymentRefund(ctx context.Context, arg AddPaymentRefundParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, addPaymentRefund, arg.Amount, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createPayment = `-- name: CreatePayment :one
 INSERT INTO payments (booking_id, amount, status, payment_method, transaction_id, paid_at, user_id)
 VALUES ($1, $2, $3, $4, $5, $6, $7)
@@ -110,10 +135,18 @@ FROM payments p
 JOIN bookings b ON p.booking_id = b.id
 JOIN listings l ON b.listing_id = l.id
 JOIN users u ON b.user_id = u.id
-WHERE l.admin_id = $1
+WHERE ((l.organization_id IS NULL AND l.admin_id = $1)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $1 AND m.role = ANY($2::text[])))
 ORDER BY p.created_at DESC
 `
 
+type GetPaymentsByAdminIDParams struct {
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
+}
+
 type GetPaymentsByAdminIDRow struct {
 	ID            int32          `json:"id"`
 	BookingID     int32          `json:"booking_id"`
@@ -127,8 +160,8 @@ type GetPaymentsByAdminIDRow struct {
 	CreatedAt     sql.NullTime   `json:"created_at"`
 }
 
-func (q *Queries) GetPaymentsByAdminID(ctx context.Context, adminID int32) ([]GetPaymentsByAdminIDRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPaymentsByAdminID, adminID)
+func (q *Queries) GetPaymentsByAdminID(ctx context.Context, arg GetPaymentsByAdminIDParams) ([]GetPaymentsByAdminIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPaymentsByAdminID, arg.AdminID, pq.Array(arg.Roles))
 	if err != nil {
 		return nil, err
 	}
@@ -162,21 +195,22 @@ func (q *Queries) GetPaymentsByAdminID(ctx context.Context, adminID int32) ([]Ge
 }
 
 const getPaymentsByBookingID = `-- name: GetPaymentsByBookingID :many
-SELECT id, booking_id, amount, status, payment_method, transaction_id, paid_at, created_at
+SELECT id, booking_id, amount, status, payment_method, transaction_id, paid_at, created_at, refunded_amount
 FROM payments
 WHERE booking_id = $1
 ORDER BY created_at DESC
 `
 
 type GetPaymentsByBookingIDRow struct {
-	ID            int32          `json:"id"`
-	BookingID     int32          `json:"booking_id"`
-	Amount        string         `json:"amount"`
-	Status        sql.NullString `json:"status"`
-	PaymentMethod sql.NullString `json:"payment_method"`
-	TransactionID sql.NullString `json:"transaction_id"`
-	PaidAt        sql.NullTime   `json:"paid_at"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
+	ID             int32          `json:"id"`
+	BookingID      int32          `json:"booking_id"`
+	Amount         string         `json:"amount"`
+	Status         sql.NullString `json:"status"`
+	PaymentMethod  sql.NullString `json:"payment_method"`
+	TransactionID  sql.NullString `json:"transaction_id"`
+	PaidAt         sql.NullTime   `json:"paid_at"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	RefundedAmount string         `json:"refunded_amount"`
 }
 
 func (q *Queries) GetPaymentsByBookingID(ctx context.Context, bookingID int32) ([]GetPaymentsByBookingIDRow, error) {
@@ -197,6 +231,7 @@ func (q *Queries) GetPaymentsByBookingID(ctx context.Context, bookingID int32) (
 			&i.TransactionID,
 			&i.PaidAt,
 			&i.CreatedAt,
+			&i.RefundedAmount,
 		); err != nil {
 			return nil, err
 		}
@@ -315,6 +350,17 @@ func (q *Queries) GetPaymentsByUserID(ctx context.Context, userID int32) ([]GetP
 	return items, nil
 }
 
+const paymentTransactionExists = `-- name: PaymentTransactionExists :one
+SELECT EXISTS (SELECT 1 FROM payments WHERE transaction_id = $1)
+`
+
+func (q *Queries) PaymentTransactionExists(ctx context.Context, transactionID sql.NullString) (bool, error) {
+	row := q.db.QueryRowContext(ctx, paymentTransactionExists, transactionID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const updatePaymentStatus = `-- name: UpdatePaymentStatus :exec
 UPDATE payments
 SET status = COALESCE($2, status)
@@ -8,27 +8,59 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 const createReview = `-- name: CreateReview :one
-INSERT INTO reviews (user_id, listing_id, rating, comment)
-VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, listing_id, rating, comment, created_at
+-- Reviews are pending until the content filter has screened them.
+INSERT INTO reviews (
+    user_id,
+    listing_id,
+    booking_id,
+    rating,
+    cleanliness_rating,
+    accuracy_rating,
+    location_rating,
+    value_rating,
+    communication_rating,
+    comment,
+    comment_fingerprint,
+    moderation_status
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'pending')
+RETURNING id, user_id, listing_id, rating, comment, created_at, booking_id, cleanliness_rating, accuracy_rating, location_rating, value_rating, communication_rating, host_reply, host_replied_at, published_at, moderation_status, moderation_reason, comment_fingerprint
 `
 
 type CreateReviewParams struct {
-	UserID    int32          `json:"user_id"`
-	ListingID int32          `json:"listing_id"`
-	Rating    int32          `json:"rating"`
-	Comment   sql.NullString `json:"comment"`
+	UserID              int32          `json:"user_id"`
+	ListingID           int32          `json:"listing_id"`
+	BookingID           sql.NullInt32  `json:"booking_id"`
+	Rating              int32          `json:"rating"`
+	CleanlinessRating   sql.NullInt32  `json:"cleanliness_rating"`
+	AccuracyRating      sql.NullInt32  `json:"accuracy_rating"`
+	LocationRating      sql.NullInt32  `json:"location_rating"`
+	ValueRating         sql.NullInt32  `json:"value_rating"`
+	CommunicationRating sql.NullInt32  `json:"communication_rating"`
+	Comment             sql.NullString `json:"comment"`
+	CommentFingerprint  sql.NullString `json:"comment_fingerprint"`
 }
 
+// Reviews are pending until the content filter has screened them.
 func (q *Queries) CreateReview(ctx context.Context, arg CreateReviewParams) (Review, error) {
 	row := q.db.QueryRowContext(ctx, createReview,
 		arg.UserID,
 		arg.ListingID,
+		arg.BookingID,
 		arg.Rating,
+		arg.CleanlinessRating,
+		arg.AccuracyRating,
+		arg.LocationRating,
+		arg.ValueRating,
+		arg.CommunicationRating,
 		arg.Comment,
+		arg.CommentFingerprint,
 	)
 	var i Review
 	err := row.Scan(
@@ -38,13 +70,26 @@ func (q *Queries) CreateReview(ctx context.Context, arg CreateReviewParams) (Rev
 		&i.Rating,
 		&i.Comment,
 		&i.CreatedAt,
+		&i.BookingID,
+		&i.CleanlinessRating,
+		&i.AccuracyRating,
+		&i.LocationRating,
+		&i.ValueRating,
+		&i.CommunicationRating,
+		&i.HostReply,
+		&i.HostRepliedAt,
+		&i.PublishedAt,
+		&i.ModerationStatus,
+		&i.ModerationReason,
+		&i.CommentFingerprint,
 	)
 	return i, err
 }
 
-const deleteReview = `-- name: DeleteReview :exec
+const deleteReview = `-- name: DeleteReview :execrows
 DELETE FROM reviews
 WHERE id = $1 and user_id = $2
+  AND (booking_id IS NULL OR published_at IS NULL)
 `
 
 type DeleteReviewParams struct {
@@ -52,27 +97,93 @@ type DeleteReviewParams struct {
 	UserID int32 `json:"user_id"`
 }
 
-func (q *Queries) DeleteReview(ctx context.Context, arg DeleteReviewParams) error {
-	_, err := q.db.ExecContext(ctx, deleteReview, arg.ID, arg.UserID)
-	return err
+// Published reviews of a stay are kept, so the guest cannot replace one after reading the
+// host's review.
+func (q *Queries) DeleteReview(ctx context.Context, arg DeleteReviewParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteReview, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getListingRatingSummary = `-- name: GetListingRatingSummary :one
+SELECT
+    COUNT(*) AS total_reviews,
+    COALESCE(ROUND(AVG(rating)::numeric, 2), 0)::float8 AS average_rating,
+    COALESCE(ROUND(AVG(cleanliness_rating)::numeric, 2), 0)::float8 AS cleanliness,
+    COALESCE(ROUND(AVG(accuracy_rating)::numeric, 2), 0)::float8 AS accuracy,
+    COALESCE(ROUND(AVG(location_rating)::numeric, 2), 0)::float8 AS location,
+    COALESCE(ROUND(AVG(value_rating)::numeric, 2), 0)::float8 AS value,
+    COALESCE(ROUND(AVG(communication_rating)::numeric, 2), 0)::float8 AS communication,
+    COUNT(*) FILTER (WHERE rating = 5) AS five_star,
+    COUNT(*) FILTER (WHERE rating = 4) AS four_star,
+    COUNT(*) FILTER (WHERE rating = 3) AS three_star,
+    COUNT(*) FILTER (WHERE rating = 2) AS two_star,
+    COUNT(*) FILTER (WHERE rating = 1) AS one_star
+FROM reviews
+WHERE listing_id = $1 AND published_at IS NOT NULL AND moderation_status = 'visible'
+`
+
+type GetListingRatingSummaryRow struct {
+	TotalReviews  int64   `json:"total_reviews"`
+	AverageRating float64 `json:"average_rating"`
+	Cleanliness   float64 `json:"cleanliness"`
+	Accuracy      float64 `json:"accuracy"`
+	Location      float64 `json:"location"`
+	Value         float64 `json:"value"`
+	Communication float64 `json:"communication"`
+	FiveStar      int64   `json:"five_star"`
+	FourStar      int64   `json:"four_star"`
+	ThreeStar     int64   `json:"three_star"`
+	TwoStar       int64   `json:"two_star"`
+	OneStar       int64   `json:"one_star"`
+}
+
+func (q *Queries) GetListingRatingSummary(ctx context.Context, listingID int32) (GetListingRatingSummaryRow, error) {
+	row := q.db.QueryRowContext(ctx, getListingRatingSummary, listingID)
+	var i GetListingRatingSummaryRow
+	err := row.Scan(
+		&i.TotalReviews,
+		&i.AverageRating,
+		&i.Cleanliness,
+		&i.Accuracy,
+		&i.Location,
+		&i.Value,
+		&i.Communication,
+		&i.FiveStar,
+		&i.FourStar,
+		&i.ThreeStar,
+		&i.TwoStar,
+		&i.OneStar,
+	)
+	return i, err
 }
 
 const getListingReviews = `-- name: GetListingReviews :many
-SELECT r.id, r.user_id, r.listing_id, r.rating, r.comment, r.created_at, u.username
+SELECT r.id, r.user_id, r.listing_id, r.rating, r.cleanliness_rating, r.accuracy_rating, r.location_rating, r.value_rating, r.communication_rating, r.comment, r.host_reply, r.host_replied_at, r.created_at, u.username
 FROM reviews r
 JOIN listings l ON r.listing_id = l.id
 JOIN users u ON r.user_id = u.id
-WHERE listing_id = $1
+WHERE listing_id = $1 AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'
+ORDER BY r.created_at DESC
 `
 
 type GetListingReviewsRow struct {
-	ID        int32          `json:"id"`
-	UserID    int32          `json:"user_id"`
-	ListingID int32          `json:"listing_id"`
-	Rating    int32          `json:"rating"`
-	Comment   sql.NullString `json:"comment"`
-	CreatedAt sql.NullTime   `json:"created_at"`
-	Username  string         `json:"username"`
+	ID                  int32          `json:"id"`
+	UserID              int32          `json:"user_id"`
+	ListingID           int32          `json:"listing_id"`
+	Rating              int32          `json:"rating"`
+	CleanlinessRating   sql.NullInt32  `json:"cleanliness_rating"`
+	AccuracyRating      sql.NullInt32  `json:"accuracy_rating"`
+	LocationRating      sql.NullInt32  `json:"location_rating"`
+	ValueRating         sql.NullInt32  `json:"value_rating"`
+	CommunicationRating sql.NullInt32  `json:"communication_rating"`
+	Comment             sql.NullString `json:"comment"`
+	HostReply           sql.NullString `json:"host_reply"`
+	HostRepliedAt       sql.NullTime   `json:"host_replied_at"`
+	CreatedAt           sql.NullTime   `json:"created_at"`
+	Username            string         `json:"username"`
 }
 
 func (q *Queries) GetListingReviews(ctx context.Context, listingID int32) ([]GetListingReviewsRow, error) {
@@ -89,7 +200,14 @@ func (q *Queries) GetListingReviews(ctx context.Context, listingID int32) ([]Get
 			&i.UserID,
 			&i.ListingID,
 			&i.Rating,
+			&i.CleanlinessRating,
+			&i.AccuracyRating,
+			&i.LocationRating,
+			&i.ValueRating,
+			&i.CommunicationRating,
 			&i.Comment,
+			&i.HostReply,
+			&i.HostRepliedAt,
 			&i.CreatedAt,
 			&i.Username,
 		); err != nil {
@@ -106,8 +224,40 @@ func (q *Queries) GetListingReviews(ctx context.Context, listingID int32) ([]Get
 	return items, nil
 }
 
+const getReviewByBookingID = `-- name: GetReviewByBookingID :one
+SELECT id, user_id, listing_id, rating, comment, created_at, booking_id, cleanliness_rating, accuracy_rating, location_rating, value_rating, communication_rating, host_reply, host_replied_at, published_at, moderation_status, moderation_reason, comment_fingerprint
+FROM reviews
+WHERE booking_id = $1
+`
+
+func (q *Queries) GetReviewByBookingID(ctx context.Context, bookingID sql.NullInt32) (Review, error) {
+	row := q.db.QueryRowContext(ctx, getReviewByBookingID, bookingID)
+	var i Review
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ListingID,
+		&i.Rating,
+		&i.Comment,
+		&i.CreatedAt,
+		&i.BookingID,
+		&i.CleanlinessRating,
+		&i.AccuracyRating,
+		&i.LocationRating,
+		&i.ValueRating,
+		&i.CommunicationRating,
+		&i.HostReply,
+		&i.HostRepliedAt,
+		&i.PublishedAt,
+		&i.ModerationStatus,
+		&i.ModerationReason,
+		&i.CommentFingerprint,
+	)
+	return i, err
+}
+
 const getReviewByID = `-- name: GetReviewByID :one
-SELECT id, user_id, listing_id, rating, comment, created_at
+SELECT id, user_id, listing_id, rating, comment, created_at, booking_id, cleanliness_rating, accuracy_rating, location_rating, value_rating, communication_rating, host_reply, host_replied_at, published_at, moderation_status, moderation_reason, comment_fingerprint
 FROM reviews
 WHERE id = $1 and user_id = $2
 `
@@ -127,24 +277,42 @@ func (q *Queries) GetReviewByID(ctx context.Context, arg GetReviewByIDParams) (R
 		&i.Rating,
 		&i.Comment,
 		&i.CreatedAt,
+		&i.BookingID,
+		&i.CleanlinessRating,
+		&i.AccuracyRating,
+		&i.LocationRating,
+		&i.ValueRating,
+		&i.CommunicationRating,
+		&i.HostReply,
+		&i.HostRepliedAt,
+		&i.PublishedAt,
+		&i.ModerationStatus,
+		&i.ModerationReason,
+		&i.CommentFingerprint,
 	)
 	return i, err
 }
 
 const getReviewsByListingID = `-- name: GetReviewsByListingID :many
-SELECT r.id, r.user_id, r.listing_id, r.rating, r.comment, r.created_at
+SELECT r.id, r.user_id, r.listing_id, r.rating, r.comment, r.created_at, r.booking_id, r.cleanliness_rating, r.accuracy_rating, r.location_rating, r.value_rating, r.communication_rating, r.host_reply, r.host_replied_at, r.published_at, r.moderation_status, r.moderation_reason, r.comment_fingerprint
 FROM reviews r
 JOIN listings l ON r.listing_id = l.id
-WHERE listing_id = $1 AND admin_id= $2
+WHERE r.listing_id = $1
+    AND ((l.organization_id IS NULL AND l.admin_id = $2)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $2 AND m.role = ANY($3::text[])))
+    AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'
 `
 
 type GetReviewsByListingIDParams struct {
-	ListingID int32 `json:"listing_id"`
-	AdminID   int32 `json:"admin_id"`
+	ListingID int32    `json:"listing_id"`
+	AdminID   int32    `json:"admin_id"`
+	Roles     []string `json:"roles"`
 }
 
 func (q *Queries) GetReviewsByListingID(ctx context.Context, arg GetReviewsByListingIDParams) ([]Review, error) {
-	rows, err := q.db.QueryContext(ctx, getReviewsByListingID, arg.ListingID, arg.AdminID)
+	rows, err := q.db.QueryContext(ctx, getReviewsByListingID, arg.ListingID, arg.AdminID, pq.Array(arg.Roles))
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +327,18 @@ func (q *Queries) GetReviewsByListingID(ctx context.Context, arg GetReviewsByLis
 			&i.Rating,
 			&i.Comment,
 			&i.CreatedAt,
+			&i.BookingID,
+			&i.CleanlinessRating,
+			&i.AccuracyRating,
+			&i.LocationRating,
+			&i.ValueRating,
+			&i.CommunicationRating,
+			&i.HostReply,
+			&i.HostRepliedAt,
+			&i.PublishedAt,
+			&i.ModerationStatus,
+			&i.ModerationReason,
+			&i.CommentFingerprint,
 		); err != nil {
 			return nil, err
 		}
@@ -172,3 +352,73 @@ func (q *Queries) GetReviewsByListingID(ctx context.Context, arg GetReviewsByLis
 	}
 	return items, nil
 }
+
+const publishExpiredReviews = `-- name: PublishExpiredReviews :execrows
+UPDATE reviews r
+SET published_at = NOW()
+FROM bookings b
+WHERE r.booking_id = b.id
+  AND r.published_at IS NULL
+  AND b.check_out_date < $1::timestamp
+`
+
+func (q *Queries) PublishExpiredReviews(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, publishExpiredReviews, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const replyToReview = `-- name: ReplyToReview :one
+-- Hosts can only reply to reviews guests can see.
+UPDATE reviews r
+SET host_reply = $1, host_replied_at = NOW()
+FROM listings l
+WHERE r.id = $2 AND r.listing_id = l.id
+    AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'
+    AND ((l.organization_id IS NULL AND l.admin_id = $3)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $3 AND m.role = ANY($4::text[])))
+RETURNING r.id, r.user_id, r.listing_id, r.rating, r.comment, r.created_at, r.booking_id, r.cleanliness_rating, r.accuracy_rating, r.location_rating, r.value_rating, r.communication_rating, r.host_reply, r.host_replied_at, r.published_at, r.moderation_status, r.moderation_reason, r.comment_fingerprint
+`
+
+type ReplyToReviewParams struct {
+	HostReply sql.NullString `json:"host_reply"`
+	ID        int32          `json:"id"`
+	AdminID   int32          `json:"admin_id"`
+	Roles     []string       `json:"roles"`
+}
+
+// Hosts can only reply to reviews guests can see.
+func (q *Queries) ReplyToReview(ctx context.Context, arg ReplyToReviewParams) (Review, error) {
+	row := q.db.QueryRowContext(ctx, replyToReview,
+		arg.HostReply,
+		arg.ID,
+		arg.AdminID,
+		pq.Array(arg.Roles),
+	)
+	var i Review
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ListingID,
+		&i.Rating,
+		&i.Comment,
+		&i.CreatedAt,
+		&i.BookingID,
+		&i.CleanlinessRating,
+		&i.AccuracyRating,
+		&i.LocationRating,
+		&i.ValueRating,
+		&i.CommunicationRating,
+		&i.HostReply,
+		&i.HostRepliedAt,
+		&i.PublishedAt,
+		&i.ModerationStatus,
+		&i.ModerationReason,
+		&i.CommentFingerprint,
+	)
+	return i, err
+}
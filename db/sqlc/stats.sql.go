@@ -8,90 +8,173 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
 )
 
-const createStat = `-- name: CreateStat :one
-INSERT INTO stats (listing_id, total_views, total_bookings, average_rating)
-VALUES ($1, $2, $3, $4)
-RETURNING id, listing_id, total_views, total_bookings, average_rating, created_at
+const getAdminDailyStats = `-- name: GetAdminDailyStats :many
+WITH days AS (
+    SELECT generate_series($1::date, $2::date - 1, INTERVAL '1 day')::date AS day
+), scope AS (
+    SELECT l.id, l.created_at
+    FROM listings l
+    WHERE ((l.organization_id IS NULL AND l.admin_id = $3)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = $3 AND m.role = ANY($4::text[])))
+      AND ($5::int IS NULL OR l.id = $5::int)
+)
+SELECT
+    d.day,
+    (SELECT COUNT(*) FROM scope s
+     WHERE s.created_at IS NULL OR s.created_at::date <= d.day)::int AS listing_nights,
+    (SELECT COALESCE(SUM(v.views), 0) FROM listing_daily_views v
+     JOIN scope s ON s.id = v.listing_id
+     WHERE v.day = d.day)::int AS views,
+    (SELECT COALESCE(SUM(v.unique_views), 0) FROM listing_daily_views v
+     JOIN scope s ON s.id = v.listing_id
+     WHERE v.day = d.day)::int AS unique_views,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.created_at::date = d.day)::int AS booking_requests,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.created_at::date = d.day AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL)::int AS confirmed_bookings,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.created_at::date = d.day AND b.status = 'cancelled')::int AS cancellations,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.check_in_date <= d.day AND b.check_out_date > d.day
+       AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL)::int AS booked_nights,
+    (SELECT COALESCE(SUM(b.total_amount / GREATEST(b.check_out_date - b.check_in_date, 1)), 0) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.check_in_date <= d.day AND b.check_out_date > d.day
+       AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL)::float8 AS revenue
+FROM days d
+ORDER BY d.day
 `
 
-type CreateStatParams struct {
-	ListingID     int32          `json:"listing_id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
+type GetAdminDailyStatsParams struct {
+	FromDate  time.Time     `json:"from_date"`
+	ToDate    time.Time     `json:"to_date"`
+	AdminID   int32         `json:"admin_id"`
+	Roles     []string      `json:"roles"`
+	ListingID sql.NullInt32 `json:"listing_id"`
 }
 
-type CreateStatRow struct {
-	ID            int32          `json:"id"`
-	ListingID     int32          `json:"listing_id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
+type GetAdminDailyStatsRow struct {
+	Day               time.Time `json:"day"`
+	ListingNights     int32     `json:"listing_nights"`
+	Views             int32     `json:"views"`
+	UniqueViews       int32     `json:"unique_views"`
+	BookingRequests   int32     `json:"booking_requests"`
+	ConfirmedBookings int32     `json:"confirmed_bookings"`
+	Cancellations     int32     `json:"cancellations"`
+	BookedNights      int32     `json:"booked_nights"`
+	Revenue           float64   `json:"revenue"`
 }
 
-func (q *Queries) CreateStat(ctx context.Context, arg CreateStatParams) (CreateStatRow, error) {
-	row := q.db.QueryRowContext(ctx, createStat,
+// Returns one row per day in [from_date, to_date) for the listings the admin hosts, or
+// for one of them when listing_id is set. Bookings count on the day they were requested; booked
+// nights and revenue count on the nights stayed, with the booking amount spread evenly.
+func (q *Queries) GetAdminDailyStats(ctx context.Context, arg GetAdminDailyStatsParams) ([]GetAdminDailyStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAdminDailyStats,
+		arg.FromDate,
+		arg.ToDate,
+		arg.AdminID,
+		pq.Array(arg.Roles),
 		arg.ListingID,
-		arg.TotalViews,
-		arg.TotalBookings,
-		arg.AverageRating,
 	)
-	var i CreateStatRow
-	err := row.Scan(
-		&i.ID,
-		&i.ListingID,
-		&i.TotalViews,
-		&i.TotalBookings,
-		&i.AverageRating,
-		&i.CreatedAt,
-	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAdminDailyStatsRow
+	for rows.Next() {
+		var i GetAdminDailyStatsRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.ListingNights,
+			&i.Views,
+			&i.UniqueViews,
+			&i.BookingRequests,
+			&i.ConfirmedBookings,
+			&i.Cancellations,
+			&i.BookedNights,
+			&i.Revenue,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const deleteStat = `-- name: DeleteStat :exec
-DELETE FROM stats
-WHERE id = $1
+const getAdminListingStats = `-- name: GetAdminListingStats :many
+SELECT
+    l.id,
+    l.title,
+    COALESCE(s.total_views, 0)::int AS total_views,
+    COALESCE(s.unique_views, 0)::int AS unique_views,
+    COALESCE(s.total_bookings, 0)::int AS total_bookings,
+    COALESCE(s.revenue, 0)::float8 AS revenue,
+    COALESCE(s.average_rating, 0)::float8 AS average_rating,
+    COALESCE(s.review_count, 0)::int AS review_count,
+    s.updated_at
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
+WHERE (l.organization_id IS NULL AND l.admin_id = $1)
+    OR l.organization_id IN (
+        SELECT m.organization_id FROM organization_members m
+        WHERE m.admin_id = $1 AND m.role = ANY($2::text[]))
+ORDER BY total_views DESC
 `
 
-func (q *Queries) DeleteStat(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, deleteStat, id)
-	return err
+type GetAdminListingStatsParams struct {
+	AdminID int32    `json:"admin_id"`
+	Roles   []string `json:"roles"`
 }
 
-const getAllStats = `-- name: GetAllStats :many
-SELECT id, listing_id, total_views, total_bookings, average_rating, created_at
-FROM stats
-ORDER BY created_at DESC
-`
-
-type GetAllStatsRow struct {
-	ID            int32          `json:"id"`
-	ListingID     int32          `json:"listing_id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
+type GetAdminListingStatsRow struct {
+	ID            int32        `json:"id"`
+	Title         string       `json:"title"`
+	TotalViews    int32        `json:"total_views"`
+	UniqueViews   int32        `json:"unique_views"`
+	TotalBookings int32        `json:"total_bookings"`
+	Revenue       float64      `json:"revenue"`
+	AverageRating float64      `json:"average_rating"`
+	ReviewCount   int32        `json:"review_count"`
+	UpdatedAt     sql.NullTime `json:"updated_at"`
 }
 
-func (q *Queries) GetAllStats(ctx context.Context) ([]GetAllStatsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getAllStats)
+// Returns the aggregates of the listings the admin hosts.
+func (q *Queries) GetAdminListingStats(ctx context.Context, arg GetAdminListingStatsParams) ([]GetAdminListingStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAdminListingStats, arg.AdminID, pq.Array(arg.Roles))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetAllStatsRow
+	var items []GetAdminListingStatsRow
 	for rows.Next() {
-		var i GetAllStatsRow
+		var i GetAdminListingStatsRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.ListingID,
+			&i.Title,
 			&i.TotalViews,
+			&i.UniqueViews,
 			&i.TotalBookings,
+			&i.Revenue,
 			&i.AverageRating,
-			&i.CreatedAt,
+			&i.ReviewCount,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -106,87 +189,111 @@ func (q *Queries) GetAllStats(ctx context.Context) ([]GetAllStatsRow, error) {
 	return items, nil
 }
 
-const getStatByID = `-- name: GetStatByID :one
-SELECT id, listing_id, total_views, total_bookings, average_rating, created_at
+const getStatsByListingID = `-- name: GetStatsByListingID :one
+SELECT id, listing_id, admin_id, total_views, total_bookings, average_rating, created_at, review_count, revenue, updated_at, unique_views
 FROM stats
-WHERE id = $1
+WHERE listing_id = $1
 `
 
-type GetStatByIDRow struct {
-	ID            int32          `json:"id"`
-	ListingID     int32          `json:"listing_id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
-}
-
-func (q *Queries) GetStatByID(ctx context.Context, id int32) (GetStatByIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getStatByID, id)
-	var i GetStatByIDRow
+func (q *Queries) GetStatsByListingID(ctx context.Context, listingID int32) (Stat, error) {
+	row := q.db.QueryRowContext(ctx, getStatsByListingID, listingID)
+	var i Stat
 	err := row.Scan(
 		&i.ID,
 		&i.ListingID,
+		&i.AdminID,
 		&i.TotalViews,
 		&i.TotalBookings,
 		&i.AverageRating,
 		&i.CreatedAt,
+		&i.ReviewCount,
+		&i.Revenue,
+		&i.UpdatedAt,
+		&i.UniqueViews,
 	)
 	return i, err
 }
 
-const getStatsByListingID = `-- name: GetStatsByListingID :one
-SELECT id, listing_id, total_views, total_bookings, average_rating, created_at
-FROM stats
-WHERE listing_id = $1
+const recordListingViews = `-- name: RecordListingViews :execrows
+WITH previous AS (
+    SELECT unique_views
+    FROM listing_daily_views
+    WHERE listing_id = $1 AND day = $2
+), viewed AS (
+    UPDATE listings
+    SET total_views = COALESCE(total_views, 0) + $3::int
+    WHERE id = $1
+    RETURNING id, admin_id, total_views
+), daily AS (
+    INSERT INTO listing_daily_views (listing_id, day, views, unique_views)
+    SELECT id, $2::date, $3::int, $4::int FROM viewed
+    ON CONFLICT (listing_id, day) DO UPDATE
+    SET views = listing_daily_views.views + EXCLUDED.views,
+        unique_views = GREATEST(listing_daily_views.unique_views, EXCLUDED.unique_views)
+)
+INSERT INTO stats (listing_id, admin_id, total_views, unique_views)
+SELECT id, admin_id, total_views, $4::int FROM viewed
+ON CONFLICT (listing_id) DO UPDATE
+SET total_views = EXCLUDED.total_views,
+    unique_views = stats.unique_views + GREATEST(EXCLUDED.unique_views - COALESCE((SELECT unique_views FROM previous), 0), 0),
+    updated_at = NOW()
 `
 
-type GetStatsByListingIDRow struct {
-	ID            int32          `json:"id"`
-	ListingID     int32          `json:"listing_id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
-	CreatedAt     sql.NullTime   `json:"created_at"`
+type RecordListingViewsParams struct {
+	ListingID   int32     `json:"listing_id"`
+	Day         time.Time `json:"day"`
+	Views       int32     `json:"views"`
+	UniqueViews int32     `json:"unique_views"`
 }
 
-func (q *Queries) GetStatsByListingID(ctx context.Context, listingID int32) (GetStatsByListingIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getStatsByListingID, listingID)
-	var i GetStatsByListingIDRow
-	err := row.Scan(
-		&i.ID,
-		&i.ListingID,
-		&i.TotalViews,
-		&i.TotalBookings,
-		&i.AverageRating,
-		&i.CreatedAt,
+// Adds a flushed batch of views to the listing's lifetime and daily counts. unique_views
+// is the day's distinct visitor count so far, so the daily figure only ever grows and
+// stats gains the difference.
+func (q *Queries) RecordListingViews(ctx context.Context, arg RecordListingViewsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordListingViews,
+		arg.ListingID,
+		arg.Day,
+		arg.Views,
+		arg.UniqueViews,
 	)
-	return i, err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const updateStat = `-- name: UpdateStat :exec
-UPDATE stats
+const refreshListingStats = `-- name: RefreshListingStats :exec
+INSERT INTO stats (listing_id, admin_id, total_views, unique_views, total_bookings, revenue, average_rating, review_count, updated_at)
+SELECT
+    l.id,
+    l.admin_id,
+    COALESCE(l.total_views, 0),
+    (SELECT COALESCE(SUM(v.unique_views), 0) FROM listing_daily_views v WHERE v.listing_id = l.id),
+    (SELECT COUNT(*) FROM bookings b
+     WHERE b.listing_id = l.id AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL),
+    (SELECT COALESCE(SUM(b.total_amount), 0) FROM bookings b
+     WHERE b.listing_id = l.id AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL),
+    (SELECT COALESCE(ROUND(AVG(r.rating)::numeric, 2), 0) FROM reviews r
+     WHERE r.listing_id = l.id AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'),
+    (SELECT COUNT(*) FROM reviews r
+     WHERE r.listing_id = l.id AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'),
+    NOW()
+FROM listings l
+WHERE $1::int IS NULL OR l.id = $1::int
+ON CONFLICT (listing_id) DO UPDATE
 SET
-    total_views = $2,
-    total_bookings = $3,
-    average_rating = $4
-WHERE id = $1
-RETURNING id, listing_id, total_views, total_bookings, average_rating, created_at
+    admin_id = EXCLUDED.admin_id,
+    total_views = EXCLUDED.total_views,
+    unique_views = EXCLUDED.unique_views,
+    total_bookings = EXCLUDED.total_bookings,
+    revenue = EXCLUDED.revenue,
+    average_rating = EXCLUDED.average_rating,
+    review_count = EXCLUDED.review_count,
+    updated_at = EXCLUDED.updated_at
 `
 
-type UpdateStatParams struct {
-	ID            int32          `json:"id"`
-	TotalViews    sql.NullInt32  `json:"total_views"`
-	TotalBookings sql.NullInt32  `json:"total_bookings"`
-	AverageRating sql.NullString `json:"average_rating"`
-}
-
-func (q *Queries) UpdateStat(ctx context.Context, arg UpdateStatParams) error {
-	_, err := q.db.ExecContext(ctx, updateStat,
-		arg.ID,
-		arg.TotalViews,
-		arg.TotalBookings,
-		arg.AverageRating,
-	)
+// Recomputes the aggregates of one listing, or of every listing when listing_id is null.
+func (q *Queries) RefreshListingStats(ctx context.Context, listingID sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, refreshListingStats, listingID)
 	return err
 }
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Store runs queries on their own, like Queries, and the operations that must run in one
+// transaction.
+type Store struct {
+	*Queries
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		Queries: New(db),
+		db:      db,
+	}
+}
+
+// execTx runs fn in a transaction and commits it if fn returns no error.
+func (s *Store) execTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(s.WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceFeedBlockedDatesTx replaces the blocked dates imported from a calendar feed, so a
+// failed import keeps the previous dates.
+func (s *Store) ReplaceFeedBlockedDatesTx(ctx context.Context, feedID sql.NullInt32, dates []CreateBlockedDateParams) error {
+	return s.execTx(ctx, func(q *Queries) error {
+		if err := q.DeleteFeedBlockedDates(ctx, feedID); err != nil {
+			return fmt.Errorf("delete blocked dates: %w", err)
+		}
+		for _, arg := range dates {
+			if _, err := q.CreateBlockedDate(ctx, arg); err != nil {
+				return fmt.Errorf("create blocked date: %w", err)
+			}
+		}
+		return nil
+	})
+}
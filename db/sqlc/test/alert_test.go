@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+func TestPriceDropAlerts(t *testing.T) {
+	listing := CreateListing(t)
+	fan := CreateRandomUser(t)
+	optedOut := CreateRandomUser(t)
+
+	for _, user := range []db.User{fan, optedOut} {
+		_, err := testQueries.CreateFavorite(context.Background(), db.CreateFavoriteParams{
+			UserID:    user.ID,
+			ListingID: listing.ID,
+		})
+		require.NoError(t, err)
+	}
+	_, err := testQueries.UpsertAlertPreferences(context.Background(), db.UpsertAlertPreferencesParams{
+		UserID:        optedOut.ID,
+		Frequency:     "daily",
+		Availability:  true,
+		SavedSearches: true,
+	})
+	require.NoError(t, err)
+
+	created, err := testQueries.CreatePriceDropAlerts(context.Background(), db.CreatePriceDropAlertsParams{
+		ListingID: listing.ID,
+		Message:   "price dropped",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), created)
+
+	alerts, err := testQueries.GetUserListingAlerts(context.Background(), db.GetUserListingAlertsParams{UserID: fan.ID})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "price_drop", alerts[0].Kind)
+	require.Equal(t, listing.Title, alerts[0].Title)
+
+	updated, err := testQueries.MarkListingAlertRead(context.Background(), db.MarkListingAlertReadParams{
+		ID:     alerts[0].ID,
+		UserID: fan.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated)
+
+	unread, err := testQueries.GetUserListingAlerts(context.Background(), db.GetUserListingAlertsParams{
+		UserID:     fan.ID,
+		UnreadOnly: true,
+	})
+	require.NoError(t, err)
+	require.Empty(t, unread)
+}
+
+func TestSavedSearchAlerts(t *testing.T) {
+	listing := CreateListing(t)
+	user := CreateRandomUser(t)
+
+	arg := db.CreateSavedSearchParams{
+		UserID:        user.ID,
+		Name:          "match",
+		Keyword:       listing.Title[2:7],
+		AlertsEnabled: true,
+	}
+	match, err := testQueries.CreateSavedSearch(context.Background(), arg)
+	require.NoError(t, err)
+
+	// A second matching search does not alert the same user twice.
+	arg.Name = "also match"
+	_, err = testQueries.CreateSavedSearch(context.Background(), arg)
+	require.NoError(t, err)
+
+	arg.Name = "too cheap"
+	arg.MaxPrice = sql.NullString{String: "0.50", Valid: true}
+	_, err = testQueries.CreateSavedSearch(context.Background(), arg)
+	require.NoError(t, err)
+
+	created, err := testQueries.CreateSavedSearchAlerts(context.Background(), db.CreateSavedSearchAlertsParams{
+		Message:   "new match",
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), created)
+
+	alerts, err := testQueries.GetUserListingAlerts(context.Background(), db.GetUserListingAlertsParams{UserID: user.ID})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, match.ID, alerts[0].SavedSearchID.Int32)
+	require.Equal(t, "match", alerts[0].SavedSearchName.String)
+}
+
+func TestAlertDigests(t *testing.T) {
+	listing := CreateListing(t)
+	user := CreateRandomUser(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, err := testQueries.CreateFavorite(context.Background(), db.CreateFavoriteParams{
+		UserID:    user.ID,
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	_, err = testQueries.CreateListingAvailableAlerts(context.Background(), db.CreateListingAvailableAlertsParams{
+		ListingID: listing.ID,
+		Message:   "available again",
+	})
+	require.NoError(t, err)
+
+	isDue := func(at time.Time) bool {
+		due, err := testQueries.GetDueAlertDigests(context.Background(), at)
+		require.NoError(t, err)
+		for _, d := range due {
+			if d.ID == user.ID {
+				return true
+			}
+		}
+		return false
+	}
+	require.True(t, isDue(now))
+
+	pending, err := testQueries.GetPendingListingAlerts(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	emailedAt := sql.NullTime{Time: now, Valid: true}
+	err = testQueries.MarkListingAlertsEmailed(context.Background(), db.MarkListingAlertsEmailedParams{
+		EmailedAt: emailedAt,
+		Ids:       []int32{pending[0].ID},
+	})
+	require.NoError(t, err)
+	err = testQueries.SetAlertDigestSent(context.Background(), db.SetAlertDigestSentParams{
+		UserID:       user.ID,
+		LastDigestAt: emailedAt,
+	})
+	require.NoError(t, err)
+
+	// A new alert waits for the next daily digest.
+	_, err = testQueries.CreateListingAvailableAlerts(context.Background(), db.CreateListingAvailableAlertsParams{
+		ListingID: listing.ID,
+		Message:   "available again",
+	})
+	require.NoError(t, err)
+	require.False(t, isDue(now.Add(time.Hour)))
+	require.True(t, isDue(now.Add(24*time.Hour)))
+}
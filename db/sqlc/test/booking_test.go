@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
@@ -70,3 +71,146 @@ func TestGetBooking(t *testing.T) {
 	require.Equal(t, booking.TotalAmount, bookingFromDB.TotalAmount)
 	require.Equal(t, booking.CreatedAt.Time.UTC(), bookingFromDB.CreatedAt.Time.UTC())
 }
+
+func TestCancelUserBooking(t *testing.T) {
+	booking := createUserBooking(t)
+	arg := db.UpdateBookingStatusByIDAndUserIDParams{
+		ID:     booking.ID,
+		UserID: booking.UserID,
+	}
+
+	cancelled, err := testQueries.UpdateBookingStatusByIDAndUserID(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cancelled)
+
+	// A cancelled booking is final.
+	cancelled, err = testQueries.UpdateBookingStatusByIDAndUserID(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, cancelled)
+}
+
+func TestUpdateBookingStay(t *testing.T) {
+	booking := createUserBooking(t)
+	arg := db.UpdateBookingStayParams{
+		ID:           booking.ID,
+		CheckInDate:  booking.CheckInDate.AddDate(0, 0, 1),
+		CheckOutDate: booking.CheckOutDate.AddDate(0, 0, 1),
+		Guests:       2,
+		TotalAmount:  booking.TotalAmount,
+	}
+
+	// The booking is still pending.
+	moved, err := testQueries.UpdateBookingStay(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, moved)
+}
+
+func TestExpireUnpaidPendingBookings(t *testing.T) {
+	booking := createUserBooking(t)
+
+	rows, err := testQueries.ExpireUnpaidPendingBookings(context.Background(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	var expired bool
+	for _, row := range rows {
+		if row.ID == booking.ID {
+			expired = true
+			require.Equal(t, "expired", row.Status.String)
+		}
+	}
+	require.True(t, expired)
+
+	// No other booking holds the listing, so it is open again.
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+	require.True(t, listing.Available.Bool)
+
+	// A second run must not return the same booking again.
+	rows, err = testQueries.ExpireUnpaidPendingBookings(context.Background(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NotEqual(t, booking.ID, row.ID)
+	}
+}
+
+func TestCountOverlappingBookings(t *testing.T) {
+	booking := createUserBooking(t)
+
+	arg := db.CountOverlappingBookingsParams{
+		ListingID:    booking.ListingID,
+		ID:           0,
+		CheckInDate:  util.GenerateDate(2024, time.November, 1, 0, 0, 0),
+		CheckOutDate: util.GenerateDate(2024, time.December, 10, 0, 0, 0),
+	}
+	count, err := testQueries.CountOverlappingBookings(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	// The booking itself is ignored when it is the one being changed.
+	arg.ID = booking.ID
+	count, err = testQueries.CountOverlappingBookings(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// A stay starting on the checkout day does not overlap.
+	arg.ID = 0
+	arg.CheckInDate = booking.CheckOutDate
+	arg.CheckOutDate = booking.CheckOutDate.AddDate(0, 0, 3)
+	count, err = testQueries.CountOverlappingBookings(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestPaymentTransactionExists(t *testing.T) {
+	booking := createUserBooking(t)
+	transactionID := sql.NullString{String: "pi_" + util.RandomString(16), Valid: true}
+
+	exists, err := testQueries.PaymentTransactionExists(context.Background(), transactionID)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	arg := db.CreatePaymentParams{
+		BookingID:     booking.ID,
+		Amount:        booking.TotalAmount,
+		Status:        sql.NullString{String: "succeeded", Valid: true},
+		TransactionID: transactionID,
+		UserID:        booking.UserID,
+	}
+	_, err = testQueries.CreatePayment(context.Background(), arg)
+	require.NoError(t, err)
+
+	exists, err = testQueries.PaymentTransactionExists(context.Background(), transactionID)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// The same payment cannot back a second booking.
+	arg.BookingID = createUserBooking(t).ID
+	_, err = testQueries.CreatePayment(context.Background(), arg)
+	require.Error(t, err)
+}
+
+func TestAddPaymentRefund(t *testing.T) {
+	booking := createUserBooking(t)
+	p, err := testQueries.CreatePayment(context.Background(), db.CreatePaymentParams{
+		BookingID:     booking.ID,
+		Amount:        "100.00",
+		Status:        sql.NullString{String: "succeeded", Valid: true},
+		TransactionID: sql.NullString{String: "pi_" + util.RandomString(16), Valid: true},
+		UserID:        booking.UserID,
+	})
+	require.NoError(t, err)
+
+	recorded, err := testQueries.AddPaymentRefund(context.Background(), db.AddPaymentRefundParams{ID: p.ID, Amount: "60.00"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), recorded)
+
+	// Only 40.00 of the payment is left to refund.
+	recorded, err = testQueries.AddPaymentRefund(context.Background(), db.AddPaymentRefundParams{ID: p.ID, Amount: "60.00"})
+	require.NoError(t, err)
+	require.Zero(t, recorded)
+
+	payments, err := testQueries.GetPaymentsByBookingID(context.Background(), booking.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	require.Equal(t, "60.00", payments[0].RefundedAmount)
+}
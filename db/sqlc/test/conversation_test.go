@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+func TestBookingConversation(t *testing.T) {
+	booking := createUserBooking(t)
+
+	conversation, err := testQueries.CreateBookingConversation(context.Background(), booking.ID)
+	require.NoError(t, err)
+	require.Equal(t, booking.UserID, conversation.UserID)
+	require.Equal(t, booking.ListingID, conversation.ListingID)
+
+	again, err := testQueries.CreateBookingConversation(context.Background(), booking.ID)
+	require.NoError(t, err)
+	require.Equal(t, conversation.ID, again.ID)
+
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetConversationForAdmin(context.Background(), db.GetConversationForAdminParams{
+		ID:      conversation.ID,
+		AdminID: createRandomAdmin(t).ID,
+		Roles:   team.RolesWith(team.Messaging),
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	hosts, err := testQueries.GetConversationHosts(context.Background(), db.GetConversationHostsParams{
+		ID:    conversation.ID,
+		Roles: team.RolesWith(team.Messaging),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{listing.AdminID}, hosts)
+}
+
+func TestConversationMessages(t *testing.T) {
+	listing := CreateListing(t)
+	user := CreateRandomUser(t)
+
+	conversation, err := testQueries.CreateInquiryConversation(context.Background(), db.CreateInquiryConversationParams{
+		ListingID: listing.ID,
+		UserID:    user.ID,
+	})
+	require.NoError(t, err)
+	require.False(t, conversation.BookingID.Valid)
+
+	question, err := testQueries.CreateMessage(context.Background(), db.CreateMessageParams{
+		ConversationID: conversation.ID,
+		SenderRole:     "guest",
+		Body:           "Is parking included?",
+		Attachments:    []string{},
+	})
+	require.NoError(t, err)
+
+	answer, err := testQueries.CreateMessage(context.Background(), db.CreateMessageParams{
+		ConversationID: conversation.ID,
+		SenderRole:     "host",
+		SenderAdminID:  sql.NullInt32{Int32: listing.AdminID, Valid: true},
+		Body:           "Yes, here is the map.",
+		Attachments:    []string{"messages/1/map.pdf"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"messages/1/map.pdf"}, answer.Attachments)
+
+	has, err := testQueries.ConversationHasAttachment(context.Background(), db.ConversationHasAttachmentParams{
+		ConversationID: conversation.ID,
+		Key:            "messages/1/map.pdf",
+	})
+	require.NoError(t, err)
+	require.True(t, has)
+	has, err = testQueries.ConversationHasAttachment(context.Background(), db.ConversationHasAttachmentParams{
+		ConversationID: conversation.ID,
+		Key:            "messages/1/other.pdf",
+	})
+	require.NoError(t, err)
+	require.False(t, has)
+
+	conversations, err := testQueries.GetUserConversations(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, conversations, 1)
+	require.Equal(t, int32(1), conversations[0].Unread)
+
+	hostConversations, err := testQueries.GetAdminConversations(context.Background(), db.GetAdminConversationsParams{
+		AdminID: listing.AdminID,
+		Roles:   team.RolesWith(team.Messaging),
+	})
+	require.NoError(t, err)
+	require.Len(t, hostConversations, 1)
+	require.Zero(t, hostConversations[0].Unread)
+
+	messages, err := testQueries.GetMessages(context.Background(), db.GetMessagesParams{
+		ConversationID: conversation.ID,
+		Limit:          1,
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, answer.ID, messages[0].ID)
+
+	messages, err = testQueries.GetMessages(context.Background(), db.GetMessagesParams{
+		ConversationID: conversation.ID,
+		Before:         sql.NullInt32{Int32: answer.ID, Valid: true},
+		Limit:          10,
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, question.ID, messages[0].ID)
+
+	markers, err := testQueries.MarkConversationRead(context.Background(), db.MarkConversationReadParams{
+		Reader: "guest",
+		ID:     conversation.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, answer.ID, markers.GuestLastReadID)
+	require.Equal(t, answer.ID, markers.HostLastReadID)
+
+	conversations, err = testQueries.GetUserConversations(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Zero(t, conversations[0].Unread)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+func createGuestReview(t *testing.T, booking db.Booking) db.GuestReview {
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+
+	arg := db.CreateGuestReviewParams{
+		BookingID:           booking.ID,
+		AdminID:             listing.AdminID,
+		UserID:              booking.UserID,
+		Rating:              4,
+		CleanlinessRating:   5,
+		CommunicationRating: 4,
+		HouseRulesRating:    3,
+		Comment:             util.RandomString(20),
+	}
+
+	review, err := testQueries.CreateGuestReview(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UserID, review.UserID)
+	require.Equal(t, arg.HouseRulesRating, review.HouseRulesRating)
+	require.False(t, review.PublishedAt.Valid)
+
+	return review
+}
+
+func TestPublishBookingReviews(t *testing.T) {
+	booking := createUserBooking(t)
+	review := createBookingReview(t, booking)
+
+	// Nothing is revealed while only the guest has written a review.
+	err := testQueries.PublishBookingReviews(context.Background(), review.BookingID)
+	require.NoError(t, err)
+	review, err = testQueries.GetReviewByBookingID(context.Background(), review.BookingID)
+	require.NoError(t, err)
+	require.False(t, review.PublishedAt.Valid)
+
+	createGuestReview(t, booking)
+
+	err = testQueries.PublishBookingReviews(context.Background(), review.BookingID)
+	require.NoError(t, err)
+
+	review, err = testQueries.GetReviewByBookingID(context.Background(), review.BookingID)
+	require.NoError(t, err)
+	require.True(t, review.PublishedAt.Valid)
+
+	guestReview, err := testQueries.GetGuestReviewByBookingID(context.Background(), booking.ID)
+	require.NoError(t, err)
+	require.True(t, guestReview.PublishedAt.Valid)
+
+	reputation, err := testQueries.GetGuestReputation(context.Background(), booking.UserID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), reputation.TotalReviews)
+	require.Equal(t, float64(4), reputation.AverageRating)
+
+	reviews, err := testQueries.GetGuestReviewsByUserID(context.Background(), booking.UserID)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+}
+
+func TestPublishExpiredGuestReviews(t *testing.T) {
+	booking := createUserBooking(t)
+	createGuestReview(t, booking)
+
+	reputation, err := testQueries.GetGuestReputation(context.Background(), booking.UserID)
+	require.NoError(t, err)
+	require.Zero(t, reputation.TotalReviews)
+
+	rows, err := testQueries.PublishExpiredGuestReviews(context.Background(), booking.CheckOutDate.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	reputation, err = testQueries.GetGuestReputation(context.Background(), booking.UserID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), reputation.TotalReviews)
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+func TestCalendarFeedBlockedDates(t *testing.T) {
+	listing := CreateListing(t)
+
+	feed, err := testQueries.CreateCalendarFeed(context.Background(), db.CreateCalendarFeedParams{
+		ListingID: listing.ID,
+		Name:      "Airbnb",
+		Url:       "https://www.airbnb.com/calendar/ical/" + util.RandomString(8) + ".ics",
+	})
+	require.NoError(t, err)
+	require.Equal(t, listing.ID, feed.ListingID)
+
+	start := util.GenerateDate(2030, time.March, 10, 0, 0, 0)
+	_, err = testQueries.CreateBlockedDate(context.Background(), db.CreateBlockedDateParams{
+		ListingID: listing.ID,
+		FeedID:    sql.NullInt32{Int32: feed.ID, Valid: true},
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, 3),
+	})
+	require.NoError(t, err)
+
+	count, err := testQueries.CountOverlappingBlockedDates(context.Background(), db.CountOverlappingBlockedDatesParams{
+		ListingID:    listing.ID,
+		CheckInDate:  start.AddDate(0, 0, -2),
+		CheckOutDate: start.AddDate(0, 0, 1),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	// Checking in on the day the block ends is allowed.
+	count, err = testQueries.CountOverlappingBlockedDates(context.Background(), db.CountOverlappingBlockedDatesParams{
+		ListingID:    listing.ID,
+		CheckInDate:  start.AddDate(0, 0, 3),
+		CheckOutDate: start.AddDate(0, 0, 5),
+	})
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Deleting the feed removes the dates it imported.
+	rows, err := testQueries.DeleteCalendarFeed(context.Background(), db.DeleteCalendarFeedParams{ID: feed.ID, ListingID: listing.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	blocks, err := testQueries.GetBlockedDatesByListingID(context.Background(), db.GetBlockedDatesByListingIDParams{
+		ListingID: listing.ID,
+		Since:     start.AddDate(0, 0, -30),
+	})
+	require.NoError(t, err)
+	require.Empty(t, blocks)
+}
+
+func TestReplaceFeedBlockedDatesTx(t *testing.T) {
+	listing := CreateListing(t)
+	store := db.NewStore(testDB)
+
+	feed, err := store.CreateCalendarFeed(context.Background(), db.CreateCalendarFeedParams{
+		ListingID: listing.ID,
+		Name:      "Airbnb",
+		Url:       "https://www.airbnb.com/calendar/ical/" + util.RandomString(8) + ".ics",
+	})
+	require.NoError(t, err)
+	feedID := sql.NullInt32{Int32: feed.ID, Valid: true}
+
+	start := util.GenerateDate(2030, time.April, 1, 0, 0, 0)
+	block := func(days int) db.CreateBlockedDateParams {
+		return db.CreateBlockedDateParams{
+			ListingID: listing.ID,
+			FeedID:    feedID,
+			StartDate: start.AddDate(0, 0, days),
+			EndDate:   start.AddDate(0, 0, days+2),
+		}
+	}
+	require.NoError(t, store.ReplaceFeedBlockedDatesTx(context.Background(), feedID, []db.CreateBlockedDateParams{block(0), block(10)}))
+
+	// A date that cannot be stored rolls the whole import back.
+	invalid := block(20)
+	invalid.ListingID = 0
+	require.Error(t, store.ReplaceFeedBlockedDatesTx(context.Background(), feedID, []db.CreateBlockedDateParams{block(5), invalid}))
+
+	blocks, err := store.GetBlockedDatesByListingID(context.Background(), db.GetBlockedDatesByListingIDParams{
+		ListingID: listing.ID,
+		Since:     start.AddDate(0, 0, -1),
+	})
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+func TestListingImageProcessing(t *testing.T) {
+	listing := CreateListing(t)
+
+	first, err := testQueries.CreateListingImage(context.Background(), db.CreateListingImageParams{
+		ListingID:  listing.ID,
+		StagedPath: sql.NullString{String: "assets/uploads/first", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pending", first.Status)
+
+	second, err := testQueries.CreateListingImage(context.Background(), db.CreateListingImageParams{
+		ListingID:  listing.ID,
+		StagedPath: sql.NullString{String: "assets/uploads/second", Valid: true},
+	})
+	require.NoError(t, err)
+
+	err = testQueries.UpsertListingImageVariant(context.Background(), db.UpsertListingImageVariantParams{
+		ImageID:   first.ID,
+		Name:      "large",
+		Format:    "jpeg",
+		Width:     1920,
+		Height:    1280,
+		ObjectKey: "listings/1/1/large.jpg",
+		Url:       "https://cdn.example.com/large.jpg",
+	})
+	require.NoError(t, err)
+
+	ready, err := testQueries.MarkListingImageReady(context.Background(), db.MarkListingImageReadyParams{
+		ID:       first.ID,
+		Url:      sql.NullString{String: "https://cdn.example.com/large.jpg", Valid: true},
+		Width:    sql.NullInt32{Int32: 4000, Valid: true},
+		Height:   sql.NullInt32{Int32: 2667, Valid: true},
+		Blurhash: sql.NullString{String: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), ready)
+
+	// A redelivered task cannot process the image twice.
+	ready, err = testQueries.MarkListingImageReady(context.Background(), db.MarkListingImageReadyParams{ID: first.ID})
+	require.NoError(t, err)
+	require.Zero(t, ready)
+
+	err = testQueries.MarkListingImageFailed(context.Background(), db.MarkListingImageFailedParams{
+		ID:    second.ID,
+		Error: sql.NullString{String: "invalid image", Valid: true},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, testQueries.SyncListingImageLinks(context.Background(), listing.ID))
+	updated, err := testQueries.GetListingByID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://cdn.example.com/large.jpg"}, updated.Imagelinks)
+
+	images, err := testQueries.GetListingImages(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	require.Equal(t, "ready", images[0].Status)
+	require.False(t, images[0].StagedPath.Valid)
+	require.Equal(t, "failed", images[1].Status)
+
+	variants, err := testQueries.GetListingImageVariants(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 1)
+
+	rows, err := testQueries.DeleteListingImage(context.Background(), db.DeleteListingImageParams{
+		ID:        first.ID,
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	// The deleted image's variant is now an orphan.
+	orphans, err := testQueries.GetOrphanedImages(context.Background(), db.GetOrphanedImagesParams{
+		CreatedAt: time.Now().Add(time.Hour),
+		Limit:     100,
+	})
+	require.NoError(t, err)
+	require.Contains(t, orphans, "listings/1/1/large.jpg")
+	require.NoError(t, testQueries.DeleteOrphanedImages(context.Background(), []string{"listings/1/1/large.jpg"}))
+}
+
+func TestArrangeListingImages(t *testing.T) {
+	listing := CreateListing(t)
+
+	var ids []int32
+	for i := 0; i < 3; i++ {
+		img, err := testQueries.CreateListingImage(context.Background(), db.CreateListingImageParams{ListingID: listing.ID})
+		require.NoError(t, err)
+		require.Equal(t, int32(i), img.Position)
+		_, err = testQueries.MarkListingImageReady(context.Background(), db.MarkListingImageReadyParams{
+			ID:  img.ID,
+			Url: sql.NullString{String: fmt.Sprintf("https://cdn.example.com/%d.jpg", i), Valid: true},
+		})
+		require.NoError(t, err)
+		ids = append(ids, img.ID)
+	}
+
+	rows, err := testQueries.ReorderListingImages(context.Background(), db.ReorderListingImagesParams{
+		Ids:       []int32{ids[2], ids[0], ids[1]},
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rows)
+
+	_, err = testQueries.SetListingImageCover(context.Background(), db.SetListingImageCoverParams{ID: ids[1], ListingID: listing.ID})
+	require.NoError(t, err)
+	_, err = testQueries.SetListingImageCover(context.Background(), db.SetListingImageCoverParams{ID: ids[0], ListingID: listing.ID})
+	require.NoError(t, err)
+
+	img, err := testQueries.UpdateListingImage(context.Background(), db.UpdateListingImageParams{
+		Caption:   sql.NullString{String: "Sea view", Valid: true},
+		Room:      sql.NullString{String: "view", Valid: true},
+		ID:        ids[2],
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Sea view", img.Caption)
+	require.Equal(t, "view", img.Room.String)
+
+	require.NoError(t, testQueries.SyncListingImageLinks(context.Background(), listing.ID))
+	updated, err := testQueries.GetListingByID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"https://cdn.example.com/0.jpg",
+		"https://cdn.example.com/2.jpg",
+		"https://cdn.example.com/1.jpg",
+	}, updated.Imagelinks)
+
+	images, err := testQueries.GetListingImages(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, ids[2], images[0].ID)
+	require.True(t, images[1].IsCover)
+	require.False(t, images[2].IsCover)
+}
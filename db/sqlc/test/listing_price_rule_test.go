@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+func TestListingPriceRules(t *testing.T) {
+	listing := CreateListing(t)
+
+	season, err := testQueries.CreateListingPriceRule(context.Background(), db.CreateListingPriceRuleParams{
+		ListingID:    listing.ID,
+		Name:         "High season",
+		Kind:         "season",
+		StartDate:    sql.NullTime{Time: util.GenerateDate(2030, time.December, 1, 0, 0, 0), Valid: true},
+		EndDate:      sql.NullTime{Time: util.GenerateDate(2031, time.January, 31, 0, 0, 0), Valid: true},
+		NightlyPrice: sql.NullString{String: "200.00", Valid: true},
+		MinStay:      sql.NullInt32{Int32: 3, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "season", season.Kind)
+
+	weekend, err := testQueries.CreateListingPriceRule(context.Background(), db.CreateListingPriceRuleParams{
+		ListingID:    listing.ID,
+		Name:         "Weekend",
+		Kind:         "weekday",
+		DaysOfWeek:   []int32{5, 6},
+		NightlyPrice: sql.NullString{String: "150.00", Valid: true},
+		Priority:     5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{5, 6}, weekend.DaysOfWeek)
+
+	rules, err := testQueries.GetListingPriceRules(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, weekend.ID, rules[0].ID)
+
+	updated, err := testQueries.UpdateListingPriceRule(context.Background(), db.UpdateListingPriceRuleParams{
+		ID:           weekend.ID,
+		ListingID:    listing.ID,
+		Name:         "Weekend",
+		Kind:         "weekday",
+		DaysOfWeek:   []int32{6},
+		NightlyPrice: sql.NullString{String: "175.00", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int32{6}, updated.DaysOfWeek)
+	require.Equal(t, "175.00", updated.NightlyPrice.String)
+
+	rows, err := testQueries.DeleteListingPriceRule(context.Background(), db.DeleteListingPriceRuleParams{ID: season.ID, ListingID: listing.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+}
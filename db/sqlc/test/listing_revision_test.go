@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+func TestListingRevisions(t *testing.T) {
+	listing := CreateListing(t)
+
+	first, err := testQueries.CreateListingRevision(context.Background(), db.CreateListingRevisionParams{
+		AdminID:   sql.NullInt32{Int32: listing.AdminID, Valid: true},
+		Note:      "Created",
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), first.Revision)
+	require.Equal(t, listing.Title, first.Title)
+	require.Equal(t, listing.Price, first.Price)
+
+	err = testQueries.UpdateListing(context.Background(), db.UpdateListingParams{
+		ID:      listing.ID,
+		AdminID: listing.AdminID,
+		Title:   sql.NullString{String: listing.Title + " updated", Valid: true},
+	})
+	require.NoError(t, err)
+
+	second, err := testQueries.CreateListingRevision(context.Background(), db.CreateListingRevisionParams{
+		Note:      "Edited",
+		ListingID: listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), second.Revision)
+	require.Equal(t, listing.Title+" updated", second.Title)
+
+	revisions, err := testQueries.GetListingRevisions(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, int32(2), revisions[0].Revision)
+
+	restored, err := testQueries.RestoreListingRevision(context.Background(), db.RestoreListingRevisionParams{
+		ID:       listing.ID,
+		Revision: first.Revision,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), restored)
+
+	row, err := testQueries.GetListingByID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, listing.Title, row.Title)
+
+	_, err = testQueries.GetListingRevision(context.Background(), db.GetListingRevisionParams{
+		ListingID: listing.ID,
+		Revision:  3,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestTransitionListing(t *testing.T) {
+	listing := CreateListing(t)
+
+	// The listing has left draft, so a transition from draft changes nothing.
+	n, err := testQueries.TransitionListing(context.Background(), db.TransitionListingParams{
+		Status:     "pending_review",
+		ID:         listing.ID,
+		FromStatus: "draft",
+	})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = testQueries.TransitionListing(context.Background(), db.TransitionListingParams{
+		Status:     "draft",
+		ID:         listing.ID,
+		FromStatus: "published",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	n, err = testQueries.TransitionListing(context.Background(), db.TransitionListingParams{
+		Status:     "pending_review",
+		ID:         listing.ID,
+		FromStatus: "draft",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	pending, err := testQueries.GetListingsPendingReview(context.Background())
+	require.NoError(t, err)
+	var found bool
+	for _, row := range pending {
+		if row.ID == listing.ID {
+			found = true
+			require.True(t, row.SubmittedAt.Valid)
+		}
+	}
+	require.True(t, found)
+
+	n, err = testQueries.TransitionListing(context.Background(), db.TransitionListingParams{
+		Status:     "draft",
+		ReviewNote: sql.NullString{String: "Add more photos", Valid: true},
+		ID:         listing.ID,
+		FromStatus: "pending_review",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	row, err := testQueries.GetListingsByAdminID(context.Background(), db.GetListingsByAdminIDParams{
+		AdminID: listing.AdminID,
+		Roles:   team.RolesWith(team.ViewListings),
+		ID:      listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "draft", row.Status)
+	require.Equal(t, "Add more photos", row.ReviewNote.String)
+	require.True(t, row.PublishedAt.Valid)
+}
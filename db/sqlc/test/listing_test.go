@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/team"
 	"github.com/weldonkipchirchir/rental_listing/util"
 )
 
@@ -38,6 +40,16 @@ func CreateListing(t *testing.T) db.Listing {
 	require.Equal(t, arg.Available, listing.Available)
 	require.Equal(t, arg.Column7, listing.Imagelinks)
 	require.NotZero(t, listing.CreatedAt.Valid)
+	require.Equal(t, "draft", listing.Status)
+
+	// Most tests need a listing guests can see, so publish it straight away.
+	n, err := testQueries.TransitionListing(context.Background(), db.TransitionListingParams{
+		Status:     "published",
+		ID:         listing.ID,
+		FromStatus: "draft",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
 
 	return db.Listing{
 		ID:          listing.ID,
@@ -49,6 +61,7 @@ func CreateListing(t *testing.T) db.Listing {
 		Available:   listing.Available,
 		Imagelinks:  listing.Imagelinks,
 		CreatedAt:   listing.CreatedAt,
+		Status:      "published",
 	}
 }
 
@@ -58,6 +71,11 @@ func TestCreateListing(t *testing.T) {
 
 func TestGetListing(t *testing.T) {
 	listing := CreateListing(t)
+
+	visible, err := testQueries.ListingVisible(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.True(t, visible)
+
 	listingFromDB, err := testQueries.GetListings(context.Background())
 	require.NoError(t, err)
 	require.NotEmpty(t, listingFromDB)
@@ -71,3 +89,121 @@ func TestGetListing(t *testing.T) {
 	require.Equal(t, listing.Imagelinks, listingFromDB[0].Imagelinks)
 	require.NotZero(t, listingFromDB[0].CreatedAt.Valid)
 }
+
+func TestListingActiveBookingCount(t *testing.T) {
+	booking := createUserBooking(t)
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+	arg := db.ListingActiveBookingCountParams{
+		ListingID: listing.ID,
+		AdminID:   listing.AdminID,
+	}
+
+	// A pending booking still holds its payment, so it counts as active.
+	count, err := testQueries.ListingActiveBookingCount(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	_, err = testQueries.UpdateBookingStatusByIDAndUserID(context.Background(), db.UpdateBookingStatusByIDAndUserIDParams{
+		ID:     booking.ID,
+		UserID: booking.UserID,
+	})
+	require.NoError(t, err)
+
+	count, err = testQueries.ListingActiveBookingCount(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestSoftDeleteListing(t *testing.T) {
+	listing := CreateListing(t)
+
+	rows, err := testQueries.SoftDeleteListing(context.Background(), db.SoftDeleteListingParams{
+		ID:      listing.ID,
+		AdminID: listing.AdminID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	visible, err := testQueries.ListingVisible(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.False(t, visible)
+
+	_, err = testQueries.GetListingsByAdminID(context.Background(), db.GetListingsByAdminIDParams{
+		AdminID: listing.AdminID,
+		Roles:   team.RolesWith(team.ViewListings),
+		ID:      listing.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	deleted, err := testQueries.GetDeletedListings(context.Background(), db.GetDeletedListingsParams{
+		AdminID: listing.AdminID,
+		Roles:   team.RolesWith(team.EditListings),
+	})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, listing.ID, deleted[0].ID)
+	require.True(t, deleted[0].Restorable)
+	require.False(t, deleted[0].HasBookings)
+
+	rows, err = testQueries.RestoreListing(context.Background(), db.RestoreListingParams{
+		AdminID: listing.AdminID,
+		Roles:   team.RolesWith(team.EditListings),
+		ID:      listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	restored, err := testQueries.GetListingByID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, "draft", restored.Status)
+
+	_, err = testQueries.SoftDeleteListing(context.Background(), db.SoftDeleteListingParams{
+		ID:      listing.ID,
+		AdminID: listing.AdminID,
+	})
+	require.NoError(t, err)
+
+	ids, err := testQueries.GetPurgeableListings(context.Background(), db.GetPurgeableListingsParams{
+		DeletedAt: time.Now().Add(time.Hour),
+		Limit:     1000,
+	})
+	require.NoError(t, err)
+	require.Contains(t, ids, listing.ID)
+
+	rows, err = testQueries.PurgeListing(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	_, err = testQueries.GetListingByID(context.Background(), listing.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestPurgeListingKeepsBookings(t *testing.T) {
+	booking := createUserBooking(t)
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+
+	_, err = testQueries.SoftDeleteListing(context.Background(), db.SoftDeleteListingParams{
+		ID:      listing.ID,
+		AdminID: listing.AdminID,
+	})
+	require.NoError(t, err)
+
+	ids, err := testQueries.GetPurgeableListings(context.Background(), db.GetPurgeableListingsParams{
+		DeletedAt: time.Now().Add(time.Hour),
+		Limit:     1000,
+	})
+	require.NoError(t, err)
+	require.NotContains(t, ids, listing.ID)
+
+	rows, err := testQueries.PurgeListing(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	_, err = testQueries.GetUserBookingByID(context.Background(), db.GetUserBookingByIDParams{
+		ID:     booking.ID,
+		UserID: booking.UserID,
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+func TestReviewModeration(t *testing.T) {
+	review := createRandomReviews(t)
+	_, err := testQueries.PublishExpiredReviews(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	err = testQueries.FlagReview(context.Background(), db.FlagReviewParams{
+		ID:               review.ID,
+		ModerationReason: sql.NullString{String: "blocked word", Valid: true},
+	})
+	require.NoError(t, err)
+
+	// Flagged reviews are left out of the listing's reviews and rating.
+	summary, err := testQueries.GetListingRatingSummary(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Zero(t, summary.TotalReviews)
+
+	reviews, err := testQueries.GetListingReviews(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Empty(t, reviews)
+
+	user := CreateRandomUser(t)
+	report, err := testQueries.CreateContentReport(context.Background(), db.CreateContentReportParams{
+		ReporterUserID: sql.NullInt32{Int32: user.ID, Valid: true},
+		ReviewID:       sql.NullInt32{Int32: review.ID, Valid: true},
+		Reason:         "offensive",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "open", report.Status)
+
+	queue, err := testQueries.GetReviewModerationQueue(context.Background())
+	require.NoError(t, err)
+	var queued bool
+	for _, r := range queue {
+		if r.ID == review.ID {
+			queued = true
+			require.Equal(t, "flagged", r.ModerationStatus)
+			require.Equal(t, int64(1), r.OpenReports)
+		}
+	}
+	require.True(t, queued)
+
+	rows, err := testQueries.SetReviewModerationStatus(context.Background(), db.SetReviewModerationStatusParams{
+		ID:               review.ID,
+		ModerationStatus: "visible",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	admin := createRandomAdmin(t)
+	err = testQueries.ResolveReviewReports(context.Background(), db.ResolveReviewReportsParams{
+		ReviewID:   sql.NullInt32{Int32: review.ID, Valid: true},
+		Resolution: sql.NullString{String: "approve", Valid: true},
+		ResolvedBy: sql.NullInt32{Int32: admin.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	summary, err = testQueries.GetListingRatingSummary(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), summary.TotalReviews)
+
+	reports, err := testQueries.GetOpenContentReports(context.Background())
+	require.NoError(t, err)
+	for _, r := range reports {
+		require.NotEqual(t, report.ID, r.ID)
+	}
+}
+
+func TestPendingReview(t *testing.T) {
+	booking := createUserBooking(t)
+	review, err := testQueries.CreateReview(context.Background(), db.CreateReviewParams{
+		UserID:    booking.UserID,
+		ListingID: booking.ListingID,
+		BookingID: sql.NullInt32{Int32: booking.ID, Valid: true},
+		Rating:    4,
+		Comment:   sql.NullString{String: "Quiet street, great host.", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pending", review.ModerationStatus)
+	_, err = testQueries.PublishExpiredReviews(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	stale, err := testQueries.GetStalePendingReviews(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Contains(t, stale, review.ID)
+	stale, err = testQueries.GetStalePendingReviews(context.Background(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.NotContains(t, stale, review.ID)
+
+	// Published reviews stay hidden until the content filter passes them.
+	reviews, err := testQueries.GetListingReviews(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Empty(t, reviews)
+
+	cleared, err := testQueries.ClearPendingReview(context.Background(), review.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cleared)
+
+	reviews, err = testQueries.GetListingReviews(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+
+	// A review a moderator hid is not shown again.
+	_, err = testQueries.SetReviewModerationStatus(context.Background(), db.SetReviewModerationStatusParams{
+		ID:               review.ID,
+		ModerationStatus: "hidden",
+	})
+	require.NoError(t, err)
+	cleared, err = testQueries.ClearPendingReview(context.Background(), review.ID)
+	require.NoError(t, err)
+	require.Zero(t, cleared)
+}
+
+func TestContentReportNeedsOneTarget(t *testing.T) {
+	user := CreateRandomUser(t)
+
+	_, err := testQueries.CreateContentReport(context.Background(), db.CreateContentReportParams{
+		ReporterUserID: sql.NullInt32{Int32: user.ID, Valid: true},
+		Reason:         "spam",
+	})
+	require.Error(t, err)
+}
+
+func TestIsSuperAdmin(t *testing.T) {
+	admin := createRandomAdmin(t)
+
+	isSuper, err := testQueries.IsSuperAdmin(context.Background(), admin.ID)
+	require.NoError(t, err)
+	require.False(t, isSuper)
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+func TestCreateEventNotification(t *testing.T) {
+	booking := createUserBooking(t)
+
+	arg := db.CreateEventNotificationParams{
+		UserID:    sql.NullInt32{Int32: booking.UserID, Valid: true},
+		Category:  sql.NullString{String: "booking_created", Valid: true},
+		BookingID: booking.ID,
+		Subject:   sql.NullString{String: "Booking received", Valid: true},
+		Message:   "We received your booking.",
+	}
+	notification, err := testQueries.CreateEventNotification(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Category, notification.Category)
+	require.Equal(t, arg.UserID, notification.UserID)
+
+	// The same event is only sent once.
+	_, err = testQueries.CreateEventNotification(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestSetUserNotificationPreference(t *testing.T) {
+	user := CreateRandomUser(t)
+	userID := sql.NullInt32{Int32: user.ID, Valid: true}
+
+	arg := db.SetUserNotificationPreferenceParams{
+		UserID:   userID,
+		Category: "review_request",
+		Channel:  "email",
+		Enabled:  false,
+	}
+	require.NoError(t, testQueries.SetUserNotificationPreference(context.Background(), arg))
+
+	arg.Enabled = true
+	require.NoError(t, testQueries.SetUserNotificationPreference(context.Background(), arg))
+
+	prefs, err := testQueries.GetUserNotificationPreferences(context.Background(), userID)
+	require.NoError(t, err)
+	require.Len(t, prefs, 1)
+	require.Equal(t, "review_request", prefs[0].Category)
+	require.True(t, prefs[0].Enabled)
+}
+
+func TestSetUserNotificationSettings(t *testing.T) {
+	user := CreateRandomUser(t)
+	userID := sql.NullInt32{Int32: user.ID, Valid: true}
+
+	_, err := testQueries.GetUserNotificationSettings(context.Background(), userID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	arg := db.SetUserNotificationSettingsParams{
+		UserID:          userID,
+		Phone:           sql.NullString{String: "+254712345678", Valid: true},
+		QuietHoursStart: sql.NullInt32{Int32: 22 * 60, Valid: true},
+		QuietHoursEnd:   sql.NullInt32{Int32: 7 * 60, Valid: true},
+		TimeZone:        "Africa/Nairobi",
+	}
+	settings, err := testQueries.SetUserNotificationSettings(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Phone, settings.Phone)
+	require.Equal(t, arg.QuietHoursStart, settings.QuietHoursStart)
+
+	// Setting them again replaces them.
+	arg.QuietHoursStart = sql.NullInt32{}
+	arg.QuietHoursEnd = sql.NullInt32{}
+	updated, err := testQueries.SetUserNotificationSettings(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, settings.ID, updated.ID)
+	require.False(t, updated.QuietHoursStart.Valid)
+
+	fetched, err := testQueries.GetUserNotificationSettings(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, updated.ID, fetched.ID)
+	require.Equal(t, "Africa/Nairobi", fetched.TimeZone)
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	user := CreateRandomUser(t)
+	admin := createRandomAdmin(t)
+	userID := sql.NullInt32{Int32: user.ID, Valid: true}
+	adminID := sql.NullInt32{Int32: admin.ID, Valid: true}
+	endpoint := "https://push.example.com/" + util.RandomString(12)
+
+	sub, err := testQueries.CreateUserPushSubscription(context.Background(), db.CreateUserPushSubscriptionParams{
+		UserID:   userID,
+		Endpoint: endpoint,
+		P256dh:   "key",
+		Auth:     "secret",
+	})
+	require.NoError(t, err)
+	require.Equal(t, userID, sub.UserID)
+
+	// A browser belongs to whoever subscribed it last.
+	moved, err := testQueries.CreateAdminPushSubscription(context.Background(), db.CreateAdminPushSubscriptionParams{
+		AdminID:  adminID,
+		Endpoint: endpoint,
+		P256dh:   "new-key",
+		Auth:     "new-secret",
+	})
+	require.NoError(t, err)
+	require.Equal(t, sub.ID, moved.ID)
+	require.False(t, moved.UserID.Valid)
+
+	subs, err := testQueries.GetUserPushSubscriptions(context.Background(), userID)
+	require.NoError(t, err)
+	require.Empty(t, subs)
+	subs, err = testQueries.GetAdminPushSubscriptions(context.Background(), adminID)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	require.Equal(t, "new-key", subs[0].P256dh)
+
+	require.NoError(t, testQueries.DeletePushSubscription(context.Background(), endpoint))
+	subs, err = testQueries.GetAdminPushSubscriptions(context.Background(), adminID)
+	require.NoError(t, err)
+	require.Empty(t, subs)
+}
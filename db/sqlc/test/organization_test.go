@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/team"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+func createRandomOrganization(t *testing.T, ownerID int32) db.Organization {
+	organization, err := testQueries.CreateOrganization(context.Background(), db.CreateOrganizationParams{
+		Name:      util.RandomString(10),
+		CreatedBy: sql.NullInt32{Int32: ownerID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	err = testQueries.AddOrganizationMember(context.Background(), db.AddOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		AdminID:        ownerID,
+		Role:           team.Owner,
+	})
+	require.NoError(t, err)
+	return organization
+}
+
+func TestOrganizationLastOwner(t *testing.T) {
+	owner := createRandomAdmin(t)
+	organization := createRandomOrganization(t, owner.ID)
+
+	rows, err := testQueries.UpdateOrganizationMemberRole(context.Background(), db.UpdateOrganizationMemberRoleParams{
+		Role:           team.Manager,
+		OrganizationID: organization.ID,
+		AdminID:        owner.ID,
+	})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	rows, err = testQueries.DeleteOrganizationMember(context.Background(), db.DeleteOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		AdminID:        owner.ID,
+	})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	second := createRandomAdmin(t)
+	err = testQueries.AddOrganizationMember(context.Background(), db.AddOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		AdminID:        second.ID,
+		Role:           team.Owner,
+	})
+	require.NoError(t, err)
+
+	rows, err = testQueries.UpdateOrganizationMemberRole(context.Background(), db.UpdateOrganizationMemberRoleParams{
+		Role:           team.Manager,
+		OrganizationID: organization.ID,
+		AdminID:        owner.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	members, err := testQueries.GetOrganizationMembers(context.Background(), organization.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+}
+
+func TestAcceptOrganizationInvitation(t *testing.T) {
+	owner := createRandomAdmin(t)
+	organization := createRandomOrganization(t, owner.ID)
+
+	token, err := team.NewInvitationToken()
+	require.NoError(t, err)
+	invitation, err := testQueries.CreateOrganizationInvitation(context.Background(), db.CreateOrganizationInvitationParams{
+		OrganizationID: organization.ID,
+		Email:          util.RandomEmail(),
+		Role:           team.Cleaner,
+		Token:          token,
+		InvitedBy:      sql.NullInt32{Int32: owner.ID, Valid: true},
+		ExpiresAt:      time.Now().Add(team.InvitationTTL),
+	})
+	require.NoError(t, err)
+
+	found, err := testQueries.GetOrganizationInvitationByToken(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, invitation.ID, found.ID)
+
+	pending, err := testQueries.GetOrganizationInvitations(context.Background(), organization.ID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	rows, err := testQueries.AcceptOrganizationInvitation(context.Background(), invitation.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	rows, err = testQueries.AcceptOrganizationInvitation(context.Background(), invitation.ID)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	pending, err = testQueries.GetOrganizationInvitations(context.Background(), organization.ID)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestOrganizationListingAccess(t *testing.T) {
+	listing := CreateListing(t)
+	organization := createRandomOrganization(t, listing.AdminID)
+
+	rows, err := testQueries.SetListingOrganization(context.Background(), db.SetListingOrganizationParams{
+		OrganizationID: sql.NullInt32{Int32: organization.ID, Valid: true},
+		AdminID:        listing.AdminID,
+		ID:             listing.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	cleaner := createRandomAdmin(t)
+	err = testQueries.AddOrganizationMember(context.Background(), db.AddOrganizationMemberParams{
+		OrganizationID: organization.ID,
+		AdminID:        cleaner.ID,
+		Role:           team.Cleaner,
+	})
+	require.NoError(t, err)
+
+	listings, err := testQueries.GetAdminListings(context.Background(), db.GetAdminListingsParams{
+		AdminID: cleaner.ID,
+		Roles:   team.RolesWith(team.ViewListings),
+	})
+	require.NoError(t, err)
+	require.Len(t, listings, 1)
+	require.Equal(t, listing.ID, listings[0].ID)
+
+	_, err = testQueries.GetListingsByAdminID(context.Background(), db.GetListingsByAdminIDParams{
+		AdminID: cleaner.ID,
+		Roles:   team.RolesWith(team.EditListings),
+		ID:      listing.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	stats, err := testQueries.GetAdminListingStats(context.Background(), db.GetAdminListingStatsParams{
+		AdminID: cleaner.ID,
+		Roles:   team.RolesWith(team.ViewListings),
+	})
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, listing.ID, stats[0].ID)
+
+	outsider := createRandomAdmin(t)
+	listings, err = testQueries.GetAdminListings(context.Background(), db.GetAdminListingsParams{
+		AdminID: outsider.ID,
+		Roles:   team.RolesWith(team.ViewListings),
+	})
+	require.NoError(t, err)
+	require.Empty(t, listings)
+}
+
+func TestGetListingHosts(t *testing.T) {
+	listing := CreateListing(t)
+	roles := team.RolesWith(team.Messaging)
+
+	hosts, err := testQueries.GetListingHosts(context.Background(), db.GetListingHostsParams{ID: listing.ID, Roles: roles})
+	require.NoError(t, err)
+	require.Equal(t, []int32{listing.AdminID}, hosts)
+
+	organization := createRandomOrganization(t, listing.AdminID)
+	_, err = testQueries.SetListingOrganization(context.Background(), db.SetListingOrganizationParams{
+		OrganizationID: sql.NullInt32{Int32: organization.ID, Valid: true},
+		AdminID:        listing.AdminID,
+		ID:             listing.ID,
+	})
+	require.NoError(t, err)
+
+	manager := createRandomAdmin(t)
+	cleaner := createRandomAdmin(t)
+	for _, member := range []db.AddOrganizationMemberParams{
+		{OrganizationID: organization.ID, AdminID: manager.ID, Role: team.Manager},
+		{OrganizationID: organization.ID, AdminID: cleaner.ID, Role: team.Cleaner},
+	} {
+		require.NoError(t, testQueries.AddOrganizationMember(context.Background(), member))
+	}
+
+	hosts, err = testQueries.GetListingHosts(context.Background(), db.GetListingHostsParams{ID: listing.ID, Roles: roles})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int32{listing.AdminID, manager.ID}, hosts)
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+func TestListingSimilarities(t *testing.T) {
+	listing := CreateListing(t)
+	liked := CreateListing(t)
+	similar := CreateListing(t)
+	user := CreateRandomUser(t)
+	trainedAt := time.Now().UTC().Truncate(time.Second)
+
+	_, err := testQueries.CreateFavorite(context.Background(), db.CreateFavoriteParams{
+		UserID:    user.ID,
+		ListingID: liked.ID,
+	})
+	require.NoError(t, err)
+
+	interactions, err := testQueries.GetListingInteractions(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, interactions, db.ListingInteraction{UserID: user.ID, ListingID: liked.ID, Weight: 3})
+
+	err = testQueries.UpsertListingSimilarities(context.Background(), db.UpsertListingSimilaritiesParams{
+		ListingIds:        []int32{listing.ID, liked.ID, liked.ID},
+		SimilarListingIds: []int32{similar.ID, similar.ID, listing.ID},
+		Scores:            []float64{0.9, 0.8, 0.1},
+		TrainedAt:         trainedAt,
+	})
+	require.NoError(t, err)
+
+	rows, err := testQueries.GetSimilarListings(context.Background(), db.GetSimilarListingsParams{
+		ListingID: liked.ID,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, similar.ID, rows[0].ID)
+	require.Equal(t, listing.ID, rows[1].ID)
+
+	// The user's feed scores listings similar to the one they favorited, leaving it out.
+	recommended, err := testQueries.GetRecommendedListings(context.Background(), db.GetRecommendedListingsParams{
+		UserID: user.ID,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, recommended, 2)
+	require.Equal(t, similar.ID, recommended[0].ID)
+	require.InDelta(t, 0.8*3, recommended[0].Score, 1e-9)
+
+	popular, err := testQueries.GetPopularListings(context.Background(), db.GetPopularListingsParams{
+		UserID: user.ID,
+		Limit:  1000,
+	})
+	require.NoError(t, err)
+	for _, row := range popular {
+		require.NotEqual(t, liked.ID, row.ID)
+	}
+
+	// A later run that no longer produces the pairs removes them.
+	deleted, err := testQueries.DeleteStaleListingSimilarities(context.Background(), trainedAt.Add(time.Hour))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, deleted, int64(3))
+
+	rows, err = testQueries.GetSimilarListings(context.Background(), db.GetSimilarListingsParams{
+		ListingID: liked.ID,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, rows)
+}
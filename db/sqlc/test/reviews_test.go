@@ -4,26 +4,44 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/team"
 	"github.com/weldonkipchirchir/rental_listing/util"
 )
 
 func createRandomReviews(t *testing.T) db.Review {
-	user := CreateRandomUser(t)
-	listing := CreateListing(t)
+	return createBookingReview(t, createUserBooking(t))
+}
 
+func createBookingReview(t *testing.T, booking db.Booking) db.Review {
 	arg := db.CreateReviewParams{
-		UserID:    user.ID,
-		ListingID: listing.ID,
-		Rating:    int32(util.RandomFloat(1, 5)),
-		Comment:   sql.NullString{String: util.RandomString(10), Valid: true},
+		UserID:              booking.UserID,
+		ListingID:           booking.ListingID,
+		BookingID:           sql.NullInt32{Int32: booking.ID, Valid: true},
+		Rating:              int32(util.RandomInt(1, 5)),
+		CleanlinessRating:   sql.NullInt32{Int32: 4, Valid: true},
+		AccuracyRating:      sql.NullInt32{Int32: 5, Valid: true},
+		LocationRating:      sql.NullInt32{Int32: 3, Valid: true},
+		ValueRating:         sql.NullInt32{Int32: 4, Valid: true},
+		CommunicationRating: sql.NullInt32{Int32: 5, Valid: true},
+		Comment:             sql.NullString{String: util.RandomString(10), Valid: true},
 	}
 
 	review, err := testQueries.CreateReview(context.Background(), arg)
 	require.NoError(t, err)
 	require.NotEmpty(t, review)
+	require.Equal(t, arg.BookingID, review.BookingID)
+	require.Equal(t, arg.CleanlinessRating, review.CleanlinessRating)
+	require.Equal(t, "pending", review.ModerationStatus)
+
+	// Pass the content filter.
+	cleared, err := testQueries.ClearPendingReview(context.Background(), review.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cleared)
+	review.ModerationStatus = "visible"
 
 	return review
 }
@@ -31,3 +49,102 @@ func createRandomReviews(t *testing.T) db.Review {
 func TestCreateReview(t *testing.T) {
 	createRandomReviews(t)
 }
+
+func TestCreateReviewOncePerBooking(t *testing.T) {
+	review := createRandomReviews(t)
+
+	found, err := testQueries.GetReviewByBookingID(context.Background(), review.BookingID)
+	require.NoError(t, err)
+	require.Equal(t, review.ID, found.ID)
+
+	_, err = testQueries.CreateReview(context.Background(), db.CreateReviewParams{
+		UserID:    review.UserID,
+		ListingID: review.ListingID,
+		BookingID: review.BookingID,
+		Rating:    5,
+	})
+	require.Error(t, err)
+}
+
+func TestDeleteReview(t *testing.T) {
+	review := createRandomReviews(t)
+	arg := db.DeleteReviewParams{ID: review.ID, UserID: review.UserID}
+
+	_, err := testQueries.PublishExpiredReviews(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	// The published review of a stay stays.
+	deleted, err := testQueries.DeleteReview(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	unpublished := createRandomReviews(t)
+	deleted, err = testQueries.DeleteReview(context.Background(), db.DeleteReviewParams{ID: unpublished.ID, UserID: unpublished.UserID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+}
+
+func TestGetListingRatingSummary(t *testing.T) {
+	review := createRandomReviews(t)
+
+	// Unpublished reviews are left out until the review window closes.
+	summary, err := testQueries.GetListingRatingSummary(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Zero(t, summary.TotalReviews)
+
+	_, err = testQueries.PublishExpiredReviews(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	summary, err = testQueries.GetListingRatingSummary(context.Background(), review.ListingID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), summary.TotalReviews)
+	require.Equal(t, float64(review.Rating), summary.AverageRating)
+	require.Equal(t, float64(4), summary.Cleanliness)
+	require.Equal(t, float64(3), summary.Location)
+
+	empty, err := testQueries.GetListingRatingSummary(context.Background(), CreateListing(t).ID)
+	require.NoError(t, err)
+	require.Zero(t, empty.TotalReviews)
+	require.Zero(t, empty.AverageRating)
+}
+
+func TestReplyToReview(t *testing.T) {
+	review := createRandomReviews(t)
+
+	listing, err := testQueries.GetListingByID(context.Background(), review.ListingID)
+	require.NoError(t, err)
+
+	reply := sql.NullString{String: util.RandomString(20), Valid: true}
+	arg := db.ReplyToReviewParams{
+		ID:        review.ID,
+		HostReply: reply,
+		AdminID:   listing.AdminID,
+		Roles:     team.RolesWith(team.Messaging),
+	}
+
+	// The review is not published yet.
+	_, err = testQueries.ReplyToReview(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = testQueries.PublishExpiredReviews(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	other := arg
+	other.AdminID = createRandomAdmin(t).ID
+	_, err = testQueries.ReplyToReview(context.Background(), other)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	replied, err := testQueries.ReplyToReview(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, reply, replied.HostReply)
+	require.True(t, replied.HostRepliedAt.Valid)
+
+	// Hidden reviews cannot be replied to.
+	_, err = testQueries.SetReviewModerationStatus(context.Background(), db.SetReviewModerationStatusParams{
+		ID:               review.ID,
+		ModerationStatus: "hidden",
+	})
+	require.NoError(t, err)
+	_, err = testQueries.ReplyToReview(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
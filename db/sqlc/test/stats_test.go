@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+func TestRecordListingViews(t *testing.T) {
+	listing := CreateListing(t)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	arg := db.RecordListingViewsParams{
+		ListingID:   listing.ID,
+		Day:         today,
+		Views:       3,
+		UniqueViews: 2,
+	}
+	rows, err := testQueries.RecordListingViews(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	// A later batch of the same day adds its views; the unique count is the day's running
+	// total, so stats only gains the difference.
+	arg.Views = 4
+	arg.UniqueViews = 5
+	_, err = testQueries.RecordListingViews(context.Background(), arg)
+	require.NoError(t, err)
+
+	stat, err := testQueries.GetStatsByListingID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, listing.AdminID, stat.AdminID)
+	require.Equal(t, int32(7), stat.TotalViews)
+	require.Equal(t, int32(5), stat.UniqueViews)
+
+	arg.ListingID = -1
+	rows, err = testQueries.RecordListingViews(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+}
+
+func TestRefreshListingStats(t *testing.T) {
+	booking := createUserBooking(t)
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+
+	// A pending booking does not count towards bookings or revenue.
+	err = testQueries.RefreshListingStats(context.Background(), sql.NullInt32{Int32: listing.ID, Valid: true})
+	require.NoError(t, err)
+	stat, err := testQueries.GetStatsByListingID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Zero(t, stat.TotalBookings)
+	require.Equal(t, "0.00", stat.Revenue)
+
+	arg := db.UpdateBookingStatusByIDAndAdminIDParams{
+		Status:     sql.NullString{String: "confirmed", Valid: true},
+		ID:         booking.ID,
+		AdminID:    listing.AdminID,
+		Roles:      team.RolesWith(team.ApproveBookings),
+		FromStatus: sql.NullString{String: "pending", Valid: true},
+	}
+	updated, err := testQueries.UpdateBookingStatusByIDAndAdminID(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated)
+
+	// The booking is no longer pending.
+	updated, err = testQueries.UpdateBookingStatusByIDAndAdminID(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, updated)
+
+	// Unpublished reviews are left out of the rating aggregates.
+	createBookingReview(t, booking)
+
+	err = testQueries.RefreshListingStats(context.Background(), sql.NullInt32{})
+	require.NoError(t, err)
+	stat, err = testQueries.GetStatsByListingID(context.Background(), listing.ID)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), stat.TotalBookings)
+	require.Equal(t, booking.TotalAmount, stat.Revenue)
+	require.Zero(t, stat.ReviewCount)
+
+	rows, err := testQueries.GetAdminListingStats(context.Background(), db.GetAdminListingStatsParams{
+		AdminID: listing.AdminID,
+		Roles:   team.RolesWith(team.ViewListings),
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, listing.ID, rows[0].ID)
+	require.Equal(t, int32(1), rows[0].TotalBookings)
+	require.True(t, rows[0].UpdatedAt.Valid)
+}
+
+func TestGetAdminDailyStats(t *testing.T) {
+	booking := createUserBooking(t)
+	listing, err := testQueries.GetListingByID(context.Background(), booking.ListingID)
+	require.NoError(t, err)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	_, err = testQueries.RecordListingViews(context.Background(), db.RecordListingViewsParams{
+		ListingID:   listing.ID,
+		Day:         today,
+		Views:       2,
+		UniqueViews: 1,
+	})
+	require.NoError(t, err)
+
+	days, err := testQueries.GetAdminDailyStats(context.Background(), db.GetAdminDailyStatsParams{
+		FromDate:  today.AddDate(0, 0, -1),
+		ToDate:    today.AddDate(0, 0, 1),
+		AdminID:   listing.AdminID,
+		Roles:     team.RolesWith(team.ViewListings),
+		ListingID: sql.NullInt32{Int32: listing.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+
+	require.Zero(t, days[0].Views)
+	require.Equal(t, today, days[1].Day.UTC())
+	require.Equal(t, int32(1), days[1].ListingNights)
+	require.Equal(t, int32(2), days[1].Views)
+	require.Equal(t, int32(1), days[1].UniqueViews)
+	require.Equal(t, int32(1), days[1].BookingRequests)
+	require.Zero(t, days[1].ConfirmedBookings)
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+func TestUploadSession(t *testing.T) {
+	listing := CreateListing(t)
+
+	session, err := testQueries.CreateUploadSession(context.Background(), db.CreateUploadSessionParams{
+		ListingID: listing.ID,
+		AdminID:   listing.AdminID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	keys := make([]string, 3)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("uploads/%d/file-%d", session.ID, i)
+		file, err := testQueries.CreateUploadSessionFile(context.Background(), db.CreateUploadSessionFileParams{
+			SessionID: session.ID,
+			Position:  int32(i),
+			Filename:  fmt.Sprintf("IMG_%d.jpg", i),
+			Size:      1 << 20,
+			ObjectKey: keys[i],
+		})
+		require.NoError(t, err)
+		require.Equal(t, "waiting", file.Status)
+	}
+	require.NoError(t, testQueries.RecordOrphanedImages(context.Background(), keys))
+
+	img, err := testQueries.CreateUploadedListingImage(context.Background(), db.CreateUploadedListingImageParams{
+		ListingID: listing.ID,
+		SourceKey: sql.NullString{String: keys[0], Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pending", img.Status)
+
+	attach := db.AttachUploadSessionFileParams{
+		SessionID: session.ID,
+		Position:  0,
+		ImageID:   sql.NullInt32{Int32: img.ID, Valid: true},
+	}
+	rows, err := testQueries.AttachUploadSessionFile(context.Background(), attach)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+	// A file is attached once.
+	rows, err = testQueries.AttachUploadSessionFile(context.Background(), attach)
+	require.NoError(t, err)
+	require.Zero(t, rows)
+
+	err = testQueries.RejectUploadSessionFile(context.Background(), db.RejectUploadSessionFileParams{
+		SessionID: session.ID,
+		Position:  1,
+		Error:     sql.NullString{String: "image file is too large", Valid: true},
+	})
+	require.NoError(t, err)
+
+	files, err := testQueries.GetUploadSessionFiles(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.Equal(t, "attached", files[0].Status)
+	require.Equal(t, "pending", files[0].ImageStatus.String)
+	require.Equal(t, "rejected", files[1].Status)
+	require.Equal(t, "waiting", files[2].Status)
+	require.False(t, files[2].ImageStatus.Valid)
+
+	// The original of an image waiting to be processed is not an orphan.
+	orphans, err := testQueries.GetOrphanedImages(context.Background(), db.GetOrphanedImagesParams{
+		CreatedAt: time.Now().Add(time.Minute),
+		Limit:     100,
+	})
+	require.NoError(t, err)
+	require.NotContains(t, orphans, keys[0])
+	require.Contains(t, orphans, keys[2])
+
+	ready, err := testQueries.MarkListingImageReady(context.Background(), db.MarkListingImageReadyParams{ID: img.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), ready)
+	img, err = testQueries.GetListingImage(context.Background(), img.ID)
+	require.NoError(t, err)
+	require.False(t, img.SourceKey.Valid)
+	require.NoError(t, testQueries.DeleteOrphanedImages(context.Background(), keys))
+
+	_, err = testQueries.GetUploadSession(context.Background(), db.GetUploadSessionParams{
+		ID:        session.ID,
+		ListingID: listing.ID + 1,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	deleted, err := testQueries.DeleteExpiredUploadSessions(context.Background(), time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, deleted, int64(1))
+	_, err = testQueries.GetUploadSession(context.Background(), db.GetUploadSessionParams{
+		ID:        session.ID,
+		ListingID: listing.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
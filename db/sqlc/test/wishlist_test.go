@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/util"
+)
+
+func createRandomWishlist(t *testing.T, user db.User) db.Wishlist {
+	wishlist, err := testQueries.CreateWishlist(context.Background(), db.CreateWishlistParams{
+		UserID: user.ID,
+		Name:   util.RandomString(10),
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.ID, wishlist.UserID)
+	require.False(t, wishlist.ShareToken.Valid)
+
+	return wishlist
+}
+
+func TestWishlistItems(t *testing.T) {
+	owner := CreateRandomUser(t)
+	wishlist := createRandomWishlist(t, owner)
+	listing := CreateListing(t)
+
+	arg := db.CreateWishlistItemParams{
+		WishlistID: wishlist.ID,
+		ListingID:  listing.ID,
+		AddedBy:    owner.ID,
+		Note:       sql.NullString{String: "sea view", Valid: true},
+	}
+	_, err := testQueries.CreateWishlistItem(context.Background(), arg)
+	require.NoError(t, err)
+
+	// A listing is only saved once per wishlist.
+	_, err = testQueries.CreateWishlistItem(context.Background(), arg)
+	require.Error(t, err)
+
+	items, err := testQueries.GetWishlistItems(context.Background(), wishlist.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, listing.Title, items[0].Title)
+	require.Equal(t, owner.Username, items[0].AddedByUsername)
+	require.Equal(t, "sea view", items[0].Note.String)
+
+	// Items of listings guests can no longer see are left out.
+	_, err = testQueries.SoftDeleteListing(context.Background(), db.SoftDeleteListingParams{
+		ID:      listing.ID,
+		AdminID: listing.AdminID,
+	})
+	require.NoError(t, err)
+	items, err = testQueries.GetWishlistItems(context.Background(), wishlist.ID)
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
+
+func TestWishlistCollaborators(t *testing.T) {
+	owner := CreateRandomUser(t)
+	collaborator := CreateRandomUser(t)
+	wishlist := createRandomWishlist(t, owner)
+
+	_, err := testQueries.GetWishlistForUser(context.Background(), db.GetWishlistForUserParams{
+		ID:     wishlist.ID,
+		UserID: collaborator.ID,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = testQueries.AddWishlistCollaborator(context.Background(), db.AddWishlistCollaboratorParams{
+		WishlistID: wishlist.ID,
+		UserID:     collaborator.ID,
+	})
+	require.NoError(t, err)
+
+	got, err := testQueries.GetWishlistForUser(context.Background(), db.GetWishlistForUserParams{
+		ID:     wishlist.ID,
+		UserID: collaborator.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, owner.Username, got.OwnerUsername)
+
+	wishlists, err := testQueries.GetUserWishlists(context.Background(), collaborator.ID)
+	require.NoError(t, err)
+	require.Len(t, wishlists, 1)
+	require.Equal(t, wishlist.ID, wishlists[0].ID)
+
+	// Only the owner can delete the wishlist.
+	rows, err := testQueries.DeleteWishlist(context.Background(), db.DeleteWishlistParams{
+		ID:     wishlist.ID,
+		UserID: collaborator.ID,
+	})
+	require.NoError(t, err)
+	require.Zero(t, rows)
+}
+
+func TestShareWishlist(t *testing.T) {
+	wishlist := createRandomWishlist(t, CreateRandomUser(t))
+	token := util.RandomString(32)
+
+	rows, err := testQueries.SetWishlistShareToken(context.Background(), db.SetWishlistShareTokenParams{
+		ID:         wishlist.ID,
+		UserID:     wishlist.UserID,
+		ShareToken: sql.NullString{String: token, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rows)
+
+	shared, err := testQueries.GetWishlistByShareToken(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, wishlist.ID, shared.ID)
+
+	_, err = testQueries.SetWishlistShareToken(context.Background(), db.SetWishlistShareTokenParams{
+		ID:     wishlist.ID,
+		UserID: wishlist.UserID,
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.GetWishlistByShareToken(context.Background(), token)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
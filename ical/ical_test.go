@@ -0,0 +1,91 @@
+package ical
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func parseFixture(t *testing.T, name string) []Event {
+	f, err := os.Open("testdata/" + name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	events, err := Parse(f)
+	require.NoError(t, err)
+	return events
+}
+
+func TestParseAirbnb(t *testing.T) {
+	events := parseFixture(t, "airbnb.ics")
+	require.Len(t, events, 2)
+
+	require.Equal(t, "Reserved", events[0].Summary)
+	require.Equal(t, date(2024, time.June, 10), events[0].Start)
+	require.Equal(t, date(2024, time.June, 15), events[0].End)
+	require.True(t, strings.HasSuffix(events[0].UID, "@airbnb.com"))
+
+	require.Equal(t, date(2024, time.July, 1), events[1].Start)
+	require.Equal(t, date(2024, time.July, 2), events[1].End)
+}
+
+func TestParseBookingCom(t *testing.T) {
+	events := parseFixture(t, "booking.ics")
+	// The cancelled event is skipped.
+	require.Len(t, events, 2)
+
+	require.Equal(t, "booking-123@booking.com", events[0].UID)
+	require.Equal(t, "CLOSED - Not available, guest stay", events[0].Summary)
+	require.Equal(t, date(2024, time.August, 1), events[0].Start)
+	require.Equal(t, date(2024, time.August, 4), events[0].End)
+
+	// An event without DTEND blocks a single night.
+	require.Equal(t, date(2024, time.August, 10), events[1].Start)
+	require.Equal(t, date(2024, time.August, 11), events[1].End)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("<html>not a calendar</html>"))
+	require.ErrorIs(t, err, ErrNoCalendar)
+
+	_, err = Parse(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2024-06-01\nEND:VEVENT\nEND:VCALENDAR\n"))
+	require.Error(t, err)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	cal := Calendar{
+		ProdID: "-//Rental Listing//Calendar//EN",
+		Name:   "Beach house, Mombasa",
+		Events: []Event{
+			{UID: "booking-1@rental-listing", Summary: "Booked", Start: date(2024, time.June, 1), End: date(2024, time.June, 5)},
+			{UID: "block-2@rental-listing", Summary: "Blocked; " + strings.Repeat("long summary ", 20), Start: date(2024, time.June, 8), End: date(2024, time.June, 9)},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Encode(&buf, cal, time.Date(2024, time.May, 1, 8, 30, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "DTSTART;VALUE=DATE:20240601\r\n")
+	require.Contains(t, out, "DTSTAMP:20240501T083000Z\r\n")
+	require.Contains(t, out, "X-WR-CALNAME:Beach house\\, Mombasa\r\n")
+	for _, line := range strings.Split(out, "\r\n") {
+		require.LessOrEqual(t, len(line), 75)
+	}
+
+	events, err := Parse(&buf)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for i := range events {
+		require.Equal(t, cal.Events[i], events[i])
+	}
+}
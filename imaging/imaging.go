@@ -0,0 +1,259 @@
+// Package imaging validates uploaded listing photos and turns them into the resized JPEG
+// variants that are stored and served. Decoding and re-encoding drops all metadata, so
+// EXIF data such as GPS coordinates never leaves the server; the EXIF orientation is
+// applied to the pixels first so photos taken sideways still display upright.
+//
+// Only JPEG is encoded here, as the standard library has no WebP encoder. WebP variants
+// are offered only with the Cloudinary image store, which converts the stored JPEGs on
+// delivery; with the local and S3 stores listings get JPEG variants alone.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Register the decoders for the other supported formats.
+	_ "image/gif"
+	_ "image/png"
+)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG, PNG and GIF images are supported")
+	ErrTooLarge        = errors.New("image file is too large")
+	ErrDimensions      = errors.New("image dimensions are out of range")
+)
+
+// supportedTypes are the sniffed content types that can be decoded.
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Limits bound the uploads that are accepted.
+type Limits struct {
+	MaxBytes  int64
+	MinWidth  int
+	MinHeight int
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixels bounds width*height, which is what decoding costs in memory.
+	MaxPixels int
+}
+
+var DefaultLimits = Limits{
+	MaxBytes:  15 << 20,
+	MinWidth:  320,
+	MinHeight: 240,
+	MaxWidth:  10000,
+	MaxHeight: 10000,
+	MaxPixels: 50_000_000,
+}
+
+// Size is a variant that is generated for every image, scaled down to fit within
+// MaxWidth x MaxWidth. Images are never scaled up.
+type Size struct {
+	Name     string
+	MaxWidth int
+}
+
+var DefaultSizes = []Size{
+	{Name: "thumb", MaxWidth: 320},
+	{Name: "small", MaxWidth: 640},
+	{Name: "medium", MaxWidth: 1024},
+	{Name: "large", MaxWidth: 1920},
+}
+
+// jpegQuality balances size and quality for photos.
+const jpegQuality = 82
+
+// Info is what Validate learns about an upload without decoding it fully.
+type Info struct {
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Validate checks the type, size and dimensions of an uploaded image. The content type is
+// sniffed from the data; the name and type the client sent are not trusted.
+func Validate(data []byte, limits Limits) (Info, error) {
+	if int64(len(data)) > limits.MaxBytes {
+		return Info{}, ErrTooLarge
+	}
+
+	info := Info{ContentType: http.DetectContentType(data)}
+	if !supportedTypes[info.ContentType] {
+		return Info{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, fmt.Errorf("invalid image: %w", err)
+	}
+	info.Width, info.Height = config.Width, config.Height
+
+	if info.Width < limits.MinWidth || info.Height < limits.MinHeight ||
+		info.Width > limits.MaxWidth || info.Height > limits.MaxHeight ||
+		info.Width*info.Height > limits.MaxPixels {
+		return Info{}, fmt.Errorf("%w: %dx%d", ErrDimensions, info.Width, info.Height)
+	}
+	return info, nil
+}
+
+// Variant is one generated size of an image, encoded as JPEG.
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Result is a processed image.
+type Result struct {
+	// Width and Height are of the upright original.
+	Width    int
+	Height   int
+	BlurHash string
+	Variants []Variant
+}
+
+// Process validates an uploaded image, rotates it upright and generates its variants and
+// blurhash placeholder.
+func Process(data []byte, limits Limits, sizes []Size) (Result, error) {
+	info, err := Validate(data, limits)
+	if err != nil {
+		return Result{}, err
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid image: %w", err)
+	}
+
+	orientation := 1
+	if info.ContentType == "image/jpeg" {
+		orientation = exifOrientation(data)
+	}
+	img := orient(flatten(src), orientation)
+
+	bounds := img.Bounds()
+	result := Result{
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		BlurHash: BlurHash(Fit(img, 32), 4, 3),
+	}
+
+	for _, size := range sizes {
+		resized := Fit(img, size.MaxWidth)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Result{}, fmt.Errorf("encode %s variant: %w", size.Name, err)
+		}
+		result.Variants = append(result.Variants, Variant{
+			Name:   size.Name,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+	return result, nil
+}
+
+// flatten draws img over a white background, as JPEG has no transparency.
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// Fit scales img down to fit within maxSide x maxSide, averaging the source pixels each
+// destination pixel covers. Smaller images are returned as they are.
+func Fit(img *image.RGBA, maxSide int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	if sw <= maxSide && sh <= maxSide {
+		return img
+	}
+
+	dw, dh := maxSide, maxSide
+	if sw >= sh {
+		dh = max(1, sh*maxSide/sw)
+	} else {
+		dw = max(1, sw*maxSide/sh)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+
+			var r, g, b, a, n uint32
+			for y := y0; y < y1; y++ {
+				row := img.Pix[y*img.Stride+x0*4 : y*img.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint32(row[i])
+					g += uint32(row[i+1])
+					b += uint32(row[i+2])
+					a += uint32(row[i+3])
+					n++
+				}
+			}
+			i := dy*dst.Stride + dx*4
+			dst.Pix[i] = uint8((r + n/2) / n)
+			dst.Pix[i+1] = uint8((g + n/2) / n)
+			dst.Pix[i+2] = uint8((b + n/2) / n)
+			dst.Pix[i+3] = uint8((a + n/2) / n)
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) so the image displays upright without it.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	// source returns the source pixel shown at (x, y) of the upright image.
+	source := func(x, y int) (int, int) {
+		switch orientation {
+		case 2:
+			return w - 1 - x, y
+		case 3:
+			return w - 1 - x, h - 1 - y
+		case 4:
+			return x, h - 1 - y
+		case 5:
+			return y, x
+		case 6:
+			return y, h - 1 - x
+		case 7:
+			return w - 1 - y, h - 1 - x
+		default: // 8
+			return w - 1 - y, x
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := source(x, y)
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], img.Pix[sy*img.Stride+sx*4:sy*img.Stride+sx*4+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,166 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// twoTone returns a w x h image whose left half is red and right half blue.
+func twoTone(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// jpegWithExif encodes img as JPEG with an EXIF segment holding the orientation and a GPS
+// marker that must not survive processing.
+func jpegWithExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	data := buf.Bytes()
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry[0:], exifOrientationTag)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 1.2921S 36.8219E"...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestValidate(t *testing.T) {
+	info, err := Validate(encodePNG(t, twoTone(400, 300)), DefaultLimits)
+	require.NoError(t, err)
+	require.Equal(t, Info{ContentType: "image/png", Width: 400, Height: 300}, info)
+
+	_, err = Validate(encodePNG(t, twoTone(100, 100)), DefaultLimits)
+	require.ErrorIs(t, err, ErrDimensions)
+
+	_, err = Validate([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), DefaultLimits)
+	require.ErrorIs(t, err, ErrUnsupportedType)
+
+	_, err = Validate([]byte("<?php echo 'hi'; ?>"), DefaultLimits)
+	require.ErrorIs(t, err, ErrUnsupportedType)
+
+	limits := DefaultLimits
+	limits.MaxBytes = 10
+	_, err = Validate(encodePNG(t, twoTone(400, 300)), limits)
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestProcess(t *testing.T) {
+	result, err := Process(encodePNG(t, twoTone(1000, 500)), DefaultLimits, DefaultSizes)
+	require.NoError(t, err)
+	require.Equal(t, 1000, result.Width)
+	require.Equal(t, 500, result.Height)
+	require.Len(t, result.BlurHash, 28)
+
+	sizes := make(map[string][2]int)
+	for _, v := range result.Variants {
+		img, err := jpeg.Decode(bytes.NewReader(v.Data))
+		require.NoError(t, err)
+		require.Equal(t, [2]int{v.Width, v.Height}, [2]int{img.Bounds().Dx(), img.Bounds().Dy()})
+		sizes[v.Name] = [2]int{v.Width, v.Height}
+	}
+	// Images are scaled down to fit each size but never up.
+	require.Equal(t, map[string][2]int{
+		"thumb":  {320, 160},
+		"small":  {640, 320},
+		"medium": {1000, 500},
+		"large":  {1000, 500},
+	}, sizes)
+}
+
+func TestProcessAppliesOrientationAndStripsExif(t *testing.T) {
+	data := jpegWithExif(t, twoTone(400, 320), 6)
+	require.Equal(t, 6, exifOrientation(data))
+
+	result, err := Process(data, DefaultLimits, []Size{{Name: "thumb", MaxWidth: 320}})
+	require.NoError(t, err)
+	require.Equal(t, 320, result.Width)
+	require.Equal(t, 400, result.Height)
+
+	thumb := result.Variants[0].Data
+	require.NotContains(t, string(thumb), "Exif")
+	require.NotContains(t, string(thumb), "GPS")
+	require.Equal(t, 1, exifOrientation(thumb))
+
+	// Rotated clockwise, the red left half of the photo ends up on top.
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	top := color.RGBAModel.Convert(img.At(result.Variants[0].Width/2, 10)).(color.RGBA)
+	bottom := color.RGBAModel.Convert(img.At(result.Variants[0].Width/2, result.Variants[0].Height-10)).(color.RGBA)
+	require.Greater(t, top.R, top.B)
+	require.Greater(t, bottom.B, bottom.R)
+}
+
+func TestOrient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i / 4)
+	}
+	// Pixel values 0 1 2 / 3 4 5, read back row by row after each orientation.
+	pixels := func(img *image.RGBA) []uint8 {
+		var out []uint8
+		for i := 0; i < len(img.Pix); i += 4 {
+			out = append(out, img.Pix[i])
+		}
+		return out
+	}
+	require.Equal(t, []uint8{0, 1, 2, 3, 4, 5}, pixels(orient(img, 1)))
+	require.Equal(t, []uint8{2, 1, 0, 5, 4, 3}, pixels(orient(img, 2)))
+	require.Equal(t, []uint8{5, 4, 3, 2, 1, 0}, pixels(orient(img, 3)))
+	require.Equal(t, []uint8{3, 4, 5, 0, 1, 2}, pixels(orient(img, 4)))
+	require.Equal(t, []uint8{0, 3, 1, 4, 2, 5}, pixels(orient(img, 5)))
+	require.Equal(t, []uint8{3, 0, 4, 1, 5, 2}, pixels(orient(img, 6)))
+	require.Equal(t, []uint8{5, 2, 4, 1, 3, 0}, pixels(orient(img, 7)))
+	require.Equal(t, []uint8{2, 5, 1, 4, 0, 3}, pixels(orient(img, 8)))
+}
+
+func TestBlurHash(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []uint8{255, 0, 0, 255})
+	}
+	// The first character encodes 4x3 components and characters 2-5 the average colour.
+	hash := BlurHash(red, 4, 3)
+	require.Len(t, hash, 28)
+	require.Equal(t, "L", hash[:1])
+	require.Equal(t, "TI:j", hash[2:6])
+	require.Equal(t, hash, BlurHash(red, 4, 3))
+
+	require.Len(t, BlurHash(twoTone(32, 16), 4, 3), 28)
+	require.NotEqual(t, BlurHash(twoTone(32, 16), 4, 3), BlurHash(red, 4, 3))
+}
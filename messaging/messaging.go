@@ -0,0 +1,183 @@
+// Package messaging carries the conversations between guests and hosts: the rules for
+// messages and their attachments, and the live events pushed to connected clients.
+// Events are published through Redis so that a client connected to any API server
+// receives them.
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The sides of a conversation. All hosts who may answer for a listing share the host
+// side and its read marker.
+const (
+	Guest = "guest"
+	Host  = "host"
+)
+
+// Event types.
+const (
+	// EventMessage carries a new message.
+	EventMessage = "message"
+	// EventRead carries both sides' read markers after one of them read the conversation.
+	EventRead = "read"
+)
+
+const (
+	MaxBodyLength      = 5000
+	MaxAttachments     = 5
+	MaxAttachmentBytes = 10 << 20
+
+	// HeartbeatInterval is how often an idle event stream is written to, so proxies do not
+	// close it.
+	HeartbeatInterval = 25 * time.Second
+)
+
+var (
+	ErrEmptyMessage       = errors.New("a message needs a body or an attachment")
+	ErrBodyTooLong        = fmt.Errorf("a message can be at most %d characters", MaxBodyLength)
+	ErrTooManyAttachments = fmt.Errorf("a message can have at most %d attachments", MaxAttachments)
+	ErrAttachmentType     = errors.New("attachments must be JPEG, PNG, GIF or WebP images or PDF documents")
+	ErrAttachmentTooLarge = fmt.Errorf("attachments can be at most %d MB", MaxAttachmentBytes>>20)
+)
+
+// attachmentTypes maps the content types attachments may have to their file extension.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// Validate checks the body and the number of attachments of a new message. body must
+// already be trimmed.
+func Validate(body string, attachments int) error {
+	if body == "" && attachments == 0 {
+		return ErrEmptyMessage
+	}
+	if len([]rune(body)) > MaxBodyLength {
+		return ErrBodyTooLong
+	}
+	if attachments > MaxAttachments {
+		return ErrTooManyAttachments
+	}
+	return nil
+}
+
+// AttachmentExtension sniffs the content of an attachment and returns the file extension
+// it is stored with. The name the client gave the file is not trusted.
+func AttachmentExtension(data []byte) (string, error) {
+	if len(data) > MaxAttachmentBytes {
+		return "", ErrAttachmentTooLarge
+	}
+	ext, ok := attachmentTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrAttachmentType
+	}
+	return ext, nil
+}
+
+// AttachmentKey is the storage key of an attachment in a conversation.
+func AttachmentKey(conversationID int32, name, ext string) string {
+	return fmt.Sprintf("messages/%d/%s%s", conversationID, name, ext)
+}
+
+// AttachmentName is the name clients fetch the attachment stored under key by. Together
+// with the conversation it gives back the key.
+func AttachmentName(key string) string {
+	return path.Base(key)
+}
+
+// Channel is the pub/sub channel of the events for a guest (a user) or a host (an
+// admin).
+func Channel(side string, id int32) string {
+	return fmt.Sprintf("messaging:%s:%d", side, id)
+}
+
+// Event is pushed to the participants of a conversation. Data is the message or the read
+// markers, as the client receives them.
+type Event struct {
+	Type           string          `json:"type"`
+	ConversationID int32           `json:"conversation_id"`
+	Data           json.RawMessage `json:"data"`
+}
+
+// NewEvent encodes data into an event.
+func NewEvent(eventType string, conversationID int32, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: eventType, ConversationID: conversationID, Data: raw}, nil
+}
+
+// RedisBroker publishes events to Redis channels and subscribes to them.
+type RedisBroker struct {
+	client *redis.Client
+}
+
+func NewRedisBroker(client *redis.Client) *RedisBroker {
+	return &RedisBroker{client: client}
+}
+
+// Publish sends e to everyone subscribed to one of channels.
+func (b *RedisBroker) Publish(ctx context.Context, e Event, channels ...string) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, channel := range channels {
+			pipe.Publish(ctx, channel, payload)
+		}
+		return nil
+	})
+	return err
+}
+
+// Subscribe returns the events published to channel. The events channel is closed when
+// ctx is done, which also ends the subscription. Malformed events are dropped.
+func (b *RedisBroker) Subscribe(ctx context.Context, channel string) (<-chan Event, error) {
+	sub := b.client.Subscribe(ctx, channel)
+	// Wait for the subscription so no event published after Subscribe returns is missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var e Event
+				if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+					continue
+				}
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
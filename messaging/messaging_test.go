@@ -0,0 +1,57 @@
+package messaging
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate("Hello", 0))
+	require.NoError(t, Validate("", 1))
+	require.ErrorIs(t, Validate("", 0), ErrEmptyMessage)
+	require.NoError(t, Validate(strings.Repeat("é", MaxBodyLength), 0))
+	require.ErrorIs(t, Validate(strings.Repeat("a", MaxBodyLength+1), 0), ErrBodyTooLong)
+	require.ErrorIs(t, Validate("Photos", MaxAttachments+1), ErrTooManyAttachments)
+}
+
+func TestAttachmentExtension(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+		ext  string
+		err  error
+	}{
+		{name: "png", data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ext: ".png"},
+		{name: "jpeg", data: []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), ext: ".jpg"},
+		{name: "pdf", data: []byte("%PDF-1.7\n"), ext: ".pdf"},
+		{name: "html", data: []byte("<html><script>alert(1)</script></html>"), err: ErrAttachmentType},
+		{name: "too large", data: make([]byte, MaxAttachmentBytes+1), err: ErrAttachmentTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ext, err := AttachmentExtension(tc.data)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.ext, ext)
+		})
+	}
+}
+
+func TestKeysAndChannels(t *testing.T) {
+	require.Equal(t, "messages/12/abc.pdf", AttachmentKey(12, "abc", ".pdf"))
+	require.Equal(t, "abc.pdf", AttachmentName("messages/12/abc.pdf"))
+	require.Equal(t, "messaging:guest:3", Channel(Guest, 3))
+	require.Equal(t, "messaging:host:4", Channel(Host, 4))
+}
+
+func TestNewEvent(t *testing.T) {
+	e, err := NewEvent(EventRead, 9, map[string]int32{"guest_last_read_id": 5})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(e)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"read","conversation_id":9,"data":{"guest_last_read_id":5}}`, string(payload))
+}
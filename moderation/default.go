@@ -0,0 +1,11 @@
+package moderation
+
+import _ "embed"
+
+//go:embed words.txt
+var defaultWords string
+
+// DefaultWordList returns the word list shipped with the application.
+func DefaultWordList() *WordList {
+	return ParseWordList(defaultWords)
+}
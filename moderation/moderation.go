@@ -0,0 +1,160 @@
+// Package moderation screens user-written text such as reviews for abuse and spam before
+// it is shown to other users.
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Content is a piece of user-written text to screen. ID identifies the content in its
+// store so filters comparing it against other content can leave it out.
+type Content struct {
+	ID   int32
+	Text string
+}
+
+// Filter checks content and returns the reasons it should be held for moderation, if any.
+type Filter interface {
+	Check(ctx context.Context, content Content) ([]string, error)
+}
+
+// Chain runs every filter in turn and collects their reasons.
+type Chain []Filter
+
+func (c Chain) Check(ctx context.Context, content Content) ([]string, error) {
+	var reasons []string
+	for _, f := range c {
+		r, err := f.Check(ctx, content)
+		if err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, r...)
+	}
+	return reasons, nil
+}
+
+// WordList flags content containing any of a set of blocked words. Matching is on whole
+// words and ignores case.
+type WordList struct {
+	words map[string]struct{}
+}
+
+func NewWordList(words []string) *WordList {
+	w := &WordList{words: make(map[string]struct{}, len(words))}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && !strings.HasPrefix(word, "#") {
+			w.words[word] = struct{}{}
+		}
+	}
+	return w
+}
+
+// ParseWordList reads a word list with one word per line. Blank lines and lines starting
+// with # are ignored.
+func ParseWordList(list string) *WordList {
+	return NewWordList(strings.Split(list, "\n"))
+}
+
+func (w *WordList) Check(ctx context.Context, content Content) ([]string, error) {
+	for _, word := range words(content.Text) {
+		if _, ok := w.words[word]; ok {
+			return []string{"blocked word"}, nil
+		}
+	}
+	return nil, nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9.-]+\.(com|net|org|io|co|info|biz|xyz|ly|me)\b|\b[\w.+-]+@[\w-]+\.[\w.]+\b`)
+
+// LinkFilter flags content containing web addresses or email addresses, which are used to
+// take bookings off the platform or to advertise.
+type LinkFilter struct{}
+
+func (LinkFilter) Check(ctx context.Context, content Content) ([]string, error) {
+	if linkPattern.MatchString(content.Text) {
+		return []string{"contains a link or email address"}, nil
+	}
+	return nil, nil
+}
+
+var phonePattern = regexp.MustCompile(`\+?\d[\d\s().-]{6,}\d`)
+
+// minPhoneDigits is the fewest digits counted as a phone number, so that dates and prices
+// are not flagged.
+const minPhoneDigits = 9
+
+// PhoneFilter flags content containing phone numbers.
+type PhoneFilter struct{}
+
+func (PhoneFilter) Check(ctx context.Context, content Content) ([]string, error) {
+	for _, match := range phonePattern.FindAllString(content.Text, -1) {
+		var digits int
+		for _, r := range match {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		if digits >= minPhoneDigits {
+			return []string{"contains a phone number"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// FingerprintStore counts the stored content, other than the given one, with a fingerprint.
+type FingerprintStore interface {
+	CountDuplicateContent(ctx context.Context, id int32, fingerprint string) (int64, error)
+}
+
+// minDuplicateWords is the fewest words a text needs before it is checked for duplicates,
+// so that short reviews like "Great stay!" are not flagged.
+const minDuplicateWords = 8
+
+// DuplicateFilter flags content whose text was already posted elsewhere, ignoring case,
+// punctuation and spacing.
+type DuplicateFilter struct {
+	store FingerprintStore
+}
+
+func NewDuplicateFilter(store FingerprintStore) *DuplicateFilter {
+	return &DuplicateFilter{store: store}
+}
+
+func (d *DuplicateFilter) Check(ctx context.Context, content Content) ([]string, error) {
+	if len(words(content.Text)) < minDuplicateWords {
+		return nil, nil
+	}
+
+	n, err := d.store.CountDuplicateContent(ctx, content.ID, Fingerprint(content.Text))
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return []string{"duplicate text"}, nil
+	}
+	return nil, nil
+}
+
+// Fingerprint returns a hash of the text's words, ignoring case, punctuation and spacing.
+// Empty text has an empty fingerprint.
+func Fingerprint(text string) string {
+	w := words(text)
+	if len(w) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(w, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// words splits text into lower-case words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFingerprints map[string]int32
+
+func (f fakeFingerprints) CountDuplicateContent(ctx context.Context, id int32, fingerprint string) (int64, error) {
+	if other, ok := f[fingerprint]; ok && other != id {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func check(t *testing.T, f Filter, text string) []string {
+	t.Helper()
+	reasons, err := f.Check(context.Background(), Content{ID: 1, Text: text})
+	require.NoError(t, err)
+	return reasons
+}
+
+func TestWordList(t *testing.T) {
+	f := ParseWordList("# comment\nscam\n\nRubbish\n")
+
+	require.NotEmpty(t, check(t, f, "Total SCAM, avoid."))
+	require.NotEmpty(t, check(t, f, "rubbish host"))
+	require.Empty(t, check(t, f, "The scampi nearby was great"))
+	require.Empty(t, check(t, f, "comment"))
+
+	require.NotEmpty(t, check(t, DefaultWordList(), "What the fuck"))
+}
+
+func TestLinkFilter(t *testing.T) {
+	for _, text := range []string{
+		"Book direct at https://example.com/villa",
+		"see www.cheapstays.net",
+		"cheapstays.com has it for less",
+		"email me: host@example.org",
+	} {
+		require.NotEmpty(t, check(t, LinkFilter{}, text), text)
+	}
+
+	require.Empty(t, check(t, LinkFilter{}, "Lovely place. Would stay again."))
+}
+
+func TestPhoneFilter(t *testing.T) {
+	require.NotEmpty(t, check(t, PhoneFilter{}, "Call me on +254 712 345 678"))
+	require.NotEmpty(t, check(t, PhoneFilter{}, "text (555) 123-4567 ext"))
+
+	require.Empty(t, check(t, PhoneFilter{}, "Stayed 2024-06-01 to 2024-06-05"))
+	require.Empty(t, check(t, PhoneFilter{}, "Paid 12,500 for 3 nights"))
+}
+
+func TestDuplicateFilter(t *testing.T) {
+	text := "Amazing place, the host was very friendly and the view was stunning!"
+	f := NewDuplicateFilter(fakeFingerprints{Fingerprint(text): 2})
+
+	require.NotEmpty(t, check(t, f, "amazing place the host was very friendly and the   view was STUNNING"))
+	require.Empty(t, check(t, f, "Amazing place, the host was friendly and the view was stunning!"))
+
+	// Short texts and the content itself are not duplicates.
+	short := NewDuplicateFilter(fakeFingerprints{Fingerprint("Great stay!"): 2})
+	require.Empty(t, check(t, short, "Great stay!"))
+	self := NewDuplicateFilter(fakeFingerprints{Fingerprint(text): 1})
+	require.Empty(t, check(t, self, text))
+}
+
+func TestChain(t *testing.T) {
+	f := Chain{NewWordList([]string{"scam"}), LinkFilter{}, PhoneFilter{}}
+
+	reasons := check(t, f, "scam! book at example.com or call 0712 345 678")
+	require.Len(t, reasons, 3)
+	require.Empty(t, check(t, f, "Clean, quiet and close to the beach."))
+}
+
+func TestFingerprint(t *testing.T) {
+	require.Equal(t, Fingerprint("Hello, World"), Fingerprint("hello world!"))
+	require.NotEqual(t, Fingerprint("hello world"), Fingerprint("world hello"))
+	require.Empty(t, Fingerprint(" ... "))
+}
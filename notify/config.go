@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/weldonkipchirchir/rental_listing/mail"
+)
+
+// SMS providers that can be selected in Config.
+const (
+	ProviderAfricasTalking = "africastalking"
+	ProviderTwilio         = "twilio"
+)
+
+// Config configures the SMS and push channels. A channel that is not configured is off,
+// so a development setup only sends emails.
+type Config struct {
+	SMSProvider string
+
+	AfricasTalkingUsername string
+	AfricasTalkingAPIKey   string
+	AfricasTalkingFrom     string
+
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string
+
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	VAPIDSubject    string
+}
+
+// ConfigFromEnv reads the notification channel configuration from the environment.
+func ConfigFromEnv(getenv func(string) string) Config {
+	cfg := Config{
+		SMSProvider:            getenv("SMS_PROVIDER"),
+		AfricasTalkingUsername: getenv("AFRICASTALKING_USERNAME"),
+		AfricasTalkingAPIKey:   getenv("AFRICASTALKING_API_KEY"),
+		AfricasTalkingFrom:     getenv("AFRICASTALKING_SENDER_ID"),
+		TwilioAccountSID:       getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:        getenv("TWILIO_AUTH_TOKEN"),
+		TwilioFrom:             getenv("TWILIO_FROM_NUMBER"),
+		VAPIDPublicKey:         getenv("VAPID_PUBLIC_KEY"),
+		VAPIDPrivateKey:        getenv("VAPID_PRIVATE_KEY"),
+		VAPIDSubject:           getenv("VAPID_SUBJECT"),
+	}
+	if cfg.VAPIDSubject == "" {
+		cfg.VAPIDSubject = "mailto:weldonkipchirchir23@gmail.com"
+	}
+	return cfg
+}
+
+// NewNotifiers returns an email notifier using sender, and the SMS and push notifiers
+// that cfg configures.
+func NewNotifiers(cfg Config, sender mail.EmailSender, client *http.Client) ([]Notifier, error) {
+	notifiers := []Notifier{NewEmailNotifier(sender)}
+
+	switch cfg.SMSProvider {
+	case "":
+	case ProviderAfricasTalking:
+		notifiers = append(notifiers, NewSMSNotifier(NewAfricasTalking(cfg.AfricasTalkingUsername, cfg.AfricasTalkingAPIKey, cfg.AfricasTalkingFrom, client)))
+	case ProviderTwilio:
+		notifiers = append(notifiers, NewSMSNotifier(NewTwilio(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFrom, client)))
+	default:
+		return nil, fmt.Errorf("unknown SMS provider %q", cfg.SMSProvider)
+	}
+
+	if cfg.VAPIDPrivateKey != "" {
+		push, err := NewPushNotifier(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey, cfg.VAPIDSubject, client)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, push)
+	}
+	return notifiers, nil
+}
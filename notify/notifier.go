@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/weldonkipchirchir/rental_listing/mail"
+)
+
+// ErrNoAddress is returned when a recipient cannot be reached on a notifier's channel.
+var ErrNoAddress = errors.New("no address for the notification channel")
+
+// Address is where a recipient is reached. Each channel uses its own field.
+type Address struct {
+	Email string            `json:"email,omitempty"`
+	Phone string            `json:"phone,omitempty"`
+	Push  *PushSubscription `json:"push,omitempty"`
+}
+
+// Notifier delivers rendered notifications on one channel.
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, to Address, msg Message) error
+}
+
+// Summary returns the paragraph of the message text that says what happened, for channels
+// that only show a line or two.
+func Summary(msg Message) string {
+	paragraphs := strings.Split(msg.Text, "\n\n")
+	// The first paragraph greets the recipient; the next one says what happened.
+	if len(paragraphs) > 1 && strings.HasPrefix(paragraphs[0], "Hello ") {
+		paragraphs = paragraphs[1:]
+	}
+	return strings.Join(strings.Fields(paragraphs[0]), " ")
+}
+
+// EmailNotifier sends notifications as HTML emails.
+type EmailNotifier struct {
+	sender mail.EmailSender
+}
+
+func NewEmailNotifier(sender mail.EmailSender) *EmailNotifier {
+	return &EmailNotifier{sender: sender}
+}
+
+func (n *EmailNotifier) Channel() string {
+	return Email
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, to Address, msg Message) error {
+	if to.Email == "" {
+		return ErrNoAddress
+	}
+	return n.sender.SendEmail(msg.Subject, msg.HTML, []string{to.Email}, nil, nil, nil)
+}
+
+// Delivery is a notification a Fake was asked to deliver.
+type Delivery struct {
+	To      Address
+	Message Message
+}
+
+// Fake is a Notifier for tests that records deliveries instead of making them. Deliveries
+// fail with Err if it is set.
+type Fake struct {
+	channel string
+
+	mu        sync.Mutex
+	delivered []Delivery
+	Err       error
+}
+
+func NewFake(channel string) *Fake {
+	return &Fake{channel: channel}
+}
+
+func (f *Fake) Channel() string {
+	return f.channel
+}
+
+func (f *Fake) Notify(ctx context.Context, to Address, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.delivered = append(f.delivered, Delivery{To: to, Message: msg})
+	return nil
+}
+
+// Delivered returns the deliveries made so far.
+func (f *Fake) Delivered() []Delivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Delivery(nil), f.delivered...)
+}
+
+var (
+	_ Notifier = (*EmailNotifier)(nil)
+	_ Notifier = (*SMSNotifier)(nil)
+	_ Notifier = (*PushNotifier)(nil)
+	_ Notifier = (*Fake)(nil)
+)
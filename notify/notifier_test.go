@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEmailSender struct {
+	subject, content string
+	to               []string
+}
+
+func (s *fakeEmailSender) SendEmail(subject string, content string, to []string, cc []string, bcc []string, attachFiles []string) error {
+	s.subject, s.content, s.to = subject, content, to
+	return nil
+}
+
+func (s *fakeEmailSender) SendVerificationEmail(toEmail string, verificationLink string, username string) error {
+	return nil
+}
+
+func TestSummary(t *testing.T) {
+	require.Equal(t, "Your booking is accepted.", Summary(Message{Text: "Hello guest,\n\nYour booking\nis accepted.\n\nSee you soon."}))
+	require.Equal(t, "Booking cancelled.", Summary(Message{Text: "Booking cancelled."}))
+	require.Equal(t, "Hello guest,", Summary(Message{Text: "Hello guest,"}))
+}
+
+func TestEmailNotifier(t *testing.T) {
+	sender := &fakeEmailSender{}
+	notifier := NewEmailNotifier(sender)
+
+	msg := Message{Subject: "Booking accepted", Text: "Hello", HTML: "<p>Hello</p>"}
+	require.NoError(t, notifier.Notify(context.Background(), Address{Email: "guest@email.com"}, msg))
+	require.Equal(t, "Booking accepted", sender.subject)
+	require.Equal(t, "<p>Hello</p>", sender.content)
+	require.Equal(t, []string{"guest@email.com"}, sender.to)
+
+	require.ErrorIs(t, notifier.Notify(context.Background(), Address{Phone: "+254712345678"}, msg), ErrNoAddress)
+}
+
+func TestFake(t *testing.T) {
+	fake := NewFake(SMS)
+	require.Equal(t, SMS, fake.Channel())
+
+	to := Address{Phone: "+254712345678"}
+	require.NoError(t, fake.Notify(context.Background(), to, Message{Subject: "Hello"}))
+	fake.Err = errors.New("gateway down")
+	require.Error(t, fake.Notify(context.Background(), to, Message{Subject: "Again"}))
+	require.Equal(t, []Delivery{{To: to, Message: Message{Subject: "Hello"}}}, fake.Delivered())
+}
+
+func TestNewNotifiers(t *testing.T) {
+	channels := func(notifiers []Notifier) []string {
+		var channels []string
+		for _, n := range notifiers {
+			channels = append(channels, n.Channel())
+		}
+		return channels
+	}
+
+	notifiers, err := NewNotifiers(ConfigFromEnv(func(string) string { return "" }), &fakeEmailSender{}, http.DefaultClient)
+	require.NoError(t, err)
+	require.Equal(t, []string{Email}, channels(notifiers))
+
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+	env := map[string]string{
+		"SMS_PROVIDER":      ProviderTwilio,
+		"VAPID_PUBLIC_KEY":  publicKey,
+		"VAPID_PRIVATE_KEY": privateKey,
+	}
+	notifiers, err = NewNotifiers(ConfigFromEnv(func(key string) string { return env[key] }), &fakeEmailSender{}, http.DefaultClient)
+	require.NoError(t, err)
+	require.Equal(t, []string{Email, SMS, Push}, channels(notifiers))
+
+	_, err = NewNotifiers(Config{SMSProvider: "pigeon"}, &fakeEmailSender{}, http.DefaultClient)
+	require.Error(t, err)
+}
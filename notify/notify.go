@@ -0,0 +1,213 @@
+// Package notify describes the notifications the platform sends on its own when something
+// happens to a booking: the categories of events, who hears about each, the templates
+// they are rendered with, and the channels they go out on unless the recipient turned
+// them off. Notifiers deliver them by email, SMS and web push.
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// Categories of booking events. A recipient gets at most one notification of each
+// category per booking, or per modification for the modification categories.
+const (
+	BookingCreated        = "booking_created"
+	BookingAccepted       = "booking_accepted"
+	BookingDeclined       = "booking_declined"
+	BookingCancelled      = "booking_cancelled"
+	BookingExpired        = "booking_expired"
+	BookingCompleted      = "booking_completed"
+	PaymentReceived       = "payment_received"
+	CheckInReminder       = "check_in_reminder"
+	ReviewRequest         = "review_request"
+	ModificationRequested = "modification_requested"
+	ModificationApproved  = "modification_approved"
+	ModificationDeclined  = "modification_declined"
+)
+
+var Categories = []string{
+	BookingCreated, BookingAccepted, BookingDeclined, BookingCancelled, BookingExpired, BookingCompleted,
+	PaymentReceived, CheckInReminder, ReviewRequest,
+	ModificationRequested, ModificationApproved, ModificationDeclined,
+}
+
+// Audiences of a booking event: the user who made the booking, and the admins who host
+// the listing.
+const (
+	Guest = "guest"
+	Host  = "host"
+)
+
+// Channels notifications go out on.
+const (
+	InApp = "in_app"
+	Email = "email"
+	SMS   = "sms"
+	Push  = "push"
+)
+
+var Channels = []string{InApp, Email, SMS, Push}
+
+var (
+	ErrUnknownCategory = errors.New("unknown notification category")
+	ErrUnknownChannel  = errors.New("unknown notification channel")
+)
+
+// audiences lists who hears about each category. Guests hear that their stay is over
+// through the review request.
+var audiences = map[string][]string{
+	BookingCreated:        {Guest, Host},
+	BookingAccepted:       {Guest},
+	BookingDeclined:       {Guest},
+	BookingCancelled:      {Guest, Host},
+	BookingExpired:        {Guest, Host},
+	BookingCompleted:      {Host},
+	PaymentReceived:       {Guest, Host},
+	CheckInReminder:       {Guest},
+	ReviewRequest:         {Guest},
+	ModificationRequested: {Host},
+	ModificationApproved:  {Guest},
+	ModificationDeclined:  {Guest},
+}
+
+// Audiences returns who hears about events of category.
+func Audiences(category string) []string {
+	return audiences[category]
+}
+
+func ValidCategory(category string) bool {
+	_, ok := audiences[category]
+	return ok
+}
+
+func ValidChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Booking is what the templates know about the booking and the recipient.
+type Booking struct {
+	Name      string
+	BookingID int32
+	Title     string
+	CheckIn   string
+	CheckOut  string
+	Status    string
+	Amount    string
+	// Deadline is when the host must answer a booking request by, if it is one.
+	Deadline string
+	// Link is where the recipient can see the booking.
+	Link string
+}
+
+// Message is a rendered notification. Text is shown in the app, HTML is the email body
+// and Link is where the recipient can see the booking.
+type Message struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+	Link    string `json:"link,omitempty"`
+}
+
+//go:embed templates
+var templateFiles embed.FS
+
+var (
+	messages = template.Must(template.ParseFS(templateFiles, "templates/messages.tmpl"))
+	emails   = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/email.html"))
+)
+
+// Render renders the notification of an event of category for audience.
+func Render(category, audience string, b Booking) (Message, error) {
+	if !ValidCategory(category) {
+		return Message{}, ErrUnknownCategory
+	}
+
+	name := category + "." + audience
+	subject, err := execute(messages, name+".subject", b)
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := execute(messages, name+".text", b)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var html bytes.Buffer
+	err = emails.ExecuteTemplate(&html, "email.html", struct {
+		Subject    string
+		Paragraphs []string
+		Link       string
+	}{subject, strings.Split(text, "\n\n"), b.Link})
+	if err != nil {
+		return Message{}, fmt.Errorf("render %s email: %w", name, err)
+	}
+
+	return Message{Subject: subject, Text: text, HTML: html.String(), Link: b.Link}, nil
+}
+
+func execute(t *template.Template, name string, data any) (string, error) {
+	if t.Lookup(name) == nil {
+		return "", fmt.Errorf("no %s template", name)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("render %s: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Preference turns a channel on or off for a category.
+type Preference struct {
+	Category string `json:"category"`
+	Channel  string `json:"channel"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// Validate checks that p is about a known category and channel.
+func (p Preference) Validate() error {
+	if !ValidCategory(p.Category) {
+		return fmt.Errorf("%w: %s", ErrUnknownCategory, p.Category)
+	}
+	if !ValidChannel(p.Channel) {
+		return fmt.Errorf("%w: %s", ErrUnknownChannel, p.Channel)
+	}
+	return nil
+}
+
+// Enabled reports whether notifications of category go out on channel given the
+// recipient's stored preferences. Text messages cost money, so SMS is off until turned on;
+// the other channels are on until turned off.
+func Enabled(prefs []Preference, category, channel string) bool {
+	for _, p := range prefs {
+		if p.Category == category && p.Channel == channel {
+			return p.Enabled
+		}
+	}
+	return channel != SMS
+}
+
+// All returns a preference for every category and channel, as set in prefs or by default.
+func All(prefs []Preference) []Preference {
+	all := make([]Preference, 0, len(Categories)*len(Channels))
+	for _, category := range Categories {
+		for _, channel := range Channels {
+			all = append(all, Preference{
+				Category: category,
+				Channel:  channel,
+				Enabled:  Enabled(prefs, category, channel),
+			})
+		}
+	}
+	return all
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderEveryTemplate(t *testing.T) {
+	b := Booking{
+		Name:      "guest",
+		BookingID: 7,
+		Title:     "Beach house",
+		CheckIn:   "2024-06-01",
+		CheckOut:  "2024-06-05",
+		Status:    "confirmed",
+		Amount:    "450.00",
+		Link:      "http://localhost:3000/bookings/7",
+	}
+
+	for _, category := range Categories {
+		for _, audience := range Audiences(category) {
+			t.Run(category+"."+audience, func(t *testing.T) {
+				msg, err := Render(category, audience, b)
+				require.NoError(t, err)
+				require.NotEmpty(t, msg.Subject)
+				require.NotContains(t, msg.Subject, "\n")
+				require.Contains(t, msg.Text, "Hello guest,\n\n")
+				require.Contains(t, msg.Text, "Beach house")
+				require.Contains(t, msg.HTML, "<h2>"+msg.Subject+"</h2>")
+				require.Contains(t, msg.HTML, `href="http://localhost:3000/bookings/7"`)
+			})
+		}
+	}
+}
+
+func TestRenderBookingRequest(t *testing.T) {
+	b := Booking{
+		Name:      "host",
+		BookingID: 7,
+		Title:     "Beach house",
+		CheckIn:   "2024-06-01",
+		CheckOut:  "2024-06-05",
+		Status:    "pending",
+		Deadline:  "2024-05-02 10:00",
+	}
+
+	msg, err := Render(BookingCreated, Host, b)
+	require.NoError(t, err)
+	require.Equal(t, "New booking request: Beach house", msg.Subject)
+	require.Contains(t, msg.Text, "accept or decline booking #7 by 2024-05-02 10:00")
+
+	b.Status = "confirmed"
+	msg, err = Render(BookingCreated, Host, b)
+	require.NoError(t, err)
+	require.Equal(t, "New booking: Beach house", msg.Subject)
+	require.NotContains(t, msg.Text, "accept or decline")
+}
+
+func TestRenderEscapesEmail(t *testing.T) {
+	msg, err := Render(ReviewRequest, Guest, Booking{Name: "guest", Title: "<script>alert(1)</script>"})
+	require.NoError(t, err)
+	require.Contains(t, msg.Text, "<script>")
+	require.NotContains(t, msg.HTML, "<script>")
+}
+
+func TestRenderUnknown(t *testing.T) {
+	_, err := Render("listing_created", Guest, Booking{})
+	require.ErrorIs(t, err, ErrUnknownCategory)
+
+	_, err = Render(BookingAccepted, Host, Booking{})
+	require.Error(t, err)
+}
+
+func TestPreferences(t *testing.T) {
+	prefs := []Preference{
+		{Category: ReviewRequest, Channel: Email, Enabled: false},
+		{Category: BookingCreated, Channel: InApp, Enabled: true},
+	}
+
+	require.False(t, Enabled(prefs, ReviewRequest, Email))
+	require.True(t, Enabled(prefs, ReviewRequest, InApp))
+	require.True(t, Enabled(prefs, BookingCreated, InApp))
+	require.True(t, Enabled(nil, PaymentReceived, Email))
+	require.False(t, Enabled(nil, PaymentReceived, SMS))
+	require.True(t, Enabled([]Preference{{Category: PaymentReceived, Channel: SMS, Enabled: true}}, PaymentReceived, SMS))
+
+	all := All(prefs)
+	require.Len(t, all, len(Categories)*len(Channels))
+	require.Contains(t, all, Preference{Category: ReviewRequest, Channel: Email, Enabled: false})
+	require.Contains(t, all, Preference{Category: CheckInReminder, Channel: Email, Enabled: true})
+	require.Contains(t, all, Preference{Category: CheckInReminder, Channel: SMS, Enabled: false})
+
+	require.NoError(t, Preference{Category: PaymentReceived, Channel: InApp}.Validate())
+	require.ErrorIs(t, Preference{Category: "marketing", Channel: InApp}.Validate(), ErrUnknownCategory)
+	require.ErrorIs(t, Preference{Category: PaymentReceived, Channel: "fax"}.Validate(), ErrUnknownChannel)
+}
@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrSubscriptionGone is returned for a push subscription the browser has dropped. It
+// should be deleted.
+var ErrSubscriptionGone = errors.New("push subscription is gone")
+
+// PushSubscription is what a browser's PushManager.subscribe returns.
+type PushSubscription struct {
+	Endpoint string   `json:"endpoint"`
+	Keys     PushKeys `json:"keys"`
+}
+
+// PushKeys are the browser's public key and authentication secret, base64url encoded.
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+const (
+	// pushTTL is how long the push service keeps a message for a browser that is offline.
+	pushTTL = 24 * time.Hour
+	// pushRecordSize is the record size of the encrypted payload. Payloads fit in one
+	// record.
+	pushRecordSize = 4096
+	// maxPushPayload is the largest payload push services must accept, less the
+	// encryption overhead.
+	maxPushPayload = 4096 - 86 - 17
+)
+
+// pushPayload is what the service worker receives.
+type pushPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+}
+
+// PushNotifier sends web push messages, signed with the application server's VAPID key
+// and encrypted for each subscription as described in RFC 8291 and RFC 8292.
+type PushNotifier struct {
+	key       *ecdsa.PrivateKey
+	publicKey string
+	subject   string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewPushNotifier returns a PushNotifier for the base64url encoded VAPID key pair.
+// subject is a mailto: or https: URL push services can contact the sender at.
+func NewPushNotifier(publicKey, privateKey, subject string, client *http.Client) (*PushNotifier, error) {
+	d, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub := priv.PublicKey().Bytes()
+	if base64.RawURLEncoding.EncodeToString(pub) != publicKey {
+		return nil, errors.New("VAPID public key does not match the private key")
+	}
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+	return &PushNotifier{key: key, publicKey: publicKey, subject: subject, client: client, now: time.Now}, nil
+}
+
+// GenerateVAPIDKeys returns a new base64url encoded VAPID key pair.
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(priv.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(priv.Bytes()), nil
+}
+
+func (p *PushNotifier) Channel() string {
+	return Push
+}
+
+func (p *PushNotifier) Notify(ctx context.Context, to Address, msg Message) error {
+	if to.Push == nil {
+		return ErrNoAddress
+	}
+	endpoint, err := url.Parse(to.Push.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return fmt.Errorf("invalid push endpoint %q", to.Push.Endpoint)
+	}
+
+	payload, err := json.Marshal(pushPayload{Title: msg.Subject, Body: Summary(msg), URL: msg.Link})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPushPayload {
+		return fmt.Errorf("push payload of %d bytes is too large", len(payload))
+	}
+
+	body, err := encryptPush(to.Push.Keys, payload, nil, nil)
+	if err != nil {
+		return err
+	}
+	token, err := p.vapidToken(endpoint.Scheme + "://" + endpoint.Host)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.Push.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, p.publicKey))
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprint(int(pushTTL.Seconds())))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push to %s: %w", endpoint.Host, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	case resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push to %s: %s: %s", endpoint.Host, resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// vapidToken returns the JWT that identifies the sender to the push service at audience.
+func (p *PushNotifier) vapidToken(audience string) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}{audience, p.now().Add(12 * time.Hour).Unix(), p.subject})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, p.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// encryptPush encrypts payload for a subscription with the aes128gcm content coding. The
+// ephemeral key and salt are generated unless given.
+func encryptPush(keys PushKeys, payload []byte, ephemeral *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaPublic, err := base64.RawURLEncoding.DecodeString(keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription secret: %w", err)
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+
+	if ephemeral == nil {
+		if ephemeral, err = ecdh.P256().GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+	if salt == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	}
+	asPublic := ephemeral.PublicKey().Bytes()
+
+	secret, err := ephemeral.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm, err := expand(hkdf.Extract(sha256.New, secret, authSecret), keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek, err := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header is the salt, the record size and the ephemeral public key. The payload
+	// is a single record, ended by the last record delimiter.
+	body := make([]byte, 0, 16+4+1+len(asPublic)+len(payload)+1+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, pushRecordSize)
+	body = append(body, byte(len(asPublic)))
+	body = append(body, asPublic...)
+	return gcm.Seal(body, nonce, append(payload[:len(payload):len(payload)], 2), nil), nil
+}
+
+func expand(prk, info []byte, length int) ([]byte, error) {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
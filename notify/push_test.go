@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/hkdf"
+)
+
+func decode(t *testing.T, s string) []byte {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return data
+}
+
+// TestEncryptPush checks the example of RFC 8291, appendix A.
+func TestEncryptPush(t *testing.T) {
+	ephemeral, err := ecdh.P256().NewPrivateKey(decode(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	require.NoError(t, err)
+	keys := PushKeys{
+		P256dh: "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		Auth:   "BTBZMqHH6r4Tts7J_aSIgg",
+	}
+
+	body, err := encryptPush(keys, []byte("When I grow up, I want to be a watermelon"), ephemeral, decode(t, "DGv6ra1nlYgDCS1FRnbzlw"))
+	require.NoError(t, err)
+	require.Equal(t, "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN",
+		base64.RawURLEncoding.EncodeToString(body))
+}
+
+// decryptPush decrypts a push message body as the browser with uaPrivate would.
+func decryptPush(t *testing.T, uaPrivate *ecdh.PrivateKey, authSecret, body []byte) []byte {
+	salt, idLen := body[:16], int(body[20])
+	asKey, err := ecdh.P256().NewPublicKey(body[21 : 21+idLen])
+	require.NoError(t, err)
+	secret, err := uaPrivate.ECDH(asKey)
+	require.NoError(t, err)
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPrivate.PublicKey().Bytes()...), asKey.Bytes()...)
+	ikm, err := expand(hkdf.Extract(sha256.New, secret, authSecret), keyInfo, 32)
+	require.NoError(t, err)
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek, err := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	require.NoError(t, err)
+	nonce, err := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	require.NoError(t, err)
+	require.Equal(t, byte(2), plaintext[len(plaintext)-1])
+	return plaintext[:len(plaintext)-1]
+}
+
+// verifyVAPID checks the VAPID authorization of a push request and returns its claims.
+func verifyVAPID(t *testing.T, authorization, publicKey string) map[string]any {
+	require.True(t, strings.HasPrefix(authorization, "vapid t="))
+	token, key, ok := strings.Cut(strings.TrimPrefix(authorization, "vapid t="), ", k=")
+	require.True(t, ok)
+	require.Equal(t, publicKey, key)
+
+	pub := decode(t, key)
+	verifier := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(pub[1:33]), Y: new(big.Int).SetBytes(pub[33:])}
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	sig := decode(t, parts[2])
+	require.Len(t, sig, 64)
+	require.True(t, ecdsa.Verify(verifier, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])))
+
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(decode(t, parts[1]), &claims))
+	return claims
+}
+
+func TestPushNotifier(t *testing.T) {
+	uaPrivate, err := ecdh.P256().NewPrivateKey(decode(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	require.NoError(t, err)
+	authSecret := decode(t, "BTBZMqHH6r4Tts7J_aSIgg")
+
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	status := http.StatusCreated
+	var received []byte
+	var claims map[string]any
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		require.Equal(t, "86400", r.Header.Get("TTL"))
+		claims = verifyVAPID(t, r.Header.Get("Authorization"), publicKey)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received = decryptPush(t, uaPrivate, authSecret, body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	notifier, err := NewPushNotifier(publicKey, privateKey, "mailto:ops@email.com", srv.Client())
+	require.NoError(t, err)
+	notifier.now = func() time.Time { return now }
+
+	to := Address{Push: &PushSubscription{
+		Endpoint: srv.URL + "/push/abc",
+		Keys:     PushKeys{P256dh: base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()), Auth: "BTBZMqHH6r4Tts7J_aSIgg"},
+	}}
+	msg := Message{
+		Subject: "Booking accepted: Beach house",
+		Text:    "Hello guest,\n\nYour booking #7 of Beach house is accepted.",
+		Link:    "http://localhost:3000/bookings/7",
+	}
+	require.NoError(t, notifier.Notify(context.Background(), to, msg))
+
+	var payload pushPayload
+	require.NoError(t, json.Unmarshal(received, &payload))
+	require.Equal(t, pushPayload{Title: msg.Subject, Body: "Your booking #7 of Beach house is accepted.", URL: msg.Link}, payload)
+	require.Equal(t, srv.URL, claims["aud"])
+	require.Equal(t, "mailto:ops@email.com", claims["sub"])
+	require.Equal(t, float64(now.Add(12*time.Hour).Unix()), claims["exp"])
+
+	// Subscriptions the browser dropped are reported so they can be deleted.
+	status = http.StatusGone
+	require.ErrorIs(t, notifier.Notify(context.Background(), to, msg), ErrSubscriptionGone)
+
+	status = http.StatusTooManyRequests
+	err = notifier.Notify(context.Background(), to, msg)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrSubscriptionGone)
+
+	require.ErrorIs(t, notifier.Notify(context.Background(), Address{Email: "guest@email.com"}, msg), ErrNoAddress)
+}
+
+func TestNewPushNotifierKeys(t *testing.T) {
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+	otherKey, _, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	_, err = NewPushNotifier(otherKey, privateKey, "mailto:ops@email.com", http.DefaultClient)
+	require.Error(t, err)
+	_, err = NewPushNotifier(publicKey, "not a key", "mailto:ops@email.com", http.DefaultClient)
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// Recipients' time zones are looked up without depending on the host's zoneinfo.
+	_ "time/tzdata"
+)
+
+var ErrInvalidClock = errors.New("time of day must be HH:MM")
+
+// QuietHours is a daily period in the recipient's time zone during which notifications
+// on channels that interrupt wait until it ends. Start and End are minutes after
+// midnight; a period that ends before it starts spans midnight.
+type QuietHours struct {
+	Start    int
+	End      int
+	Location *time.Location
+}
+
+// Interrupts reports whether notifications on channel wait for quiet hours to end. In-app
+// notifications and emails are only seen when the recipient looks for them.
+func Interrupts(channel string) bool {
+	return channel == SMS || channel == Push
+}
+
+// Until returns when the quiet hours that t falls in end, or false if t falls outside
+// them.
+func (q QuietHours) Until(t time.Time) (time.Time, bool) {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	year, month, day := local.Date()
+
+	switch {
+	case q.Start == q.End:
+		return time.Time{}, false
+	case q.Start < q.End:
+		if minute < q.Start || minute >= q.End {
+			return time.Time{}, false
+		}
+	case minute >= q.Start:
+		// The period spans midnight and ends tomorrow.
+		day++
+	case minute >= q.End:
+		return time.Time{}, false
+	}
+	return time.Date(year, month, day, 0, q.End, 0, 0, loc), true
+}
+
+// ParseClock parses a time of day such as "22:30" into minutes after midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidClock, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatClock formats minutes after midnight as a time of day such as "22:30".
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
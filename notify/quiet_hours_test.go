@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuietHoursUntil(t *testing.T) {
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	require.NoError(t, err)
+	night := QuietHours{Start: 22 * 60, End: 7 * 60, Location: nairobi}
+	afternoon := QuietHours{Start: 13 * 60, End: 14*60 + 30}
+
+	tests := []struct {
+		name  string
+		quiet QuietHours
+		at    time.Time
+		until time.Time
+	}{
+		{"before midnight", night, time.Date(2024, time.June, 1, 23, 15, 0, 0, nairobi), time.Date(2024, time.June, 2, 7, 0, 0, 0, nairobi)},
+		{"after midnight", night, time.Date(2024, time.June, 2, 3, 0, 0, 0, nairobi), time.Date(2024, time.June, 2, 7, 0, 0, 0, nairobi)},
+		{"at the start", night, time.Date(2024, time.June, 1, 22, 0, 0, 0, nairobi), time.Date(2024, time.June, 2, 7, 0, 0, 0, nairobi)},
+		{"at the end", night, time.Date(2024, time.June, 2, 7, 0, 0, 0, nairobi), time.Time{}},
+		{"daytime", night, time.Date(2024, time.June, 2, 12, 0, 0, 0, nairobi), time.Time{}},
+		// 20:30 UTC is 23:30 in Nairobi.
+		{"in another zone", night, time.Date(2024, time.June, 1, 20, 30, 0, 0, time.UTC), time.Date(2024, time.June, 2, 7, 0, 0, 0, nairobi)},
+		{"same day", afternoon, time.Date(2024, time.June, 1, 14, 0, 0, 0, time.UTC), time.Date(2024, time.June, 1, 14, 30, 0, 0, time.UTC)},
+		{"after same day", afternoon, time.Date(2024, time.June, 1, 15, 0, 0, 0, time.UTC), time.Time{}},
+		{"empty", QuietHours{Start: 60, End: 60}, time.Date(2024, time.June, 1, 1, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, ok := tt.quiet.Until(tt.at)
+			require.Equal(t, !tt.until.IsZero(), ok)
+			require.True(t, tt.until.Equal(until), "got %s", until)
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	minutes, err := ParseClock("22:30")
+	require.NoError(t, err)
+	require.Equal(t, 22*60+30, minutes)
+	require.Equal(t, "22:30", FormatClock(minutes))
+	require.Equal(t, "07:05", FormatClock(7*60+5))
+
+	for _, s := range []string{"24:00", "7pm", "", "12:60"} {
+		_, err := ParseClock(s)
+		require.ErrorIs(t, err, ErrInvalidClock, s)
+	}
+
+	require.True(t, Interrupts(SMS))
+	require.True(t, Interrupts(Push))
+	require.False(t, Interrupts(Email))
+	require.False(t, Interrupts(InApp))
+}
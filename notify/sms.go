@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SMSProvider sends text messages through an SMS gateway. Phone numbers are in E.164
+// format, such as +254712345678.
+type SMSProvider interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// ValidPhone reports whether phone is a number in E.164 format.
+func ValidPhone(phone string) bool {
+	return phonePattern.MatchString(phone)
+}
+
+// SMSNotifier sends the subject of notifications and a link to the booking as text
+// messages.
+type SMSNotifier struct {
+	provider SMSProvider
+}
+
+func NewSMSNotifier(provider SMSProvider) *SMSNotifier {
+	return &SMSNotifier{provider: provider}
+}
+
+func (n *SMSNotifier) Channel() string {
+	return SMS
+}
+
+func (n *SMSNotifier) Notify(ctx context.Context, to Address, msg Message) error {
+	if to.Phone == "" {
+		return ErrNoAddress
+	}
+	if !ValidPhone(to.Phone) {
+		return fmt.Errorf("invalid phone number %q", to.Phone)
+	}
+	body := msg.Subject
+	if msg.Link != "" {
+		body += "\n" + msg.Link
+	}
+	return n.provider.SendSMS(ctx, to.Phone, body)
+}
+
+// AfricasTalking sends text messages through the Africa's Talking SMS API.
+type AfricasTalking struct {
+	username string
+	apiKey   string
+	// from is the registered sender ID or short code. The account default is used if it
+	// is empty.
+	from    string
+	baseURL string
+	client  *http.Client
+}
+
+// NewAfricasTalking returns an Africa's Talking provider. The "sandbox" username uses the
+// sandbox API.
+func NewAfricasTalking(username, apiKey, from string, client *http.Client) *AfricasTalking {
+	baseURL := "https://api.africastalking.com"
+	if username == "sandbox" {
+		baseURL = "https://api.sandbox.africastalking.com"
+	}
+	return &AfricasTalking{username: username, apiKey: apiKey, from: from, baseURL: baseURL, client: client}
+}
+
+func (a *AfricasTalking) SendSMS(ctx context.Context, to, body string) error {
+	form := url.Values{
+		"username": {a.username},
+		"to":       {to},
+		"message":  {body},
+	}
+	if a.from != "" {
+		form.Set("from", a.from)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/version1/messaging", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apiKey", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	data, err := do(a.client, req)
+	if err != nil {
+		return fmt.Errorf("africa's talking: %w", err)
+	}
+
+	var result struct {
+		SMSMessageData struct {
+			Message    string `json:"Message"`
+			Recipients []struct {
+				Number     string `json:"number"`
+				Status     string `json:"status"`
+				StatusCode int    `json:"statusCode"`
+			} `json:"Recipients"`
+		} `json:"SMSMessageData"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("africa's talking: decode response: %w", err)
+	}
+	if len(result.SMSMessageData.Recipients) == 0 {
+		return fmt.Errorf("africa's talking: %s", result.SMSMessageData.Message)
+	}
+	for _, r := range result.SMSMessageData.Recipients {
+		// 100 is processed, 101 sent and 102 queued.
+		if r.StatusCode < 100 || r.StatusCode > 102 {
+			return fmt.Errorf("africa's talking: sending to %s: %s", r.Number, r.Status)
+		}
+	}
+	return nil
+}
+
+// Twilio sends text messages through the Twilio Messaging API.
+type Twilio struct {
+	accountSID string
+	authToken  string
+	from       string
+	baseURL    string
+	client     *http.Client
+}
+
+func NewTwilio(accountSID, authToken, from string, client *http.Client) *Twilio {
+	return &Twilio{accountSID: accountSID, authToken: authToken, from: from, baseURL: "https://api.twilio.com", client: client}
+}
+
+func (t *Twilio) SendSMS(ctx context.Context, to, body string) error {
+	form := url.Values{
+		"To":   {to},
+		"From": {t.from},
+		"Body": {body},
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.baseURL, url.PathEscape(t.accountSID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := do(t.client, req); err != nil {
+		return fmt.Errorf("twilio: %w", err)
+	}
+	return nil
+}
+
+// do sends req and returns the response body, or an error with the start of the body if
+// the response is not a success.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return data, nil
+}
+
+var (
+	_ SMSProvider = (*AfricasTalking)(nil)
+	_ SMSProvider = (*Twilio)(nil)
+)
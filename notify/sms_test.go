@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSMSProvider struct {
+	to, body string
+}
+
+func (p *fakeSMSProvider) SendSMS(ctx context.Context, to, body string) error {
+	p.to, p.body = to, body
+	return nil
+}
+
+func TestSMSNotifier(t *testing.T) {
+	provider := &fakeSMSProvider{}
+	notifier := NewSMSNotifier(provider)
+	msg := Message{Subject: "Booking accepted: Beach house", Text: "Hello guest", Link: "http://localhost:3000/bookings/7"}
+
+	require.NoError(t, notifier.Notify(context.Background(), Address{Phone: "+254712345678"}, msg))
+	require.Equal(t, "+254712345678", provider.to)
+	require.Equal(t, "Booking accepted: Beach house\nhttp://localhost:3000/bookings/7", provider.body)
+
+	require.ErrorIs(t, notifier.Notify(context.Background(), Address{Email: "guest@email.com"}, msg), ErrNoAddress)
+	require.Error(t, notifier.Notify(context.Background(), Address{Phone: "0712345678"}, msg))
+}
+
+func TestAfricasTalking(t *testing.T) {
+	response := `{"SMSMessageData":{"Message":"Sent to 1/1 Total Cost: KES 0.8000","Recipients":[{"statusCode":101,"number":"+254712345678","status":"Success"}]}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/version1/messaging", r.URL.Path)
+		require.Equal(t, "secret", r.Header.Get("apiKey"))
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "rentals", r.PostForm.Get("username"))
+		require.Equal(t, "+254712345678", r.PostForm.Get("to"))
+		require.Equal(t, "Hello", r.PostForm.Get("message"))
+		require.Equal(t, "RENTALS", r.PostForm.Get("from"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(response))
+	}))
+	defer srv.Close()
+
+	provider := NewAfricasTalking("rentals", "secret", "RENTALS", srv.Client())
+	provider.baseURL = srv.URL
+	require.NoError(t, provider.SendSMS(context.Background(), "+254712345678", "Hello"))
+
+	// A rejected recipient fails the message.
+	response = `{"SMSMessageData":{"Message":"Sent to 0/1 Total Cost: 0","Recipients":[{"statusCode":403,"number":"+254712345678","status":"InvalidPhoneNumber"}]}}`
+	require.ErrorContains(t, provider.SendSMS(context.Background(), "+254712345678", "Hello"), "InvalidPhoneNumber")
+
+	require.Equal(t, "https://api.sandbox.africastalking.com", NewAfricasTalking("sandbox", "secret", "", nil).baseURL)
+}
+
+func TestTwilio(t *testing.T) {
+	status := http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", r.URL.Path)
+		user, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "AC123", user)
+		require.Equal(t, "token", password)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "+15005550006", r.PostForm.Get("From"))
+		require.Equal(t, "+254712345678", r.PostForm.Get("To"))
+		require.Equal(t, "Hello", r.PostForm.Get("Body"))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number"}`))
+	}))
+	defer srv.Close()
+
+	provider := NewTwilio("AC123", "token", "+15005550006", srv.Client())
+	provider.baseURL = srv.URL
+	require.NoError(t, provider.SendSMS(context.Background(), "+254712345678", "Hello"))
+
+	status = http.StatusBadRequest
+	require.ErrorContains(t, provider.SendSMS(context.Background(), "+254712345678", "Hello"), "Invalid 'To' Phone Number")
+}
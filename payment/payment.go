@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CaptureMethod controls whether funds are captured as soon as the intent is confirmed
+// or only authorized and captured later.
+type CaptureMethod string
+
+const (
+	CaptureAutomatic CaptureMethod = "automatic"
+	CaptureManual    CaptureMethod = "manual"
+)
+
+// Intent statuses reported by the gateway.
+const (
+	StatusSucceeded       = "succeeded"
+	StatusRequiresCapture = "requires_capture"
+	StatusCanceled        = "canceled"
+)
+
+// Intent is a gateway payment intent.
+type Intent struct {
+	ID           string
+	ClientSecret string
+	Amount       int64
+	Status       string
+}
+
+// Refund is a full or partial refund of a captured intent.
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   int64
+	Status   string
+}
+
+// Gateway is the payment provider used for bookings. Capture and Refund take an idempotency
+// key: a request repeated with the same key returns the result of the first one instead of
+// moving money again.
+type Gateway interface {
+	CreateIntent(ctx context.Context, amount int64, capture CaptureMethod) (*Intent, error)
+	GetIntent(ctx context.Context, id string) (*Intent, error)
+	Capture(ctx context.Context, id, idempotencyKey string) (*Intent, error)
+	Cancel(ctx context.Context, id string) (*Intent, error)
+	Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error)
+}
+
+// ToCents converts a decimal amount such as "120.50" into the smallest currency unit.
+func ToCents(amount string) (int64, error) {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("invalid amount %q: must not be negative", amount)
+	}
+	return int64(math.Round(value * 100)), nil
+}
+
+// FormatCents converts an amount in the smallest currency unit back into a decimal string.
+func FormatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
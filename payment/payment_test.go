@@ -0,0 +1,29 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToCents(t *testing.T) {
+	cents, err := ToCents("120.50")
+	require.NoError(t, err)
+	require.Equal(t, int64(12050), cents)
+
+	cents, err = ToCents("19.999")
+	require.NoError(t, err)
+	require.Equal(t, int64(2000), cents)
+
+	_, err = ToCents("abc")
+	require.Error(t, err)
+
+	_, err = ToCents("-1")
+	require.Error(t, err)
+}
+
+func TestFormatCents(t *testing.T) {
+	require.Equal(t, "120.50", FormatCents(12050))
+	require.Equal(t, "0.05", FormatCents(5))
+	require.Equal(t, "-30.00", FormatCents(-3000))
+}
@@ -0,0 +1,104 @@
+package payment
+
+import (
+	"context"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/paymentintent"
+	"github.com/stripe/stripe-go/v72/refund"
+)
+
+// StripeGateway implements Gateway with Stripe payment intents.
+type StripeGateway struct {
+	intents paymentintent.Client
+	refunds refund.Client
+}
+
+func NewStripeGateway(secretKey string) *StripeGateway {
+	backend := stripe.GetBackend(stripe.APIBackend)
+	return &StripeGateway{
+		intents: paymentintent.Client{B: backend, Key: secretKey},
+		refunds: refund.Client{B: backend, Key: secretKey},
+	}
+}
+
+func (g *StripeGateway) CreateIntent(ctx context.Context, amount int64, capture CaptureMethod) (*Intent, error) {
+	params := &stripe.PaymentIntentParams{
+		Amount:        stripe.Int64(amount),
+		Currency:      stripe.String("usd"),
+		CaptureMethod: stripe.String(string(capture)),
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+	}
+	params.Context = ctx
+
+	pi, err := g.intents.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return newIntent(pi), nil
+}
+
+func (g *StripeGateway) GetIntent(ctx context.Context, id string) (*Intent, error) {
+	params := &stripe.PaymentIntentParams{}
+	params.Context = ctx
+
+	pi, err := g.intents.Get(id, params)
+	if err != nil {
+		return nil, err
+	}
+	return newIntent(pi), nil
+}
+
+func (g *StripeGateway) Capture(ctx context.Context, id, idempotencyKey string) (*Intent, error) {
+	params := &stripe.PaymentIntentCaptureParams{}
+	params.Context = ctx
+	params.SetIdempotencyKey(idempotencyKey)
+
+	pi, err := g.intents.Capture(id, params)
+	if err != nil {
+		return nil, err
+	}
+	return newIntent(pi), nil
+}
+
+func (g *StripeGateway) Cancel(ctx context.Context, id string) (*Intent, error) {
+	params := &stripe.PaymentIntentCancelParams{}
+	params.Context = ctx
+
+	pi, err := g.intents.Cancel(id, params)
+	if err != nil {
+		return nil, err
+	}
+	return newIntent(pi), nil
+}
+
+func (g *StripeGateway) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(intentID),
+		Amount:        stripe.Int64(amount),
+	}
+	params.Context = ctx
+	params.SetIdempotencyKey(idempotencyKey)
+
+	r, err := g.refunds.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{
+		ID:       r.ID,
+		IntentID: intentID,
+		Amount:   r.Amount,
+		Status:   string(r.Status),
+	}, nil
+}
+
+func newIntent(pi *stripe.PaymentIntent) *Intent {
+	return &Intent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Amount:       pi.Amount,
+		Status:       string(pi.Status),
+	}
+}
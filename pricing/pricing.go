@@ -0,0 +1,270 @@
+// Package pricing quotes the price of a stay at a listing from its nightly price and the
+// host's price rules.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Rule kinds.
+const (
+	KindOverride   = "override"
+	KindSeason     = "season"
+	KindWeekday    = "weekday"
+	KindLastMinute = "last_minute"
+	KindEarlyBird  = "early_bird"
+)
+
+var (
+	ErrInvalidStay  = errors.New("check-out date must be after check-in date")
+	ErrStayTooShort = errors.New("stay is shorter than the minimum stay")
+	ErrStayTooLong  = errors.New("stay is longer than the maximum stay")
+)
+
+// Rule adjusts the price or the allowed stay length of a listing. Amounts are in the
+// smallest currency unit, dates are inclusive and a zero date leaves that side open.
+//
+// The nightly price of a day comes from the highest priority override covering it, else
+// from a matching weekday rule, else from a season, else the listing's own price. Seasons
+// also set the minimum and maximum stay for stays checking in during them. Last-minute and
+// early-bird rules discount the whole stay by a percentage; only the largest applies.
+type Rule struct {
+	ID              int32
+	Kind            string
+	StartDate       time.Time
+	EndDate         time.Time
+	DaysOfWeek      []time.Weekday
+	NightlyPrice    int64
+	MinStay         int
+	MaxStay         int
+	DiscountPercent float64
+	LeadDays        int
+	Priority        int
+}
+
+// Validate checks that the rule carries the fields its kind needs.
+func (r Rule) Validate() error {
+	if !r.StartDate.IsZero() && !r.EndDate.IsZero() && r.EndDate.Before(r.StartDate) {
+		return errors.New("end date must not be before start date")
+	}
+	if r.MinStay < 0 || r.MaxStay < 0 || r.NightlyPrice < 0 || r.LeadDays < 0 {
+		return errors.New("prices, stay lengths and lead days must not be negative")
+	}
+	if r.MinStay > 0 && r.MaxStay > 0 && r.MaxStay < r.MinStay {
+		return errors.New("maximum stay must not be less than minimum stay")
+	}
+
+	switch r.Kind {
+	case KindOverride:
+		if r.StartDate.IsZero() || r.EndDate.IsZero() || r.NightlyPrice == 0 {
+			return errors.New("override rules need a start date, an end date and a nightly price")
+		}
+	case KindSeason:
+		if r.StartDate.IsZero() || r.EndDate.IsZero() {
+			return errors.New("season rules need a start date and an end date")
+		}
+		if r.NightlyPrice == 0 && r.MinStay == 0 && r.MaxStay == 0 {
+			return errors.New("season rules need a nightly price or a minimum or maximum stay")
+		}
+	case KindWeekday:
+		if len(r.DaysOfWeek) == 0 || r.NightlyPrice == 0 {
+			return errors.New("weekday rules need days of the week and a nightly price")
+		}
+		for _, d := range r.DaysOfWeek {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("invalid day of the week %d", d)
+			}
+		}
+	case KindLastMinute, KindEarlyBird:
+		if r.DiscountPercent <= 0 || r.DiscountPercent > 100 {
+			return errors.New("discount rules need a discount percent between 0 and 100")
+		}
+		if r.Kind == KindEarlyBird && r.LeadDays == 0 {
+			return errors.New("early bird rules need lead days")
+		}
+	default:
+		return fmt.Errorf("unknown rule kind %q", r.Kind)
+	}
+
+	return nil
+}
+
+// covers reports whether the day falls inside the rule's date range.
+func (r Rule) covers(day time.Time) bool {
+	if !r.StartDate.IsZero() && day.Before(dateOf(r.StartDate)) {
+		return false
+	}
+	if !r.EndDate.IsZero() && day.After(dateOf(r.EndDate)) {
+		return false
+	}
+	return true
+}
+
+func (r Rule) onWeekday(day time.Time) bool {
+	for _, d := range r.DaysOfWeek {
+		if d == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// Night is the price of one night of a stay.
+type Night struct {
+	Date   time.Time `json:"date"`
+	Price  int64     `json:"price"`
+	RuleID int32     `json:"rule_id,omitempty"`
+}
+
+// Quote is the price of a stay.
+type Quote struct {
+	Nights         []Night `json:"nights"`
+	Subtotal       int64   `json:"subtotal"`
+	Discount       int64   `json:"discount"`
+	DiscountRuleID int32   `json:"discount_rule_id,omitempty"`
+	Total          int64   `json:"total"`
+}
+
+// Day is one day of a listing's price calendar.
+type Day struct {
+	Date    time.Time `json:"date"`
+	Price   int64     `json:"price"`
+	RuleID  int32     `json:"rule_id,omitempty"`
+	MinStay int       `json:"min_stay,omitempty"`
+	MaxStay int       `json:"max_stay,omitempty"`
+}
+
+// Nights returns the number of nights between the check-in and check-out dates,
+// ignoring the time of day.
+func Nights(checkIn, checkOut time.Time) int {
+	return int(dateOf(checkOut).Sub(dateOf(checkIn)).Hours() / 24)
+}
+
+// QuoteStay prices a stay checking in and out on the given dates and booked at bookedAt.
+func QuoteStay(base int64, rules []Rule, checkIn, checkOut, bookedAt time.Time) (Quote, error) {
+	nights := Nights(checkIn, checkOut)
+	if nights <= 0 {
+		return Quote{}, ErrInvalidStay
+	}
+
+	rules = sorted(rules)
+
+	minStay, maxStay := stayLimits(rules, dateOf(checkIn))
+	if minStay > 0 && nights < minStay {
+		return Quote{}, fmt.Errorf("%w of %d nights", ErrStayTooShort, minStay)
+	}
+	if maxStay > 0 && nights > maxStay {
+		return Quote{}, fmt.Errorf("%w of %d nights", ErrStayTooLong, maxStay)
+	}
+
+	var quote Quote
+	day := dateOf(checkIn)
+	for i := 0; i < nights; i++ {
+		price, ruleID := nightlyPrice(base, rules, day)
+		quote.Nights = append(quote.Nights, Night{Date: day, Price: price, RuleID: ruleID})
+		quote.Subtotal += price
+		day = day.AddDate(0, 0, 1)
+	}
+
+	if rule, ok := bestDiscount(rules, dateOf(checkIn), dateOf(bookedAt)); ok {
+		quote.Discount = int64(math.Round(float64(quote.Subtotal) * rule.DiscountPercent / 100))
+		quote.DiscountRuleID = rule.ID
+	}
+	quote.Total = quote.Subtotal - quote.Discount
+
+	return quote, nil
+}
+
+// Calendar returns the nightly price and stay limits of every day from `from` up to, but
+// not including, `to`.
+func Calendar(base int64, rules []Rule, from, to time.Time) []Day {
+	rules = sorted(rules)
+
+	var days []Day
+	for day := dateOf(from); day.Before(dateOf(to)); day = day.AddDate(0, 0, 1) {
+		price, ruleID := nightlyPrice(base, rules, day)
+		minStay, maxStay := stayLimits(rules, day)
+		days = append(days, Day{Date: day, Price: price, RuleID: ruleID, MinStay: minStay, MaxStay: maxStay})
+	}
+	return days
+}
+
+// nightlyPrice expects rules sorted by priority.
+func nightlyPrice(base int64, rules []Rule, day time.Time) (int64, int32) {
+	for _, kind := range []string{KindOverride, KindWeekday, KindSeason} {
+		for _, r := range rules {
+			if r.Kind != kind || r.NightlyPrice == 0 || !r.covers(day) {
+				continue
+			}
+			if kind == KindWeekday && !r.onWeekday(day) {
+				continue
+			}
+			return r.NightlyPrice, r.ID
+		}
+	}
+	return base, 0
+}
+
+// stayLimits returns the minimum and maximum stay set by the first season covering the
+// check-in day that sets them. Rules must be sorted by priority.
+func stayLimits(rules []Rule, checkIn time.Time) (minStay, maxStay int) {
+	for _, r := range rules {
+		if r.Kind != KindSeason || !r.covers(checkIn) {
+			continue
+		}
+		if minStay == 0 {
+			minStay = r.MinStay
+		}
+		if maxStay == 0 {
+			maxStay = r.MaxStay
+		}
+	}
+	return minStay, maxStay
+}
+
+// bestDiscount returns the largest last-minute or early-bird discount the stay qualifies for.
+func bestDiscount(rules []Rule, checkIn, bookedOn time.Time) (Rule, bool) {
+	lead := int(checkIn.Sub(bookedOn).Hours() / 24)
+
+	var best Rule
+	var found bool
+	for _, r := range rules {
+		if !r.covers(checkIn) {
+			continue
+		}
+
+		var applies bool
+		switch r.Kind {
+		case KindLastMinute:
+			applies = lead >= 0 && lead <= r.LeadDays
+		case KindEarlyBird:
+			applies = lead >= r.LeadDays
+		}
+
+		if applies && (!found || r.DiscountPercent > best.DiscountPercent) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// sorted orders rules by descending priority, newest first among equal priorities.
+func sorted(rules []Rule) []Rule {
+	out := make([]Rule, len(rules))
+	copy(out, rules)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority > out[j].Priority
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,139 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestQuoteStay(t *testing.T) {
+	checkIn := time.Date(2024, time.June, 1, 15, 0, 0, 0, time.UTC)
+	checkOut := time.Date(2024, time.June, 4, 10, 0, 0, 0, time.UTC)
+
+	quote, err := QuoteStay(12050, nil, checkIn, checkOut, date(2024, time.May, 1))
+	require.NoError(t, err)
+	require.Len(t, quote.Nights, 3)
+	require.Equal(t, int64(36150), quote.Subtotal)
+	require.Equal(t, int64(36150), quote.Total)
+
+	_, err = QuoteStay(12050, nil, checkOut, checkIn, date(2024, time.May, 1))
+	require.ErrorIs(t, err, ErrInvalidStay)
+
+	_, err = QuoteStay(12050, nil, checkIn, checkIn, date(2024, time.May, 1))
+	require.ErrorIs(t, err, ErrInvalidStay)
+}
+
+func TestQuoteStayRules(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Kind: KindSeason, StartDate: date(2024, time.December, 1), EndDate: date(2025, time.January, 31), NightlyPrice: 20000, MinStay: 3},
+		{ID: 2, Kind: KindWeekday, DaysOfWeek: []time.Weekday{time.Friday, time.Saturday}, NightlyPrice: 15000},
+		{ID: 3, Kind: KindOverride, StartDate: date(2024, time.December, 31), EndDate: date(2024, time.December, 31), NightlyPrice: 50000},
+	}
+
+	// Mon 30 Dec to Thu 2 Jan: season, New Year's Eve override, season.
+	quote, err := QuoteStay(10000, rules, date(2024, time.December, 30), date(2025, time.January, 2), date(2024, time.October, 1))
+	require.NoError(t, err)
+	require.Equal(t, []Night{
+		{Date: date(2024, time.December, 30), Price: 20000, RuleID: 1},
+		{Date: date(2024, time.December, 31), Price: 50000, RuleID: 3},
+		{Date: date(2025, time.January, 1), Price: 20000, RuleID: 1},
+	}, quote.Nights)
+	require.Equal(t, int64(90000), quote.Total)
+
+	// Weekday pricing applies outside and inside the season: Fri 7 and Sat 8 June.
+	quote, err = QuoteStay(10000, rules, date(2024, time.June, 6), date(2024, time.June, 9), date(2024, time.May, 1))
+	require.NoError(t, err)
+	require.Equal(t, int64(10000+15000+15000), quote.Total)
+
+	// The season requires at least three nights.
+	_, err = QuoteStay(10000, rules, date(2024, time.December, 10), date(2024, time.December, 12), date(2024, time.October, 1))
+	require.ErrorIs(t, err, ErrStayTooShort)
+}
+
+func TestQuoteStayPriority(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Kind: KindSeason, StartDate: date(2024, time.July, 1), EndDate: date(2024, time.August, 31), NightlyPrice: 18000, MaxStay: 14},
+		{ID: 2, Kind: KindSeason, StartDate: date(2024, time.August, 1), EndDate: date(2024, time.August, 15), NightlyPrice: 25000, Priority: 10},
+	}
+
+	quote, err := QuoteStay(10000, rules, date(2024, time.July, 31), date(2024, time.August, 2), date(2024, time.June, 1))
+	require.NoError(t, err)
+	require.Equal(t, int64(18000+25000), quote.Total)
+
+	_, err = QuoteStay(10000, rules, date(2024, time.July, 1), date(2024, time.July, 20), date(2024, time.June, 1))
+	require.ErrorIs(t, err, ErrStayTooLong)
+}
+
+func TestQuoteStayDiscounts(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Kind: KindLastMinute, DiscountPercent: 15, LeadDays: 3},
+		{ID: 2, Kind: KindEarlyBird, DiscountPercent: 10, LeadDays: 60},
+		{ID: 3, Kind: KindEarlyBird, DiscountPercent: 20, LeadDays: 180},
+	}
+	checkIn := date(2024, time.September, 10)
+	checkOut := date(2024, time.September, 12)
+
+	quote, err := QuoteStay(10000, rules, checkIn, checkOut, date(2024, time.September, 8))
+	require.NoError(t, err)
+	require.Equal(t, int64(3000), quote.Discount)
+	require.Equal(t, int32(1), quote.DiscountRuleID)
+	require.Equal(t, int64(17000), quote.Total)
+
+	quote, err = QuoteStay(10000, rules, checkIn, checkOut, date(2024, time.June, 1))
+	require.NoError(t, err)
+	require.Equal(t, int32(2), quote.DiscountRuleID)
+	require.Equal(t, int64(18000), quote.Total)
+
+	// Only the largest discount applies.
+	quote, err = QuoteStay(10000, rules, checkIn, checkOut, date(2024, time.January, 1))
+	require.NoError(t, err)
+	require.Equal(t, int32(3), quote.DiscountRuleID)
+	require.Equal(t, int64(16000), quote.Total)
+
+	quote, err = QuoteStay(10000, rules, checkIn, checkOut, date(2024, time.August, 20))
+	require.NoError(t, err)
+	require.Zero(t, quote.Discount)
+}
+
+func TestCalendar(t *testing.T) {
+	rules := []Rule{
+		{ID: 1, Kind: KindSeason, StartDate: date(2024, time.June, 3), EndDate: date(2024, time.June, 30), NightlyPrice: 12000, MinStay: 2},
+	}
+
+	days := Calendar(10000, rules, date(2024, time.June, 1), date(2024, time.June, 5))
+	require.Len(t, days, 4)
+	require.Equal(t, Day{Date: date(2024, time.June, 1), Price: 10000}, days[0])
+	require.Equal(t, Day{Date: date(2024, time.June, 3), Price: 12000, RuleID: 1, MinStay: 2}, days[2])
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := []Rule{
+		{Kind: KindOverride, StartDate: date(2024, time.June, 1), EndDate: date(2024, time.June, 1), NightlyPrice: 100},
+		{Kind: KindSeason, StartDate: date(2024, time.June, 1), EndDate: date(2024, time.August, 31), MinStay: 3},
+		{Kind: KindWeekday, DaysOfWeek: []time.Weekday{time.Saturday}, NightlyPrice: 100},
+		{Kind: KindLastMinute, DiscountPercent: 10},
+		{Kind: KindEarlyBird, DiscountPercent: 10, LeadDays: 30},
+	}
+	for _, r := range valid {
+		require.NoError(t, r.Validate(), r.Kind)
+	}
+
+	invalid := []Rule{
+		{Kind: "holiday"},
+		{Kind: KindOverride, StartDate: date(2024, time.June, 1), NightlyPrice: 100},
+		{Kind: KindSeason, StartDate: date(2024, time.June, 1), EndDate: date(2024, time.August, 31)},
+		{Kind: KindSeason, StartDate: date(2024, time.June, 1), EndDate: date(2024, time.May, 1), NightlyPrice: 100},
+		{Kind: KindSeason, StartDate: date(2024, time.June, 1), EndDate: date(2024, time.August, 31), MinStay: 7, MaxStay: 3},
+		{Kind: KindWeekday, DaysOfWeek: []time.Weekday{7}, NightlyPrice: 100},
+		{Kind: KindLastMinute, DiscountPercent: 120},
+		{Kind: KindEarlyBird, DiscountPercent: 10},
+	}
+	for _, r := range invalid {
+		require.Error(t, r.Validate(), r.Kind)
+	}
+}
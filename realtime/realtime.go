@@ -0,0 +1,294 @@
+// Package realtime pushes new notifications, unread counts and booking status changes to
+// signed-in users and admins as they happen. Events are published through Redis so that
+// a client connected to any API server receives them, and the latest events of every
+// recipient are kept in a Redis stream so that a client that reconnects can resume where
+// it stopped.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Kinds of recipients.
+const (
+	KindUser  = "user"
+	KindAdmin = "admin"
+)
+
+// Event types.
+const (
+	// EventNotification carries a notification the recipient received.
+	EventNotification = "notification"
+	// EventUnreadCount carries the recipient's number of unread notifications.
+	EventUnreadCount = "unread_count"
+	// EventBookingStatus carries the new status of a booking the recipient made or hosts.
+	EventBookingStatus = "booking_status"
+)
+
+const (
+	// HistoryLength is about how many of their latest events are kept per recipient for
+	// clients that reconnect.
+	HistoryLength = 100
+	// HistoryTTL is how long the events of a recipient are kept after the last one.
+	HistoryTTL = 24 * time.Hour
+
+	// HeartbeatInterval is how often an idle stream is written to, so proxies do not close
+	// it.
+	HeartbeatInterval = 25 * time.Second
+	// RetryInterval is how long clients wait before reconnecting to a closed stream.
+	RetryInterval = 3 * time.Second
+)
+
+var ErrInvalidEventID = errors.New("invalid event ID")
+
+// Recipient is the user or admin events are pushed to.
+type Recipient struct {
+	Kind string
+	ID   int32
+}
+
+func User(id int32) Recipient {
+	return Recipient{Kind: KindUser, ID: id}
+}
+
+func Admin(id int32) Recipient {
+	return Recipient{Kind: KindAdmin, ID: id}
+}
+
+func (r Recipient) String() string {
+	return fmt.Sprintf("%s %d", r.Kind, r.ID)
+}
+
+// channel is the pub/sub channel of the recipient's live events.
+func (r Recipient) channel() string {
+	return fmt.Sprintf("realtime:%s:%d", r.Kind, r.ID)
+}
+
+// history is the stream that keeps the recipient's latest events.
+func (r Recipient) history() string {
+	return fmt.Sprintf("realtime:%s:%d:history", r.Kind, r.ID)
+}
+
+// Event is pushed to a recipient. ID is the ID of the event in the recipient's history,
+// which clients send back as Last-Event-ID when they reconnect. Events that are not kept
+// in the history, such as the unread count sent on connect, have no ID.
+type Event struct {
+	ID   string          `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// UnreadCount is the data of an EventUnreadCount event.
+type UnreadCount struct {
+	Unread int64 `json:"unread"`
+}
+
+// BookingStatus is the data of an EventBookingStatus event.
+type BookingStatus struct {
+	BookingID int32  `json:"booking_id"`
+	ListingID int32  `json:"listing_id"`
+	Title     string `json:"title"`
+	Status    string `json:"status"`
+}
+
+// NewEvent encodes data into an event without an ID.
+func NewEvent(eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: eventType, Data: raw}, nil
+}
+
+// WriteEvent writes e in the server-sent events format. The data is JSON, which has no
+// line breaks, so it fits on a single data line.
+func WriteEvent(w io.Writer, e Event) error {
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", e.Type, e.Data)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteRetry tells clients how long to wait before reconnecting.
+func WriteRetry(w io.Writer, d time.Duration) error {
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", d.Milliseconds())
+	return err
+}
+
+// WriteHeartbeat writes a comment line, which clients ignore.
+func WriteHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ": heartbeat\n\n")
+	return err
+}
+
+// parseID splits a Redis stream entry ID into its milliseconds and sequence number.
+func parseID(id string) (ms, seq uint64, ok bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err = strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
+
+// ValidID reports whether id can be a Last-Event-ID sent by a client.
+func ValidID(id string) bool {
+	_, _, ok := parseID(id)
+	return ok
+}
+
+// after reports whether the event id was published after the event other. Events without
+// a valid ID are never after another.
+func after(id, other string) bool {
+	ms, seq, ok := parseID(id)
+	if !ok {
+		return false
+	}
+	otherMS, otherSeq, ok := parseID(other)
+	if !ok {
+		return true
+	}
+	return ms > otherMS || (ms == otherMS && seq > otherSeq)
+}
+
+// Hub publishes events to recipients and subscribes to them.
+type Hub struct {
+	client *redis.Client
+}
+
+func NewHub(client *redis.Client) *Hub {
+	return &Hub{client: client}
+}
+
+// Publish adds an event with data to the history of r and sends it to the streams r has
+// open.
+func (h *Hub) Publish(ctx context.Context, r Recipient, eventType string, data any) error {
+	e, err := NewEvent(eventType, data)
+	if err != nil {
+		return err
+	}
+
+	e.ID, err = h.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.history(),
+		MaxLen: HistoryLength,
+		Approx: true,
+		Values: map[string]any{"type": e.Type, "data": string(e.Data)},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("add %s event to the history of %s: %w", eventType, r, err)
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = h.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Expire(ctx, r.history(), HistoryTTL)
+		pipe.Publish(ctx, r.channel(), payload)
+		return nil
+	})
+	return err
+}
+
+// Subscribe returns the events of r. When lastEventID is set, the events after it that are
+// still in the history come first. The events channel is closed when ctx is done, which
+// also ends the subscription.
+func (h *Hub) Subscribe(ctx context.Context, r Recipient, lastEventID string) (<-chan Event, error) {
+	if lastEventID != "" && !ValidID(lastEventID) {
+		return nil, ErrInvalidEventID
+	}
+
+	sub := h.client.Subscribe(ctx, r.channel())
+	// Subscribe before reading the history so no event published in between is missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	var missed []Event
+	if lastEventID != "" {
+		entries, err := h.client.XRange(ctx, r.history(), "("+lastEventID, "+").Result()
+		if err != nil {
+			sub.Close()
+			return nil, fmt.Errorf("read the history of %s: %w", r, err)
+		}
+		for _, entry := range entries {
+			missed = append(missed, eventFromEntry(entry))
+		}
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+
+		last := lastEventID
+		send := func(e Event) bool {
+			select {
+			case events <- e:
+				last = e.ID
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, e := range missed {
+			if !send(e) {
+				return
+			}
+		}
+
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var e Event
+				if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+					continue
+				}
+				// Events published while the history was read were replayed already.
+				if !after(e.ID, last) {
+					continue
+				}
+				if !send(e) {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func eventFromEntry(entry redis.XMessage) Event {
+	e := Event{ID: entry.ID}
+	e.Type, _ = entry.Values["type"].(string)
+	if data, ok := entry.Values["data"].(string); ok {
+		e.Data = json.RawMessage(data)
+	}
+	return e
+}
@@ -0,0 +1,85 @@
+package realtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidID(t *testing.T) {
+	require.True(t, ValidID("1700000000000-0"))
+	require.True(t, ValidID("1700000000000-12"))
+	require.False(t, ValidID(""))
+	require.False(t, ValidID("1700000000000"))
+	require.False(t, ValidID("1700000000000-"))
+	require.False(t, ValidID("abc-1"))
+	require.False(t, ValidID("-1-0"))
+	require.False(t, ValidID("1-0\nevent: x"))
+}
+
+func TestAfter(t *testing.T) {
+	testCases := []struct {
+		name  string
+		id    string
+		other string
+		after bool
+	}{
+		{name: "later millisecond", id: "1700000000001-0", other: "1700000000000-5", after: true},
+		{name: "later sequence", id: "1700000000000-2", other: "1700000000000-1", after: true},
+		{name: "same", id: "1700000000000-1", other: "1700000000000-1", after: false},
+		{name: "earlier", id: "1699999999999-9", other: "1700000000000-0", after: false},
+		{name: "numeric not lexical", id: "1700000000000-10", other: "1700000000000-9", after: true},
+		{name: "nothing sent yet", id: "1700000000000-0", other: "", after: true},
+		{name: "no ID", id: "", other: "1700000000000-0", after: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.after, after(tc.id, tc.other))
+		})
+	}
+}
+
+func TestRecipientKeys(t *testing.T) {
+	require.Equal(t, "realtime:user:7", User(7).channel())
+	require.Equal(t, "realtime:admin:7:history", Admin(7).history())
+	require.NotEqual(t, User(7).channel(), Admin(7).channel())
+}
+
+func TestWriteEvent(t *testing.T) {
+	e, err := NewEvent(EventUnreadCount, UnreadCount{Unread: 3})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteEvent(&buf, e))
+	require.Equal(t, "event: unread_count\ndata: {\"unread\":3}\n\n", buf.String())
+
+	buf.Reset()
+	e.ID = "1700000000000-0"
+	require.NoError(t, WriteEvent(&buf, e))
+	require.Equal(t, "id: 1700000000000-0\nevent: unread_count\ndata: {\"unread\":3}\n\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteRetry(&buf, RetryInterval))
+	require.Equal(t, "retry: 3000\n\n", buf.String())
+}
+
+func TestEventFromEntry(t *testing.T) {
+	data, err := json.Marshal(BookingStatus{BookingID: 4, ListingID: 2, Title: "Loft", Status: "confirmed"})
+	require.NoError(t, err)
+
+	e := eventFromEntry(redis.XMessage{
+		ID:     "1700000000000-3",
+		Values: map[string]any{"type": EventBookingStatus, "data": string(data)},
+	})
+	require.Equal(t, "1700000000000-3", e.ID)
+	require.Equal(t, EventBookingStatus, e.Type)
+
+	var status BookingStatus
+	require.NoError(t, json.Unmarshal(e.Data, &status))
+	require.Equal(t, int32(4), status.BookingID)
+	require.Equal(t, "confirmed", status.Status)
+}
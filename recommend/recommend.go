@@ -0,0 +1,177 @@
+// Package recommend trains the "similar listings" model behind listing recommendations.
+// Two listings are similar when the same users favorited, booked or reviewed them well
+// (item-item co-occurrence), and, to a lesser degree, when they are in the same location
+// at a similar price, which also covers new listings nobody has interacted with yet.
+// Training is deterministic: the same input always produces the same similarities.
+package recommend
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultNeighbors is how many similar listings are kept per listing.
+	DefaultNeighbors = 20
+
+	// shrinkage damps the co-occurrence score of pairs seen together by few users, so a
+	// single shared guest does not make two listings look identical.
+	shrinkage = 2.0
+
+	// contentWeight scales content similarity below co-occurrence, which is the stronger
+	// signal when both exist.
+	contentWeight = 0.3
+)
+
+// Interaction is how strongly a user showed interest in a listing, summed over their
+// favorites, bookings and reviews of it.
+type Interaction struct {
+	UserID    int32
+	ListingID int32
+	Weight    float64
+}
+
+// Item is a listing with the attributes used for content similarity.
+type Item struct {
+	ID       int32
+	Location string
+	Price    float64
+}
+
+// Similarity is a trained, directed listing pair: SimilarID is recommended on ListingID.
+type Similarity struct {
+	ListingID int32
+	SimilarID int32
+	Score     float64
+}
+
+type pair struct {
+	a, b int32
+}
+
+// Train computes up to neighbors similar listings for each item. Interactions with
+// listings that are not in items are ignored.
+func Train(interactions []Interaction, items []Item, neighbors int) []Similarity {
+	known := make(map[int32]bool, len(items))
+	for _, item := range items {
+		known[item.ID] = true
+	}
+
+	scores := make(map[pair]float64)
+	for p, s := range cooccurrence(interactions, known) {
+		scores[p] += s
+	}
+	for p, s := range contentSimilarity(items) {
+		scores[p] += contentWeight * s
+	}
+
+	byListing := make(map[int32][]Similarity)
+	for p, s := range scores {
+		if s <= 0 {
+			continue
+		}
+		byListing[p.a] = append(byListing[p.a], Similarity{ListingID: p.a, SimilarID: p.b, Score: s})
+		byListing[p.b] = append(byListing[p.b], Similarity{ListingID: p.b, SimilarID: p.a, Score: s})
+	}
+
+	ids := make([]int32, 0, len(byListing))
+	for id := range byListing {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var result []Similarity
+	for _, id := range ids {
+		similar := byListing[id]
+		sort.Slice(similar, func(i, j int) bool {
+			if similar[i].Score != similar[j].Score {
+				return similar[i].Score > similar[j].Score
+			}
+			return similar[i].SimilarID < similar[j].SimilarID
+		})
+		if len(similar) > neighbors {
+			similar = similar[:neighbors]
+		}
+		result = append(result, similar...)
+	}
+	return result
+}
+
+// cooccurrence returns the cosine similarity of the user vectors of each pair of listings
+// with a user in common, shrunk by the number of users they have in common. Pairs are
+// keyed with the lower ID first.
+func cooccurrence(interactions []Interaction, known map[int32]bool) map[pair]float64 {
+	byUser := make(map[int32]map[int32]float64)
+	norms := make(map[int32]float64)
+	for _, in := range interactions {
+		if !known[in.ListingID] || in.Weight <= 0 {
+			continue
+		}
+		if byUser[in.UserID] == nil {
+			byUser[in.UserID] = make(map[int32]float64)
+		}
+		byUser[in.UserID][in.ListingID] += in.Weight
+	}
+
+	dots := make(map[pair]float64)
+	common := make(map[pair]int)
+	for _, listings := range byUser {
+		ids := make([]int32, 0, len(listings))
+		for id, w := range listings {
+			ids = append(ids, id)
+			norms[id] += w * w
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				p := pair{ids[i], ids[j]}
+				dots[p] += listings[ids[i]] * listings[ids[j]]
+				common[p]++
+			}
+		}
+	}
+
+	scores := make(map[pair]float64, len(dots))
+	for p, dot := range dots {
+		n := float64(common[p])
+		scores[p] = dot / math.Sqrt(norms[p.a]*norms[p.b]) * n / (n + shrinkage)
+	}
+	return scores
+}
+
+// contentSimilarity scores pairs of listings in the same location by how close their
+// prices are, from 0.5 for very different prices to 1 for the same price. Listings
+// without a location are not compared.
+func contentSimilarity(items []Item) map[pair]float64 {
+	byLocation := make(map[string][]Item)
+	for _, item := range items {
+		location := strings.ToLower(strings.TrimSpace(item.Location))
+		if location == "" {
+			continue
+		}
+		byLocation[location] = append(byLocation[location], item)
+	}
+
+	scores := make(map[pair]float64)
+	for _, group := range byLocation {
+		for i := range group {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if a.ID > b.ID {
+					a, b = b, a
+				}
+				scores[pair{a.ID, b.ID}] = 0.5 + 0.5*priceCloseness(a.Price, b.Price)
+			}
+		}
+	}
+	return scores
+}
+
+func priceCloseness(a, b float64) float64 {
+	high := math.Max(a, b)
+	if high <= 0 {
+		return 1
+	}
+	return 1 - math.Abs(a-b)/high
+}
@@ -0,0 +1,75 @@
+package recommend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func similarIDs(sims []Similarity, listingID int32) []int32 {
+	var ids []int32
+	for _, s := range sims {
+		if s.ListingID == listingID {
+			ids = append(ids, s.SimilarID)
+		}
+	}
+	return ids
+}
+
+func TestTrainCooccurrence(t *testing.T) {
+	items := []Item{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	interactions := []Interaction{
+		// Listings 1 and 2 share three users, 1 and 3 share one.
+		{UserID: 10, ListingID: 1, Weight: 5}, {UserID: 10, ListingID: 2, Weight: 5},
+		{UserID: 11, ListingID: 1, Weight: 3}, {UserID: 11, ListingID: 2, Weight: 3},
+		{UserID: 12, ListingID: 1, Weight: 3}, {UserID: 12, ListingID: 2, Weight: 3}, {UserID: 12, ListingID: 3, Weight: 3},
+		// Listing 99 is gone and listing 4 has no interactions.
+		{UserID: 13, ListingID: 1, Weight: 3}, {UserID: 13, ListingID: 99, Weight: 5},
+	}
+
+	sims := Train(interactions, items, DefaultNeighbors)
+	require.Equal(t, []int32{2, 3}, similarIDs(sims, 1))
+	require.Equal(t, []int32{1, 3}, similarIDs(sims, 2))
+	// Listing 1 also has user 13, so it is a little less like listing 3 than listing 2 is.
+	require.Equal(t, []int32{2, 1}, similarIDs(sims, 3))
+	require.Empty(t, similarIDs(sims, 4))
+	require.Empty(t, similarIDs(sims, 99))
+
+	// Training is deterministic.
+	require.Equal(t, sims, Train(interactions, items, DefaultNeighbors))
+}
+
+func TestTrainContent(t *testing.T) {
+	items := []Item{
+		{ID: 1, Location: "Nairobi", Price: 100},
+		{ID: 2, Location: " nairobi", Price: 400},
+		{ID: 3, Location: "Nairobi", Price: 110},
+		{ID: 4, Location: "Mombasa", Price: 100},
+		{ID: 5, Price: 100},
+	}
+
+	sims := Train(nil, items, 1)
+	require.Equal(t, []int32{3}, similarIDs(sims, 1))
+	require.Equal(t, []int32{3}, similarIDs(sims, 2))
+	require.Empty(t, similarIDs(sims, 4))
+	require.Empty(t, similarIDs(sims, 5))
+	require.InDelta(t, contentWeight*(0.5+0.5*(1-10.0/110)), sims[0].Score, 1e-9)
+}
+
+func TestTrainBlendsSignals(t *testing.T) {
+	// Listing 3 is next door at the same price, but guests of listing 1 book listing 2.
+	items := []Item{
+		{ID: 1, Location: "Nairobi", Price: 100},
+		{ID: 2, Location: "Naivasha", Price: 300},
+		{ID: 3, Location: "Nairobi", Price: 100},
+	}
+	var interactions []Interaction
+	for user := int32(1); user <= 5; user++ {
+		interactions = append(interactions,
+			Interaction{UserID: user, ListingID: 1, Weight: 5},
+			Interaction{UserID: user, ListingID: 2, Weight: 5})
+	}
+
+	sims := Train(interactions, items, DefaultNeighbors)
+	require.Equal(t, []int32{2, 3}, similarIDs(sims, 1))
+}
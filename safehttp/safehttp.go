@@ -0,0 +1,107 @@
+// Package safehttp fetches URLs that hosts supply, such as external calendar feeds,
+// without letting them reach the server's own network: requests to loopback, private,
+// link-local and unspecified addresses are refused after DNS resolution, including on
+// redirects.
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrForbiddenAddress = errors.New("address is not publicly routable")
+	ErrScheme           = errors.New("url must be an http or https url")
+)
+
+// maxRedirects matches the default of net/http.
+const maxRedirects = 10
+
+// Allowed reports whether requests may be sent to ip.
+func Allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// control refuses connections to addresses that are not Allowed. It runs for every
+// connection the dialer makes, after the host name is resolved.
+func control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("safehttp: %w", err)
+	}
+	if !Allowed(addrPort.Addr()) {
+		return fmt.Errorf("safehttp: %s: %w", addrPort.Addr(), ErrForbiddenAddress)
+	}
+	return nil
+}
+
+// NewClient returns a client that only connects to publicly routable addresses. Proxies
+// configured in the environment are not used, since they would connect on its behalf.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// checkRedirect only follows redirects to http and https URLs. Where they lead is checked
+// when the connection is made.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return ErrScheme
+	}
+	return nil
+}
+
+// CheckURL parses raw and checks that it is an http or https URL whose host resolves only
+// to publicly routable addresses. It lets a URL be refused when it is registered; the
+// client still checks the address it connects to, since DNS can change in between.
+func CheckURL(ctx context.Context, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, ErrScheme
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !Allowed(addr) {
+			return nil, fmt.Errorf("%s: %w", u.Hostname(), ErrForbiddenAddress)
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,56 @@
+package safehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	for addr, allowed := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"0.0.0.0":          false,
+		"::":               false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		require.Equal(t, allowed, Allowed(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewClient(5 * time.Second).Get(srv.URL)
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+}
+
+func TestCheckURL(t *testing.T) {
+	_, err := CheckURL(context.Background(), "http://127.0.0.1:8080/calendar.ics")
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+	_, err = CheckURL(context.Background(), "http://[::1]/calendar.ics")
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+	_, err = CheckURL(context.Background(), "http://localhost/calendar.ics")
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+	_, err = CheckURL(context.Background(), "file:///etc/passwd")
+	require.ErrorIs(t, err, ErrScheme)
+
+	u, err := CheckURL(context.Background(), "https://93.184.216.34/calendar.ics")
+	require.NoError(t, err)
+	require.Equal(t, "93.184.216.34", u.Hostname())
+}
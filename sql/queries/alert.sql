@@ -0,0 +1,135 @@
+-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (user_id, name, keyword, location, min_price, max_price, alerts_enabled)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetSavedSearches :many
+SELECT *
+FROM saved_searches
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UpdateSavedSearch :one
+UPDATE saved_searches
+SET name = $3, keyword = $4, location = $5, min_price = $6, max_price = $7, alerts_enabled = $8
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteSavedSearch :execrows
+DELETE FROM saved_searches
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetAlertPreferences :one
+SELECT *
+FROM alert_preferences
+WHERE user_id = $1;
+
+-- name: UpsertAlertPreferences :one
+INSERT INTO alert_preferences (user_id, frequency, price_drops, availability, saved_searches)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE
+SET frequency = EXCLUDED.frequency,
+    price_drops = EXCLUDED.price_drops,
+    availability = EXCLUDED.availability,
+    saved_searches = EXCLUDED.saved_searches,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetAlertDigestSent :exec
+INSERT INTO alert_preferences (user_id, last_digest_at)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET last_digest_at = EXCLUDED.last_digest_at;
+
+-- name: CreatePriceDropAlerts :execrows
+-- Alerts the users who favorited the listing or saved it to a wishlist, unless they turned
+-- price-drop alerts off.
+WITH watchers AS (
+    SELECT f.user_id FROM favorites f WHERE f.listing_id = sqlc.arg(listing_id)::int
+    UNION
+    SELECT w.user_id
+    FROM wishlist_items i
+    JOIN wishlists w ON w.id = i.wishlist_id
+    WHERE i.listing_id = sqlc.arg(listing_id)::int
+)
+INSERT INTO listing_alerts (user_id, listing_id, kind, message)
+SELECT wt.user_id, sqlc.arg(listing_id)::int, 'price_drop', sqlc.arg(message)::text
+FROM watchers wt
+LEFT JOIN alert_preferences p ON p.user_id = wt.user_id
+WHERE COALESCE(p.price_drops, TRUE);
+
+-- name: CreateListingAvailableAlerts :execrows
+-- Alerts the users who favorited the listing or saved it to a wishlist, unless they turned
+-- availability alerts off.
+WITH watchers AS (
+    SELECT f.user_id FROM favorites f WHERE f.listing_id = sqlc.arg(listing_id)::int
+    UNION
+    SELECT w.user_id
+    FROM wishlist_items i
+    JOIN wishlists w ON w.id = i.wishlist_id
+    WHERE i.listing_id = sqlc.arg(listing_id)::int
+)
+INSERT INTO listing_alerts (user_id, listing_id, kind, message)
+SELECT wt.user_id, sqlc.arg(listing_id)::int, 'available', sqlc.arg(message)::text
+FROM watchers wt
+LEFT JOIN alert_preferences p ON p.user_id = wt.user_id
+WHERE COALESCE(p.availability, TRUE);
+
+-- name: CreateSavedSearchAlerts :execrows
+-- Alerts the users with an enabled saved search the listing matches, once per user even
+-- if several of their searches match.
+INSERT INTO listing_alerts (user_id, listing_id, saved_search_id, kind, message)
+SELECT DISTINCT ON (s.user_id) s.user_id, l.id, s.id, 'new_match', sqlc.arg(message)::text
+FROM saved_searches s
+JOIN listings l ON l.id = sqlc.arg(listing_id)::int
+LEFT JOIN alert_preferences p ON p.user_id = s.user_id
+WHERE s.alerts_enabled
+  AND COALESCE(p.saved_searches, TRUE)
+  AND (s.keyword = '' OR l.title ILIKE '%' || s.keyword || '%' OR l.description ILIKE '%' || s.keyword || '%')
+  AND (s.location IS NULL OR l.location ILIKE '%' || s.location || '%')
+  AND (s.min_price IS NULL OR l.price >= s.min_price)
+  AND (s.max_price IS NULL OR l.price <= s.max_price)
+ORDER BY s.user_id, s.id;
+
+-- name: GetUserListingAlerts :many
+SELECT
+    a.id, a.listing_id, a.saved_search_id, s.name AS saved_search_name,
+    a.kind, a.message, a.read, a.created_at,
+    l.title, l.price, l.imageLinks
+FROM listing_alerts a
+JOIN listings l ON l.id = a.listing_id
+LEFT JOIN saved_searches s ON s.id = a.saved_search_id
+WHERE a.user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::bool OR NOT a.read)
+ORDER BY a.created_at DESC;
+
+-- name: MarkListingAlertRead :execrows
+UPDATE listing_alerts
+SET read = TRUE
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetDueAlertDigests :many
+-- Returns the users with alerts not yet emailed whose digest frequency says one is due.
+SELECT u.id, u.username, u.email
+FROM users u
+LEFT JOIN alert_preferences p ON p.user_id = u.id
+WHERE EXISTS (SELECT 1 FROM listing_alerts a WHERE a.user_id = u.id AND a.emailed_at IS NULL)
+  AND CASE COALESCE(p.frequency, 'daily')
+        WHEN 'instant' THEN TRUE
+        WHEN 'daily' THEN p.last_digest_at IS NULL OR p.last_digest_at <= sqlc.arg(now)::timestamp - INTERVAL '1 day'
+        WHEN 'weekly' THEN p.last_digest_at IS NULL OR p.last_digest_at <= sqlc.arg(now)::timestamp - INTERVAL '7 days'
+        ELSE FALSE
+      END
+ORDER BY u.id;
+
+-- name: GetPendingListingAlerts :many
+SELECT a.id, a.listing_id, a.kind, a.message, a.created_at, l.title, l.price
+FROM listing_alerts a
+JOIN listings l ON l.id = a.listing_id
+WHERE a.user_id = $1 AND a.emailed_at IS NULL
+ORDER BY a.created_at;
+
+-- name: MarkListingAlertsEmailed :exec
+UPDATE listing_alerts
+SET emailed_at = sqlc.arg(emailed_at)
+WHERE id = ANY(sqlc.arg(ids)::int[]);
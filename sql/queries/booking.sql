@@ -1,10 +1,10 @@
 -- name: CreateBooking :one
-INSERT INTO bookings (user_id, listing_id, check_in_date, check_out_date, total_amount)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at;
+INSERT INTO bookings (user_id, listing_id, check_in_date, check_out_date, total_amount, status, approval_deadline, guests)
+VALUES ($1, $2, $3, $4, $5, COALESCE(sqlc.narg(status), 'pending'), sqlc.narg(approval_deadline), COALESCE(sqlc.narg(guests), 1))
+RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, approval_deadline, guests, created_at;
 
 -- name: GetUserBookingByID :one
-SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, guests, created_at
 FROM bookings
 WHERE id = $1 AND user_id = $2;
 
@@ -29,7 +29,9 @@ SELECT
     b.check_out_date, 
     b.total_amount, 
     b.status, 
-    b.created_at
+    b.created_at,
+    (SELECT COUNT(*) FROM guest_reviews g WHERE g.user_id = b.user_id AND g.published_at IS NOT NULL) AS guest_review_count,
+    (SELECT COALESCE(ROUND(AVG(g.rating)::numeric, 2), 0)::float8 FROM guest_reviews g WHERE g.user_id = b.user_id AND g.published_at IS NOT NULL) AS guest_rating
 FROM 
     bookings b
 JOIN 
@@ -37,36 +39,55 @@ JOIN
 JOIN 
     users u ON b.user_id = u.id  
 WHERE 
-    l.admin_id = $1 AND (b.status = 'completed' OR b.status = 'confirmed' OR b.status = 'pending')
+    ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND (b.status = 'completed' OR b.status = 'confirmed' OR b.status = 'pending')
 ORDER BY 
     b.created_at DESC;
 
 
 -- name: GetBookingsByAdminIDByID :one
-SELECT b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.created_at
+SELECT b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.approval_deadline, b.created_at
 FROM bookings b
 JOIN listings l ON b.listing_id = l.id
-WHERE l.admin_id = $1 AND b.id = $2
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND b.id = @id
 ORDER BY b.created_at DESC;
 
 -- name: GetBookingsByListingID :many
 SELECT b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.created_at
 FROM bookings b
 JOIN listings l ON b.listing_id = l.id
-WHERE b.listing_id = $1 AND l.admin_id = $2
+WHERE b.listing_id = @listing_id
+    AND ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
 ORDER BY b.created_at DESC;
 
--- name: UpdateBookingStatusByIDAndAdminID :exec
+-- name: UpdateBookingStatusByIDAndAdminID :execrows
+-- Moves the booking on only if it still has from_status, so a booking the expiry jobs or
+-- the guest changed in the meantime is left alone.
 UPDATE bookings b
 SET status = COALESCE(sqlc.narg(status), status)
 FROM listings l
-WHERE b.listing_id = l.id AND b.id = @id AND l.admin_id = @admin_id
-RETURNING b.id, b.user_id, b.listing_id, b.check_in_date, b.check_out_date, b.total_amount, b.status, b.created_at;
+WHERE b.listing_id = l.id AND b.id = @id
+    AND ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND b.status = @from_status;
 
--- name: UpdateBookingStatusByIDAndUserID :exec
+-- name: UpdateBookingStatusByIDAndUserID :execrows
+-- Only bookings that are still pending or confirmed can be cancelled.
 UPDATE bookings
 SET status = 'cancelled'
-WHERE id = $1 AND user_id = $2;
+WHERE id = $1 AND user_id = $2 AND status IN ('pending', 'confirmed');
 
 -- name: DeleteUserBooking :exec
 UPDATE bookings
@@ -79,3 +100,143 @@ FROM bookings
 WHERE user_id = $1;
 
 
+-- name: ExpireUnpaidPendingBookings :many
+-- Listings no other booking holds are re-opened in the same statement, so a failure
+-- between the two cannot leave a listing closed.
+WITH expired AS (
+    UPDATE bookings b
+    SET status = 'expired'
+    WHERE b.status = 'pending'
+      AND b.deleted_at IS NULL
+      AND b.created_at < sqlc.arg(cutoff)::timestamp
+      AND NOT EXISTS (
+          SELECT 1 FROM payments p
+          WHERE p.booking_id = b.id AND p.status IN ('succeeded', 'paid', 'authorized')
+      )
+    RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+), reopened AS (
+    UPDATE listings l
+    SET available = TRUE
+    WHERE l.id IN (SELECT listing_id FROM expired)
+      AND NOT EXISTS (
+          SELECT 1 FROM bookings b
+          WHERE b.listing_id = l.id
+            AND b.status IN ('pending', 'confirmed')
+            AND b.deleted_at IS NULL
+            AND b.id NOT IN (SELECT id FROM expired)
+      )
+)
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+FROM expired;
+
+-- name: GetUnapprovedBookings :many
+-- Returns the pending bookings whose approval deadline has passed. Their payment holds are
+-- released before ExpireUnapprovedBookings expires them.
+SELECT id
+FROM bookings
+WHERE status = 'pending'
+  AND deleted_at IS NULL
+  AND approval_deadline < sqlc.arg(now)::timestamp;
+
+-- name: ExpireUnapprovedBookings :many
+-- Bookings with a payment that is still authorized are left pending until the hold is
+-- released.
+WITH expired AS (
+    UPDATE bookings
+    SET status = 'expired'
+    WHERE status = 'pending'
+      AND deleted_at IS NULL
+      AND approval_deadline < sqlc.arg(now)::timestamp
+      AND NOT EXISTS (
+          SELECT 1 FROM payments p
+          WHERE p.booking_id = bookings.id AND p.status = 'authorized'
+      )
+    RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+), reopened AS (
+    UPDATE listings l
+    SET available = TRUE
+    WHERE l.id IN (SELECT listing_id FROM expired)
+      AND NOT EXISTS (
+          SELECT 1 FROM bookings b
+          WHERE b.listing_id = l.id
+            AND b.status IN ('pending', 'confirmed')
+            AND b.deleted_at IS NULL
+            AND b.id NOT IN (SELECT id FROM expired)
+      )
+)
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+FROM expired;
+
+-- name: CompleteFinishedBookings :many
+WITH completed AS (
+    UPDATE bookings
+    SET status = 'completed'
+    WHERE status = 'confirmed'
+      AND deleted_at IS NULL
+      AND check_out_date < sqlc.arg(now)::timestamp
+    RETURNING id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+), reopened AS (
+    UPDATE listings l
+    SET available = TRUE
+    WHERE l.id IN (SELECT listing_id FROM completed)
+      AND NOT EXISTS (
+          SELECT 1 FROM bookings b
+          WHERE b.listing_id = l.id
+            AND b.status IN ('pending', 'confirmed')
+            AND b.deleted_at IS NULL
+            AND b.id NOT IN (SELECT id FROM completed)
+      )
+)
+SELECT id, user_id, listing_id, check_in_date, check_out_date, total_amount, status, created_at
+FROM completed;
+
+-- name: GetBookingParties :one
+SELECT
+    b.id,
+    b.listing_id,
+    l.title,
+    b.check_in_date,
+    b.check_out_date,
+    b.status,
+    b.total_amount,
+    b.approval_deadline,
+    b.user_id,
+    u.username AS user_username,
+    u.email AS user_email,
+    a.username AS admin_username,
+    a.email AS admin_email
+FROM bookings b
+JOIN listings l ON b.listing_id = l.id
+JOIN users u ON b.user_id = u.id
+JOIN admins a ON l.admin_id = a.id
+WHERE b.id = $1;
+
+-- name: ClaimCheckInReminders :many
+-- Records that the guests of the confirmed bookings checking in between from and to are
+-- being reminded, and returns those bookings. Each booking is returned once.
+INSERT INTO booking_reminders (booking_id)
+SELECT b.id
+FROM bookings b
+WHERE b.status = 'confirmed' AND b.deleted_at IS NULL
+    AND b.check_in_date BETWEEN @from_date::date AND @to_date::date
+ON CONFLICT DO NOTHING
+RETURNING booking_id;
+
+-- name: CountOverlappingBookings :one
+SELECT COUNT(*)
+FROM bookings
+WHERE listing_id = @listing_id
+  AND id <> @id
+  AND deleted_at IS NULL
+  AND status IN ('pending', 'confirmed')
+  AND check_in_date < @check_out_date
+  AND check_out_date > @check_in_date;
+
+-- name: UpdateBookingStay :execrows
+-- Only confirmed bookings are moved to a new stay.
+UPDATE bookings
+SET check_in_date = $2,
+    check_out_date = $3,
+    guests = $4,
+    total_amount = $5
+WHERE id = $1 AND status = 'confirmed';
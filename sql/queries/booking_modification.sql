@@ -0,0 +1,43 @@
+-- name: CreateBookingModification :one
+INSERT INTO booking_modifications (
+    booking_id,
+    user_id,
+    check_in_date,
+    check_out_date,
+    guests,
+    previous_check_in_date,
+    previous_check_out_date,
+    previous_guests,
+    previous_amount,
+    new_amount,
+    price_difference,
+    payment_intent_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING *;
+
+-- name: GetPendingBookingModification :one
+SELECT * FROM booking_modifications
+WHERE booking_id = $1 AND status = 'pending'
+LIMIT 1;
+
+-- name: GetBookingModificationsByBookingID :many
+SELECT * FROM booking_modifications
+WHERE booking_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetBookingModificationForAdmin :one
+SELECT m.id, m.booking_id, m.user_id, m.check_in_date, m.check_out_date, m.guests, m.previous_amount, m.new_amount, m.price_difference, m.status, m.payment_intent_id, b.listing_id
+FROM booking_modifications m
+JOIN bookings b ON m.booking_id = b.id
+JOIN listings l ON b.listing_id = l.id
+WHERE m.id = @id
+    AND ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])));
+
+-- name: ResolveBookingModification :execrows
+UPDATE booking_modifications
+SET status = $2, resolved_at = NOW()
+WHERE id = $1 AND status = 'pending';
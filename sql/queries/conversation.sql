@@ -0,0 +1,113 @@
+-- name: CreateBookingConversation :one
+-- Opens the conversation about a booking, or returns the one already open.
+INSERT INTO conversations (listing_id, user_id, booking_id)
+SELECT b.listing_id, b.user_id, b.id
+FROM bookings b
+WHERE b.id = $1
+ON CONFLICT (booking_id) WHERE booking_id IS NOT NULL
+DO UPDATE SET booking_id = EXCLUDED.booking_id
+RETURNING *;
+
+-- name: CreateInquiryConversation :one
+-- Opens a guest's conversation about a listing they have not booked, or returns the one
+-- already open.
+INSERT INTO conversations (listing_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (listing_id, user_id) WHERE booking_id IS NULL
+DO UPDATE SET listing_id = EXCLUDED.listing_id
+RETURNING *;
+
+-- name: GetConversationForUser :one
+SELECT *
+FROM conversations
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetConversationForAdmin :one
+-- Returns a conversation about a listing the admin owns, or that belongs to an
+-- organisation where the admin has one of roles.
+SELECT c.id, c.listing_id, c.user_id, c.booking_id, c.guest_last_read_id, c.host_last_read_id, c.last_message_at, c.created_at
+FROM conversations c
+JOIN listings l ON l.id = c.listing_id
+WHERE c.id = @id
+    AND ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])));
+
+-- name: GetUserConversations :many
+SELECT c.id, c.listing_id, l.title, c.booking_id, c.host_last_read_id, c.last_message_at, c.created_at,
+    (SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND m.sender_role = 'host' AND m.id > c.guest_last_read_id)::int AS unread
+FROM conversations c
+JOIN listings l ON l.id = c.listing_id
+WHERE c.user_id = $1
+ORDER BY COALESCE(c.last_message_at, c.created_at) DESC;
+
+-- name: GetAdminConversations :many
+-- Returns the conversations about the admin's listings and those of organisations where
+-- the admin has one of roles.
+SELECT c.id, c.listing_id, l.title, c.user_id, u.username, c.booking_id, c.guest_last_read_id, c.last_message_at, c.created_at,
+    (SELECT COUNT(*) FROM messages msg WHERE msg.conversation_id = c.id AND msg.sender_role = 'guest' AND msg.id > c.host_last_read_id)::int AS unread
+FROM conversations c
+JOIN listings l ON l.id = c.listing_id
+JOIN users u ON u.id = c.user_id
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+ORDER BY COALESCE(c.last_message_at, c.created_at) DESC;
+
+-- name: GetConversationHosts :many
+-- Returns the admins who can read a conversation as the host: the admin of a personal
+-- listing, or the members of the listing's organisation with one of roles.
+SELECT l.admin_id
+FROM conversations c
+JOIN listings l ON l.id = c.listing_id
+WHERE c.id = @id AND l.organization_id IS NULL
+UNION
+SELECT m.admin_id
+FROM conversations c
+JOIN listings l ON l.id = c.listing_id
+JOIN organization_members m ON m.organization_id = l.organization_id
+WHERE c.id = @id AND m.role = ANY(@roles::text[]);
+
+-- name: CreateMessage :one
+-- Adds a message to a conversation. The sender's side has read everything up to it.
+WITH message AS (
+    INSERT INTO messages (conversation_id, sender_role, sender_admin_id, body, attachments)
+    VALUES (@conversation_id, @sender_role, sqlc.narg(sender_admin_id), @body, @attachments::text[])
+    RETURNING *
+), touched AS (
+    UPDATE conversations c
+    SET last_message_at = message.created_at,
+        guest_last_read_id = CASE WHEN message.sender_role = 'guest' THEN message.id ELSE c.guest_last_read_id END,
+        host_last_read_id = CASE WHEN message.sender_role = 'host' THEN message.id ELSE c.host_last_read_id END
+    FROM message
+    WHERE c.id = message.conversation_id
+)
+SELECT * FROM message;
+
+-- name: ConversationHasAttachment :one
+-- Reports whether a message in the conversation has the attachment stored under key.
+SELECT EXISTS (
+    SELECT 1 FROM messages
+    WHERE conversation_id = @conversation_id AND @key::text = ANY(attachments)
+);
+
+-- name: GetMessages :many
+-- Returns a page of a conversation's messages, newest first, older than before when it
+-- is set.
+SELECT *
+FROM messages
+WHERE conversation_id = @conversation_id
+    AND (sqlc.narg(before)::int IS NULL OR id < sqlc.narg(before)::int)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: MarkConversationRead :one
+-- Moves the reader's read marker to the latest message. Markers never move back.
+UPDATE conversations c
+SET guest_last_read_id = CASE WHEN sqlc.arg(reader)::text = 'guest' THEN GREATEST(c.guest_last_read_id, latest.id) ELSE c.guest_last_read_id END,
+    host_last_read_id = CASE WHEN sqlc.arg(reader)::text = 'host' THEN GREATEST(c.host_last_read_id, latest.id) ELSE c.host_last_read_id END
+FROM (SELECT COALESCE(MAX(m.id), 0)::int AS id FROM messages m WHERE m.conversation_id = sqlc.arg(id)) latest
+WHERE c.id = sqlc.arg(id)
+RETURNING c.guest_last_read_id, c.host_last_read_id;
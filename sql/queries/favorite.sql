@@ -24,6 +24,14 @@ FROM favorites
 WHERE user_id = $1
 ORDER BY created_at DESC;
 
+-- name: GetFavoriteListings :many
+-- Listings guests can no longer see are left out.
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at
+FROM favorites f
+JOIN listings l ON l.id = f.listing_id
+WHERE f.user_id = $1 AND l.status = 'published' AND l.deleted_at IS NULL
+ORDER BY f.created_at DESC;
+
 -- name: DeleteFavorite :exec
 DELETE FROM favorites
 WHERE listing_id = $1 and user_id = $2;
@@ -34,5 +42,5 @@ FROM favorites f
 JOIN listings l ON f.listing_id = l.id
 WHERE 
     (l.title ILIKE '%' || $1 || '%' OR l.description ILIKE '%' || $1 || '%') 
-    AND l.available = TRUE AND f.user_id = $2
+    AND l.available = TRUE AND l.status = 'published' AND f.user_id = $2
 ORDER BY f.created_at DESC;
\ No newline at end of file
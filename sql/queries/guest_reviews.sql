@@ -0,0 +1,57 @@
+-- name: CreateGuestReview :one
+INSERT INTO guest_reviews (
+    booking_id,
+    admin_id,
+    user_id,
+    rating,
+    cleanliness_rating,
+    communication_rating,
+    house_rules_rating,
+    comment
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetGuestReviewByBookingID :one
+SELECT *
+FROM guest_reviews
+WHERE booking_id = $1;
+
+-- name: GetGuestReviewsByUserID :many
+SELECT g.id, g.booking_id, g.rating, g.cleanliness_rating, g.communication_rating, g.house_rules_rating, g.comment, g.published_at, a.username AS host_username
+FROM guest_reviews g
+JOIN admins a ON g.admin_id = a.id
+WHERE g.user_id = $1 AND g.published_at IS NOT NULL
+ORDER BY g.published_at DESC;
+
+-- name: GetGuestReputation :one
+SELECT
+    COUNT(*) AS total_reviews,
+    COALESCE(ROUND(AVG(rating)::numeric, 2), 0)::float8 AS average_rating,
+    COALESCE(ROUND(AVG(cleanliness_rating)::numeric, 2), 0)::float8 AS cleanliness,
+    COALESCE(ROUND(AVG(communication_rating)::numeric, 2), 0)::float8 AS communication,
+    COALESCE(ROUND(AVG(house_rules_rating)::numeric, 2), 0)::float8 AS house_rules
+FROM guest_reviews
+WHERE user_id = $1 AND published_at IS NOT NULL;
+
+-- name: PublishBookingReviews :exec
+WITH published_review AS (
+    UPDATE reviews
+    SET published_at = NOW()
+    WHERE reviews.booking_id = $1
+      AND reviews.published_at IS NULL
+      AND EXISTS (SELECT 1 FROM guest_reviews g WHERE g.booking_id = $1)
+)
+UPDATE guest_reviews
+SET published_at = NOW()
+WHERE guest_reviews.booking_id = $1
+  AND guest_reviews.published_at IS NULL
+  AND EXISTS (SELECT 1 FROM reviews r WHERE r.booking_id = $1);
+
+-- name: PublishExpiredGuestReviews :execrows
+UPDATE guest_reviews g
+SET published_at = NOW()
+FROM bookings b
+WHERE g.booking_id = b.id
+  AND g.published_at IS NULL
+  AND b.check_out_date < sqlc.arg(cutoff)::timestamp;
@@ -1,30 +1,72 @@
 -- name: CreateListing :one
-INSERT INTO listings (admin_id, title, description, price, location, available, imageLinks)
-VALUES ($1, $2, $3, $4, $5, $6, $7::text[]
+INSERT INTO listings (admin_id, title, description, price, location, available, imageLinks, booking_mode, organization_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7::text[], COALESCE(sqlc.narg(booking_mode), 'instant'), sqlc.narg(organization_id)
 )
-RETURNING id, admin_id, title, description, price, location, available, imageLinks, created_at;
+RETURNING id, admin_id, title, description, price, location, available, imageLinks, booking_mode, created_at, status, organization_id;
 
 -- name: GetListingByID :one
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
+SELECT id, admin_id, title, description, price, location, available, imageLinks, booking_mode, created_at, status, published_at, organization_id
 FROM listings
 WHERE id = $1;
 
+-- name: GetListingHosts :many
+-- Returns the admins who host a listing: its admin if it is personal, or the members of
+-- its organisation with one of roles.
+SELECT l.admin_id
+FROM listings l
+WHERE l.id = @id AND l.organization_id IS NULL
+UNION
+SELECT m.admin_id
+FROM listings l
+JOIN organization_members m ON m.organization_id = l.organization_id
+WHERE l.id = @id AND m.role = ANY(@roles::text[]);
+
+-- name: GetListingHostContacts :many
+-- Returns the hosts of a listing, as GetListingHosts does, with their contact details.
+SELECT a.id, a.username, a.email
+FROM admins a
+WHERE a.id IN (
+    SELECT l.admin_id
+    FROM listings l
+    WHERE l.id = @id AND l.organization_id IS NULL
+    UNION
+    SELECT m.admin_id
+    FROM listings l
+    JOIN organization_members m ON m.organization_id = l.organization_id
+    WHERE l.id = @id AND m.role = ANY(@roles::text[]))
+ORDER BY a.id;
+
 -- name: GetListings :many
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE available = TRUE
-ORDER BY created_at DESC;
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at,
+    COALESCE(s.average_rating, 0)::float8 AS average_rating,
+    COALESCE(s.review_count, 0)::int AS review_count
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
+WHERE l.available = TRUE AND l.status = 'published'
+ORDER BY l.created_at DESC;
 
 -- name: GetAdminListings :many
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE admin_id = $1
-ORDER BY created_at DESC;
+-- Returns the listings the admin owns, and those of organisations where the admin has
+-- one of roles.
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at, l.status, l.organization_id
+FROM listings l
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND l.deleted_at IS NULL
+ORDER BY l.created_at DESC;
 
 -- name: GetListingsByAdminID :one
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
-WHERE admin_id = $1 AND id = $2;
+-- Returns a listing the admin owns, or that belongs to an organisation where the admin
+-- has one of roles.
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at, l.status, l.review_note, l.published_at, l.organization_id
+FROM listings l
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND l.id = @id AND l.deleted_at IS NULL;
 
 -- name: UpdateListing :exec
 UPDATE listings
@@ -34,61 +76,126 @@ SET
     price = COALESCE(sqlc.narg(price), price),
     location = COALESCE(sqlc.narg(location), location),
     available = COALESCE(sqlc.narg(available), available),
-    imageLinks = COALESCE(sqlc.narg(imageLinks), imageLinks)
+    imageLinks = COALESCE(sqlc.narg(imageLinks), imageLinks),
+    booking_mode = COALESCE(sqlc.narg(booking_mode), booking_mode)
 WHERE id = @id AND admin_id = @admin_id
 RETURNING *;
 
--- name: UpdateTotalViews :exec
+-- name: TransitionListing :execrows
+-- Moves a listing to status unless it has left from_status meanwhile. The first
+-- publication sets published_at.
 UPDATE listings
-SET total_views = total_views + 1
-WHERE id = $1;
+SET status = sqlc.arg(status),
+    review_note = sqlc.narg(review_note),
+    submitted_at = CASE WHEN sqlc.arg(status) = 'pending_review' THEN NOW() ELSE submitted_at END,
+    published_at = CASE WHEN sqlc.arg(status) = 'published' THEN COALESCE(published_at, NOW()) ELSE published_at END
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status) AND deleted_at IS NULL;
+
+-- name: GetListingsPendingReview :many
+SELECT l.id, l.admin_id, a.username AS admin_username, l.title, l.description, l.price, l.location, l.submitted_at,
+    (SELECT COUNT(*) FROM listing_images i WHERE i.listing_id = l.id AND i.status = 'ready')::int AS photos
+FROM listings l
+JOIN admins a ON a.id = l.admin_id
+WHERE l.status = 'pending_review'
+ORDER BY l.submitted_at, l.id;
 
 -- name: UpdateListingStatus :exec
 UPDATE listings
 SET available = $2
 WHERE id = $1;
 
--- name: DeleteListing :exec
-DELETE FROM listings
-WHERE id = $1 AND admin_id = $2;
+-- name: SoftDeleteListing :execrows
+-- Archives a listing and marks it deleted by its host. Its bookings, payments and reviews
+-- are kept.
+UPDATE listings
+SET status = 'archived', deleted_at = NOW(), deleted_by = admin_id
+WHERE id = $1 AND admin_id = $2 AND deleted_at IS NULL;
+
+-- name: GetDeletedListings :many
+-- Returns the deleted listings the admin owns or can act for through roles, most recent
+-- first. Only listings deleted by their host can be restored; listings with bookings are
+-- never purged.
+SELECT l.id, l.title, l.price, l.location, l.deleted_at,
+    COALESCE(l.deleted_by = l.admin_id, FALSE)::bool AS restorable,
+    EXISTS (SELECT 1 FROM bookings b WHERE b.listing_id = l.id)::bool AS has_bookings
+FROM listings l
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND l.deleted_at IS NOT NULL
+ORDER BY l.deleted_at DESC;
+
+-- name: RestoreListing :execrows
+-- Brings back a listing its host deleted as a draft, which must be published again.
+UPDATE listings l
+SET status = 'draft', review_note = NULL, deleted_at = NULL, deleted_by = NULL
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND l.id = @id AND l.deleted_at IS NOT NULL AND l.deleted_by = l.admin_id;
+
+-- name: SetListingOrganization :execrows
+-- Moves a listing into an organisation, or out of it to admin_id.
+UPDATE listings
+SET organization_id = sqlc.narg(organization_id), admin_id = @admin_id
+WHERE id = @id AND deleted_at IS NULL;
+
+-- name: GetPurgeableListings :many
+-- Returns listings deleted before deleted_at that have no bookings, oldest first.
+SELECT l.id
+FROM listings l
+WHERE l.deleted_at < $1
+    AND NOT EXISTS (SELECT 1 FROM bookings b WHERE b.listing_id = l.id)
+ORDER BY l.deleted_at
+LIMIT $2;
+
+-- name: PurgeListing :execrows
+-- Permanently removes a deleted listing unless it has gained bookings.
+DELETE FROM listings l
+WHERE l.id = $1 AND l.deleted_at IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM bookings b WHERE b.listing_id = l.id);
 
 -- name: ListingActiveBookingCount :one
-SELECT COUNT(*) AS confirmed_count
+-- Counts the pending and confirmed bookings of the listing.
+SELECT COUNT(*) AS active_count
 FROM bookings b
 JOIN listings l ON b.listing_id = l.id
-WHERE b.listing_id = $1 AND b.status = 'confirmed' AND l.admin_id = $2;
+WHERE b.listing_id = $1 AND b.status IN ('pending', 'confirmed') AND l.admin_id = $2;
+
+-- name: ListingVisible :one
+-- Reports whether guests can see the listing.
+SELECT EXISTS (
+    SELECT 1 FROM listings
+    WHERE id = $1 AND status = 'published' AND deleted_at IS NULL
+);
+
+-- name: LockListing :exec
+-- Locks the listing until the end of the transaction, so bookings of it are made one at
+-- a time.
+SELECT id FROM listings
+WHERE id = $1
+FOR UPDATE;
 
 -- name: SearchListings :many
-SELECT id, admin_id, title, description, price, location, available, imageLinks, created_at
-FROM listings
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at,
+    COALESCE(s.average_rating, 0)::float8 AS average_rating,
+    COALESCE(s.review_count, 0)::int AS review_count
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
 WHERE 
-    (title ILIKE '%' || $1 || '%' OR description ILIKE '%' || $1 || '%') 
-    AND available = TRUE
-ORDER BY created_at DESC;
-
--- name: Statistics :many
-SELECT
-    l.id,
-    l.title,
-    l.total_views,
-    COUNT(DISTINCT b.id) AS total_bookings,
-    CASE 
-        WHEN COUNT(r.id) > 0 THEN ROUND(AVG(r.rating)::numeric, 2)
-        ELSE NULL
-    END AS average_rating,
-    COALESCE(SUM(CASE 
-        WHEN b.status IN ('confirmed', 'completed') THEN b.total_amount 
-        ELSE 0 
-    END), 0) AS total_confirmed_amount
-FROM
-    listings l
-LEFT JOIN
-    bookings b ON l.id = b.listing_id
-LEFT JOIN
-    reviews r ON l.id = r.listing_id
-WHERE
-    l.admin_id = $1
-GROUP BY
-    l.id, l.title, l.total_views
-ORDER BY
-    l.total_views DESC;
\ No newline at end of file
+    (l.title ILIKE '%' || $1 || '%' OR l.description ILIKE '%' || $1 || '%') 
+    AND l.available = TRUE AND l.status = 'published'
+ORDER BY l.created_at DESC;
+
+-- name: ReopenListingIfFree :exec
+UPDATE listings l
+SET available = TRUE
+WHERE l.id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM bookings b
+      WHERE b.listing_id = l.id
+        AND b.status IN ('pending', 'confirmed')
+        AND b.deleted_at IS NULL
+  );
@@ -0,0 +1,66 @@
+-- name: UpsertListingCalendarToken :one
+INSERT INTO listing_calendar_tokens (listing_id, token)
+VALUES ($1, $2)
+ON CONFLICT (listing_id) DO UPDATE SET token = EXCLUDED.token, created_at = NOW()
+RETURNING *;
+
+-- name: GetListingCalendarToken :one
+SELECT token FROM listing_calendar_tokens
+WHERE listing_id = $1;
+
+-- name: CreateCalendarFeed :one
+INSERT INTO calendar_feeds (listing_id, name, url)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetCalendarFeedsByListingID :many
+SELECT * FROM calendar_feeds
+WHERE listing_id = $1
+ORDER BY id;
+
+-- name: ListCalendarFeeds :many
+SELECT * FROM calendar_feeds
+ORDER BY id;
+
+-- name: DeleteCalendarFeed :execrows
+DELETE FROM calendar_feeds
+WHERE id = $1 AND listing_id = $2;
+
+-- name: UpdateCalendarFeedSync :exec
+UPDATE calendar_feeds
+SET last_synced_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: CreateBlockedDate :one
+INSERT INTO blocked_dates (listing_id, feed_id, start_date, end_date, summary, uid)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: DeleteFeedBlockedDates :exec
+DELETE FROM blocked_dates
+WHERE feed_id = $1;
+
+-- name: DeleteManualBlockedDate :execrows
+DELETE FROM blocked_dates
+WHERE id = $1 AND listing_id = $2 AND feed_id IS NULL;
+
+-- name: GetBlockedDatesByListingID :many
+SELECT * FROM blocked_dates
+WHERE listing_id = $1 AND end_date >= @since::date
+ORDER BY start_date;
+
+-- name: CountOverlappingBlockedDates :one
+SELECT COUNT(*)
+FROM blocked_dates
+WHERE listing_id = @listing_id
+  AND start_date < @check_out_date
+  AND end_date > @check_in_date;
+
+-- name: GetCalendarBookings :many
+SELECT id, check_in_date, check_out_date, status
+FROM bookings
+WHERE listing_id = $1
+  AND deleted_at IS NULL
+  AND status IN ('pending', 'confirmed')
+  AND check_out_date >= @since::date
+ORDER BY check_in_date;
@@ -0,0 +1,134 @@
+-- name: CreateListingImage :one
+-- Adds an image after the listing's other images.
+INSERT INTO listing_images (listing_id, staged_path, position)
+VALUES ($1, $2, (SELECT COALESCE(MAX(position) + 1, 0) FROM listing_images WHERE listing_id = $1))
+RETURNING *;
+
+-- name: CreateUploadedListingImage :one
+-- Adds an image uploaded directly to the image store after the listing's other images.
+INSERT INTO listing_images (listing_id, source_key, position)
+VALUES ($1, $2, (SELECT COALESCE(MAX(position) + 1, 0) FROM listing_images WHERE listing_id = $1))
+RETURNING *;
+
+-- name: GetListingImage :one
+SELECT *
+FROM listing_images
+WHERE id = $1;
+
+-- name: GetListingImages :many
+SELECT *
+FROM listing_images
+WHERE listing_id = $1
+ORDER BY position, id;
+
+-- name: UpdateListingImage :one
+-- An empty room clears it.
+UPDATE listing_images
+SET caption = COALESCE(sqlc.narg(caption), caption),
+    room = NULLIF(COALESCE(sqlc.narg(room), room), '')
+WHERE id = sqlc.arg(id) AND listing_id = sqlc.arg(listing_id)
+RETURNING *;
+
+-- name: ReorderListingImages :execrows
+-- Positions the listing's images in the order of ids.
+UPDATE listing_images i
+SET position = o.n - 1
+FROM unnest(sqlc.arg(ids)::int[]) WITH ORDINALITY AS o(id, n)
+WHERE i.id = o.id AND i.listing_id = sqlc.arg(listing_id);
+
+-- name: SetListingImageCover :execrows
+UPDATE listing_images
+SET is_cover = (id = sqlc.arg(id))
+WHERE listing_id = sqlc.arg(listing_id) AND (is_cover OR id = sqlc.arg(id));
+
+-- name: DeleteListingImage :execrows
+-- Deletes an image and records its stored variants for cleanup.
+WITH orphans AS (
+    INSERT INTO orphaned_images (object_key)
+    SELECT v.object_key
+    FROM listing_image_variants v
+    JOIN listing_images i ON i.id = v.image_id
+    WHERE i.id = sqlc.arg(id) AND i.listing_id = sqlc.arg(listing_id)
+    ON CONFLICT (object_key) DO UPDATE SET created_at = EXCLUDED.created_at
+)
+DELETE FROM listing_images
+WHERE id = sqlc.arg(id) AND listing_id = sqlc.arg(listing_id);
+
+-- name: UpsertListingImageVariant :exec
+INSERT INTO listing_image_variants (image_id, name, format, width, height, object_key, url)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (image_id, name, format) DO UPDATE
+SET width = EXCLUDED.width,
+    height = EXCLUDED.height,
+    object_key = EXCLUDED.object_key,
+    url = EXCLUDED.url;
+
+-- name: GetListingImageVariants :many
+SELECT v.*
+FROM listing_image_variants v
+JOIN listing_images i ON i.id = v.image_id
+WHERE i.listing_id = $1
+ORDER BY i.position, v.image_id, v.width, v.format;
+
+-- name: MarkListingImageReady :execrows
+UPDATE listing_images
+SET status = 'ready', url = $2, width = $3, height = $4, blurhash = $5, staged_path = NULL, source_key = NULL, processed_at = NOW()
+WHERE id = $1 AND status = 'pending';
+
+-- name: MarkListingImageFailed :exec
+UPDATE listing_images
+SET status = 'failed', error = $2, staged_path = NULL, source_key = NULL, processed_at = NOW()
+WHERE id = $1 AND status = 'pending';
+
+-- name: SyncListingImageLinks :exec
+-- Sets the listing's imageLinks to the URLs of its ready images, cover first.
+UPDATE listings
+SET imageLinks = ARRAY(
+    SELECT i.url FROM listing_images i
+    WHERE i.listing_id = listings.id AND i.status = 'ready'
+    ORDER BY i.is_cover DESC, i.position, i.id
+)
+WHERE id = $1;
+
+-- name: RecordOrphanedImages :exec
+-- Records objects that are about to be stored, so they are cleaned up if they never end
+-- up referenced by a variant.
+INSERT INTO orphaned_images (object_key)
+SELECT unnest(sqlc.arg(object_keys)::text[])
+ON CONFLICT (object_key) DO UPDATE SET created_at = EXCLUDED.created_at;
+
+-- name: RecordListingOrphanedImages :exec
+-- Records the stored variants of a listing that is about to be deleted.
+INSERT INTO orphaned_images (object_key)
+SELECT v.object_key
+FROM listing_image_variants v
+JOIN listing_images i ON i.id = v.image_id
+WHERE i.listing_id = $1
+ON CONFLICT (object_key) DO UPDATE SET created_at = EXCLUDED.created_at;
+
+-- name: GetOrphanedImages :many
+-- Returns recorded objects older than before that no variant references and no image is
+-- waiting to process.
+SELECT o.object_key
+FROM orphaned_images o
+WHERE o.created_at < $1
+  AND NOT EXISTS (SELECT 1 FROM listing_image_variants v WHERE v.object_key = o.object_key)
+  AND NOT EXISTS (SELECT 1 FROM listing_images i WHERE i.source_key = o.object_key)
+ORDER BY o.created_at
+LIMIT $2;
+
+-- name: DeleteOrphanedImages :exec
+DELETE FROM orphaned_images
+WHERE object_key = ANY(sqlc.arg(object_keys)::text[]);
+
+-- name: DeleteReferencedOrphanedImages :execrows
+-- Forgets recorded objects older than before that turned out to be in use.
+DELETE FROM orphaned_images o
+WHERE o.created_at < $1
+  AND EXISTS (SELECT 1 FROM listing_image_variants v WHERE v.object_key = o.object_key);
+
+-- name: GetStagedListingImagePaths :many
+-- Returns which of paths are uploads still waiting to be processed.
+SELECT staged_path::text
+FROM listing_images
+WHERE staged_path = ANY(sqlc.arg(paths)::text[]);
@@ -0,0 +1,42 @@
+-- name: CreateListingPriceRule :one
+INSERT INTO listing_price_rules (
+    listing_id,
+    name,
+    kind,
+    start_date,
+    end_date,
+    days_of_week,
+    nightly_price,
+    min_stay,
+    max_stay,
+    discount_percent,
+    lead_days,
+    priority
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING *;
+
+-- name: GetListingPriceRules :many
+SELECT * FROM listing_price_rules
+WHERE listing_id = $1
+ORDER BY priority DESC, id DESC;
+
+-- name: UpdateListingPriceRule :one
+UPDATE listing_price_rules
+SET name = $3,
+    kind = $4,
+    start_date = $5,
+    end_date = $6,
+    days_of_week = $7,
+    nightly_price = $8,
+    min_stay = $9,
+    max_stay = $10,
+    discount_percent = $11,
+    lead_days = $12,
+    priority = $13
+WHERE id = $1 AND listing_id = $2
+RETURNING *;
+
+-- name: DeleteListingPriceRule :execrows
+DELETE FROM listing_price_rules
+WHERE id = $1 AND listing_id = $2;
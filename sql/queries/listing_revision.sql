@@ -0,0 +1,31 @@
+-- name: CreateListingRevision :one
+-- Records the listing's current content as its next revision.
+INSERT INTO listing_revisions (listing_id, revision, admin_id, title, description, price, location, booking_mode, note)
+SELECT l.id,
+    COALESCE((SELECT MAX(r.revision) FROM listing_revisions r WHERE r.listing_id = l.id), 0) + 1,
+    sqlc.narg(admin_id), l.title, l.description, l.price, l.location, l.booking_mode, sqlc.arg(note)
+FROM listings l
+WHERE l.id = sqlc.arg(listing_id)
+RETURNING *;
+
+-- name: GetListingRevisions :many
+SELECT *
+FROM listing_revisions
+WHERE listing_id = $1
+ORDER BY revision DESC;
+
+-- name: GetListingRevision :one
+SELECT *
+FROM listing_revisions
+WHERE listing_id = $1 AND revision = $2;
+
+-- name: RestoreListingRevision :execrows
+-- Puts the content of a revision back on the listing.
+UPDATE listings l
+SET title = r.title,
+    description = r.description,
+    price = r.price,
+    location = r.location,
+    booking_mode = r.booking_mode
+FROM listing_revisions r
+WHERE l.id = $1 AND r.listing_id = l.id AND r.revision = $2;
@@ -0,0 +1,84 @@
+-- name: IsSuperAdmin :one
+SELECT EXISTS (SELECT 1 FROM super_admins WHERE admin_id = $1);
+
+-- name: CreateContentReport :one
+INSERT INTO content_reports (reporter_user_id, reporter_admin_id, review_id, listing_id, reason, details)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOpenContentReports :many
+SELECT *
+FROM content_reports
+WHERE status = 'open'
+ORDER BY created_at;
+
+-- name: GetReviewModerationQueue :many
+SELECT r.id, r.listing_id, r.user_id, r.rating, r.comment, r.moderation_status, r.moderation_reason, r.created_at, COUNT(cr.id) AS open_reports
+FROM reviews r
+LEFT JOIN content_reports cr ON cr.review_id = r.id AND cr.status = 'open'
+WHERE r.moderation_status = 'flagged' OR cr.id IS NOT NULL
+GROUP BY r.id
+ORDER BY open_reports DESC, r.created_at;
+
+-- name: GetListingModerationQueue :many
+SELECT l.id, l.admin_id, l.title, l.description, COUNT(cr.id) AS open_reports
+FROM listings l
+JOIN content_reports cr ON cr.listing_id = l.id AND cr.status = 'open'
+GROUP BY l.id
+ORDER BY open_reports DESC;
+
+-- name: GetReviewForModeration :one
+SELECT id, listing_id, comment
+FROM reviews
+WHERE id = $1;
+
+-- name: CountDuplicateReviews :one
+SELECT COUNT(*)
+FROM reviews
+WHERE comment_fingerprint = $2 AND id <> $1;
+
+-- name: FlagReview :exec
+UPDATE reviews
+SET moderation_status = 'flagged', moderation_reason = $2
+WHERE id = $1 AND moderation_status IN ('pending', 'visible');
+
+-- name: GetStalePendingReviews :many
+-- Returns the reviews created before cutoff that the content filter has not screened.
+SELECT id FROM reviews
+WHERE moderation_status = 'pending' AND created_at < sqlc.arg(cutoff)::timestamp
+ORDER BY id;
+
+-- name: ClearPendingReview :execrows
+-- Shows a review the content filter passed.
+UPDATE reviews
+SET moderation_status = 'visible'
+WHERE id = $1 AND moderation_status = 'pending';
+
+-- name: SetReviewModerationStatus :execrows
+UPDATE reviews
+SET moderation_status = $2, moderation_reason = $3
+WHERE id = $1;
+
+-- name: ModeratorDeleteReview :execrows
+DELETE FROM reviews
+WHERE id = $1;
+
+-- name: ModeratorDeleteListing :execrows
+UPDATE listings
+SET status = 'archived', deleted_at = NOW(), deleted_by = $2
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ModeratorRestoreListing :execrows
+UPDATE listings
+SET status = 'draft', review_note = NULL, deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: ResolveReviewReports :exec
+UPDATE content_reports
+SET status = 'resolved', resolution = $2, resolved_by = $3, resolved_at = NOW()
+WHERE review_id = $1 AND status = 'open';
+
+-- name: ResolveListingReports :exec
+UPDATE content_reports
+SET status = 'resolved', resolution = $2, resolved_by = $3, resolved_at = NOW()
+WHERE listing_id = $1 AND status = 'open';
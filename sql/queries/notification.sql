@@ -8,15 +8,25 @@ INSERT INTO notifications (admin_id, subject, sender_user_id, email, booking_id,
 VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
+-- name: CreateEventNotification :one
+-- Creates the notification of a booking event for a user or an admin. Nothing is returned
+-- if they already have the notification of this category for the booking, or for the
+-- modification if the event is about one.
+INSERT INTO notifications (user_id, admin_id, category, booking_id, modification_id, subject, message)
+VALUES (sqlc.narg(user_id), sqlc.narg(admin_id), @category, @booking_id, sqlc.narg(modification_id), @subject, @message)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
 -- name: GetNotificationByID :one
 SELECT id, user_id, message, read, email, created_at
 FROM notifications
 WHERE id = $1 AND user_id = $2;
 
 -- name: GetNotificationsByUserID :many
-SELECT n.id, n.user_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at
+-- Notifications about booking events have a category and no sender.
+SELECT n.id, n.user_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at, n.category
 FROM notifications n
-JOIN admins a ON n.sender_admin_id = a.id
+LEFT JOIN admins a ON n.sender_admin_id = a.id
 WHERE n.user_id = $1
 ORDER BY n.created_at DESC;
 
@@ -28,9 +38,10 @@ WHERE n.sender_user_id = $1
 ORDER BY n.created_at DESC;
 
 -- name: GetNotificationsByAdminID :many
-SELECT n.id, n.admin_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at
+-- Notifications about booking events have a category and no sender.
+SELECT n.id, n.admin_id, a.id, n.subject, n.booking_id, a.email, n.message, n.read, n.created_at, n.category
 FROM notifications n
-JOIN users a ON n.sender_user_id = a.id
+LEFT JOIN users a ON n.sender_user_id = a.id
 WHERE n.admin_id = $1
 ORDER BY n.created_at DESC;
 
@@ -53,6 +64,16 @@ FROM notifications
 WHERE admin_id = $1 AND read = FALSE
 ORDER BY created_at DESC;
 
+-- name: CountUnreadNotificationsByUserID :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1 AND read = FALSE;
+
+-- name: CountUnreadNotificationsByAdminID :one
+SELECT COUNT(*)
+FROM notifications
+WHERE admin_id = $1 AND read = FALSE;
+
 -- name: UpdateNotificationReadStatus :exec
 UPDATE notifications
 SET read = COALESCE(sqlc.arg(read), read)
@@ -65,7 +86,99 @@ SET read = COALESCE(sqlc.arg(read), read)
 WHERE id = $1 AND admin_id = $2
 RETURNING id, admin_id, message, read, created_at;
 
--- name: DeleteNotification :exec
+-- name: DeleteNotification :one
 DELETE FROM notifications
-WHERE id = $1;
+WHERE id = $1
+RETURNING user_id, admin_id;
+
+-- name: GetUserNotificationPreferences :many
+SELECT category, channel, enabled
+FROM notification_preferences
+WHERE user_id = $1
+ORDER BY category, channel;
+
+-- name: GetAdminNotificationPreferences :many
+SELECT category, channel, enabled
+FROM notification_preferences
+WHERE admin_id = $1
+ORDER BY category, channel;
+
+-- name: SetUserNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, category, channel, enabled)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, category, channel) WHERE user_id IS NOT NULL
+DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW();
+
+-- name: SetAdminNotificationPreference :exec
+INSERT INTO notification_preferences (admin_id, category, channel, enabled)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (admin_id, category, channel) WHERE admin_id IS NOT NULL
+DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW();
+
+-- name: GetUserNotificationSettings :one
+SELECT * FROM notification_settings
+WHERE user_id = $1;
+
+-- name: GetAdminNotificationSettings :one
+SELECT * FROM notification_settings
+WHERE admin_id = $1;
+
+-- name: SetUserNotificationSettings :one
+INSERT INTO notification_settings (user_id, phone, quiet_hours_start, quiet_hours_end, time_zone)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) WHERE user_id IS NOT NULL
+DO UPDATE SET
+    phone = EXCLUDED.phone,
+    quiet_hours_start = EXCLUDED.quiet_hours_start,
+    quiet_hours_end = EXCLUDED.quiet_hours_end,
+    time_zone = EXCLUDED.time_zone,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: SetAdminNotificationSettings :one
+INSERT INTO notification_settings (admin_id, phone, quiet_hours_start, quiet_hours_end, time_zone)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (admin_id) WHERE admin_id IS NOT NULL
+DO UPDATE SET
+    phone = EXCLUDED.phone,
+    quiet_hours_start = EXCLUDED.quiet_hours_start,
+    quiet_hours_end = EXCLUDED.quiet_hours_end,
+    time_zone = EXCLUDED.time_zone,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: CreateUserPushSubscription :one
+INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint)
+DO UPDATE SET user_id = EXCLUDED.user_id, admin_id = NULL, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth
+RETURNING *;
+
+-- name: CreateAdminPushSubscription :one
+INSERT INTO push_subscriptions (admin_id, endpoint, p256dh, auth)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (endpoint)
+DO UPDATE SET admin_id = EXCLUDED.admin_id, user_id = NULL, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth
+RETURNING *;
+
+-- name: GetUserPushSubscriptions :many
+SELECT * FROM push_subscriptions
+WHERE user_id = $1
+ORDER BY id;
+
+-- name: GetAdminPushSubscriptions :many
+SELECT * FROM push_subscriptions
+WHERE admin_id = $1
+ORDER BY id;
+
+-- name: DeleteUserPushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = $1 AND user_id = $2;
+
+-- name: DeleteAdminPushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = $1 AND admin_id = $2;
 
+-- name: DeletePushSubscription :exec
+DELETE FROM push_subscriptions
+WHERE endpoint = $1;
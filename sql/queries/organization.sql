@@ -0,0 +1,75 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name, created_by)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetOrganization :one
+SELECT *
+FROM organizations
+WHERE id = $1;
+
+-- name: GetAdminOrganizations :many
+SELECT o.id, o.name, m.role, o.created_at
+FROM organization_members m
+JOIN organizations o ON o.id = m.organization_id
+WHERE m.admin_id = $1
+ORDER BY o.name;
+
+-- name: AddOrganizationMember :exec
+-- Adds a member, leaving the role of an existing member unchanged.
+INSERT INTO organization_members (organization_id, admin_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (organization_id, admin_id) DO NOTHING;
+
+-- name: GetOrganizationMember :one
+SELECT *
+FROM organization_members
+WHERE organization_id = $1 AND admin_id = $2;
+
+-- name: GetOrganizationMembers :many
+SELECT m.admin_id, a.username, a.email, m.role, m.created_at
+FROM organization_members m
+JOIN admins a ON a.id = m.admin_id
+WHERE m.organization_id = $1
+ORDER BY m.created_at;
+
+-- name: UpdateOrganizationMemberRole :execrows
+-- Changes a member's role unless that would leave the organisation without an owner.
+UPDATE organization_members m
+SET role = @role
+WHERE m.organization_id = @organization_id AND m.admin_id = @admin_id
+    AND (m.role <> 'owner' OR @role = 'owner'
+        OR (SELECT COUNT(*) FROM organization_members o WHERE o.organization_id = m.organization_id AND o.role = 'owner') > 1);
+
+-- name: DeleteOrganizationMember :execrows
+-- Removes a member unless they are the organisation's last owner.
+DELETE FROM organization_members m
+WHERE m.organization_id = $1 AND m.admin_id = $2
+    AND (m.role <> 'owner'
+        OR (SELECT COUNT(*) FROM organization_members o WHERE o.organization_id = m.organization_id AND o.role = 'owner') > 1);
+
+-- name: CreateOrganizationInvitation :one
+INSERT INTO organization_invitations (organization_id, email, role, token, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOrganizationInvitations :many
+-- Returns the invitations of an organisation that have not been accepted yet.
+SELECT id, organization_id, email, role, invited_by, expires_at, created_at
+FROM organization_invitations
+WHERE organization_id = $1 AND accepted_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: GetOrganizationInvitationByToken :one
+SELECT *
+FROM organization_invitations
+WHERE token = $1;
+
+-- name: AcceptOrganizationInvitation :execrows
+UPDATE organization_invitations
+SET accepted_at = NOW()
+WHERE id = $1 AND accepted_at IS NULL AND expires_at > NOW();
+
+-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations
+WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL;
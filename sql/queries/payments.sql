@@ -8,8 +8,11 @@ SELECT id, booking_id, amount, status, payment_method, transaction_id, paid_at,
 FROM payments
 WHERE id = $1;
 
+-- name: PaymentTransactionExists :one
+SELECT EXISTS (SELECT 1 FROM payments WHERE transaction_id = $1);
+
 -- name: GetPaymentsByBookingID :many
-SELECT id, booking_id, amount, status, payment_method, transaction_id, paid_at, created_at
+SELECT id, booking_id, amount, status, payment_method, transaction_id, paid_at, created_at, refunded_amount
 FROM payments
 WHERE booking_id = $1
 ORDER BY created_at DESC;
@@ -28,7 +31,10 @@ FROM payments p
 JOIN bookings b ON p.booking_id = b.id
 JOIN listings l ON b.listing_id = l.id
 JOIN users u ON b.user_id = u.id
-WHERE l.admin_id = $1
+WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
 ORDER BY p.created_at DESC;
 
 -- name: GetPaymentsByStatus :many
@@ -43,6 +49,13 @@ SET status = COALESCE(sqlc.arg(status), status)
 WHERE id = $1
 RETURNING id, booking_id, amount, status, payment_method, transaction_id, paid_at, created_at;
 
+-- name: AddPaymentRefund :execrows
+-- Records that amount of a payment was refunded. Nothing is recorded if it is more than
+-- the rest of the payment.
+UPDATE payments
+SET refunded_amount = refunded_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id) AND refunded_amount + sqlc.arg(amount) <= payments.amount;
+
 -- name: DeletePayment :exec
 DELETE FROM payments
 WHERE id = $1;
@@ -0,0 +1,60 @@
+-- name: GetListingInteractions :many
+SELECT *
+FROM listing_interactions;
+
+-- name: GetRecommendationItems :many
+SELECT id, location, price::float8 AS price
+FROM listings;
+
+-- name: UpsertListingSimilarities :exec
+INSERT INTO listing_similarities (listing_id, similar_listing_id, score, trained_at)
+SELECT
+    unnest(sqlc.arg(listing_ids)::int[]),
+    unnest(sqlc.arg(similar_listing_ids)::int[]),
+    unnest(sqlc.arg(scores)::float8[]),
+    sqlc.arg(trained_at)::timestamp
+ON CONFLICT (listing_id, similar_listing_id) DO UPDATE
+SET score = EXCLUDED.score, trained_at = EXCLUDED.trained_at;
+
+-- name: DeleteStaleListingSimilarities :execrows
+DELETE FROM listing_similarities
+WHERE trained_at < $1;
+
+-- name: GetSimilarListings :many
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at, s.score
+FROM listing_similarities s
+JOIN listings l ON l.id = s.similar_listing_id
+WHERE s.listing_id = $1 AND l.available = TRUE AND l.status = 'published'
+ORDER BY s.score DESC, l.id
+LIMIT $2;
+
+-- name: GetRecommendedListings :many
+-- Scores the listings similar to those the user interacted with by how strongly they
+-- did, leaving out listings the user already knows.
+WITH history AS (
+    SELECT listing_id, weight FROM listing_interactions WHERE user_id = $1
+)
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at,
+    SUM(s.score * h.weight)::float8 AS score
+FROM history h
+JOIN listing_similarities s ON s.listing_id = h.listing_id
+JOIN listings l ON l.id = s.similar_listing_id
+WHERE l.available = TRUE AND l.status = 'published'
+  AND NOT EXISTS (SELECT 1 FROM history k WHERE k.listing_id = l.id)
+GROUP BY l.id
+ORDER BY score DESC, l.id
+LIMIT $2;
+
+-- name: GetPopularListings :many
+-- Ranks available listings by confirmed bookings, then ratings and unique views, leaving
+-- out listings the user already interacted with. Pass user_id 0 for anonymous visitors.
+SELECT l.id, l.admin_id, l.title, l.description, l.price, l.location, l.available, l.imageLinks, l.created_at,
+    (COALESCE(s.total_bookings, 0) * 5
+     + COALESCE(s.average_rating, 0) * COALESCE(s.review_count, 0)
+     + COALESCE(s.unique_views, 0) * 0.05)::float8 AS score
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
+WHERE l.available = TRUE AND l.status = 'published'
+  AND NOT EXISTS (SELECT 1 FROM listing_interactions i WHERE i.user_id = $1 AND i.listing_id = l.id)
+ORDER BY score DESC, l.created_at DESC, l.id
+LIMIT $2;
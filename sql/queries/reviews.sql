@@ -1,26 +1,92 @@
 -- name: CreateReview :one
-INSERT INTO reviews (user_id, listing_id, rating, comment)
-VALUES ($1, $2, $3, $4)
-RETURNING id, user_id, listing_id, rating, comment, created_at;
+-- Reviews are pending until the content filter has screened them.
+INSERT INTO reviews (
+    user_id,
+    listing_id,
+    booking_id,
+    rating,
+    cleanliness_rating,
+    accuracy_rating,
+    location_rating,
+    value_rating,
+    communication_rating,
+    comment,
+    comment_fingerprint,
+    moderation_status
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'pending')
+RETURNING *;
 
 -- name: GetReviewByID :one
-SELECT id, user_id, listing_id, rating, comment, created_at
+SELECT *
 FROM reviews
 WHERE id = $1 and user_id = $2;
 
+-- name: GetReviewByBookingID :one
+SELECT *
+FROM reviews
+WHERE booking_id = $1;
+
 -- name: GetReviewsByListingID :many
-SELECT r.id, r.user_id, r.listing_id, r.rating, r.comment, r.created_at
+SELECT r.*
 FROM reviews r
 JOIN listings l ON r.listing_id = l.id
-WHERE listing_id = $1 AND admin_id= $2;
+WHERE r.listing_id = @listing_id
+    AND ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+    AND r.published_at IS NOT NULL AND r.moderation_status = 'visible';
 
 -- name: GetListingReviews :many
-SELECT r.id, r.user_id, r.listing_id, r.rating, r.comment, r.created_at, u.username
+SELECT r.id, r.user_id, r.listing_id, r.rating, r.cleanliness_rating, r.accuracy_rating, r.location_rating, r.value_rating, r.communication_rating, r.comment, r.host_reply, r.host_replied_at, r.created_at, u.username
 FROM reviews r
 JOIN listings l ON r.listing_id = l.id
 JOIN users u ON r.user_id = u.id
-WHERE listing_id = $1;
+WHERE listing_id = $1 AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'
+ORDER BY r.created_at DESC;
+
+-- name: GetListingRatingSummary :one
+SELECT
+    COUNT(*) AS total_reviews,
+    COALESCE(ROUND(AVG(rating)::numeric, 2), 0)::float8 AS average_rating,
+    COALESCE(ROUND(AVG(cleanliness_rating)::numeric, 2), 0)::float8 AS cleanliness,
+    COALESCE(ROUND(AVG(accuracy_rating)::numeric, 2), 0)::float8 AS accuracy,
+    COALESCE(ROUND(AVG(location_rating)::numeric, 2), 0)::float8 AS location,
+    COALESCE(ROUND(AVG(value_rating)::numeric, 2), 0)::float8 AS value,
+    COALESCE(ROUND(AVG(communication_rating)::numeric, 2), 0)::float8 AS communication,
+    COUNT(*) FILTER (WHERE rating = 5) AS five_star,
+    COUNT(*) FILTER (WHERE rating = 4) AS four_star,
+    COUNT(*) FILTER (WHERE rating = 3) AS three_star,
+    COUNT(*) FILTER (WHERE rating = 2) AS two_star,
+    COUNT(*) FILTER (WHERE rating = 1) AS one_star
+FROM reviews
+WHERE listing_id = $1 AND published_at IS NOT NULL AND moderation_status = 'visible';
+
+-- name: ReplyToReview :one
+-- Hosts can only reply to reviews guests can see.
+UPDATE reviews r
+SET host_reply = @host_reply, host_replied_at = NOW()
+FROM listings l
+WHERE r.id = @id AND r.listing_id = l.id
+    AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'
+    AND ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+RETURNING r.*;
+
+-- name: PublishExpiredReviews :execrows
+UPDATE reviews r
+SET published_at = NOW()
+FROM bookings b
+WHERE r.booking_id = b.id
+  AND r.published_at IS NULL
+  AND b.check_out_date < sqlc.arg(cutoff)::timestamp;
 
--- name: DeleteReview :exec
+-- name: DeleteReview :execrows
+-- Published reviews of a stay are kept, so the guest cannot replace one after reading the
+-- host's review.
 DELETE FROM reviews
-WHERE id = $1 and user_id = $2;
\ No newline at end of file
+WHERE id = $1 and user_id = $2
+  AND (booking_id IS NULL OR published_at IS NULL);
@@ -1,32 +1,126 @@
--- name: CreateStat :one
-INSERT INTO stats (listing_id, total_views, total_bookings, average_rating)
-VALUES ($1, $2, $3, $4)
-RETURNING id, listing_id, total_views, total_bookings, average_rating, created_at;
-
--- name: GetStatByID :one
-SELECT id, listing_id, total_views, total_bookings, average_rating, created_at
-FROM stats
-WHERE id = $1;
+-- name: RefreshListingStats :exec
+-- Recomputes the aggregates of one listing, or of every listing when listing_id is null.
+INSERT INTO stats (listing_id, admin_id, total_views, unique_views, total_bookings, revenue, average_rating, review_count, updated_at)
+SELECT
+    l.id,
+    l.admin_id,
+    COALESCE(l.total_views, 0),
+    (SELECT COALESCE(SUM(v.unique_views), 0) FROM listing_daily_views v WHERE v.listing_id = l.id),
+    (SELECT COUNT(*) FROM bookings b
+     WHERE b.listing_id = l.id AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL),
+    (SELECT COALESCE(SUM(b.total_amount), 0) FROM bookings b
+     WHERE b.listing_id = l.id AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL),
+    (SELECT COALESCE(ROUND(AVG(r.rating)::numeric, 2), 0) FROM reviews r
+     WHERE r.listing_id = l.id AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'),
+    (SELECT COUNT(*) FROM reviews r
+     WHERE r.listing_id = l.id AND r.published_at IS NOT NULL AND r.moderation_status = 'visible'),
+    NOW()
+FROM listings l
+WHERE sqlc.narg(listing_id)::int IS NULL OR l.id = sqlc.narg(listing_id)::int
+ON CONFLICT (listing_id) DO UPDATE
+SET
+    admin_id = EXCLUDED.admin_id,
+    total_views = EXCLUDED.total_views,
+    unique_views = EXCLUDED.unique_views,
+    total_bookings = EXCLUDED.total_bookings,
+    revenue = EXCLUDED.revenue,
+    average_rating = EXCLUDED.average_rating,
+    review_count = EXCLUDED.review_count,
+    updated_at = EXCLUDED.updated_at;
 
 -- name: GetStatsByListingID :one
-SELECT id, listing_id, total_views, total_bookings, average_rating, created_at
+SELECT *
 FROM stats
 WHERE listing_id = $1;
 
--- name: UpdateStat :exec
-UPDATE stats
-SET
-    total_views = $2,
-    total_bookings = $3,
-    average_rating = $4
-WHERE id = $1
-RETURNING id, listing_id, total_views, total_bookings, average_rating, created_at;
+-- name: RecordListingViews :execrows
+-- Adds a flushed batch of views to the listing's lifetime and daily counts. unique_views
+-- is the day's distinct visitor count so far, so the daily figure only ever grows and
+-- stats gains the difference.
+WITH previous AS (
+    SELECT unique_views
+    FROM listing_daily_views
+    WHERE listing_id = sqlc.arg(listing_id) AND day = sqlc.arg(day)
+), viewed AS (
+    UPDATE listings
+    SET total_views = COALESCE(total_views, 0) + sqlc.arg(views)::int
+    WHERE id = sqlc.arg(listing_id)
+    RETURNING id, admin_id, total_views
+), daily AS (
+    INSERT INTO listing_daily_views (listing_id, day, views, unique_views)
+    SELECT id, sqlc.arg(day)::date, sqlc.arg(views)::int, sqlc.arg(unique_views)::int FROM viewed
+    ON CONFLICT (listing_id, day) DO UPDATE
+    SET views = listing_daily_views.views + EXCLUDED.views,
+        unique_views = GREATEST(listing_daily_views.unique_views, EXCLUDED.unique_views)
+)
+INSERT INTO stats (listing_id, admin_id, total_views, unique_views)
+SELECT id, admin_id, total_views, sqlc.arg(unique_views)::int FROM viewed
+ON CONFLICT (listing_id) DO UPDATE
+SET total_views = EXCLUDED.total_views,
+    unique_views = stats.unique_views + GREATEST(EXCLUDED.unique_views - COALESCE((SELECT unique_views FROM previous), 0), 0),
+    updated_at = NOW();
 
--- name: DeleteStat :exec
-DELETE FROM stats
-WHERE id = $1;
+-- name: GetAdminDailyStats :many
+-- Returns one row per day in [from_date, to_date) for the listings the admin hosts, or
+-- for one of them when listing_id is set. Bookings count on the day they were requested; booked
+-- nights and revenue count on the nights stayed, with the booking amount spread evenly.
+WITH days AS (
+    SELECT generate_series(sqlc.arg(from_date)::date, sqlc.arg(to_date)::date - 1, INTERVAL '1 day')::date AS day
+), scope AS (
+    SELECT l.id, l.created_at
+    FROM listings l
+    WHERE ((l.organization_id IS NULL AND l.admin_id = @admin_id)
+        OR l.organization_id IN (
+            SELECT m.organization_id FROM organization_members m
+            WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[])))
+      AND (sqlc.narg(listing_id)::int IS NULL OR l.id = sqlc.narg(listing_id)::int)
+)
+SELECT
+    d.day,
+    (SELECT COUNT(*) FROM scope s
+     WHERE s.created_at IS NULL OR s.created_at::date <= d.day)::int AS listing_nights,
+    (SELECT COALESCE(SUM(v.views), 0) FROM listing_daily_views v
+     JOIN scope s ON s.id = v.listing_id
+     WHERE v.day = d.day)::int AS views,
+    (SELECT COALESCE(SUM(v.unique_views), 0) FROM listing_daily_views v
+     JOIN scope s ON s.id = v.listing_id
+     WHERE v.day = d.day)::int AS unique_views,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.created_at::date = d.day)::int AS booking_requests,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.created_at::date = d.day AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL)::int AS confirmed_bookings,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.created_at::date = d.day AND b.status = 'cancelled')::int AS cancellations,
+    (SELECT COUNT(*) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.check_in_date <= d.day AND b.check_out_date > d.day
+       AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL)::int AS booked_nights,
+    (SELECT COALESCE(SUM(b.total_amount / GREATEST(b.check_out_date - b.check_in_date, 1)), 0) FROM bookings b
+     JOIN scope s ON s.id = b.listing_id
+     WHERE b.check_in_date <= d.day AND b.check_out_date > d.day
+       AND b.status IN ('confirmed', 'completed') AND b.deleted_at IS NULL)::float8 AS revenue
+FROM days d
+ORDER BY d.day;
 
--- name: GetAllStats :many
-SELECT id, listing_id, total_views, total_bookings, average_rating, created_at
-FROM stats
-ORDER BY created_at DESC;
+-- name: GetAdminListingStats :many
+-- Returns the aggregates of the listings the admin hosts.
+SELECT
+    l.id,
+    l.title,
+    COALESCE(s.total_views, 0)::int AS total_views,
+    COALESCE(s.unique_views, 0)::int AS unique_views,
+    COALESCE(s.total_bookings, 0)::int AS total_bookings,
+    COALESCE(s.revenue, 0)::float8 AS revenue,
+    COALESCE(s.average_rating, 0)::float8 AS average_rating,
+    COALESCE(s.review_count, 0)::int AS review_count,
+    s.updated_at
+FROM listings l
+LEFT JOIN stats s ON s.listing_id = l.id
+WHERE (l.organization_id IS NULL AND l.admin_id = @admin_id)
+    OR l.organization_id IN (
+        SELECT m.organization_id FROM organization_members m
+        WHERE m.admin_id = @admin_id AND m.role = ANY(@roles::text[]))
+ORDER BY total_views DESC;
@@ -0,0 +1,37 @@
+-- name: CreateUploadSession :one
+INSERT INTO upload_sessions (listing_id, admin_id, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: CreateUploadSessionFile :one
+INSERT INTO upload_session_files (session_id, position, filename, size, object_key)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUploadSession :one
+SELECT *
+FROM upload_sessions
+WHERE id = $1 AND listing_id = $2;
+
+-- name: GetUploadSessionFiles :many
+SELECT f.session_id, f.position, f.filename, f.size, f.object_key, f.status, f.image_id, f.error,
+    i.status AS image_status, i.url AS image_url, i.error AS image_error
+FROM upload_session_files f
+LEFT JOIN listing_images i ON i.id = f.image_id
+WHERE f.session_id = $1
+ORDER BY f.position;
+
+-- name: AttachUploadSessionFile :execrows
+-- Marks a waiting file attached to the image created from it.
+UPDATE upload_session_files
+SET status = 'attached', image_id = $3
+WHERE session_id = $1 AND position = $2 AND status = 'waiting';
+
+-- name: RejectUploadSessionFile :exec
+UPDATE upload_session_files
+SET status = 'rejected', error = $3
+WHERE session_id = $1 AND position = $2 AND status = 'waiting';
+
+-- name: DeleteExpiredUploadSessions :execrows
+DELETE FROM upload_sessions
+WHERE expires_at < $1;
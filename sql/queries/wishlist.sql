@@ -0,0 +1,95 @@
+-- name: CreateWishlist :one
+INSERT INTO wishlists (user_id, name)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: GetUserWishlists :many
+-- Returns the wishlists the user owns or collaborates on.
+SELECT
+    w.id, w.user_id, w.name, w.share_token, w.created_at, w.updated_at,
+    u.username AS owner_username,
+    (SELECT COUNT(*) FROM wishlist_items i JOIN listings l ON l.id = i.listing_id
+     WHERE i.wishlist_id = w.id AND l.status = 'published' AND l.deleted_at IS NULL) AS item_count
+FROM wishlists w
+JOIN users u ON u.id = w.user_id
+WHERE w.user_id = $1
+   OR EXISTS (SELECT 1 FROM wishlist_collaborators c WHERE c.wishlist_id = w.id AND c.user_id = $1)
+ORDER BY w.updated_at DESC;
+
+-- name: GetWishlistForUser :one
+-- Returns the wishlist if the user owns or collaborates on it.
+SELECT w.id, w.user_id, w.name, w.share_token, w.created_at, w.updated_at, u.username AS owner_username
+FROM wishlists w
+JOIN users u ON u.id = w.user_id
+WHERE w.id = sqlc.arg(id)
+  AND (w.user_id = sqlc.arg(user_id)
+       OR EXISTS (SELECT 1 FROM wishlist_collaborators c WHERE c.wishlist_id = w.id AND c.user_id = sqlc.arg(user_id)));
+
+-- name: GetWishlistByShareToken :one
+SELECT w.id, w.user_id, w.name, w.share_token, w.created_at, w.updated_at, u.username AS owner_username
+FROM wishlists w
+JOIN users u ON u.id = w.user_id
+WHERE w.share_token = sqlc.arg(share_token)::text;
+
+-- name: UpdateWishlist :one
+UPDATE wishlists
+SET name = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: SetWishlistShareToken :execrows
+UPDATE wishlists
+SET share_token = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWishlist :execrows
+DELETE FROM wishlists
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWishlistItem :one
+INSERT INTO wishlist_items (wishlist_id, listing_id, added_by, note, check_in_date, check_out_date)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetWishlistItemByListing :one
+SELECT *
+FROM wishlist_items
+WHERE wishlist_id = $1 AND listing_id = $2;
+
+-- name: GetWishlistItems :many
+-- Listings guests can no longer see are left out; the items come back if they return.
+SELECT
+    i.id, i.listing_id, i.note, i.check_in_date, i.check_out_date, i.created_at,
+    u.username AS added_by_username,
+    l.title, l.price, l.location, l.available, l.imageLinks
+FROM wishlist_items i
+JOIN listings l ON l.id = i.listing_id
+JOIN users u ON u.id = i.added_by
+WHERE i.wishlist_id = $1 AND l.status = 'published' AND l.deleted_at IS NULL
+ORDER BY i.created_at DESC;
+
+-- name: UpdateWishlistItem :one
+UPDATE wishlist_items
+SET note = $3, check_in_date = $4, check_out_date = $5
+WHERE id = $1 AND wishlist_id = $2
+RETURNING *;
+
+-- name: DeleteWishlistItem :execrows
+DELETE FROM wishlist_items
+WHERE id = $1 AND wishlist_id = $2;
+
+-- name: AddWishlistCollaborator :exec
+INSERT INTO wishlist_collaborators (wishlist_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetWishlistCollaborators :many
+SELECT u.id, u.username, c.created_at
+FROM wishlist_collaborators c
+JOIN users u ON u.id = c.user_id
+WHERE c.wishlist_id = $1
+ORDER BY c.created_at;
+
+-- name: RemoveWishlistCollaborator :execrows
+DELETE FROM wishlist_collaborators
+WHERE wishlist_id = $1 AND user_id = $2;
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go"
+	"github.com/cloudinary/cloudinary-go/api/uploader"
+)
+
+// Cloudinary stores objects as Cloudinary image assets. The key without its extension is
+// the public ID and the extension is the format the asset is stored in, so two keys that
+// differ only in their extension are the same asset. Other formats of an asset are
+// delivered with TranscodedURL instead.
+type Cloudinary struct {
+	cld    *cloudinary.Cloudinary
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewCloudinary(cloudinaryURL string) (*Cloudinary, error) {
+	if cloudinaryURL == "" {
+		return nil, errors.New("cloudinary image store needs CLOUDINARY_URL")
+	}
+	cld, err := cloudinary.NewFromURL(cloudinaryURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Cloudinary{
+		cld:    cld,
+		client: &http.Client{Timeout: time.Minute},
+		now:    time.Now,
+	}, nil
+}
+
+func splitKey(key string) (publicID, format string, err error) {
+	key, err = CleanKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext), strings.TrimPrefix(ext, "."), nil
+}
+
+func (c *Cloudinary) Put(ctx context.Context, key string, data []byte) (string, error) {
+	publicID, format, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.cld.Upload.Upload(ctx, bytes.NewReader(data), uploader.UploadParams{
+		PublicID:   publicID,
+		Format:     format,
+		Overwrite:  true,
+		Invalidate: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Error.Message != "" {
+		return "", errors.New(resp.Error.Message)
+	}
+	return resp.SecureURL, nil
+}
+
+func (c *Cloudinary) Delete(ctx context.Context, key string) error {
+	publicID, _, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	resp, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID, Invalidate: true})
+	if err != nil {
+		return err
+	}
+	if resp.Error.Message != "" {
+		return errors.New(resp.Error.Message)
+	}
+	return nil
+}
+
+// SignedURL returns a signed delivery URL. Uploaded assets are public, so the signature
+// only stops the URL being altered; it expires only if token authentication is enabled
+// on the Cloudinary account.
+func (c *Cloudinary) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	img, err := c.cld.Image(key)
+	if err != nil {
+		return "", err
+	}
+	img.Config.URL.Secure = true
+	img.Config.URL.SignURL = true
+	return img.String()
+}
+
+// TranscodedURL returns the delivery URL of the asset under key converted to format by
+// Cloudinary's f_ transformation, such as f_webp.
+func (c *Cloudinary) TranscodedURL(key, format string) (string, error) {
+	publicID, _, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+	img, err := c.cld.Image(publicID)
+	if err != nil {
+		return "", err
+	}
+	img.Config.URL.Secure = true
+	img.Transformation = "f_" + format
+	return img.String()
+}
+
+// cloudinarySignatureLifetime is how long Cloudinary accepts a signed upload for.
+const cloudinarySignatureLifetime = time.Hour
+
+// PresignUpload returns the signed form fields for an upload straight to Cloudinary.
+// Cloudinary accepts a signature for an hour whatever expiry is, and cannot limit the
+// size of the upload, so maxBytes is not enforced.
+func (c *Cloudinary) PresignUpload(ctx context.Context, key string, maxBytes int64, expiry time.Duration) (DirectUpload, error) {
+	publicID, _, err := splitKey(key)
+	if err != nil {
+		return DirectUpload{}, err
+	}
+
+	now := c.now()
+	params := url.Values{}
+	params.Set("public_id", publicID)
+	params.Set("timestamp", strconv.FormatInt(now.Unix(), 10))
+	signature, err := signCloudinaryParams(params, c.cld.Config.Cloud.APISecret)
+	if err != nil {
+		return DirectUpload{}, err
+	}
+
+	return DirectUpload{
+		Method: http.MethodPost,
+		URL:    fmt.Sprintf("%s/v1_1/%s/image/upload", c.cld.Config.API.UploadPrefix, c.cld.Config.Cloud.CloudName),
+		Fields: map[string]string{
+			"public_id": publicID,
+			"timestamp": params.Get("timestamp"),
+			"api_key":   c.cld.Config.Cloud.APIKey,
+			"signature": signature,
+		},
+		ExpiresAt: now.Add(min(expiry, cloudinarySignatureLifetime)),
+	}, nil
+}
+
+// signCloudinaryParams signs upload parameters the way Cloudinary verifies them: the
+// SHA-1 of the sorted, unescaped parameters followed by the API secret.
+func signCloudinaryParams(params url.Values, secret string) (string, error) {
+	unescaped, err := url.QueryUnescape(params.Encode())
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(unescaped + secret))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// deliveryURL is the public URL of the original uploaded under key.
+func (c *Cloudinary) deliveryURL(key string) (string, error) {
+	publicID, _, err := splitKey(key)
+	if err != nil {
+		return "", err
+	}
+	img, err := c.cld.Image(publicID)
+	if err != nil {
+		return "", err
+	}
+	img.Config.URL.Secure = true
+	return img.String()
+}
+
+// fetch requests the delivered original under key.
+func (c *Cloudinary) fetch(ctx context.Context, method, key string) (*http.Response, error) {
+	u, err := c.deliveryURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		resp.Body.Close()
+		return nil, fmt.Errorf("cloudinary responded %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *Cloudinary) Stat(ctx context.Context, key string) (int64, error) {
+	resp, err := c.fetch(ctx, http.MethodHead, key)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+func (c *Cloudinary) Get(ctx context.Context, key string, maxBytes int64) ([]byte, error) {
+	resp, err := c.fetch(ctx, http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readLimited(resp.Body, maxBytes)
+}
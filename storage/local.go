@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local stores objects as files under a directory, for development and single-server
+// deployments. The files are served, and direct uploads received, by its ServeHTTP at
+// baseURL.
+type Local struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+	now        func() time.Time
+}
+
+func NewLocal(root, baseURL string, signingKey []byte) (*Local, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Local{
+		root:       root,
+		baseURL:    baseURL,
+		signingKey: signingKey,
+		now:        time.Now,
+	}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) url(key string) string {
+	return l.baseURL + (&url.URL{Path: key}).EscapedPath()
+}
+
+// Put writes the object to a temporary file first, so it is never served half-written.
+func (l *Local) Put(ctx context.Context, key string, data []byte) (string, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+	return l.url(key), nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.signedURL(http.MethodGet, key, "", expiry)
+}
+
+// PresignUpload returns a signed URL the client can PUT the object to. ServeHTTP enforces
+// maxBytes.
+func (l *Local) PresignUpload(ctx context.Context, key string, maxBytes int64, expiry time.Duration) (DirectUpload, error) {
+	signed, err := l.signedURL(http.MethodPut, key, strconv.FormatInt(maxBytes, 10), expiry)
+	if err != nil {
+		return DirectUpload{}, err
+	}
+	return DirectUpload{
+		Method:    http.MethodPut,
+		URL:       signed,
+		ExpiresAt: l.now().Add(expiry),
+	}, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (int64, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (l *Local) Get(ctx context.Context, key string, maxBytes int64) ([]byte, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, maxBytes)
+}
+
+// signedURL signs a request of method for key. maxBytes limits the size of uploads.
+func (l *Local) signedURL(method, key, maxBytes string, expiry time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if len(l.signingKey) == 0 {
+		return "", errors.New("local image store has no signing key")
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(l.now().Add(expiry).Unix(), 10))
+	if maxBytes != "" {
+		query.Set("max", maxBytes)
+	}
+	query.Set("signature", l.sign(method, key, query.Get("expires"), maxBytes))
+	return l.url(key) + "?" + query.Encode(), nil
+}
+
+func (l *Local) sign(method, key, expires, maxBytes string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(strings.Join([]string{method, key, expires, maxBytes}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether the signed URL in query is valid for a request of method for key.
+func (l *Local) verify(method, key string, query url.Values) bool {
+	if len(l.signingKey) == 0 {
+		return false
+	}
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || l.now().Unix() > unix {
+		return false
+	}
+	expected := l.sign(method, key, expires, query.Get("max"))
+	return hmac.Equal([]byte(query.Get("signature")), []byte(expected))
+}
+
+// ServeHTTP serves the object named by the request path, relative to the path the handler
+// is mounted at, and accepts uploads to presigned URLs. Objects under PublicPrefix are
+// immutable once written, so they are cached publicly for a year; every other object
+// needs a signed URL, which is only cached privately until it expires and rejected
+// afterwards.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, err := CleanKey(strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil || strings.HasPrefix(filepath.Base(key), ".") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		l.serveObject(w, r, key)
+	case http.MethodPut:
+		l.receiveUpload(w, r, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (l *Local) serveObject(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	if query.Get("signature") == "" && strings.HasPrefix(key, PublicPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		if !l.verify(http.MethodGet, key, query) {
+			http.Error(w, "invalid or expired signature", http.StatusForbidden)
+			return
+		}
+		unix, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", unix-l.now().Unix()))
+	}
+
+	name := filepath.Join(l.root, filepath.FromSlash(key))
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(key))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, key, info.ModTime(), f)
+}
+
+func (l *Local) receiveUpload(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	maxBytes, err := strconv.ParseInt(query.Get("max"), 10, 64)
+	if err != nil || !l.verify(http.MethodPut, key, query) {
+		http.Error(w, "invalid or expired signature", http.StatusForbidden)
+		return
+	}
+
+	data, err := readLimited(r.Body, maxBytes)
+	if errors.Is(err, ErrTooLarge) {
+		http.Error(w, ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if _, err := l.Put(r.Context(), key, data); err != nil {
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanKey(t *testing.T) {
+	key, err := CleanKey("listings/1/2/large.jpg")
+	require.NoError(t, err)
+	require.Equal(t, "listings/1/2/large.jpg", key)
+
+	for _, bad := range []string{"", "/etc/passwd", "../secret", "a/../../b", "a//b", "a\\b", ".", "a/"} {
+		_, err := CleanKey(bad)
+		require.ErrorIs(t, err, ErrInvalidKey, bad)
+	}
+}
+
+func TestLocalPutServeAndDelete(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root, "/media", []byte("secret"))
+	require.NoError(t, err)
+
+	url, err := store.Put(context.Background(), "listings/1/2/large.jpg", []byte("jpeg"))
+	require.NoError(t, err)
+	require.Equal(t, "/media/listings/1/2/large.jpg", url)
+
+	data, err := os.ReadFile(filepath.Join(root, "listings", "1", "2", "large.jpg"))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", string(data))
+
+	rec := httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/listings/1/2/large.jpg", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "jpeg", rec.Body.String())
+	require.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+	require.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+
+	require.NoError(t, store.Delete(context.Background(), "listings/1/2/large.jpg"))
+	require.NoError(t, store.Delete(context.Background(), "listings/1/2/large.jpg"))
+
+	rec = httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/listings/1/2/large.jpg", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err = store.Put(context.Background(), "../outside.jpg", []byte("jpeg"))
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "/media/", []byte("secret"))
+	require.NoError(t, err)
+	now := time.Date(2024, time.June, 20, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	_, err = store.Put(context.Background(), "docs/id.png", []byte("png"))
+	require.NoError(t, err)
+
+	signed, err := store.SignedURL(context.Background(), "docs/id.png", time.Hour)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	require.Equal(t, "/media/docs/id.png", u.Path)
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/id.png?"+query, nil))
+		return rec
+	}
+
+	rec := serve(u.RawQuery)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "private, max-age=3600", rec.Header().Get("Cache-Control"))
+
+	// Only listing images are served without a signature.
+	require.Equal(t, http.StatusForbidden, serve("").Code)
+
+	tampered := u.Query()
+	tampered.Set("expires", "9999999999")
+	require.Equal(t, http.StatusForbidden, serve(tampered.Encode()).Code)
+
+	now = now.Add(2 * time.Hour)
+	require.Equal(t, http.StatusForbidden, serve(u.RawQuery).Code)
+}
+
+func TestNewLocalWithoutSigningKey(t *testing.T) {
+	store, err := New(Config{Backend: BackendLocal, LocalDir: t.TempDir(), LocalBaseURL: "/media/"})
+	require.NoError(t, err)
+
+	// A key is made up, so signed URLs work until the server restarts.
+	_, err = store.SignedURL(context.Background(), "docs/id.png", time.Hour)
+	require.NoError(t, err)
+}
+
+func TestLocalPresignedUpload(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "/media/", []byte("secret"))
+	require.NoError(t, err)
+
+	upload, err := store.PresignUpload(context.Background(), "uploads/1/photo", 8, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPut, upload.Method)
+	u, err := url.Parse(upload.URL)
+	require.NoError(t, err)
+
+	put := func(query, body string) int {
+		rec := httptest.NewRecorder()
+		store.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/uploads/1/photo?"+query, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, put(u.RawQuery, "too large!"))
+	_, err = store.Stat(context.Background(), "uploads/1/photo")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.Equal(t, http.StatusCreated, put(u.RawQuery, "photo"))
+	size, err := store.Stat(context.Background(), "uploads/1/photo")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), size)
+
+	data, err := store.Get(context.Background(), "uploads/1/photo", 8)
+	require.NoError(t, err)
+	require.Equal(t, "photo", string(data))
+	_, err = store.Get(context.Background(), "uploads/1/photo", 4)
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	// A download URL cannot be used to upload, nor can the size limit be raised.
+	signed, err := store.SignedURL(context.Background(), "uploads/1/photo", time.Hour)
+	require.NoError(t, err)
+	download, err := url.Parse(signed)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, put(download.RawQuery+"&max=100", "photo"))
+
+	raised := u.Query()
+	raised.Set("max", "100")
+	require.Equal(t, http.StatusForbidden, put(raised.Encode(), "photo"))
+}
@@ -0,0 +1,205 @@
+// Package storage stores listing images and other media as objects addressed by key, in
+// Cloudinary, an S3-compatible bucket or a local directory. Keys are slash-separated
+// relative paths such as "listings/12/40/large.jpg"; the extension determines the content
+// type the object is served with.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PublicPrefix is the prefix of the keys of listing images, which anyone may see. Other
+// objects are only served through signed URLs where the backend can enforce it.
+const PublicPrefix = "listings/"
+
+var (
+	ErrInvalidKey = errors.New("invalid object key")
+	ErrNotFound   = errors.New("object not found")
+	ErrTooLarge   = errors.New("object is too large")
+)
+
+// ImageStore stores objects and hands out URLs for them.
+type ImageStore interface {
+	// Put stores data under key, replacing any existing object, and returns its URL.
+	// Objects outside PublicPrefix may only be readable through a SignedURL.
+	Put(ctx context.Context, key string, data []byte) (string, error)
+	// Delete removes the object under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL for the object that stops working after expiry.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// DirectStore is an ImageStore that clients can upload objects to directly, so large
+// files do not pass through the API server.
+type DirectStore interface {
+	ImageStore
+	// PresignUpload returns how a client can upload one object of at most maxBytes under
+	// key until expiry. Not every backend can enforce maxBytes; check the size with Stat.
+	PresignUpload(ctx context.Context, key string, maxBytes int64, expiry time.Duration) (DirectUpload, error)
+	// Stat returns the size of the object under key, or ErrNotFound.
+	Stat(ctx context.Context, key string) (int64, error)
+	// Get returns the object under key, or ErrTooLarge if it is over maxBytes.
+	Get(ctx context.Context, key string, maxBytes int64) ([]byte, error)
+}
+
+// Transcoder is an ImageStore that can deliver a stored image in another format without
+// storing a copy of it.
+type Transcoder interface {
+	ImageStore
+	// TranscodedURL returns the URL the image stored under key is delivered at in format.
+	TranscodedURL(key, format string) (string, error)
+}
+
+// DirectUpload describes an upload request a client makes. With Fields it is a multipart
+// form POST with the object in the "file" field; otherwise the object is the request body.
+type DirectUpload struct {
+	Method    string
+	URL       string
+	Headers   map[string]string
+	Fields    map[string]string
+	ExpiresAt time.Time
+}
+
+// readLimited reads r, failing with ErrTooLarge past maxBytes.
+func readLimited(r io.Reader, maxBytes int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Backends that can be selected in Config.
+const (
+	BackendLocal      = "local"
+	BackendS3         = "s3"
+	BackendCloudinary = "cloudinary"
+)
+
+// Config selects and configures the image store. Listing images get WebP variants only
+// with BackendCloudinary, which converts the stored JPEGs on delivery; the local and S3
+// backends serve JPEG variants alone.
+type Config struct {
+	Backend string
+
+	// Local backend. Without a SigningKey a random one is made at startup, so signed URLs
+	// and direct upload URLs stop working when the server restarts.
+	LocalDir     string
+	LocalBaseURL string
+	SigningKey   string
+
+	// S3 backend.
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PublicURL string
+	S3PathStyle bool
+
+	// Cloudinary backend.
+	CloudinaryURL string
+}
+
+// ConfigFromEnv reads the image store configuration from the environment. The local
+// backend is the default, so a development setup needs no configuration.
+func ConfigFromEnv(getenv func(string) string) Config {
+	cfg := Config{
+		Backend:       getenv("IMAGE_STORE"),
+		LocalDir:      getenv("MEDIA_DIR"),
+		LocalBaseURL:  getenv("MEDIA_BASE_URL"),
+		SigningKey:    getenv("MEDIA_SIGNING_KEY"),
+		S3Endpoint:    getenv("S3_ENDPOINT"),
+		S3Region:      getenv("S3_REGION"),
+		S3Bucket:      getenv("S3_BUCKET"),
+		S3AccessKey:   getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:   getenv("S3_SECRET_ACCESS_KEY"),
+		S3PublicURL:   getenv("S3_PUBLIC_URL"),
+		CloudinaryURL: getenv("CLOUDINARY_URL"),
+	}
+	cfg.S3PathStyle, _ = strconv.ParseBool(getenv("S3_PATH_STYLE"))
+
+	if cfg.Backend == "" {
+		cfg.Backend = BackendLocal
+	}
+	if cfg.LocalDir == "" {
+		cfg.LocalDir = "assets/media"
+	}
+	if cfg.LocalBaseURL == "" {
+		cfg.LocalBaseURL = "/media/"
+	}
+	if cfg.S3Region == "" {
+		cfg.S3Region = "us-east-1"
+	}
+	return cfg
+}
+
+// New returns the image store selected by cfg.
+func New(cfg Config) (ImageStore, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		key := []byte(cfg.SigningKey)
+		if len(key) == 0 {
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			log.Println("MEDIA_SIGNING_KEY is not set; signed media URLs will stop working when the server restarts")
+		}
+		return NewLocal(cfg.LocalDir, cfg.LocalBaseURL, key)
+	case BackendS3:
+		return NewS3(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.S3PublicURL,
+			PathStyle: cfg.S3PathStyle,
+		})
+	case BackendCloudinary:
+		return NewCloudinary(cfg.CloudinaryURL)
+	default:
+		return nil, fmt.Errorf("unknown image store %q", cfg.Backend)
+	}
+}
+
+// CleanKey checks that key is a relative path that stays inside the store and returns it
+// in canonical form.
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// ContentType returns the content type of the object under key.
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+var (
+	_ DirectStore = (*Local)(nil)
+	_ DirectStore = (*S3)(nil)
+	_ DirectStore = (*Cloudinary)(nil)
+)
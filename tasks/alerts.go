@@ -0,0 +1,241 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/mail"
+	"github.com/weldonkipchirchir/rental_listing/workflow"
+)
+
+const (
+	TypePriceDropAlert        = "alerts:price_drop"
+	TypeListingAvailableAlert = "alerts:listing_available"
+	TypeListingMatchAlert     = "alerts:listing_match"
+	TypeSendAlertDigests      = "alerts:digest"
+)
+
+type PriceDropAlertPayload struct {
+	ListingID int32  `json:"listing_id"`
+	OldPrice  string `json:"old_price"`
+	NewPrice  string `json:"new_price"`
+}
+
+type ListingAlertPayload struct {
+	ListingID int32 `json:"listing_id"`
+}
+
+// AlertStore is the subset of db.Queries used to create and email listing alerts.
+type AlertStore interface {
+	GetListingByID(ctx context.Context, id int32) (db.GetListingByIDRow, error)
+	CreatePriceDropAlerts(ctx context.Context, arg db.CreatePriceDropAlertsParams) (int64, error)
+	CreateListingAvailableAlerts(ctx context.Context, arg db.CreateListingAvailableAlertsParams) (int64, error)
+	CreateSavedSearchAlerts(ctx context.Context, arg db.CreateSavedSearchAlertsParams) (int64, error)
+	GetDueAlertDigests(ctx context.Context, now time.Time) ([]db.GetDueAlertDigestsRow, error)
+	GetPendingListingAlerts(ctx context.Context, userID int32) ([]db.GetPendingListingAlertsRow, error)
+	MarkListingAlertsEmailed(ctx context.Context, arg db.MarkListingAlertsEmailedParams) error
+	SetAlertDigestSent(ctx context.Context, arg db.SetAlertDigestSentParams) error
+}
+
+// ListingAlerts turns listing changes into in-app alerts for the users following them, and
+// emails the alerts in digests. Users who favorited a listing or saved it to a wishlist
+// hear about price drops and the listing becoming available again; users with a matching
+// saved search hear about new listings. Digests go out on the periodic run once the user's
+// frequency allows: every run for instant, otherwise daily or weekly.
+type ListingAlerts struct {
+	store  AlertStore
+	mailer mail.EmailSender
+	now    func() time.Time
+}
+
+func NewListingAlerts(store AlertStore, mailer mail.EmailSender, now func() time.Time) *ListingAlerts {
+	return &ListingAlerts{
+		store:  store,
+		mailer: mailer,
+		now:    now,
+	}
+}
+
+func NewPriceDropAlertTask(listingID int32, oldPrice, newPrice string) (*asynq.Task, error) {
+	payload, err := json.Marshal(PriceDropAlertPayload{
+		ListingID: listingID,
+		OldPrice:  oldPrice,
+		NewPrice:  newPrice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypePriceDropAlert, payload), nil
+}
+
+func NewListingAvailableAlertTask(listingID int32) (*asynq.Task, error) {
+	payload, err := json.Marshal(ListingAlertPayload{ListingID: listingID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeListingAvailableAlert, payload), nil
+}
+
+func NewListingMatchAlertTask(listingID int32) (*asynq.Task, error) {
+	payload, err := json.Marshal(ListingAlertPayload{ListingID: listingID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeListingMatchAlert, payload), nil
+}
+
+func NewSendAlertDigestsTask() *asynq.Task {
+	return asynq.NewTask(TypeSendAlertDigests, nil)
+}
+
+// listing loads the listing an alert task is about. It returns false if the listing has
+// been deleted or taken off the market since the task was enqueued.
+func (a *ListingAlerts) listing(ctx context.Context, id int32) (db.GetListingByIDRow, bool, error) {
+	listing, err := a.store.GetListingByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return listing, false, nil
+	}
+	if err != nil {
+		return listing, false, fmt.Errorf("get listing %d: %w", id, err)
+	}
+	return listing, workflow.Visible(listing.Status), nil
+}
+
+func (a *ListingAlerts) HandlePriceDropAlertTask(ctx context.Context, t *asynq.Task) error {
+	var payload PriceDropAlertPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	listing, ok, err := a.listing(ctx, payload.ListingID)
+	if err != nil || !ok {
+		return err
+	}
+
+	created, err := a.store.CreatePriceDropAlerts(ctx, db.CreatePriceDropAlertsParams{
+		ListingID: listing.ID,
+		Message:   fmt.Sprintf("The price of %q dropped from %s to %s.", listing.Title, payload.OldPrice, payload.NewPrice),
+	})
+	if err != nil {
+		return fmt.Errorf("create price drop alerts for listing %d: %w", listing.ID, err)
+	}
+
+	log.Printf("Created %d price drop alerts for listing %d", created, listing.ID)
+	return nil
+}
+
+func (a *ListingAlerts) HandleListingAvailableAlertTask(ctx context.Context, t *asynq.Task) error {
+	var payload ListingAlertPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	listing, ok, err := a.listing(ctx, payload.ListingID)
+	if err != nil || !ok || !listing.Available.Bool {
+		// The host may have taken the listing off again before the task ran.
+		return err
+	}
+
+	created, err := a.store.CreateListingAvailableAlerts(ctx, db.CreateListingAvailableAlertsParams{
+		ListingID: listing.ID,
+		Message:   fmt.Sprintf("%q is available to book again.", listing.Title),
+	})
+	if err != nil {
+		return fmt.Errorf("create availability alerts for listing %d: %w", listing.ID, err)
+	}
+
+	log.Printf("Created %d availability alerts for listing %d", created, listing.ID)
+	return nil
+}
+
+func (a *ListingAlerts) HandleListingMatchAlertTask(ctx context.Context, t *asynq.Task) error {
+	var payload ListingAlertPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	listing, ok, err := a.listing(ctx, payload.ListingID)
+	if err != nil || !ok || !listing.Available.Bool {
+		return err
+	}
+
+	created, err := a.store.CreateSavedSearchAlerts(ctx, db.CreateSavedSearchAlertsParams{
+		Message:   fmt.Sprintf("New listing %q matches your saved search.", listing.Title),
+		ListingID: listing.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("create saved search alerts for listing %d: %w", listing.ID, err)
+	}
+
+	log.Printf("Created %d saved search alerts for listing %d", created, listing.ID)
+	return nil
+}
+
+func (a *ListingAlerts) HandleSendAlertDigestsTask(ctx context.Context, t *asynq.Task) error {
+	now := a.now()
+
+	users, err := a.store.GetDueAlertDigests(ctx, now)
+	if err != nil {
+		return fmt.Errorf("get due alert digests: %w", err)
+	}
+
+	var sent, failed int
+	for _, user := range users {
+		alerts, err := a.store.GetPendingListingAlerts(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("get pending alerts of user %d: %w", user.ID, err)
+		}
+		if len(alerts) == 0 {
+			continue
+		}
+
+		subject, content := alertDigest(user.Username, alerts)
+		if err := a.mailer.SendEmail(subject, content, []string{user.Email}, nil, nil, nil); err != nil {
+			// Leave the alerts pending so the next run retries this user.
+			failed++
+			log.Printf("Failed to send alert digest to user %d: %v", user.ID, err)
+			continue
+		}
+
+		ids := make([]int32, len(alerts))
+		for i, alert := range alerts {
+			ids[i] = alert.ID
+		}
+		emailedAt := sql.NullTime{Time: now, Valid: true}
+		if err := a.store.MarkListingAlertsEmailed(ctx, db.MarkListingAlertsEmailedParams{EmailedAt: emailedAt, Ids: ids}); err != nil {
+			return fmt.Errorf("mark alerts of user %d emailed: %w", user.ID, err)
+		}
+		if err := a.store.SetAlertDigestSent(ctx, db.SetAlertDigestSentParams{UserID: user.ID, LastDigestAt: emailedAt}); err != nil {
+			return fmt.Errorf("record alert digest of user %d: %w", user.ID, err)
+		}
+		sent++
+	}
+
+	if sent > 0 || failed > 0 {
+		log.Printf("Sent %d alert digests, %d failed", sent, failed)
+	}
+	return nil
+}
+
+func alertDigest(username string, alerts []db.GetPendingListingAlertsRow) (string, string) {
+	subject := "1 update on listings you follow"
+	if len(alerts) > 1 {
+		subject = fmt.Sprintf("%d updates on listings you follow", len(alerts))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,<br/><br/>Here is what changed on listings you follow:<ul>", html.EscapeString(username))
+	for _, alert := range alerts {
+		fmt.Fprintf(&b, "<li>%s <i>(%s)</i></li>", html.EscapeString(alert.Message), alert.CreatedAt.Format("2006-01-02"))
+	}
+	b.WriteString("</ul>You can change how often you get these emails in your alert preferences.")
+	return subject, b.String()
+}
@@ -0,0 +1,162 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+type fakeAlertStore struct {
+	listings  map[int32]db.GetListingByIDRow
+	priceDrop []db.CreatePriceDropAlertsParams
+	available []db.CreateListingAvailableAlertsParams
+	matches   []db.CreateSavedSearchAlertsParams
+	due       []db.GetDueAlertDigestsRow
+	pending   map[int32][]db.GetPendingListingAlertsRow
+	emailed   []db.MarkListingAlertsEmailedParams
+	digests   []db.SetAlertDigestSentParams
+}
+
+func (f *fakeAlertStore) GetListingByID(ctx context.Context, id int32) (db.GetListingByIDRow, error) {
+	listing, ok := f.listings[id]
+	if !ok {
+		return db.GetListingByIDRow{}, sql.ErrNoRows
+	}
+	return listing, nil
+}
+
+func (f *fakeAlertStore) CreatePriceDropAlerts(ctx context.Context, arg db.CreatePriceDropAlertsParams) (int64, error) {
+	f.priceDrop = append(f.priceDrop, arg)
+	return 1, nil
+}
+
+func (f *fakeAlertStore) CreateListingAvailableAlerts(ctx context.Context, arg db.CreateListingAvailableAlertsParams) (int64, error) {
+	f.available = append(f.available, arg)
+	return 1, nil
+}
+
+func (f *fakeAlertStore) CreateSavedSearchAlerts(ctx context.Context, arg db.CreateSavedSearchAlertsParams) (int64, error) {
+	f.matches = append(f.matches, arg)
+	return 1, nil
+}
+
+func (f *fakeAlertStore) GetDueAlertDigests(ctx context.Context, now time.Time) ([]db.GetDueAlertDigestsRow, error) {
+	return f.due, nil
+}
+
+func (f *fakeAlertStore) GetPendingListingAlerts(ctx context.Context, userID int32) ([]db.GetPendingListingAlertsRow, error) {
+	return f.pending[userID], nil
+}
+
+func (f *fakeAlertStore) MarkListingAlertsEmailed(ctx context.Context, arg db.MarkListingAlertsEmailedParams) error {
+	f.emailed = append(f.emailed, arg)
+	return nil
+}
+
+func (f *fakeAlertStore) SetAlertDigestSent(ctx context.Context, arg db.SetAlertDigestSentParams) error {
+	f.digests = append(f.digests, arg)
+	return nil
+}
+
+type sentEmail struct {
+	subject string
+	content string
+	to      []string
+}
+
+type fakeMailer struct {
+	sent   []sentEmail
+	failTo string
+}
+
+func (f *fakeMailer) SendEmail(subject string, content string, to []string, cc []string, bcc []string, attachFiles []string) error {
+	if len(to) > 0 && to[0] == f.failTo {
+		return errors.New("smtp unavailable")
+	}
+	f.sent = append(f.sent, sentEmail{subject: subject, content: content, to: to})
+	return nil
+}
+
+func (f *fakeMailer) SendVerificationEmail(toEmail string, verificationLink string, username string) error {
+	return nil
+}
+
+func TestListingAlertTasks(t *testing.T) {
+	store := &fakeAlertStore{listings: map[int32]db.GetListingByIDRow{
+		1: {ID: 1, Title: "Loft", Available: sql.NullBool{Bool: true, Valid: true}, Status: "published"},
+		2: {ID: 2, Title: "Cabin", Available: sql.NullBool{Bool: false, Valid: true}, Status: "published"},
+		4: {ID: 4, Title: "Villa", Available: sql.NullBool{Bool: true, Valid: true}, Status: "paused"},
+	}}
+	alerts := NewListingAlerts(store, &fakeMailer{}, time.Now)
+	ctx := context.Background()
+
+	task, err := NewPriceDropAlertTask(1, "120.00", "95.00")
+	require.NoError(t, err)
+	require.NoError(t, alerts.HandlePriceDropAlertTask(ctx, task))
+	require.Equal(t, []db.CreatePriceDropAlertsParams{
+		{ListingID: 1, Message: `The price of "Loft" dropped from 120.00 to 95.00.`},
+	}, store.priceDrop)
+
+	task, err = NewListingAvailableAlertTask(1)
+	require.NoError(t, err)
+	require.NoError(t, alerts.HandleListingAvailableAlertTask(ctx, task))
+	task, err = NewListingMatchAlertTask(1)
+	require.NoError(t, err)
+	require.NoError(t, alerts.HandleListingMatchAlertTask(ctx, task))
+
+	// Listings that are unavailable again, gone or taken off the market by the time the
+	// task runs are skipped.
+	for _, id := range []int32{2, 3, 4} {
+		task, err = NewListingAvailableAlertTask(id)
+		require.NoError(t, err)
+		require.NoError(t, alerts.HandleListingAvailableAlertTask(ctx, task))
+		task, err = NewListingMatchAlertTask(id)
+		require.NoError(t, err)
+		require.NoError(t, alerts.HandleListingMatchAlertTask(ctx, task))
+	}
+
+	require.Equal(t, []db.CreateListingAvailableAlertsParams{
+		{ListingID: 1, Message: `"Loft" is available to book again.`},
+	}, store.available)
+	require.Equal(t, []db.CreateSavedSearchAlertsParams{
+		{Message: `New listing "Loft" matches your saved search.`, ListingID: 1},
+	}, store.matches)
+}
+
+func TestHandleSendAlertDigestsTask(t *testing.T) {
+	now := time.Date(2024, time.June, 20, 12, 0, 0, 0, time.UTC)
+	store := &fakeAlertStore{
+		due: []db.GetDueAlertDigestsRow{
+			{ID: 1, Username: "ann", Email: "ann@example.com"},
+			{ID: 2, Username: "bob", Email: "bob@example.com"},
+			{ID: 3, Username: "cat", Email: "cat@example.com"},
+		},
+		pending: map[int32][]db.GetPendingListingAlertsRow{
+			1: {
+				{ID: 10, Message: `The price of "Loft" dropped from 120.00 to 95.00.`, CreatedAt: now},
+				{ID: 11, Message: `"Cabin" is available to book again.`, CreatedAt: now},
+			},
+			2: {{ID: 12, Message: `New listing "Loft" matches your saved search.`, CreatedAt: now}},
+		},
+	}
+	mailer := &fakeMailer{failTo: "bob@example.com"}
+	alerts := NewListingAlerts(store, mailer, func() time.Time { return now })
+
+	require.NoError(t, alerts.HandleSendAlertDigestsTask(context.Background(), NewSendAlertDigestsTask()))
+
+	// ann gets one email with both alerts, bob's failed send leaves his alert pending and
+	// cat has nothing left to send.
+	require.Len(t, mailer.sent, 1)
+	require.Equal(t, []string{"ann@example.com"}, mailer.sent[0].to)
+	require.Equal(t, "2 updates on listings you follow", mailer.sent[0].subject)
+	require.Contains(t, mailer.sent[0].content, "The price of &#34;Loft&#34; dropped from 120.00 to 95.00.")
+
+	emailedAt := sql.NullTime{Time: now, Valid: true}
+	require.Equal(t, []db.MarkListingAlertsEmailedParams{{EmailedAt: emailedAt, Ids: []int32{10, 11}}}, store.emailed)
+	require.Equal(t, []db.SetAlertDigestSentParams{{UserID: 1, LastDigestAt: emailedAt}}, store.digests)
+}
@@ -0,0 +1,205 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+const (
+	TypeExpirePendingBookings = "booking:expire_pending"
+	TypeCompleteBookings      = "booking:complete"
+)
+
+// DefaultPendingHoldWindow is how long an unpaid pending booking holds the listing before it expires.
+const DefaultPendingHoldWindow = 30 * time.Minute
+
+// BookingStore is the subset of db.Queries used by the booking lifecycle tasks.
+type BookingStore interface {
+	ExpireUnpaidPendingBookings(ctx context.Context, cutoff time.Time) ([]db.ExpireUnpaidPendingBookingsRow, error)
+	GetUnapprovedBookings(ctx context.Context, now time.Time) ([]int32, error)
+	ExpireUnapprovedBookings(ctx context.Context, now time.Time) ([]db.ExpireUnapprovedBookingsRow, error)
+	CompleteFinishedBookings(ctx context.Context, now time.Time) ([]db.CompleteFinishedBookingsRow, error)
+	GetBookingParties(ctx context.Context, id int32) (db.GetBookingPartiesRow, error)
+	GetListingHosts(ctx context.Context, arg db.GetListingHostsParams) ([]int32, error)
+	GetPaymentsByBookingID(ctx context.Context, bookingID int32) ([]db.GetPaymentsByBookingIDRow, error)
+	UpdatePaymentStatus(ctx context.Context, arg db.UpdatePaymentStatusParams) error
+}
+
+// Enqueuer is implemented by *asynq.Client.
+type Enqueuer interface {
+	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+}
+
+// LivePublisher is implemented by *realtime.Hub.
+type LivePublisher interface {
+	Publish(ctx context.Context, r realtime.Recipient, eventType string, data any) error
+}
+
+// BookingLifecycle expires unpaid pending bookings and booking requests the host did not
+// approve in time, and completes stays past checkout, asking the guest for a review. All
+// handlers only touch bookings that are still in the source status, so re-running them is
+// safe. The status change and re-opening the listing happen in one statement; the
+// notifications are enqueued after it, de-duplicated by task ID.
+type BookingLifecycle struct {
+	store      BookingStore
+	client     Enqueuer
+	payments   payment.Gateway
+	live       LivePublisher
+	now        func() time.Time
+	holdWindow time.Duration
+}
+
+func NewBookingLifecycle(store BookingStore, client Enqueuer, payments payment.Gateway, live LivePublisher, now func() time.Time, holdWindow time.Duration) *BookingLifecycle {
+	return &BookingLifecycle{
+		store:      store,
+		client:     client,
+		payments:   payments,
+		live:       live,
+		now:        now,
+		holdWindow: holdWindow,
+	}
+}
+
+func NewExpirePendingBookingsTask() *asynq.Task {
+	return asynq.NewTask(TypeExpirePendingBookings, nil)
+}
+
+func NewCompleteBookingsTask() *asynq.Task {
+	return asynq.NewTask(TypeCompleteBookings, nil)
+}
+
+func (b *BookingLifecycle) HandleExpirePendingBookingsTask(ctx context.Context, t *asynq.Task) error {
+	cutoff := b.now().Add(-b.holdWindow)
+
+	bookings, err := b.store.ExpireUnpaidPendingBookings(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("expire pending bookings: %w", err)
+	}
+
+	// A booking that fails to notify does not hold back the others; its status has already
+	// changed, so a retry would not pick it up again.
+	var errs []error
+	for _, booking := range bookings {
+		errs = append(errs, b.settle(ctx, booking.ID, "expired", notify.BookingExpired))
+	}
+
+	// The card holds of booking requests are released before they expire, so a failed
+	// cancel leaves the booking pending and is retried on the next run.
+	now := b.now()
+	unapproved, err := b.store.GetUnapprovedBookings(ctx, now)
+	if err != nil {
+		return fmt.Errorf("get unapproved bookings: %w", err)
+	}
+	for _, id := range unapproved {
+		errs = append(errs, b.releaseAuthorizations(ctx, id))
+	}
+
+	requests, err := b.store.ExpireUnapprovedBookings(ctx, now)
+	if err != nil {
+		return fmt.Errorf("expire unapproved bookings: %w", err)
+	}
+
+	for _, booking := range requests {
+		errs = append(errs, b.settle(ctx, booking.ID, "expired", notify.BookingExpired))
+	}
+
+	log.Printf("Expired %d unpaid and %d unapproved bookings", len(bookings), len(requests))
+	return errors.Join(errs...)
+}
+
+func (b *BookingLifecycle) HandleCompleteBookingsTask(ctx context.Context, t *asynq.Task) error {
+	bookings, err := b.store.CompleteFinishedBookings(ctx, b.now())
+	if err != nil {
+		return fmt.Errorf("complete bookings: %w", err)
+	}
+
+	var errs []error
+	for _, booking := range bookings {
+		errs = append(errs, b.settle(ctx, booking.ID, "completed", notify.BookingCompleted))
+		errs = append(errs, EnqueueBookingEvent(b.client, notify.ReviewRequest, booking.ID))
+	}
+
+	log.Printf("Completed %d bookings past checkout", len(bookings))
+	return errors.Join(errs...)
+}
+
+// releaseAuthorizations cancels card holds that were never captured for the booking.
+func (b *BookingLifecycle) releaseAuthorizations(ctx context.Context, bookingID int32) error {
+	payments, err := b.store.GetPaymentsByBookingID(ctx, bookingID)
+	if err != nil {
+		return fmt.Errorf("get booking %d payments: %w", bookingID, err)
+	}
+
+	for _, p := range payments {
+		if p.Status.String != "authorized" || !p.TransactionID.Valid {
+			continue
+		}
+		if _, err := b.payments.Cancel(ctx, p.TransactionID.String); err != nil {
+			return fmt.Errorf("cancel payment %d: %w", p.ID, err)
+		}
+		err := b.store.UpdatePaymentStatus(ctx, db.UpdatePaymentStatusParams{
+			ID:     p.ID,
+			Status: sql.NullString{String: "cancelled", Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("update payment %d status: %w", p.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// settle notifies the guest and the hosts about the new status of a booking, with the
+// notifications of category and a live status update.
+func (b *BookingLifecycle) settle(ctx context.Context, bookingID int32, status, category string) error {
+	if err := EnqueueBookingEvent(b.client, category, bookingID); err != nil {
+		return err
+	}
+
+	parties, err := b.store.GetBookingParties(ctx, bookingID)
+	if err != nil {
+		return fmt.Errorf("get booking %d parties: %w", bookingID, err)
+	}
+	b.publishStatus(ctx, parties, status)
+	return nil
+}
+
+// publishStatus pushes the new status of a booking to the guest and the hosts who can see
+// its bookings. Clients that miss it see the status when they next load the booking, so
+// failures are only logged.
+func (b *BookingLifecycle) publishStatus(ctx context.Context, parties db.GetBookingPartiesRow, status string) {
+	recipients := []realtime.Recipient{realtime.User(parties.UserID)}
+	hosts, err := b.store.GetListingHosts(ctx, db.GetListingHostsParams{
+		ID:    parties.ListingID,
+		Roles: team.RolesWith(team.ViewBookings),
+	})
+	if err != nil {
+		log.Printf("Failed to load hosts of listing %d: %v", parties.ListingID, err)
+	}
+	for _, adminID := range hosts {
+		recipients = append(recipients, realtime.Admin(adminID))
+	}
+
+	data := realtime.BookingStatus{
+		BookingID: parties.ID,
+		ListingID: parties.ListingID,
+		Title:     parties.Title,
+		Status:    status,
+	}
+	for _, r := range recipients {
+		if err := b.live.Publish(ctx, r, realtime.EventBookingStatus, data); err != nil {
+			log.Printf("Failed to publish booking %d status to %s: %v", parties.ID, r, err)
+		}
+	}
+}
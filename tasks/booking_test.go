@@ -0,0 +1,351 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/payment"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+)
+
+type fakeBooking struct {
+	db.Booking
+	paid bool
+}
+
+type fakeBookingStore struct {
+	bookings map[int32]*fakeBooking
+	payments []db.GetPaymentsByBookingIDRow
+	reopened []int32
+}
+
+func (s *fakeBookingStore) ExpireUnpaidPendingBookings(ctx context.Context, cutoff time.Time) ([]db.ExpireUnpaidPendingBookingsRow, error) {
+	var rows []db.ExpireUnpaidPendingBookingsRow
+	for _, b := range s.bookings {
+		if b.Status.String == "pending" && !b.paid && b.CreatedAt.Time.Before(cutoff) {
+			b.Status = sql.NullString{String: "expired", Valid: true}
+			s.reopen(b.ListingID)
+			rows = append(rows, db.ExpireUnpaidPendingBookingsRow{ID: b.ID, ListingID: b.ListingID, Status: b.Status})
+		}
+	}
+	return rows, nil
+}
+
+func (s *fakeBookingStore) GetUnapprovedBookings(ctx context.Context, now time.Time) ([]int32, error) {
+	var ids []int32
+	for _, b := range s.bookings {
+		if b.Status.String == "pending" && b.ApprovalDeadline.Valid && b.ApprovalDeadline.Time.Before(now) {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *fakeBookingStore) ExpireUnapprovedBookings(ctx context.Context, now time.Time) ([]db.ExpireUnapprovedBookingsRow, error) {
+	var rows []db.ExpireUnapprovedBookingsRow
+	for _, b := range s.bookings {
+		if b.Status.String == "pending" && b.ApprovalDeadline.Valid && b.ApprovalDeadline.Time.Before(now) && !s.authorized(b.ID) {
+			b.Status = sql.NullString{String: "expired", Valid: true}
+			s.reopen(b.ListingID)
+			rows = append(rows, db.ExpireUnapprovedBookingsRow{ID: b.ID, ListingID: b.ListingID, Status: b.Status})
+		}
+	}
+	return rows, nil
+}
+
+func (s *fakeBookingStore) authorized(bookingID int32) bool {
+	for _, p := range s.payments {
+		if p.BookingID == bookingID && p.Status.String == "authorized" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fakeBookingStore) GetPaymentsByBookingID(ctx context.Context, bookingID int32) ([]db.GetPaymentsByBookingIDRow, error) {
+	var rows []db.GetPaymentsByBookingIDRow
+	for _, p := range s.payments {
+		if p.BookingID == bookingID {
+			rows = append(rows, p)
+		}
+	}
+	return rows, nil
+}
+
+func (s *fakeBookingStore) UpdatePaymentStatus(ctx context.Context, arg db.UpdatePaymentStatusParams) error {
+	for i := range s.payments {
+		if s.payments[i].ID == arg.ID {
+			s.payments[i].Status = arg.Status
+		}
+	}
+	return nil
+}
+
+func (s *fakeBookingStore) CompleteFinishedBookings(ctx context.Context, now time.Time) ([]db.CompleteFinishedBookingsRow, error) {
+	var rows []db.CompleteFinishedBookingsRow
+	for _, b := range s.bookings {
+		if b.Status.String == "confirmed" && b.CheckOutDate.Before(now) {
+			b.Status = sql.NullString{String: "completed", Valid: true}
+			s.reopen(b.ListingID)
+			rows = append(rows, db.CompleteFinishedBookingsRow{ID: b.ID, ListingID: b.ListingID, Status: b.Status})
+		}
+	}
+	return rows, nil
+}
+
+func (s *fakeBookingStore) GetBookingParties(ctx context.Context, id int32) (db.GetBookingPartiesRow, error) {
+	b, ok := s.bookings[id]
+	if !ok {
+		return db.GetBookingPartiesRow{}, sql.ErrNoRows
+	}
+	return db.GetBookingPartiesRow{
+		ID:            b.ID,
+		ListingID:     b.ListingID,
+		Title:         "Beach house",
+		CheckInDate:   b.CheckInDate,
+		CheckOutDate:  b.CheckOutDate,
+		Status:        b.Status,
+		UserID:        b.UserID,
+		UserUsername:  "guest",
+		UserEmail:     "guest@email.com",
+		AdminUsername: "host",
+		AdminEmail:    "host@email.com",
+	}, nil
+}
+
+func (s *fakeBookingStore) GetListingHosts(ctx context.Context, arg db.GetListingHostsParams) ([]int32, error) {
+	return []int32{50}, nil
+}
+
+// reopen records that the listing was re-opened with the status change, unless another
+// booking still holds it.
+func (s *fakeBookingStore) reopen(listingID int32) {
+	for _, b := range s.bookings {
+		if b.ListingID == listingID && (b.Status.String == "pending" || b.Status.String == "confirmed") {
+			return
+		}
+	}
+	s.reopened = append(s.reopened, listingID)
+}
+
+type fakeEnqueuer struct {
+	ids     map[string]bool
+	tasks   []*asynq.Task
+	options [][]asynq.Option
+}
+
+func (e *fakeEnqueuer) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if e.ids == nil {
+		e.ids = map[string]bool{}
+	}
+	for _, opt := range opts {
+		if opt.Type() == asynq.TaskIDOpt {
+			id := opt.Value().(string)
+			if e.ids[id] {
+				return nil, asynq.ErrTaskIDConflict
+			}
+			e.ids[id] = true
+		}
+	}
+	e.tasks = append(e.tasks, task)
+	e.options = append(e.options, opts)
+	return &asynq.TaskInfo{}, nil
+}
+
+type liveEvent struct {
+	recipient realtime.Recipient
+	eventType string
+	data      any
+}
+
+type fakeLivePublisher struct {
+	events []liveEvent
+}
+
+func (p *fakeLivePublisher) Publish(ctx context.Context, r realtime.Recipient, eventType string, data any) error {
+	p.events = append(p.events, liveEvent{recipient: r, eventType: eventType, data: data})
+	return nil
+}
+
+type fakeGateway struct {
+	cancelled []string
+	cancelErr error
+}
+
+func (g *fakeGateway) CreateIntent(ctx context.Context, amount int64, capture payment.CaptureMethod) (*payment.Intent, error) {
+	return &payment.Intent{ID: "pi_new", Amount: amount}, nil
+}
+
+func (g *fakeGateway) GetIntent(ctx context.Context, id string) (*payment.Intent, error) {
+	return &payment.Intent{ID: id}, nil
+}
+
+func (g *fakeGateway) Capture(ctx context.Context, id, idempotencyKey string) (*payment.Intent, error) {
+	return &payment.Intent{ID: id, Status: payment.StatusSucceeded}, nil
+}
+
+func (g *fakeGateway) Cancel(ctx context.Context, id string) (*payment.Intent, error) {
+	if g.cancelErr != nil {
+		return nil, g.cancelErr
+	}
+	g.cancelled = append(g.cancelled, id)
+	return &payment.Intent{ID: id, Status: payment.StatusCanceled}, nil
+}
+
+func (g *fakeGateway) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*payment.Refund, error) {
+	return &payment.Refund{ID: "re_new", IntentID: intentID, Amount: amount}, nil
+}
+
+func newFakeBooking(id, listingID int32, status string, createdAt, checkOut time.Time, paid bool) *fakeBooking {
+	return &fakeBooking{
+		Booking: db.Booking{
+			ID:           id,
+			UserID:       100 + id,
+			ListingID:    listingID,
+			CheckInDate:  checkOut.AddDate(0, 0, -3),
+			CheckOutDate: checkOut,
+			Status:       sql.NullString{String: status, Valid: true},
+			CreatedAt:    sql.NullTime{Time: createdAt, Valid: true},
+		},
+		paid: paid,
+	}
+}
+
+func TestExpirePendingBookings(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	store := &fakeBookingStore{bookings: map[int32]*fakeBooking{
+		1: newFakeBooking(1, 10, "pending", now.Add(-2*time.Hour), now.AddDate(0, 0, 7), false),
+		2: newFakeBooking(2, 11, "pending", now.Add(-10*time.Minute), now.AddDate(0, 0, 7), false),
+		3: newFakeBooking(3, 12, "pending", now.Add(-2*time.Hour), now.AddDate(0, 0, 7), true),
+	}}
+	client := &fakeEnqueuer{}
+	live := &fakeLivePublisher{}
+	lifecycle := NewBookingLifecycle(store, client, &fakeGateway{}, live, func() time.Time { return now }, 30*time.Minute)
+
+	err := lifecycle.HandleExpirePendingBookingsTask(context.Background(), NewExpirePendingBookingsTask())
+	require.NoError(t, err)
+
+	require.Equal(t, "expired", store.bookings[1].Status.String)
+	require.Equal(t, "pending", store.bookings[2].Status.String)
+	require.Equal(t, "pending", store.bookings[3].Status.String)
+	require.Equal(t, []int32{10}, store.reopened)
+	require.Len(t, client.tasks, 1)
+	require.Equal(t, TypeBookingEvent, client.tasks[0].Type())
+	var event BookingEventPayload
+	require.NoError(t, json.Unmarshal(client.tasks[0].Payload(), &event))
+	require.Equal(t, BookingEventPayload{Category: notify.BookingExpired, BookingID: 1}, event)
+
+	// The guest and the host see the new status live.
+	require.Len(t, live.events, 2)
+	require.Equal(t, realtime.User(101), live.events[0].recipient)
+	require.Equal(t, realtime.Admin(50), live.events[1].recipient)
+	for _, e := range live.events {
+		require.Equal(t, realtime.EventBookingStatus, e.eventType)
+		require.Equal(t, realtime.BookingStatus{BookingID: 1, ListingID: 10, Title: "Beach house", Status: "expired"}, e.data)
+	}
+
+	// Running again must not touch the booking or notify twice.
+	err = lifecycle.HandleExpirePendingBookingsTask(context.Background(), NewExpirePendingBookingsTask())
+	require.NoError(t, err)
+	require.Len(t, client.tasks, 1)
+	require.Len(t, live.events, 2)
+	require.Equal(t, []int32{10}, store.reopened)
+}
+
+func TestExpireUnapprovedBookings(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	late := newFakeBooking(1, 10, "pending", now.Add(-30*time.Hour), now.AddDate(0, 0, 7), true)
+	late.ApprovalDeadline = sql.NullTime{Time: now.Add(-6 * time.Hour), Valid: true}
+	waiting := newFakeBooking(2, 11, "pending", now.Add(-2*time.Hour), now.AddDate(0, 0, 7), true)
+	waiting.ApprovalDeadline = sql.NullTime{Time: now.Add(22 * time.Hour), Valid: true}
+
+	store := &fakeBookingStore{
+		bookings: map[int32]*fakeBooking{1: late, 2: waiting},
+		payments: []db.GetPaymentsByBookingIDRow{
+			{ID: 100, BookingID: 1, Status: sql.NullString{String: "authorized", Valid: true}, TransactionID: sql.NullString{String: "pi_1", Valid: true}},
+			{ID: 101, BookingID: 2, Status: sql.NullString{String: "authorized", Valid: true}, TransactionID: sql.NullString{String: "pi_2", Valid: true}},
+		},
+	}
+	gateway := &fakeGateway{}
+	client := &fakeEnqueuer{}
+	lifecycle := NewBookingLifecycle(store, client, gateway, &fakeLivePublisher{}, func() time.Time { return now }, DefaultPendingHoldWindow)
+
+	err := lifecycle.HandleExpirePendingBookingsTask(context.Background(), NewExpirePendingBookingsTask())
+	require.NoError(t, err)
+
+	require.Equal(t, "expired", late.Status.String)
+	require.Equal(t, "pending", waiting.Status.String)
+	require.Equal(t, []string{"pi_1"}, gateway.cancelled)
+	require.Equal(t, "cancelled", store.payments[0].Status.String)
+	require.Equal(t, "authorized", store.payments[1].Status.String)
+	require.Len(t, client.tasks, 1)
+}
+
+func TestExpireUnapprovedBookingCancelFails(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	late := newFakeBooking(1, 10, "pending", now.Add(-30*time.Hour), now.AddDate(0, 0, 7), true)
+	late.ApprovalDeadline = sql.NullTime{Time: now.Add(-6 * time.Hour), Valid: true}
+
+	store := &fakeBookingStore{
+		bookings: map[int32]*fakeBooking{1: late},
+		payments: []db.GetPaymentsByBookingIDRow{
+			{ID: 100, BookingID: 1, Status: sql.NullString{String: "authorized", Valid: true}, TransactionID: sql.NullString{String: "pi_1", Valid: true}},
+		},
+	}
+	gateway := &fakeGateway{cancelErr: errors.New("stripe unavailable")}
+	client := &fakeEnqueuer{}
+	lifecycle := NewBookingLifecycle(store, client, gateway, &fakeLivePublisher{}, func() time.Time { return now }, DefaultPendingHoldWindow)
+
+	// The hold is still on the card, so the booking stays pending for the next run.
+	err := lifecycle.HandleExpirePendingBookingsTask(context.Background(), NewExpirePendingBookingsTask())
+	require.Error(t, err)
+	require.Equal(t, "pending", late.Status.String)
+	require.Equal(t, "authorized", store.payments[0].Status.String)
+	require.Empty(t, client.tasks)
+
+	gateway.cancelErr = nil
+	err = lifecycle.HandleExpirePendingBookingsTask(context.Background(), NewExpirePendingBookingsTask())
+	require.NoError(t, err)
+	require.Equal(t, "expired", late.Status.String)
+	require.Equal(t, "cancelled", store.payments[0].Status.String)
+	require.Len(t, client.tasks, 1)
+}
+
+func TestCompleteBookings(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	store := &fakeBookingStore{bookings: map[int32]*fakeBooking{
+		1: newFakeBooking(1, 10, "confirmed", now.AddDate(0, 0, -10), now.AddDate(0, 0, -1), true),
+		2: newFakeBooking(2, 11, "confirmed", now.AddDate(0, 0, -10), now.AddDate(0, 0, 2), true),
+		3: newFakeBooking(3, 12, "cancelled", now.AddDate(0, 0, -10), now.AddDate(0, 0, -1), true),
+	}}
+	client := &fakeEnqueuer{}
+	clock := now
+	lifecycle := NewBookingLifecycle(store, client, &fakeGateway{}, &fakeLivePublisher{}, func() time.Time { return clock }, DefaultPendingHoldWindow)
+
+	err := lifecycle.HandleCompleteBookingsTask(context.Background(), NewCompleteBookingsTask())
+	require.NoError(t, err)
+	require.Equal(t, "completed", store.bookings[1].Status.String)
+	require.Equal(t, "confirmed", store.bookings[2].Status.String)
+	require.Equal(t, "cancelled", store.bookings[3].Status.String)
+	// The completed stay for the hosts, and the review request for the guest.
+	require.Len(t, client.tasks, 2)
+	for _, task := range client.tasks {
+		require.Equal(t, TypeBookingEvent, task.Type())
+	}
+
+	// Advance the clock past the second checkout.
+	clock = now.AddDate(0, 0, 3)
+	err = lifecycle.HandleCompleteBookingsTask(context.Background(), NewCompleteBookingsTask())
+	require.NoError(t, err)
+	require.Equal(t, "completed", store.bookings[2].Status.String)
+	require.ElementsMatch(t, []int32{10, 11}, store.reopened)
+	require.Len(t, client.tasks, 4)
+}
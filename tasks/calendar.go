@@ -0,0 +1,131 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/ical"
+)
+
+const TypeSyncCalendars = "calendar:sync"
+
+// maxCalendarSize caps how much of an external calendar feed is read.
+const maxCalendarSize = 5 << 20
+
+// CalendarStore is the subset of db.Store used to import external calendars.
+type CalendarStore interface {
+	ListCalendarFeeds(ctx context.Context) ([]db.CalendarFeed, error)
+	ReplaceFeedBlockedDatesTx(ctx context.Context, feedID sql.NullInt32, dates []db.CreateBlockedDateParams) error
+	UpdateCalendarFeedSync(ctx context.Context, arg db.UpdateCalendarFeedSyncParams) error
+}
+
+// CalendarSync imports the external iCal feeds registered for listings as blocked dates.
+// Each feed's blocked dates are replaced on every run; a feed that cannot be fetched keeps
+// its previous dates and records the error.
+type CalendarSync struct {
+	store  CalendarStore
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewCalendarSync(store CalendarStore, client *http.Client, now func() time.Time) *CalendarSync {
+	return &CalendarSync{
+		store:  store,
+		client: client,
+		now:    now,
+	}
+}
+
+func NewSyncCalendarsTask() *asynq.Task {
+	return asynq.NewTask(TypeSyncCalendars, nil)
+}
+
+func (s *CalendarSync) HandleSyncCalendarsTask(ctx context.Context, t *asynq.Task) error {
+	feeds, err := s.store.ListCalendarFeeds(ctx)
+	if err != nil {
+		return fmt.Errorf("list calendar feeds: %w", err)
+	}
+
+	var failed int
+	for _, feed := range feeds {
+		arg := db.UpdateCalendarFeedSyncParams{
+			ID:           feed.ID,
+			LastSyncedAt: feed.LastSyncedAt,
+		}
+
+		if err := s.syncFeed(ctx, feed); err != nil {
+			failed++
+			log.Printf("Failed to sync calendar feed %d: %v", feed.ID, err)
+			arg.LastError = sql.NullString{String: err.Error(), Valid: true}
+		} else {
+			arg.LastSyncedAt = sql.NullTime{Time: s.now(), Valid: true}
+		}
+
+		if err := s.store.UpdateCalendarFeedSync(ctx, arg); err != nil {
+			return fmt.Errorf("update calendar feed %d: %w", feed.ID, err)
+		}
+	}
+
+	log.Printf("Synced %d calendar feeds, %d failed", len(feeds)-failed, failed)
+	return nil
+}
+
+func (s *CalendarSync) syncFeed(ctx context.Context, feed db.CalendarFeed) error {
+	events, err := s.fetch(ctx, feed.Url)
+	if err != nil {
+		return err
+	}
+
+	feedID := sql.NullInt32{Int32: feed.ID, Valid: true}
+	today := s.now().UTC().Truncate(24 * time.Hour)
+	var dates []db.CreateBlockedDateParams
+	for _, event := range events {
+		if !event.End.After(today) {
+			continue
+		}
+
+		dates = append(dates, db.CreateBlockedDateParams{
+			ListingID: feed.ListingID,
+			FeedID:    feedID,
+			StartDate: event.Start,
+			EndDate:   event.End,
+			Summary:   sql.NullString{String: truncate(event.Summary, 255), Valid: event.Summary != ""},
+			Uid:       sql.NullString{String: truncate(event.UID, 255), Valid: event.UID != ""},
+		})
+	}
+
+	return s.store.ReplaceFeedBlockedDatesTx(ctx, feedID, dates)
+}
+
+func (s *CalendarSync) fetch(ctx context.Context, url string) ([]ical.Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return ical.Parse(io.LimitReader(res.Body, maxCalendarSize))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
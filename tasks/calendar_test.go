@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+type fakeCalendarStore struct {
+	feeds   []db.CalendarFeed
+	blocked []db.CreateBlockedDateParams
+}
+
+func (s *fakeCalendarStore) ListCalendarFeeds(ctx context.Context) ([]db.CalendarFeed, error) {
+	return s.feeds, nil
+}
+
+func (s *fakeCalendarStore) ReplaceFeedBlockedDatesTx(ctx context.Context, feedID sql.NullInt32, dates []db.CreateBlockedDateParams) error {
+	var kept []db.CreateBlockedDateParams
+	for _, b := range s.blocked {
+		if b.FeedID != feedID {
+			kept = append(kept, b)
+		}
+	}
+	s.blocked = append(kept, dates...)
+	return nil
+}
+
+func (s *fakeCalendarStore) UpdateCalendarFeedSync(ctx context.Context, arg db.UpdateCalendarFeedSyncParams) error {
+	for i := range s.feeds {
+		if s.feeds[i].ID == arg.ID {
+			s.feeds[i].LastSyncedAt = arg.LastSyncedAt
+			s.feeds[i].LastError = arg.LastError
+		}
+	}
+	return nil
+}
+
+func TestSyncCalendars(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir("../ical/testdata")))
+	defer srv.Close()
+
+	now := time.Date(2024, time.June, 20, 9, 0, 0, 0, time.UTC)
+	store := &fakeCalendarStore{feeds: []db.CalendarFeed{
+		{ID: 1, ListingID: 10, Name: "Airbnb", Url: srv.URL + "/airbnb.ics"},
+		{ID: 2, ListingID: 11, Name: "Booking.com", Url: srv.URL + "/booking.ics"},
+		{ID: 3, ListingID: 12, Name: "Broken", Url: srv.URL + "/missing.ics"},
+	}}
+	sync := NewCalendarSync(store, srv.Client(), func() time.Time { return now })
+
+	err := sync.HandleSyncCalendarsTask(context.Background(), NewSyncCalendarsTask())
+	require.NoError(t, err)
+
+	// The Airbnb stay in the past is skipped, the July block is imported.
+	var airbnb, booking int
+	for _, b := range store.blocked {
+		switch b.ListingID {
+		case 10:
+			airbnb++
+			require.Equal(t, time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC), b.StartDate)
+		case 11:
+			booking++
+		}
+	}
+	require.Equal(t, 1, airbnb)
+	require.Equal(t, 2, booking)
+
+	require.True(t, store.feeds[0].LastSyncedAt.Valid)
+	require.False(t, store.feeds[0].LastError.Valid)
+	require.False(t, store.feeds[2].LastSyncedAt.Valid)
+	require.Contains(t, store.feeds[2].LastError.String, "404")
+
+	// Re-running replaces the imported dates instead of duplicating them.
+	err = sync.HandleSyncCalendarsTask(context.Background(), NewSyncCalendarsTask())
+	require.NoError(t, err)
+	require.Len(t, store.blocked, 3)
+}
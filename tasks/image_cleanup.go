@@ -0,0 +1,160 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/storage"
+)
+
+const TypeCleanupImages = "images:cleanup"
+
+// DefaultOrphanGracePeriod is how old an unreferenced object or staged upload must be
+// before it is deleted, which leaves in-flight uploads and processing alone.
+const DefaultOrphanGracePeriod = 24 * time.Hour
+
+// orphanBatchSize bounds the objects deleted per query.
+const orphanBatchSize = 500
+
+// ImageCleanupStore is the subset of db.Queries used to clean up orphaned images.
+type ImageCleanupStore interface {
+	DeleteReferencedOrphanedImages(ctx context.Context, createdAt time.Time) (int64, error)
+	GetOrphanedImages(ctx context.Context, arg db.GetOrphanedImagesParams) ([]string, error)
+	DeleteOrphanedImages(ctx context.Context, objectKeys []string) error
+	GetStagedListingImagePaths(ctx context.Context, paths []string) ([]string, error)
+	DeleteExpiredUploadSessions(ctx context.Context, expiresAt time.Time) (int64, error)
+}
+
+// ImageCleanup deletes stored images that no listing uses any more, which are recorded
+// when images and listings are deleted, staged uploads that were never processed and
+// expired upload sessions. Files uploaded to a session but never completed are recorded
+// when the session is created, so they are deleted as orphans.
+type ImageCleanup struct {
+	store      ImageCleanupStore
+	images     storage.ImageStore
+	stagingDir string
+	now        func() time.Time
+	grace      time.Duration
+}
+
+func NewImageCleanup(store ImageCleanupStore, images storage.ImageStore, stagingDir string, now func() time.Time, grace time.Duration) *ImageCleanup {
+	return &ImageCleanup{
+		store:      store,
+		images:     images,
+		stagingDir: stagingDir,
+		now:        now,
+		grace:      grace,
+	}
+}
+
+func NewCleanupImagesTask() *asynq.Task {
+	return asynq.NewTask(TypeCleanupImages, nil)
+}
+
+func (c *ImageCleanup) HandleCleanupImagesTask(ctx context.Context, t *asynq.Task) error {
+	before := c.now().Add(-c.grace)
+
+	if _, err := c.store.DeleteReferencedOrphanedImages(ctx, before); err != nil {
+		return fmt.Errorf("delete referenced orphaned images: %w", err)
+	}
+
+	deleted := 0
+	for {
+		keys, err := c.store.GetOrphanedImages(ctx, db.GetOrphanedImagesParams{
+			CreatedAt: before,
+			Limit:     orphanBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("get orphaned images: %w", err)
+		}
+
+		var done []string
+		for _, key := range keys {
+			if err := c.images.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete orphaned image %s: %v", key, err)
+				continue
+			}
+			done = append(done, key)
+		}
+		if len(done) > 0 {
+			if err := c.store.DeleteOrphanedImages(ctx, done); err != nil {
+				return fmt.Errorf("delete orphaned images: %w", err)
+			}
+		}
+		deleted += len(done)
+
+		// Stop on a short batch, or when the store is failing so the same keys would come
+		// back again.
+		if len(keys) < orphanBatchSize || len(done) == 0 {
+			break
+		}
+	}
+
+	removed, err := c.removeStaleUploads(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := c.store.DeleteExpiredUploadSessions(ctx, c.now())
+	if err != nil {
+		return fmt.Errorf("delete expired upload sessions: %w", err)
+	}
+
+	log.Printf("Deleted %d orphaned images, %d stale uploads and %d expired upload sessions", deleted, removed, sessions)
+	return nil
+}
+
+// removeStaleUploads removes staged uploads last modified before before that no image is
+// waiting to process.
+func (c *ImageCleanup) removeStaleUploads(ctx context.Context, before time.Time) (int, error) {
+	entries, err := os.ReadDir(c.stagingDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read staging directory: %w", err)
+	}
+
+	var stale []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		stale = append(stale, filepath.Join(c.stagingDir, entry.Name()))
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	pending, err := c.store.GetStagedListingImagePaths(ctx, stale)
+	if err != nil {
+		return 0, fmt.Errorf("get staged listing image paths: %w", err)
+	}
+	inUse := make(map[string]bool, len(pending))
+	for _, path := range pending {
+		inUse[path] = true
+	}
+
+	removed := 0
+	for _, path := range stale {
+		if inUse[path] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove stale upload %s: %v", path, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
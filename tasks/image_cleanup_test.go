@@ -0,0 +1,114 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+type fakeImageCleanupStore struct {
+	orphans        []string
+	deleted        []string
+	referencedTime time.Time
+	pending        []string
+	expiredBefore  time.Time
+}
+
+func (f *fakeImageCleanupStore) DeleteReferencedOrphanedImages(ctx context.Context, createdAt time.Time) (int64, error) {
+	f.referencedTime = createdAt
+	return 0, nil
+}
+
+func (f *fakeImageCleanupStore) GetOrphanedImages(ctx context.Context, arg db.GetOrphanedImagesParams) ([]string, error) {
+	var keys []string
+	for _, key := range f.orphans {
+		if !contains(f.deleted, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeImageCleanupStore) DeleteOrphanedImages(ctx context.Context, objectKeys []string) error {
+	f.deleted = append(f.deleted, objectKeys...)
+	return nil
+}
+
+func (f *fakeImageCleanupStore) GetStagedListingImagePaths(ctx context.Context, paths []string) ([]string, error) {
+	var found []string
+	for _, path := range paths {
+		if contains(f.pending, path) {
+			found = append(found, path)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeImageCleanupStore) DeleteExpiredUploadSessions(ctx context.Context, expiresAt time.Time) (int64, error) {
+	f.expiredBefore = expiresAt
+	return 1, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// failingImageStore fails to delete the keys in fail.
+type failingImageStore struct {
+	fakeImageStore
+	fail    string
+	removed []string
+}
+
+func (f *failingImageStore) Delete(ctx context.Context, key string) error {
+	if key == f.fail {
+		return errors.New("store unavailable")
+	}
+	f.removed = append(f.removed, key)
+	return nil
+}
+
+func TestHandleCleanupImagesTask(t *testing.T) {
+	now := time.Date(2024, time.June, 20, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	stage := func(name string, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("upload"), 0o600))
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		return path
+	}
+	abandoned := stage("abandoned", 48*time.Hour)
+	waiting := stage("waiting", 48*time.Hour)
+	recent := stage("recent", time.Hour)
+
+	store := &fakeImageCleanupStore{
+		orphans: []string{"listings/1/1/large.jpg", "listings/1/1/thumb.jpg"},
+		pending: []string{waiting},
+	}
+	images := &failingImageStore{fail: "listings/1/1/thumb.jpg"}
+	cleanup := NewImageCleanup(store, images, dir, func() time.Time { return now }, DefaultOrphanGracePeriod)
+
+	require.NoError(t, cleanup.HandleCleanupImagesTask(context.Background(), NewCleanupImagesTask()))
+
+	require.Equal(t, now.Add(-DefaultOrphanGracePeriod), store.referencedTime)
+	require.Equal(t, []string{"listings/1/1/large.jpg"}, images.removed)
+	// The object that could not be deleted stays recorded for the next run.
+	require.Equal(t, []string{"listings/1/1/large.jpg"}, store.deleted)
+
+	require.NoFileExists(t, abandoned)
+	require.FileExists(t, waiting)
+	require.FileExists(t, recent)
+
+	require.Equal(t, now, store.expiredBefore)
+}
@@ -0,0 +1,239 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/imaging"
+	"github.com/weldonkipchirchir/rental_listing/storage"
+)
+
+const TypeProcessListingImage = "images:process"
+
+type ProcessListingImagePayload struct {
+	ImageID int32 `json:"image_id"`
+}
+
+// ListingImageStore is the subset of db.Queries used to process listing images.
+type ListingImageStore interface {
+	GetListingImage(ctx context.Context, id int32) (db.ListingImage, error)
+	UpsertListingImageVariant(ctx context.Context, arg db.UpsertListingImageVariantParams) error
+	MarkListingImageReady(ctx context.Context, arg db.MarkListingImageReadyParams) (int64, error)
+	MarkListingImageFailed(ctx context.Context, arg db.MarkListingImageFailedParams) error
+	SyncListingImageLinks(ctx context.Context, id int32) error
+	RecordOrphanedImages(ctx context.Context, objectKeys []string) error
+}
+
+// ImageProcessor turns a staged or directly uploaded original into resized, metadata-free
+// variants in every format, stores them, records them and adds the image to the listing. Uploads that
+// turn out not to be valid images are marked failed rather than retried.
+//
+// Each variant is stored once, as JPEG. Its other formats are the same object delivered
+// converted by the image store, so they share its object key.
+type ImageProcessor struct {
+	store   ListingImageStore
+	images  storage.ImageStore
+	limits  imaging.Limits
+	sizes   []imaging.Size
+	formats []string
+}
+
+// NewImageProcessor returns a processor that records variants in each of formats. Formats
+// other than "jpeg" need an image store that is a storage.Transcoder.
+func NewImageProcessor(store ListingImageStore, images storage.ImageStore, limits imaging.Limits, sizes []imaging.Size, formats []string) *ImageProcessor {
+	return &ImageProcessor{
+		store:   store,
+		images:  images,
+		limits:  limits,
+		sizes:   sizes,
+		formats: formats,
+	}
+}
+
+func NewProcessListingImageTask(imageID int32) (*asynq.Task, error) {
+	payload, err := json.Marshal(ProcessListingImagePayload{ImageID: imageID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeProcessListingImage, payload), nil
+}
+
+// ImageObjectKey is where a variant of a listing image is stored.
+func ImageObjectKey(listingID, imageID int32, size, format string) string {
+	ext := format
+	if format == "jpeg" {
+		ext = "jpg"
+	}
+	return fmt.Sprintf("listings/%d/%d/%s.%s", listingID, imageID, size, ext)
+}
+
+func (p *ImageProcessor) HandleProcessListingImageTask(ctx context.Context, t *asynq.Task) error {
+	var payload ProcessListingImagePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	img, err := p.store.GetListingImage(ctx, payload.ImageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The listing or its images were deleted before the task ran.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get listing image: %w", err)
+	}
+	if img.Status != "pending" {
+		return nil
+	}
+
+	data, err := p.readUpload(ctx, img)
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return p.fail(ctx, img, permanent.err)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := imaging.Process(data, p.limits, p.sizes)
+	if err != nil {
+		return p.fail(ctx, img, err)
+	}
+
+	// Record the keys before storing anything, so the objects are cleaned up if the image
+	// is deleted while it is being processed.
+	var keys []string
+	for _, variant := range result.Variants {
+		keys = append(keys, ImageObjectKey(img.ListingID, img.ID, variant.Name, "jpeg"))
+	}
+	if err := p.store.RecordOrphanedImages(ctx, keys); err != nil {
+		return fmt.Errorf("record image keys: %w", err)
+	}
+
+	var url string
+	for _, variant := range result.Variants {
+		key := ImageObjectKey(img.ListingID, img.ID, variant.Name, "jpeg")
+		jpegURL, err := p.images.Put(ctx, key, variant.Data)
+		if err != nil {
+			return fmt.Errorf("store %s: %w", key, err)
+		}
+		// The largest JPEG is the URL existing clients see in imageLinks.
+		url = jpegURL
+
+		for _, format := range p.formats {
+			variantURL := jpegURL
+			if format != "jpeg" {
+				transcoder, ok := p.images.(storage.Transcoder)
+				if !ok {
+					return fmt.Errorf("image store cannot deliver %s images", format)
+				}
+				if variantURL, err = transcoder.TranscodedURL(key, format); err != nil {
+					return fmt.Errorf("%s url of %s: %w", format, key, err)
+				}
+			}
+			if err := p.store.UpsertListingImageVariant(ctx, db.UpsertListingImageVariantParams{
+				ImageID:   img.ID,
+				Name:      variant.Name,
+				Format:    format,
+				Width:     int32(variant.Width),
+				Height:    int32(variant.Height),
+				ObjectKey: key,
+				Url:       variantURL,
+			}); err != nil {
+				return fmt.Errorf("save image variant: %w", err)
+			}
+		}
+	}
+
+	ready, err := p.store.MarkListingImageReady(ctx, db.MarkListingImageReadyParams{
+		ID:       img.ID,
+		Url:      sql.NullString{String: url, Valid: url != ""},
+		Width:    sql.NullInt32{Int32: int32(result.Width), Valid: true},
+		Height:   sql.NullInt32{Int32: int32(result.Height), Valid: true},
+		Blurhash: sql.NullString{String: result.BlurHash, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("mark listing image ready: %w", err)
+	}
+	if ready > 0 {
+		if err := p.store.SyncListingImageLinks(ctx, img.ListingID); err != nil {
+			return fmt.Errorf("sync listing image links: %w", err)
+		}
+	}
+
+	p.discardUpload(ctx, img)
+	log.Printf("Processed image %d of listing %d into %d variants", img.ID, img.ListingID, len(result.Variants)*len(p.formats))
+	return nil
+}
+
+// permanentError is a failure to read an upload that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// readUpload reads the original of img from the image store or, for uploads staged
+// before they were kept there, from the staging directory.
+func (p *ImageProcessor) readUpload(ctx context.Context, img db.ListingImage) ([]byte, error) {
+	if !img.SourceKey.Valid {
+		data, err := os.ReadFile(img.StagedPath.String)
+		if err != nil {
+			return nil, &permanentError{fmt.Errorf("read staged upload: %w", err)}
+		}
+		return data, nil
+	}
+
+	direct, ok := p.images.(storage.DirectStore)
+	if !ok {
+		return nil, &permanentError{errors.New("image store does not accept direct uploads")}
+	}
+	data, err := direct.Get(ctx, img.SourceKey.String, p.limits.MaxBytes)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, &permanentError{errors.New("uploaded file not found")}
+	case errors.Is(err, storage.ErrTooLarge):
+		return nil, &permanentError{imaging.ErrTooLarge}
+	case err != nil:
+		return nil, fmt.Errorf("read uploaded file %s: %w", img.SourceKey.String, err)
+	}
+	return data, nil
+}
+
+// discardUpload removes the original of img once it is no longer needed.
+func (p *ImageProcessor) discardUpload(ctx context.Context, img db.ListingImage) {
+	removeStaged(img.StagedPath.String)
+	if img.SourceKey.Valid {
+		if err := p.images.Delete(ctx, img.SourceKey.String); err != nil {
+			log.Printf("Failed to delete uploaded file %s: %v", img.SourceKey.String, err)
+		}
+	}
+}
+
+// fail marks an image that can never be processed as failed and discards its upload.
+func (p *ImageProcessor) fail(ctx context.Context, img db.ListingImage, cause error) error {
+	if err := p.store.MarkListingImageFailed(ctx, db.MarkListingImageFailedParams{
+		ID:    img.ID,
+		Error: sql.NullString{String: cause.Error(), Valid: true},
+	}); err != nil {
+		return fmt.Errorf("mark listing image failed: %w", err)
+	}
+	p.discardUpload(ctx, img)
+	log.Printf("Failed to process image %d of listing %d: %v", img.ID, img.ListingID, cause)
+	return nil
+}
+
+func removeStaged(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove staged upload %s: %v", path, err)
+	}
+}
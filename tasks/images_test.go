@@ -0,0 +1,200 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/imaging"
+	"github.com/weldonkipchirchir/rental_listing/storage"
+)
+
+type fakeListingImageStore struct {
+	images   map[int32]db.ListingImage
+	variants []db.UpsertListingImageVariantParams
+	synced   []int32
+	recorded []string
+}
+
+func (f *fakeListingImageStore) GetListingImage(ctx context.Context, id int32) (db.ListingImage, error) {
+	img, ok := f.images[id]
+	if !ok {
+		return db.ListingImage{}, sql.ErrNoRows
+	}
+	return img, nil
+}
+
+func (f *fakeListingImageStore) UpsertListingImageVariant(ctx context.Context, arg db.UpsertListingImageVariantParams) error {
+	f.variants = append(f.variants, arg)
+	return nil
+}
+
+func (f *fakeListingImageStore) MarkListingImageReady(ctx context.Context, arg db.MarkListingImageReadyParams) (int64, error) {
+	img := f.images[arg.ID]
+	if img.Status != "pending" {
+		return 0, nil
+	}
+	img.Status = "ready"
+	img.Url, img.Width, img.Height, img.Blurhash = arg.Url, arg.Width, arg.Height, arg.Blurhash
+	img.StagedPath, img.SourceKey = sql.NullString{}, sql.NullString{}
+	f.images[arg.ID] = img
+	return 1, nil
+}
+
+func (f *fakeListingImageStore) MarkListingImageFailed(ctx context.Context, arg db.MarkListingImageFailedParams) error {
+	img := f.images[arg.ID]
+	img.Status = "failed"
+	img.Error = arg.Error
+	img.StagedPath, img.SourceKey = sql.NullString{}, sql.NullString{}
+	f.images[arg.ID] = img
+	return nil
+}
+
+func (f *fakeListingImageStore) SyncListingImageLinks(ctx context.Context, id int32) error {
+	f.synced = append(f.synced, id)
+	return nil
+}
+
+func (f *fakeListingImageStore) RecordOrphanedImages(ctx context.Context, objectKeys []string) error {
+	f.recorded = append(f.recorded, objectKeys...)
+	return nil
+}
+
+type fakeImageStore struct {
+	keys []string
+}
+
+func (f *fakeImageStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	f.keys = append(f.keys, key)
+	return "https://cdn.example.com/" + key, nil
+}
+
+func (f *fakeImageStore) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (f *fakeImageStore) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "https://cdn.example.com/" + key + "?signed", nil
+}
+
+// fakeTranscodingStore delivers the images it stores in other formats.
+type fakeTranscodingStore struct {
+	fakeImageStore
+}
+
+func (f *fakeTranscodingStore) TranscodedURL(key, format string) (string, error) {
+	return "https://cdn.example.com/f_" + format + "/" + key, nil
+}
+
+func stageFile(t *testing.T, data []byte) string {
+	path := filepath.Join(t.TempDir(), "upload")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestHandleProcessListingImageTask(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 600))))
+	staged := stageFile(t, buf.Bytes())
+
+	store := &fakeListingImageStore{images: map[int32]db.ListingImage{
+		5: {ID: 5, ListingID: 2, Status: "pending", StagedPath: sql.NullString{String: staged, Valid: true}},
+	}}
+	images := &fakeTranscodingStore{}
+	sizes := []imaging.Size{{Name: "small", MaxWidth: 320}, {Name: "large", MaxWidth: 640}}
+	processor := NewImageProcessor(store, images, imaging.DefaultLimits, sizes, []string{"webp", "jpeg"})
+
+	task, err := NewProcessListingImageTask(5)
+	require.NoError(t, err)
+	require.NoError(t, processor.HandleProcessListingImageTask(context.Background(), task))
+
+	// Each size is stored once; the WebP variant is the same object delivered converted.
+	require.Equal(t, []string{"listings/2/5/small.jpg", "listings/2/5/large.jpg"}, images.keys)
+	require.Equal(t, images.keys, store.recorded)
+	require.Len(t, store.variants, 4)
+	require.Equal(t, "webp", store.variants[2].Format)
+	require.Equal(t, "listings/2/5/large.jpg", store.variants[2].ObjectKey)
+	require.Equal(t, "https://cdn.example.com/f_webp/listings/2/5/large.jpg", store.variants[2].Url)
+	require.Equal(t, int32(640), store.variants[3].Width)
+	require.Equal(t, int32(480), store.variants[3].Height)
+
+	img := store.images[5]
+	require.Equal(t, "ready", img.Status)
+	require.Equal(t, "https://cdn.example.com/listings/2/5/large.jpg", img.Url.String)
+	require.Equal(t, int32(800), img.Width.Int32)
+	require.NotEmpty(t, img.Blurhash.String)
+	require.Equal(t, []int32{2}, store.synced)
+	require.NoFileExists(t, staged)
+
+	// A redelivered task does nothing once the image is processed.
+	require.NoError(t, processor.HandleProcessListingImageTask(context.Background(), task))
+	require.Len(t, images.keys, 2)
+}
+
+func TestHandleProcessListingImageTaskInvalidImage(t *testing.T) {
+	staged := stageFile(t, []byte("not an image"))
+	store := &fakeListingImageStore{images: map[int32]db.ListingImage{
+		5: {ID: 5, ListingID: 2, Status: "pending", StagedPath: sql.NullString{String: staged, Valid: true}},
+	}}
+	images := &fakeImageStore{}
+	processor := NewImageProcessor(store, images, imaging.DefaultLimits, imaging.DefaultSizes, []string{"jpeg"})
+
+	task, err := NewProcessListingImageTask(5)
+	require.NoError(t, err)
+	require.NoError(t, processor.HandleProcessListingImageTask(context.Background(), task))
+
+	require.Equal(t, "failed", store.images[5].Status)
+	require.Contains(t, store.images[5].Error.String, "only JPEG, PNG and GIF")
+	require.Empty(t, images.keys)
+	require.Empty(t, store.synced)
+	require.NoFileExists(t, staged)
+
+	// Images deleted before the task runs are skipped.
+	task, err = NewProcessListingImageTask(99)
+	require.NoError(t, err)
+	require.NoError(t, processor.HandleProcessListingImageTask(context.Background(), task))
+}
+
+func TestHandleProcessListingImageTaskDirectUpload(t *testing.T) {
+	images, err := storage.NewLocal(t.TempDir(), "/media/", []byte("secret"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 600))))
+	_, err = images.Put(context.Background(), "uploads/1/original", buf.Bytes())
+	require.NoError(t, err)
+
+	store := &fakeListingImageStore{images: map[int32]db.ListingImage{
+		5: {ID: 5, ListingID: 2, Status: "pending", SourceKey: sql.NullString{String: "uploads/1/original", Valid: true}},
+		6: {ID: 6, ListingID: 2, Status: "pending", SourceKey: sql.NullString{String: "uploads/1/missing", Valid: true}},
+	}}
+	sizes := []imaging.Size{{Name: "large", MaxWidth: 640}}
+	processor := NewImageProcessor(store, images, imaging.DefaultLimits, sizes, []string{"jpeg"})
+
+	task, err := NewProcessListingImageTask(5)
+	require.NoError(t, err)
+	require.NoError(t, processor.HandleProcessListingImageTask(context.Background(), task))
+
+	require.Equal(t, "ready", store.images[5].Status)
+	require.Equal(t, "/media/listings/2/5/large.jpg", store.images[5].Url.String)
+	_, err = images.Stat(context.Background(), "listings/2/5/large.jpg")
+	require.NoError(t, err)
+	// The original is deleted once processed.
+	_, err = images.Stat(context.Background(), "uploads/1/original")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// A file that was never uploaded fails the image instead of being retried.
+	task, err = NewProcessListingImageTask(6)
+	require.NoError(t, err)
+	require.NoError(t, processor.HandleProcessListingImageTask(context.Background(), task))
+	require.Equal(t, "failed", store.images[6].Status)
+	require.Equal(t, "uploaded file not found", store.images[6].Error.String)
+}
@@ -0,0 +1,86 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+const TypePurgeListings = "listing:purge"
+
+// DefaultListingRetention is how long a deleted listing can be restored before it is
+// purged.
+const DefaultListingRetention = 30 * 24 * time.Hour
+
+// purgeBatchSize bounds the listings purged per query.
+const purgeBatchSize = 100
+
+// ListingPurgeStore is the subset of db.Queries used to purge deleted listings.
+type ListingPurgeStore interface {
+	GetPurgeableListings(ctx context.Context, arg db.GetPurgeableListingsParams) ([]int32, error)
+	RecordListingOrphanedImages(ctx context.Context, listingID int32) error
+	PurgeListing(ctx context.Context, id int32) (int64, error)
+}
+
+// ListingPurger permanently removes listings deleted longer than the retention period
+// ago, along with their reviews, favorites and stats. Listings with bookings are kept so
+// the bookings and their payments still refer to them. The stored images of purged
+// listings are deleted by the image cleanup task.
+type ListingPurger struct {
+	store     ListingPurgeStore
+	now       func() time.Time
+	retention time.Duration
+}
+
+func NewListingPurger(store ListingPurgeStore, now func() time.Time, retention time.Duration) *ListingPurger {
+	return &ListingPurger{store: store, now: now, retention: retention}
+}
+
+func NewPurgeListingsTask() *asynq.Task {
+	return asynq.NewTask(TypePurgeListings, nil)
+}
+
+func (p *ListingPurger) HandlePurgeListingsTask(ctx context.Context, t *asynq.Task) error {
+	before := p.now().Add(-p.retention)
+
+	purged := 0
+	for {
+		ids, err := p.store.GetPurgeableListings(ctx, db.GetPurgeableListingsParams{
+			DeletedAt: before,
+			Limit:     purgeBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("get purgeable listings: %w", err)
+		}
+
+		done := 0
+		for _, id := range ids {
+			// Images are recorded first: if the purge then fails, the cleanup task skips
+			// objects that are still referenced.
+			if err := p.store.RecordListingOrphanedImages(ctx, id); err != nil {
+				log.Printf("Failed to record images of listing %d: %v", id, err)
+				continue
+			}
+			rows, err := p.store.PurgeListing(ctx, id)
+			if err != nil {
+				log.Printf("Failed to purge listing %d: %v", id, err)
+				continue
+			}
+			done++
+			purged += int(rows)
+		}
+
+		// Stop on a short batch, or when the store is failing so the same listings would
+		// come back again.
+		if len(ids) < purgeBatchSize || done == 0 {
+			break
+		}
+	}
+
+	log.Printf("Purged %d deleted listings", purged)
+	return nil
+}
@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+type fakeListingPurgeStore struct {
+	purgeable []int32
+	before    time.Time
+	recorded  []int32
+	purged    []int32
+	fail      int32
+}
+
+func (f *fakeListingPurgeStore) GetPurgeableListings(ctx context.Context, arg db.GetPurgeableListingsParams) ([]int32, error) {
+	f.before = arg.DeletedAt
+	var ids []int32
+	for _, id := range f.purgeable {
+		if !containsID(f.purged, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeListingPurgeStore) RecordListingOrphanedImages(ctx context.Context, listingID int32) error {
+	f.recorded = append(f.recorded, listingID)
+	return nil
+}
+
+func (f *fakeListingPurgeStore) PurgeListing(ctx context.Context, id int32) (int64, error) {
+	if id == f.fail {
+		return 0, errors.New("database unavailable")
+	}
+	f.purged = append(f.purged, id)
+	return 1, nil
+}
+
+func containsID(ids []int32, id int32) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHandlePurgeListingsTask(t *testing.T) {
+	now := time.Date(2024, time.June, 20, 12, 0, 0, 0, time.UTC)
+	store := &fakeListingPurgeStore{purgeable: []int32{1, 2, 3}, fail: 2}
+	purger := NewListingPurger(store, func() time.Time { return now }, DefaultListingRetention)
+
+	err := purger.HandlePurgeListingsTask(context.Background(), NewPurgeListingsTask())
+	require.NoError(t, err)
+
+	require.Equal(t, time.Date(2024, time.May, 21, 12, 0, 0, 0, time.UTC), store.before)
+	// The images of every listing are recorded before it is purged; a listing that fails
+	// to purge is left for the next run.
+	require.Equal(t, []int32{1, 2, 3}, store.recorded)
+	require.Equal(t, []int32{1, 3}, store.purged)
+}
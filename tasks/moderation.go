@@ -0,0 +1,172 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/moderation"
+)
+
+const (
+	TypeModerateReview        = "review:moderate"
+	TypeRequeuePendingReviews = "review:requeue_pending"
+)
+
+// pendingReviewGrace is how long a new review waits for the content filter before it is
+// queued again, in case queueing it when it was written failed.
+const pendingReviewGrace = 5 * time.Minute
+
+type ModerateReviewPayload struct {
+	ReviewID int32 `json:"review_id"`
+}
+
+func NewModerateReviewTask(reviewID int32) (*asynq.Task, error) {
+	payload, err := json.Marshal(ModerateReviewPayload{ReviewID: reviewID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeModerateReview, payload, asynq.TaskID(fmt.Sprintf("review:%d:moderate", reviewID))), nil
+}
+
+func NewRequeuePendingReviewsTask() *asynq.Task {
+	return asynq.NewTask(TypeRequeuePendingReviews, nil)
+}
+
+// ModerationStore is the subset of db.Queries used to screen new reviews.
+type ModerationStore interface {
+	GetReviewForModeration(ctx context.Context, id int32) (db.GetReviewForModerationRow, error)
+	FlagReview(ctx context.Context, arg db.FlagReviewParams) error
+	ClearPendingReview(ctx context.Context, id int32) (int64, error)
+	GetStalePendingReviews(ctx context.Context, cutoff time.Time) ([]int32, error)
+}
+
+// ReviewModerator runs new reviews through a content filter. New reviews are pending, and
+// only shown once they pass; the ones it flags are held for a moderator and hidden until
+// a moderator approves them.
+type ReviewModerator struct {
+	store  ModerationStore
+	client Enqueuer
+	filter moderation.Filter
+	now    func() time.Time
+}
+
+func NewReviewModerator(store ModerationStore, client Enqueuer, filter moderation.Filter, now func() time.Time) *ReviewModerator {
+	return &ReviewModerator{store: store, client: client, filter: filter, now: now}
+}
+
+func (m *ReviewModerator) HandleModerateReviewTask(ctx context.Context, t *asynq.Task) error {
+	var payload ModerateReviewPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	review, err := m.store.GetReviewForModeration(ctx, payload.ReviewID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get review %d: %w", payload.ReviewID, err)
+	}
+	if !review.Comment.Valid {
+		return m.clear(ctx, review)
+	}
+
+	reasons, err := m.filter.Check(ctx, moderation.Content{ID: review.ID, Text: review.Comment.String})
+	if err != nil {
+		return fmt.Errorf("check review %d: %w", review.ID, err)
+	}
+	if len(reasons) == 0 {
+		return m.clear(ctx, review)
+	}
+
+	err = m.store.FlagReview(ctx, db.FlagReviewParams{
+		ID:               review.ID,
+		ModerationReason: sql.NullString{String: strings.Join(reasons, ", "), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("flag review %d: %w", review.ID, err)
+	}
+
+	log.Printf("Flagged review %d for moderation: %s", review.ID, strings.Join(reasons, ", "))
+	return nil
+}
+
+// HandleRequeuePendingReviewsTask queues the reviews that are still pending a while after
+// they were written, so a review whose moderation task was never queued is not hidden
+// for good.
+func (m *ReviewModerator) HandleRequeuePendingReviewsTask(ctx context.Context, t *asynq.Task) error {
+	ids, err := m.store.GetStalePendingReviews(ctx, m.now().Add(-pendingReviewGrace))
+	if err != nil {
+		return fmt.Errorf("get pending reviews: %w", err)
+	}
+
+	queued := 0
+	for _, id := range ids {
+		task, err := NewModerateReviewTask(id)
+		if err != nil {
+			return err
+		}
+		// A review whose task is still queued or retrying is left to it.
+		if _, err := m.client.Enqueue(task); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				continue
+			}
+			return fmt.Errorf("queue review %d: %w", id, err)
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		log.Printf("Queued %d pending reviews for moderation again", queued)
+	}
+	return nil
+}
+
+// clear shows a review that passed the filter and refreshes its listing's rating.
+func (m *ReviewModerator) clear(ctx context.Context, review db.GetReviewForModerationRow) error {
+	cleared, err := m.store.ClearPendingReview(ctx, review.ID)
+	if err != nil {
+		return fmt.Errorf("clear review %d: %w", review.ID, err)
+	}
+	if cleared == 0 {
+		return nil
+	}
+
+	task, err := NewRefreshListingStatsTask(review.ListingID)
+	if err != nil {
+		return err
+	}
+	if _, err := m.client.Enqueue(task); err != nil {
+		log.Printf("Failed to refresh listing %d stats: %v", review.ListingID, err)
+	}
+	return nil
+}
+
+// DuplicateReviewStore is the subset of db.Queries used to find duplicate reviews.
+type DuplicateReviewStore interface {
+	CountDuplicateReviews(ctx context.Context, arg db.CountDuplicateReviewsParams) (int64, error)
+}
+
+// ReviewFingerprints looks up duplicate review text for moderation.DuplicateFilter.
+type ReviewFingerprints struct {
+	store DuplicateReviewStore
+}
+
+func NewReviewFingerprints(store DuplicateReviewStore) *ReviewFingerprints {
+	return &ReviewFingerprints{store: store}
+}
+
+func (r *ReviewFingerprints) CountDuplicateContent(ctx context.Context, id int32, fingerprint string) (int64, error) {
+	return r.store.CountDuplicateReviews(ctx, db.CountDuplicateReviewsParams{
+		ID:                 id,
+		CommentFingerprint: sql.NullString{String: fingerprint, Valid: true},
+	})
+}
@@ -0,0 +1,100 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/moderation"
+)
+
+type fakeModerationStore struct {
+	reviews map[int32]db.GetReviewForModerationRow
+	flagged map[int32]string
+	cleared []int32
+	pending map[int32]time.Time
+}
+
+func (f *fakeModerationStore) GetReviewForModeration(ctx context.Context, id int32) (db.GetReviewForModerationRow, error) {
+	r, ok := f.reviews[id]
+	if !ok {
+		return db.GetReviewForModerationRow{}, sql.ErrNoRows
+	}
+	return r, nil
+}
+
+func (f *fakeModerationStore) FlagReview(ctx context.Context, arg db.FlagReviewParams) error {
+	f.flagged[arg.ID] = arg.ModerationReason.String
+	return nil
+}
+
+func (f *fakeModerationStore) ClearPendingReview(ctx context.Context, id int32) (int64, error) {
+	f.cleared = append(f.cleared, id)
+	return 1, nil
+}
+
+func (f *fakeModerationStore) GetStalePendingReviews(ctx context.Context, cutoff time.Time) ([]int32, error) {
+	var ids []int32
+	for id, createdAt := range f.pending {
+		if createdAt.Before(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func TestHandleModerateReviewTask(t *testing.T) {
+	store := &fakeModerationStore{
+		reviews: map[int32]db.GetReviewForModerationRow{
+			1: {ID: 1, Comment: sql.NullString{String: "Lovely, quiet flat near the park.", Valid: true}},
+			2: {ID: 2, Comment: sql.NullString{String: "Scam. Book direct on cheapstays.com instead", Valid: true}},
+			3: {ID: 3},
+		},
+		flagged: map[int32]string{},
+	}
+	client := &fakeEnqueuer{}
+	moderator := NewReviewModerator(store, client, moderation.Chain{
+		moderation.NewWordList([]string{"scam"}),
+		moderation.LinkFilter{},
+	}, time.Now)
+
+	for _, id := range []int32{1, 2, 3, 4} {
+		task, err := NewModerateReviewTask(id)
+		require.NoError(t, err)
+		require.NoError(t, moderator.HandleModerateReviewTask(context.Background(), task))
+	}
+
+	require.Equal(t, map[int32]string{2: "blocked word, contains a link or email address"}, store.flagged)
+	// The reviews that passed are shown and counted in their listing's rating.
+	require.Equal(t, []int32{1, 3}, store.cleared)
+	require.Len(t, client.tasks, 2)
+	require.Equal(t, TypeRefreshListingStats, client.tasks[0].Type())
+}
+
+func TestRequeuePendingReviews(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeModerationStore{pending: map[int32]time.Time{
+		1: now.Add(-time.Hour),
+		2: now.Add(-10 * time.Minute),
+		3: now.Add(-time.Minute),
+	}}
+	client := &fakeEnqueuer{}
+	moderator := NewReviewModerator(store, client, moderation.Chain{}, func() time.Time { return now })
+
+	require.NoError(t, moderator.HandleRequeuePendingReviewsTask(context.Background(), NewRequeuePendingReviewsTask()))
+
+	// The review written a minute ago may still be waiting for its first task.
+	require.Len(t, client.tasks, 2)
+	for i, id := range []int32{1, 2} {
+		require.Equal(t, TypeModerateReview, client.tasks[i].Type())
+		var payload ModerateReviewPayload
+		require.NoError(t, json.Unmarshal(client.tasks[i].Payload(), &payload))
+		require.Equal(t, id, payload.ReviewID)
+	}
+}
@@ -0,0 +1,468 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+	"github.com/weldonkipchirchir/rental_listing/team"
+)
+
+const (
+	TypeBookingEvent         = "notification:booking_event"
+	TypeCheckInReminders     = "notification:check_in_reminders"
+	TypeNotificationDelivery = "notification:deliver"
+)
+
+// DefaultCheckInReminderLead is how many days before check-in guests are reminded.
+const DefaultCheckInReminderLead = 1
+
+const (
+	guestBookingLink = "http://localhost:3000/bookings/%d"
+	hostBookingLink  = "http://localhost:3000/admin/bookings/%d"
+)
+
+type BookingEventPayload struct {
+	Category  string `json:"category"`
+	BookingID int32  `json:"booking_id"`
+	// ModificationID is set for events about a modification of the booking.
+	ModificationID int32 `json:"modification_id,omitempty"`
+}
+
+type NotificationDeliveryPayload struct {
+	Channel string         `json:"channel"`
+	To      notify.Address `json:"to"`
+	Message notify.Message `json:"message"`
+}
+
+func NewBookingEventTask(payload BookingEventPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeBookingEvent, data), nil
+}
+
+// EnqueueBookingEvent queues the notifications of an event of category about a booking.
+// An event that is already queued is not queued again.
+func EnqueueBookingEvent(client Enqueuer, category string, bookingID int32) error {
+	return enqueueBookingEvent(client, BookingEventPayload{Category: category, BookingID: bookingID})
+}
+
+// EnqueueModificationEvent is EnqueueBookingEvent for an event about a modification of a
+// booking. Each modification is notified of separately.
+func EnqueueModificationEvent(client Enqueuer, category string, bookingID, modificationID int32) error {
+	return enqueueBookingEvent(client, BookingEventPayload{Category: category, BookingID: bookingID, ModificationID: modificationID})
+}
+
+func enqueueBookingEvent(client Enqueuer, payload BookingEventPayload) error {
+	task, err := NewBookingEventTask(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := client.Enqueue(task, asynq.TaskID("booking_event:"+payload.key())); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("enqueue %s notification: %w", payload.Category, err)
+	}
+	return nil
+}
+
+// key identifies the event in task IDs.
+func (p BookingEventPayload) key() string {
+	if p.ModificationID != 0 {
+		return fmt.Sprintf("%s:%d:%d", p.Category, p.BookingID, p.ModificationID)
+	}
+	return fmt.Sprintf("%s:%d", p.Category, p.BookingID)
+}
+
+func NewCheckInRemindersTask() *asynq.Task {
+	return asynq.NewTask(TypeCheckInReminders, nil)
+}
+
+func NewNotificationDeliveryTask(payload NotificationDeliveryPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeNotificationDelivery, data), nil
+}
+
+// NotificationStore is the subset of db.Queries used to send booking event notifications.
+type NotificationStore interface {
+	GetBookingParties(ctx context.Context, id int32) (db.GetBookingPartiesRow, error)
+	GetListingHostContacts(ctx context.Context, arg db.GetListingHostContactsParams) ([]db.GetListingHostContactsRow, error)
+	GetUserNotificationPreferences(ctx context.Context, userID sql.NullInt32) ([]db.GetUserNotificationPreferencesRow, error)
+	GetAdminNotificationPreferences(ctx context.Context, adminID sql.NullInt32) ([]db.GetAdminNotificationPreferencesRow, error)
+	GetUserNotificationSettings(ctx context.Context, userID sql.NullInt32) (db.NotificationSetting, error)
+	GetAdminNotificationSettings(ctx context.Context, adminID sql.NullInt32) (db.NotificationSetting, error)
+	GetUserPushSubscriptions(ctx context.Context, userID sql.NullInt32) ([]db.PushSubscription, error)
+	GetAdminPushSubscriptions(ctx context.Context, adminID sql.NullInt32) ([]db.PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, endpoint string) error
+	CreateEventNotification(ctx context.Context, arg db.CreateEventNotificationParams) (db.Notification, error)
+	CountUnreadNotificationsByUserID(ctx context.Context, userID sql.NullInt32) (int64, error)
+	CountUnreadNotificationsByAdminID(ctx context.Context, adminID sql.NullInt32) (int64, error)
+	ClaimCheckInReminders(ctx context.Context, arg db.ClaimCheckInRemindersParams) ([]int32, error)
+}
+
+// BookingNotifications tells guests and hosts about booking events. Each event becomes an
+// in-app notification, pushed to the recipient's open streams, and a delivery task for
+// each other channel there is a notifier for, on the channels the recipient has not
+// turned off for the event's category. Text messages and push notifications wait for the
+// recipient's quiet hours to end. Recipients get at most one notification per booking,
+// category, modification and channel, so re-running a task is safe.
+type BookingNotifications struct {
+	store     NotificationStore
+	client    Enqueuer
+	live      LivePublisher
+	notifiers map[string]notify.Notifier
+	now       func() time.Time
+}
+
+func NewBookingNotifications(store NotificationStore, client Enqueuer, live LivePublisher, notifiers []notify.Notifier, now func() time.Time) *BookingNotifications {
+	n := &BookingNotifications{
+		store:     store,
+		client:    client,
+		live:      live,
+		notifiers: make(map[string]notify.Notifier, len(notifiers)),
+		now:       now,
+	}
+	for _, notifier := range notifiers {
+		n.notifiers[notifier.Channel()] = notifier
+	}
+	return n
+}
+
+// contact is someone who hears about a booking event.
+type contact struct {
+	recipient realtime.Recipient
+	name      string
+	email     string
+}
+
+func (n *BookingNotifications) HandleBookingEventTask(ctx context.Context, t *asynq.Task) error {
+	var payload BookingEventPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	if !notify.ValidCategory(payload.Category) {
+		return fmt.Errorf("%w: %s: %w", notify.ErrUnknownCategory, payload.Category, asynq.SkipRetry)
+	}
+
+	parties, err := n.store.GetBookingParties(ctx, payload.BookingID)
+	if err == sql.ErrNoRows {
+		log.Printf("Booking %d is gone, not sending %s notifications", payload.BookingID, payload.Category)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get booking %d parties: %w", payload.BookingID, err)
+	}
+
+	for _, audience := range notify.Audiences(payload.Category) {
+		contacts, err := n.contacts(ctx, audience, parties)
+		if err != nil {
+			return err
+		}
+		for _, to := range contacts {
+			if err := n.send(ctx, payload, audience, parties, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// contacts returns the guest of the booking, or the admins who can see the bookings of its
+// listing.
+func (n *BookingNotifications) contacts(ctx context.Context, audience string, parties db.GetBookingPartiesRow) ([]contact, error) {
+	if audience == notify.Guest {
+		return []contact{{recipient: realtime.User(parties.UserID), name: parties.UserUsername, email: parties.UserEmail}}, nil
+	}
+
+	hosts, err := n.store.GetListingHostContacts(ctx, db.GetListingHostContactsParams{
+		ID:    parties.ListingID,
+		Roles: team.RolesWith(team.ViewBookings),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get listing %d hosts: %w", parties.ListingID, err)
+	}
+	contacts := make([]contact, 0, len(hosts))
+	for _, h := range hosts {
+		contacts = append(contacts, contact{recipient: realtime.Admin(h.ID), name: h.Username, email: h.Email})
+	}
+	return contacts, nil
+}
+
+func (n *BookingNotifications) send(ctx context.Context, event BookingEventPayload, audience string, parties db.GetBookingPartiesRow, to contact) error {
+	category := event.Category
+	prefs, err := n.preferences(ctx, to.recipient)
+	if err != nil {
+		return fmt.Errorf("get notification preferences of %s: %w", to.recipient, err)
+	}
+
+	link := guestBookingLink
+	if audience == notify.Host {
+		link = hostBookingLink
+	}
+	booking := notify.Booking{
+		Name:      to.name,
+		BookingID: parties.ID,
+		Title:     parties.Title,
+		CheckIn:   parties.CheckInDate.Format("2006-01-02"),
+		CheckOut:  parties.CheckOutDate.Format("2006-01-02"),
+		Status:    parties.Status.String,
+		Amount:    parties.TotalAmount,
+		Link:      fmt.Sprintf(link, parties.ID),
+	}
+	if parties.Status.String == "pending" && parties.ApprovalDeadline.Valid {
+		booking.Deadline = parties.ApprovalDeadline.Time.Format("2006-01-02 15:04")
+	}
+	msg, err := notify.Render(category, audience, booking)
+	if err != nil {
+		return err
+	}
+
+	if notify.Enabled(prefs, category, notify.InApp) {
+		arg := db.CreateEventNotificationParams{
+			Category:       sql.NullString{String: category, Valid: true},
+			BookingID:      parties.ID,
+			ModificationID: sql.NullInt32{Int32: event.ModificationID, Valid: event.ModificationID != 0},
+			Subject:        sql.NullString{String: msg.Subject, Valid: true},
+			Message:        msg.Text,
+		}
+		if to.recipient.Kind == realtime.KindAdmin {
+			arg.AdminID = sql.NullInt32{Int32: to.recipient.ID, Valid: true}
+		} else {
+			arg.UserID = sql.NullInt32{Int32: to.recipient.ID, Valid: true}
+		}
+
+		notification, err := n.store.CreateEventNotification(ctx, arg)
+		switch {
+		case err == sql.ErrNoRows:
+			// Sent by an earlier run.
+		case err != nil:
+			return fmt.Errorf("create %s notification for %s: %w", category, to.recipient, err)
+		default:
+			n.publish(ctx, to.recipient, notification)
+		}
+	}
+
+	settings, err := n.settings(ctx, to.recipient)
+	if err != nil {
+		return err
+	}
+	for _, channel := range []string{notify.Email, notify.SMS, notify.Push} {
+		if _, ok := n.notifiers[channel]; !ok || !notify.Enabled(prefs, category, channel) {
+			continue
+		}
+		destinations, err := n.destinations(ctx, channel, to, settings)
+		if err != nil {
+			return err
+		}
+
+		delivery := msg
+		if channel != notify.Email {
+			delivery.HTML = ""
+		}
+		for _, d := range destinations {
+			task, err := NewNotificationDeliveryTask(NotificationDeliveryPayload{Channel: channel, To: d.to, Message: delivery})
+			if err != nil {
+				return err
+			}
+			opts := []asynq.Option{asynq.TaskID(fmt.Sprintf("notification:%s:%s:%d:%s", event.key(), to.recipient.Kind, to.recipient.ID, d.key))}
+			if until, ok := settings.quietUntil(n.now()); ok && notify.Interrupts(channel) {
+				opts = append(opts, asynq.ProcessAt(until))
+			}
+			if _, err := n.client.Enqueue(task, opts...); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+				return fmt.Errorf("enqueue %s %s notification: %w", category, channel, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (n *BookingNotifications) preferences(ctx context.Context, r realtime.Recipient) ([]notify.Preference, error) {
+	var prefs []notify.Preference
+	id := sql.NullInt32{Int32: r.ID, Valid: true}
+	if r.Kind == realtime.KindAdmin {
+		rows, err := n.store.GetAdminNotificationPreferences(ctx, id)
+		for _, row := range rows {
+			prefs = append(prefs, notify.Preference(row))
+		}
+		return prefs, err
+	}
+	rows, err := n.store.GetUserNotificationPreferences(ctx, id)
+	for _, row := range rows {
+		prefs = append(prefs, notify.Preference(row))
+	}
+	return prefs, err
+}
+
+// channelSettings are how and when a recipient is reached outside the app.
+type channelSettings struct {
+	phone string
+	quiet *notify.QuietHours
+}
+
+// quietUntil returns when the recipient's quiet hours that t falls in end.
+func (c channelSettings) quietUntil(t time.Time) (time.Time, bool) {
+	if c.quiet == nil {
+		return time.Time{}, false
+	}
+	return c.quiet.Until(t)
+}
+
+func (n *BookingNotifications) settings(ctx context.Context, r realtime.Recipient) (channelSettings, error) {
+	var row db.NotificationSetting
+	var err error
+	id := sql.NullInt32{Int32: r.ID, Valid: true}
+	if r.Kind == realtime.KindAdmin {
+		row, err = n.store.GetAdminNotificationSettings(ctx, id)
+	} else {
+		row, err = n.store.GetUserNotificationSettings(ctx, id)
+	}
+	if err == sql.ErrNoRows {
+		return channelSettings{}, nil
+	}
+	if err != nil {
+		return channelSettings{}, fmt.Errorf("get notification settings of %s: %w", r, err)
+	}
+
+	settings := channelSettings{phone: row.Phone.String}
+	if row.QuietHoursStart.Valid && row.QuietHoursEnd.Valid {
+		loc, err := time.LoadLocation(row.TimeZone)
+		if err != nil {
+			log.Printf("Unknown time zone %q of %s, using UTC: %v", row.TimeZone, r, err)
+			loc = time.UTC
+		}
+		settings.quiet = &notify.QuietHours{
+			Start:    int(row.QuietHoursStart.Int32),
+			End:      int(row.QuietHoursEnd.Int32),
+			Location: loc,
+		}
+	}
+	return settings, nil
+}
+
+// destination is an address a notification is delivered to on a channel. The key tells
+// it apart from the recipient's other addresses on the channel.
+type destination struct {
+	key string
+	to  notify.Address
+}
+
+// destinations returns where to reaches on channel: their email address, their phone
+// number if they gave one, or each browser they subscribed to push notifications.
+func (n *BookingNotifications) destinations(ctx context.Context, channel string, to contact, settings channelSettings) ([]destination, error) {
+	switch channel {
+	case notify.Email:
+		return []destination{{key: channel, to: notify.Address{Email: to.email}}}, nil
+	case notify.SMS:
+		if settings.phone == "" {
+			return nil, nil
+		}
+		return []destination{{key: channel, to: notify.Address{Phone: settings.phone}}}, nil
+	}
+
+	var subscriptions []db.PushSubscription
+	var err error
+	id := sql.NullInt32{Int32: to.recipient.ID, Valid: true}
+	if to.recipient.Kind == realtime.KindAdmin {
+		subscriptions, err = n.store.GetAdminPushSubscriptions(ctx, id)
+	} else {
+		subscriptions, err = n.store.GetUserPushSubscriptions(ctx, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get push subscriptions of %s: %w", to.recipient, err)
+	}
+	destinations := make([]destination, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		destinations = append(destinations, destination{
+			key: fmt.Sprintf("%s:%d", channel, sub.ID),
+			to: notify.Address{Push: &notify.PushSubscription{
+				Endpoint: sub.Endpoint,
+				Keys:     notify.PushKeys{P256dh: sub.P256dh, Auth: sub.Auth},
+			}},
+		})
+	}
+	return destinations, nil
+}
+
+// publish pushes a new notification and the new unread count to the recipient's open
+// streams. Failures are only logged; the notification is there when the client reloads.
+func (n *BookingNotifications) publish(ctx context.Context, r realtime.Recipient, notification db.Notification) {
+	if err := n.live.Publish(ctx, r, realtime.EventNotification, notification); err != nil {
+		log.Printf("Failed to publish notification %d to %s: %v", notification.ID, r, err)
+	}
+
+	var unread int64
+	var err error
+	id := sql.NullInt32{Int32: r.ID, Valid: true}
+	if r.Kind == realtime.KindAdmin {
+		unread, err = n.store.CountUnreadNotificationsByAdminID(ctx, id)
+	} else {
+		unread, err = n.store.CountUnreadNotificationsByUserID(ctx, id)
+	}
+	if err != nil {
+		log.Printf("Failed to count unread notifications of %s: %v", r, err)
+		return
+	}
+	if err := n.live.Publish(ctx, r, realtime.EventUnreadCount, realtime.UnreadCount{Unread: unread}); err != nil {
+		log.Printf("Failed to publish unread count to %s: %v", r, err)
+	}
+}
+
+// HandleCheckInRemindersTask reminds the guests of confirmed bookings that check in within
+// DefaultCheckInReminderLead days. Each booking is reminded once.
+func (n *BookingNotifications) HandleCheckInRemindersTask(ctx context.Context, t *asynq.Task) error {
+	today := n.now()
+	bookings, err := n.store.ClaimCheckInReminders(ctx, db.ClaimCheckInRemindersParams{
+		FromDate: today,
+		ToDate:   today.AddDate(0, 0, DefaultCheckInReminderLead),
+	})
+	if err != nil {
+		return fmt.Errorf("claim check-in reminders: %w", err)
+	}
+
+	for _, id := range bookings {
+		if err := EnqueueBookingEvent(n.client, notify.CheckInReminder, id); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Sent check-in reminders for %d bookings", len(bookings))
+	return nil
+}
+
+// HandleNotificationDeliveryTask delivers a notification on its channel. Push
+// subscriptions the browser has dropped are deleted.
+func (n *BookingNotifications) HandleNotificationDeliveryTask(ctx context.Context, t *asynq.Task) error {
+	var payload NotificationDeliveryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	notifier, ok := n.notifiers[payload.Channel]
+	if !ok {
+		return fmt.Errorf("%w: %s: %w", notify.ErrUnknownChannel, payload.Channel, asynq.SkipRetry)
+	}
+
+	err := notifier.Notify(ctx, payload.To, payload.Message)
+	switch {
+	case errors.Is(err, notify.ErrSubscriptionGone):
+		log.Printf("Deleting push subscription %s: %v", payload.To.Push.Endpoint, err)
+		return n.store.DeletePushSubscription(ctx, payload.To.Push.Endpoint)
+	case errors.Is(err, notify.ErrNoAddress):
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	case err != nil:
+		return err
+	}
+
+	log.Printf("Sent %q %s notification", payload.Message.Subject, payload.Channel)
+	return nil
+}
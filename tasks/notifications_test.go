@@ -0,0 +1,409 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/notify"
+	"github.com/weldonkipchirchir/rental_listing/realtime"
+)
+
+type fakeNotificationStore struct {
+	parties       map[int32]db.GetBookingPartiesRow
+	hosts         []db.GetListingHostContactsRow
+	userPrefs     map[int32][]db.GetUserNotificationPreferencesRow
+	adminPrefs    map[int32][]db.GetAdminNotificationPreferencesRow
+	notifications []db.Notification
+	checkIns      map[int32]time.Time
+	reminded      map[int32]bool
+	userSettings  map[int32]db.NotificationSetting
+	userPush      map[int32][]db.PushSubscription
+	deletedPush   []string
+}
+
+func (s *fakeNotificationStore) GetBookingParties(ctx context.Context, id int32) (db.GetBookingPartiesRow, error) {
+	p, ok := s.parties[id]
+	if !ok {
+		return db.GetBookingPartiesRow{}, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (s *fakeNotificationStore) GetListingHostContacts(ctx context.Context, arg db.GetListingHostContactsParams) ([]db.GetListingHostContactsRow, error) {
+	return s.hosts, nil
+}
+
+func (s *fakeNotificationStore) GetUserNotificationPreferences(ctx context.Context, userID sql.NullInt32) ([]db.GetUserNotificationPreferencesRow, error) {
+	return s.userPrefs[userID.Int32], nil
+}
+
+func (s *fakeNotificationStore) GetAdminNotificationPreferences(ctx context.Context, adminID sql.NullInt32) ([]db.GetAdminNotificationPreferencesRow, error) {
+	return s.adminPrefs[adminID.Int32], nil
+}
+
+func (s *fakeNotificationStore) GetUserNotificationSettings(ctx context.Context, userID sql.NullInt32) (db.NotificationSetting, error) {
+	settings, ok := s.userSettings[userID.Int32]
+	if !ok {
+		return db.NotificationSetting{}, sql.ErrNoRows
+	}
+	return settings, nil
+}
+
+func (s *fakeNotificationStore) GetAdminNotificationSettings(ctx context.Context, adminID sql.NullInt32) (db.NotificationSetting, error) {
+	return db.NotificationSetting{}, sql.ErrNoRows
+}
+
+func (s *fakeNotificationStore) GetUserPushSubscriptions(ctx context.Context, userID sql.NullInt32) ([]db.PushSubscription, error) {
+	return s.userPush[userID.Int32], nil
+}
+
+func (s *fakeNotificationStore) GetAdminPushSubscriptions(ctx context.Context, adminID sql.NullInt32) ([]db.PushSubscription, error) {
+	return nil, nil
+}
+
+func (s *fakeNotificationStore) DeletePushSubscription(ctx context.Context, endpoint string) error {
+	s.deletedPush = append(s.deletedPush, endpoint)
+	return nil
+}
+
+func (s *fakeNotificationStore) CreateEventNotification(ctx context.Context, arg db.CreateEventNotificationParams) (db.Notification, error) {
+	for _, n := range s.notifications {
+		if n.BookingID == arg.BookingID && n.Category == arg.Category && n.ModificationID == arg.ModificationID && n.UserID == arg.UserID && n.AdminID == arg.AdminID {
+			return db.Notification{}, sql.ErrNoRows
+		}
+	}
+	n := db.Notification{
+		ID:             int32(len(s.notifications) + 1),
+		UserID:         arg.UserID,
+		AdminID:        arg.AdminID,
+		Category:       arg.Category,
+		BookingID:      arg.BookingID,
+		ModificationID: arg.ModificationID,
+		Subject:        arg.Subject,
+		Message:        arg.Message,
+	}
+	s.notifications = append(s.notifications, n)
+	return n, nil
+}
+
+func (s *fakeNotificationStore) CountUnreadNotificationsByUserID(ctx context.Context, userID sql.NullInt32) (int64, error) {
+	return s.count(func(n db.Notification) bool { return n.UserID == userID }), nil
+}
+
+func (s *fakeNotificationStore) CountUnreadNotificationsByAdminID(ctx context.Context, adminID sql.NullInt32) (int64, error) {
+	return s.count(func(n db.Notification) bool { return n.AdminID == adminID }), nil
+}
+
+func (s *fakeNotificationStore) count(match func(db.Notification) bool) int64 {
+	var unread int64
+	for _, n := range s.notifications {
+		if match(n) && !n.Read.Bool {
+			unread++
+		}
+	}
+	return unread
+}
+
+func (s *fakeNotificationStore) ClaimCheckInReminders(ctx context.Context, arg db.ClaimCheckInRemindersParams) ([]int32, error) {
+	if s.reminded == nil {
+		s.reminded = map[int32]bool{}
+	}
+	var ids []int32
+	for id, checkIn := range s.checkIns {
+		if !s.reminded[id] && !checkIn.Before(arg.FromDate.Truncate(24*time.Hour)) && !checkIn.After(arg.ToDate) {
+			s.reminded[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func newFakeNotificationStore() *fakeNotificationStore {
+	return &fakeNotificationStore{
+		parties: map[int32]db.GetBookingPartiesRow{
+			7: {
+				ID:               7,
+				ListingID:        10,
+				Title:            "Beach house",
+				CheckInDate:      time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+				CheckOutDate:     time.Date(2024, time.June, 5, 0, 0, 0, 0, time.UTC),
+				Status:           sql.NullString{String: "pending", Valid: true},
+				TotalAmount:      "450.00",
+				ApprovalDeadline: sql.NullTime{Time: time.Date(2024, time.May, 2, 10, 0, 0, 0, time.UTC), Valid: true},
+				UserID:           3,
+				UserUsername:     "guest",
+				UserEmail:        "guest@email.com",
+			},
+		},
+		hosts: []db.GetListingHostContactsRow{
+			{ID: 50, Username: "host", Email: "host@email.com"},
+			{ID: 51, Username: "manager", Email: "manager@email.com"},
+		},
+	}
+}
+
+// allNotifiers returns fake notifiers for every channel outside the app.
+func allNotifiers() []notify.Notifier {
+	return []notify.Notifier{notify.NewFake(notify.Email), notify.NewFake(notify.SMS), notify.NewFake(notify.Push)}
+}
+
+func deliveryPayloads(t *testing.T, tasks []*asynq.Task, channel string) []NotificationDeliveryPayload {
+	var payloads []NotificationDeliveryPayload
+	for _, task := range tasks {
+		if task.Type() != TypeNotificationDelivery {
+			continue
+		}
+		var p NotificationDeliveryPayload
+		require.NoError(t, json.Unmarshal(task.Payload(), &p))
+		if p.Channel == channel {
+			payloads = append(payloads, p)
+		}
+	}
+	return payloads
+}
+
+func TestBookingEventNotifications(t *testing.T) {
+	store := newFakeNotificationStore()
+	store.userPrefs = map[int32][]db.GetUserNotificationPreferencesRow{
+		3: {{Category: notify.BookingCreated, Channel: notify.InApp, Enabled: false}},
+	}
+	store.adminPrefs = map[int32][]db.GetAdminNotificationPreferencesRow{
+		51: {{Category: notify.BookingCreated, Channel: notify.Email, Enabled: false}},
+	}
+	client := &fakeEnqueuer{}
+	live := &fakeLivePublisher{}
+	notifications := NewBookingNotifications(store, client, live, allNotifiers(), time.Now)
+
+	task, err := NewBookingEventTask(BookingEventPayload{Category: notify.BookingCreated, BookingID: 7})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleBookingEventTask(context.Background(), task))
+
+	// The guest turned off in-app notifications of new bookings, the manager emails.
+	require.Len(t, store.notifications, 2)
+	for i, adminID := range []int32{50, 51} {
+		n := store.notifications[i]
+		require.Equal(t, sql.NullInt32{Int32: adminID, Valid: true}, n.AdminID)
+		require.False(t, n.UserID.Valid)
+		require.Equal(t, notify.BookingCreated, n.Category.String)
+		require.Equal(t, "New booking request: Beach house", n.Subject.String)
+		require.Contains(t, n.Message, "by 2024-05-02 10:00")
+	}
+
+	emails := deliveryPayloads(t, client.tasks, notify.Email)
+	require.Len(t, emails, 2)
+	require.Equal(t, "guest@email.com", emails[0].To.Email)
+	require.Equal(t, "Booking request sent: Beach house", emails[0].Message.Subject)
+	require.Contains(t, emails[0].Message.HTML, "http://localhost:3000/bookings/7")
+	require.Equal(t, "host@email.com", emails[1].To.Email)
+	require.Contains(t, emails[1].Message.HTML, "http://localhost:3000/admin/bookings/7")
+
+	// Nobody turned on text messages or subscribed to push notifications.
+	require.Len(t, client.tasks, 2)
+
+	// Each host sees the notification and their unread count live.
+	require.Len(t, live.events, 4)
+	require.Equal(t, realtime.Admin(50), live.events[0].recipient)
+	require.Equal(t, realtime.EventNotification, live.events[0].eventType)
+	require.Equal(t, realtime.EventUnreadCount, live.events[1].eventType)
+	require.Equal(t, realtime.UnreadCount{Unread: 1}, live.events[1].data)
+
+	// Running again sends nothing twice.
+	require.NoError(t, notifications.HandleBookingEventTask(context.Background(), task))
+	require.Len(t, store.notifications, 2)
+	require.Len(t, deliveryPayloads(t, client.tasks, notify.Email), 2)
+	require.Len(t, live.events, 4)
+}
+
+func TestBookingEventGuestOnly(t *testing.T) {
+	store := newFakeNotificationStore()
+	client := &fakeEnqueuer{}
+	notifications := NewBookingNotifications(store, client, &fakeLivePublisher{}, allNotifiers(), time.Now)
+
+	task, err := NewBookingEventTask(BookingEventPayload{Category: notify.ReviewRequest, BookingID: 7})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleBookingEventTask(context.Background(), task))
+
+	require.Len(t, store.notifications, 1)
+	require.Equal(t, sql.NullInt32{Int32: 3, Valid: true}, store.notifications[0].UserID)
+	require.Equal(t, "How was your stay at Beach house?", store.notifications[0].Subject.String)
+	require.Len(t, deliveryPayloads(t, client.tasks, notify.Email), 1)
+}
+
+func TestBookingEventUnknown(t *testing.T) {
+	store := newFakeNotificationStore()
+	notifications := NewBookingNotifications(store, &fakeEnqueuer{}, &fakeLivePublisher{}, allNotifiers(), time.Now)
+
+	task, err := NewBookingEventTask(BookingEventPayload{Category: "listing_created", BookingID: 7})
+	require.NoError(t, err)
+	err = notifications.HandleBookingEventTask(context.Background(), task)
+	require.ErrorIs(t, err, notify.ErrUnknownCategory)
+	require.ErrorIs(t, err, asynq.SkipRetry)
+
+	// A booking deleted since the event is skipped.
+	task, err = NewBookingEventTask(BookingEventPayload{Category: notify.BookingCancelled, BookingID: 8})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleBookingEventTask(context.Background(), task))
+	require.Empty(t, store.notifications)
+}
+
+func TestEnqueueBookingEvent(t *testing.T) {
+	client := &fakeEnqueuer{}
+	require.NoError(t, EnqueueBookingEvent(client, notify.PaymentReceived, 7))
+	require.NoError(t, EnqueueBookingEvent(client, notify.PaymentReceived, 7))
+	require.NoError(t, EnqueueBookingEvent(client, notify.BookingAccepted, 7))
+	require.Len(t, client.tasks, 2)
+
+	// Each change of dates is a separate event.
+	require.NoError(t, EnqueueModificationEvent(client, notify.ModificationRequested, 7, 1))
+	require.NoError(t, EnqueueModificationEvent(client, notify.ModificationRequested, 7, 1))
+	require.NoError(t, EnqueueModificationEvent(client, notify.ModificationRequested, 7, 2))
+	require.Len(t, client.tasks, 4)
+
+	var payload BookingEventPayload
+	require.NoError(t, json.Unmarshal(client.tasks[0].Payload(), &payload))
+	require.Equal(t, BookingEventPayload{Category: notify.PaymentReceived, BookingID: 7}, payload)
+}
+
+func TestCheckInReminders(t *testing.T) {
+	now := time.Date(2024, time.June, 10, 15, 0, 0, 0, time.UTC)
+	today := time.Date(2024, time.June, 10, 0, 0, 0, 0, time.UTC)
+	store := newFakeNotificationStore()
+	store.checkIns = map[int32]time.Time{
+		1: today,
+		2: today.AddDate(0, 0, 1),
+		3: today.AddDate(0, 0, 3),
+		4: today.AddDate(0, 0, -1),
+	}
+	client := &fakeEnqueuer{}
+	notifications := NewBookingNotifications(store, client, &fakeLivePublisher{}, allNotifiers(), func() time.Time { return now })
+
+	require.NoError(t, notifications.HandleCheckInRemindersTask(context.Background(), NewCheckInRemindersTask()))
+	require.Len(t, client.tasks, 2)
+	var ids []int32
+	for _, task := range client.tasks {
+		var payload BookingEventPayload
+		require.NoError(t, json.Unmarshal(task.Payload(), &payload))
+		require.Equal(t, notify.CheckInReminder, payload.Category)
+		ids = append(ids, payload.BookingID)
+	}
+	require.ElementsMatch(t, []int32{1, 2}, ids)
+
+	// Bookings are reminded once.
+	require.NoError(t, notifications.HandleCheckInRemindersTask(context.Background(), NewCheckInRemindersTask()))
+	require.Len(t, client.tasks, 2)
+}
+
+func TestBookingEventChannels(t *testing.T) {
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	require.NoError(t, err)
+	// 23:00 in Nairobi, during the guest's quiet hours from 22:00 to 07:00.
+	now := time.Date(2024, time.June, 1, 20, 0, 0, 0, time.UTC)
+
+	store := newFakeNotificationStore()
+	store.userPrefs = map[int32][]db.GetUserNotificationPreferencesRow{
+		3: {{Category: notify.BookingAccepted, Channel: notify.SMS, Enabled: true}},
+	}
+	store.userSettings = map[int32]db.NotificationSetting{
+		3: {
+			Phone:           sql.NullString{String: "+254712345678", Valid: true},
+			QuietHoursStart: sql.NullInt32{Int32: 22 * 60, Valid: true},
+			QuietHoursEnd:   sql.NullInt32{Int32: 7 * 60, Valid: true},
+			TimeZone:        "Africa/Nairobi",
+		},
+	}
+	store.userPush = map[int32][]db.PushSubscription{
+		3: {
+			{ID: 1, Endpoint: "https://push.example.com/laptop", P256dh: "laptop-key", Auth: "laptop-secret"},
+			{ID: 2, Endpoint: "https://push.example.com/phone", P256dh: "phone-key", Auth: "phone-secret"},
+		},
+	}
+	client := &fakeEnqueuer{}
+	notifications := NewBookingNotifications(store, client, &fakeLivePublisher{}, allNotifiers(), func() time.Time { return now })
+
+	task, err := NewBookingEventTask(BookingEventPayload{Category: notify.BookingAccepted, BookingID: 7})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleBookingEventTask(context.Background(), task))
+	require.Len(t, client.tasks, 4)
+
+	sms := deliveryPayloads(t, client.tasks, notify.SMS)
+	require.Len(t, sms, 1)
+	require.Equal(t, notify.Address{Phone: "+254712345678"}, sms[0].To)
+	require.Equal(t, "Booking accepted: Beach house", sms[0].Message.Subject)
+	require.Empty(t, sms[0].Message.HTML)
+
+	push := deliveryPayloads(t, client.tasks, notify.Push)
+	require.Len(t, push, 2)
+	require.Equal(t, "https://push.example.com/phone", push[1].To.Push.Endpoint)
+	require.Equal(t, notify.PushKeys{P256dh: "phone-key", Auth: "phone-secret"}, push[1].To.Push.Keys)
+
+	// Emails go out at once; text messages and push notifications wait for 07:00.
+	morning := time.Date(2024, time.June, 2, 7, 0, 0, 0, nairobi)
+	for i, task := range client.tasks {
+		var payload NotificationDeliveryPayload
+		require.NoError(t, json.Unmarshal(task.Payload(), &payload))
+		var processAt time.Time
+		for _, opt := range client.options[i] {
+			if opt.Type() == asynq.ProcessAtOpt {
+				processAt = opt.Value().(time.Time)
+			}
+		}
+		if payload.Channel == notify.Email {
+			require.True(t, processAt.IsZero())
+		} else {
+			require.True(t, morning.Equal(processAt), payload.Channel)
+		}
+	}
+
+	// Channels without a notifier are skipped.
+	client = &fakeEnqueuer{}
+	notifications = NewBookingNotifications(store, client, &fakeLivePublisher{}, []notify.Notifier{notify.NewFake(notify.Email)}, time.Now)
+	task, err = NewBookingEventTask(BookingEventPayload{Category: notify.PaymentReceived, BookingID: 7})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleBookingEventTask(context.Background(), task))
+	for _, task := range client.tasks {
+		var payload NotificationDeliveryPayload
+		require.NoError(t, json.Unmarshal(task.Payload(), &payload))
+		require.Equal(t, notify.Email, payload.Channel)
+	}
+}
+
+func TestNotificationDeliveryTask(t *testing.T) {
+	email, push := notify.NewFake(notify.Email), notify.NewFake(notify.Push)
+	store := newFakeNotificationStore()
+	notifications := NewBookingNotifications(store, &fakeEnqueuer{}, &fakeLivePublisher{}, []notify.Notifier{email, push}, time.Now)
+	ctx := context.Background()
+
+	msg := notify.Message{Subject: "Booking accepted", Text: "Hello", HTML: "<p>Hello</p>"}
+	task, err := NewNotificationDeliveryTask(NotificationDeliveryPayload{Channel: notify.Email, To: notify.Address{Email: "guest@email.com"}, Message: msg})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleNotificationDeliveryTask(ctx, task))
+	require.Equal(t, []notify.Delivery{{To: notify.Address{Email: "guest@email.com"}, Message: msg}}, email.Delivered())
+
+	// Failed deliveries are retried by asynq.
+	email.Err = errors.New("smtp unavailable")
+	err = notifications.HandleNotificationDeliveryTask(ctx, task)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, asynq.SkipRetry)
+
+	// Push subscriptions the browser dropped are deleted.
+	to := notify.Address{Push: &notify.PushSubscription{Endpoint: "https://push.example.com/laptop"}}
+	push.Err = notify.ErrSubscriptionGone
+	task, err = NewNotificationDeliveryTask(NotificationDeliveryPayload{Channel: notify.Push, To: to, Message: msg})
+	require.NoError(t, err)
+	require.NoError(t, notifications.HandleNotificationDeliveryTask(ctx, task))
+	require.Equal(t, []string{"https://push.example.com/laptop"}, store.deletedPush)
+
+	// Channels that are no longer configured are not retried.
+	task, err = NewNotificationDeliveryTask(NotificationDeliveryPayload{Channel: notify.SMS, To: notify.Address{Phone: "+254712345678"}, Message: msg})
+	require.NoError(t, err)
+	err = notifications.HandleNotificationDeliveryTask(ctx, task)
+	require.ErrorIs(t, err, notify.ErrUnknownChannel)
+	require.ErrorIs(t, err, asynq.SkipRetry)
+}
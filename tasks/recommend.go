@@ -0,0 +1,90 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/recommend"
+)
+
+const TypeTrainRecommendations = "recommend:train"
+
+// similarityBatchSize bounds the number of rows written per statement.
+const similarityBatchSize = 5000
+
+// RecommendationStore is the subset of db.Queries used to train listing recommendations.
+type RecommendationStore interface {
+	GetListingInteractions(ctx context.Context) ([]db.ListingInteraction, error)
+	GetRecommendationItems(ctx context.Context) ([]db.GetRecommendationItemsRow, error)
+	UpsertListingSimilarities(ctx context.Context, arg db.UpsertListingSimilaritiesParams) error
+	DeleteStaleListingSimilarities(ctx context.Context, trainedAt time.Time) (int64, error)
+}
+
+// RecommendationTrainer retrains the similar listings model from every user's favorites,
+// bookings and reviews. New similarities are written before the ones the run did not
+// produce are deleted, so recommendations never go empty while it runs.
+type RecommendationTrainer struct {
+	store     RecommendationStore
+	now       func() time.Time
+	neighbors int
+}
+
+func NewRecommendationTrainer(store RecommendationStore, now func() time.Time, neighbors int) *RecommendationTrainer {
+	return &RecommendationTrainer{
+		store:     store,
+		now:       now,
+		neighbors: neighbors,
+	}
+}
+
+func NewTrainRecommendationsTask() *asynq.Task {
+	return asynq.NewTask(TypeTrainRecommendations, nil)
+}
+
+func (r *RecommendationTrainer) HandleTrainRecommendationsTask(ctx context.Context, t *asynq.Task) error {
+	trainedAt := r.now().UTC().Truncate(time.Microsecond)
+
+	rows, err := r.store.GetListingInteractions(ctx)
+	if err != nil {
+		return fmt.Errorf("get listing interactions: %w", err)
+	}
+	interactions := make([]recommend.Interaction, len(rows))
+	for i, row := range rows {
+		interactions[i] = recommend.Interaction{UserID: row.UserID, ListingID: row.ListingID, Weight: row.Weight}
+	}
+
+	listings, err := r.store.GetRecommendationItems(ctx)
+	if err != nil {
+		return fmt.Errorf("get recommendation items: %w", err)
+	}
+	items := make([]recommend.Item, len(listings))
+	for i, listing := range listings {
+		items[i] = recommend.Item{ID: listing.ID, Location: listing.Location.String, Price: listing.Price}
+	}
+
+	sims := recommend.Train(interactions, items, r.neighbors)
+	for start := 0; start < len(sims); start += similarityBatchSize {
+		end := min(start+similarityBatchSize, len(sims))
+		arg := db.UpsertListingSimilaritiesParams{TrainedAt: trainedAt}
+		for _, s := range sims[start:end] {
+			arg.ListingIds = append(arg.ListingIds, s.ListingID)
+			arg.SimilarListingIds = append(arg.SimilarListingIds, s.SimilarID)
+			arg.Scores = append(arg.Scores, s.Score)
+		}
+		if err := r.store.UpsertListingSimilarities(ctx, arg); err != nil {
+			return fmt.Errorf("save listing similarities: %w", err)
+		}
+	}
+
+	stale, err := r.store.DeleteStaleListingSimilarities(ctx, trainedAt)
+	if err != nil {
+		return fmt.Errorf("delete stale listing similarities: %w", err)
+	}
+
+	log.Printf("Trained %d listing similarities from %d interactions, removed %d stale", len(sims), len(interactions), stale)
+	return nil
+}
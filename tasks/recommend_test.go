@@ -0,0 +1,60 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+)
+
+type fakeRecommendationStore struct {
+	interactions []db.ListingInteraction
+	items        []db.GetRecommendationItemsRow
+	saved        []db.UpsertListingSimilaritiesParams
+	staleBefore  time.Time
+}
+
+func (f *fakeRecommendationStore) GetListingInteractions(ctx context.Context) ([]db.ListingInteraction, error) {
+	return f.interactions, nil
+}
+
+func (f *fakeRecommendationStore) GetRecommendationItems(ctx context.Context) ([]db.GetRecommendationItemsRow, error) {
+	return f.items, nil
+}
+
+func (f *fakeRecommendationStore) UpsertListingSimilarities(ctx context.Context, arg db.UpsertListingSimilaritiesParams) error {
+	f.saved = append(f.saved, arg)
+	return nil
+}
+
+func (f *fakeRecommendationStore) DeleteStaleListingSimilarities(ctx context.Context, trainedAt time.Time) (int64, error) {
+	f.staleBefore = trainedAt
+	return 0, nil
+}
+
+func TestHandleTrainRecommendationsTask(t *testing.T) {
+	now := time.Date(2024, time.June, 20, 12, 0, 0, 0, time.UTC)
+	store := &fakeRecommendationStore{
+		interactions: []db.ListingInteraction{
+			{UserID: 1, ListingID: 10, Weight: 5},
+			{UserID: 1, ListingID: 11, Weight: 3},
+		},
+		items: []db.GetRecommendationItemsRow{
+			{ID: 10, Location: sql.NullString{String: "Nairobi", Valid: true}, Price: 100},
+			{ID: 11, Price: 120},
+			{ID: 12},
+		},
+	}
+	trainer := NewRecommendationTrainer(store, func() time.Time { return now }, 5)
+
+	require.NoError(t, trainer.HandleTrainRecommendationsTask(context.Background(), NewTrainRecommendationsTask()))
+
+	require.Len(t, store.saved, 1)
+	require.Equal(t, []int32{10, 11}, store.saved[0].ListingIds)
+	require.Equal(t, []int32{11, 10}, store.saved[0].SimilarListingIds)
+	require.Equal(t, now, store.saved[0].TrainedAt)
+	require.Equal(t, now, store.staleBefore)
+}
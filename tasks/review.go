@@ -0,0 +1,54 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const TypePublishReviews = "review:publish"
+
+// ReviewWindow is how long after check-out guests and hosts may review a stay. Reviews are
+// kept hidden until both sides have written theirs or the window closes.
+const ReviewWindow = 14 * 24 * time.Hour
+
+// ReviewStore is the subset of db.Queries used to publish reviews.
+type ReviewStore interface {
+	PublishExpiredReviews(ctx context.Context, cutoff time.Time) (int64, error)
+	PublishExpiredGuestReviews(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ReviewPublisher reveals the listing and guest reviews of stays whose review window has
+// closed, whether or not the other side wrote one.
+type ReviewPublisher struct {
+	store ReviewStore
+	now   func() time.Time
+}
+
+func NewReviewPublisher(store ReviewStore, now func() time.Time) *ReviewPublisher {
+	return &ReviewPublisher{store: store, now: now}
+}
+
+func NewPublishReviewsTask() *asynq.Task {
+	return asynq.NewTask(TypePublishReviews, nil)
+}
+
+func (p *ReviewPublisher) HandlePublishReviewsTask(ctx context.Context, t *asynq.Task) error {
+	cutoff := p.now().Add(-ReviewWindow)
+
+	reviews, err := p.store.PublishExpiredReviews(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("publish listing reviews: %w", err)
+	}
+
+	guestReviews, err := p.store.PublishExpiredGuestReviews(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("publish guest reviews: %w", err)
+	}
+
+	log.Printf("Published %d listing and %d guest reviews after the review window", reviews, guestReviews)
+	return nil
+}
@@ -0,0 +1,37 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReviewStore struct {
+	reviewCutoff      time.Time
+	guestReviewCutoff time.Time
+}
+
+func (f *fakeReviewStore) PublishExpiredReviews(ctx context.Context, cutoff time.Time) (int64, error) {
+	f.reviewCutoff = cutoff
+	return 2, nil
+}
+
+func (f *fakeReviewStore) PublishExpiredGuestReviews(ctx context.Context, cutoff time.Time) (int64, error) {
+	f.guestReviewCutoff = cutoff
+	return 1, nil
+}
+
+func TestHandlePublishReviewsTask(t *testing.T) {
+	now := time.Date(2024, time.June, 20, 12, 0, 0, 0, time.UTC)
+	store := &fakeReviewStore{}
+	publisher := NewReviewPublisher(store, func() time.Time { return now })
+
+	err := publisher.HandlePublishReviewsTask(context.Background(), NewPublishReviewsTask())
+	require.NoError(t, err)
+
+	cutoff := time.Date(2024, time.June, 6, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, cutoff, store.reviewCutoff)
+	require.Equal(t, cutoff, store.guestReviewCutoff)
+}
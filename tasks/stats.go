@@ -0,0 +1,70 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TypeRefreshListingStats = "stats:refresh_listing"
+	TypeRefreshAllStats     = "stats:refresh_all"
+)
+
+type RefreshListingStatsPayload struct {
+	ListingID int32 `json:"listing_id"`
+}
+
+// StatsStore is the subset of db.Queries used to maintain listing stats.
+type StatsStore interface {
+	RefreshListingStats(ctx context.Context, listingID sql.NullInt32) error
+}
+
+// StatsRefresher keeps the per-listing aggregates in the stats table up to date. A listing
+// is refreshed when one of its bookings or reviews changes, and every listing is
+// recomputed periodically to pick up changes made without an event, such as reviews
+// published when their window closes.
+type StatsRefresher struct {
+	store StatsStore
+}
+
+func NewStatsRefresher(store StatsStore) *StatsRefresher {
+	return &StatsRefresher{store: store}
+}
+
+func NewRefreshListingStatsTask(listingID int32) (*asynq.Task, error) {
+	payload, err := json.Marshal(RefreshListingStatsPayload{ListingID: listingID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeRefreshListingStats, payload), nil
+}
+
+func NewRefreshAllStatsTask() *asynq.Task {
+	return asynq.NewTask(TypeRefreshAllStats, nil)
+}
+
+func (r *StatsRefresher) HandleRefreshListingStatsTask(ctx context.Context, t *asynq.Task) error {
+	var payload RefreshListingStatsPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	if err := r.store.RefreshListingStats(ctx, sql.NullInt32{Int32: payload.ListingID, Valid: true}); err != nil {
+		return fmt.Errorf("refresh listing %d stats: %w", payload.ListingID, err)
+	}
+	return nil
+}
+
+func (r *StatsRefresher) HandleRefreshAllStatsTask(ctx context.Context, t *asynq.Task) error {
+	if err := r.store.RefreshListingStats(ctx, sql.NullInt32{}); err != nil {
+		return fmt.Errorf("refresh listing stats: %w", err)
+	}
+
+	log.Println("Recomputed listing stats")
+	return nil
+}
@@ -0,0 +1,30 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStatsStore struct {
+	refreshed []sql.NullInt32
+}
+
+func (f *fakeStatsStore) RefreshListingStats(ctx context.Context, listingID sql.NullInt32) error {
+	f.refreshed = append(f.refreshed, listingID)
+	return nil
+}
+
+func TestStatsRefresher(t *testing.T) {
+	store := &fakeStatsStore{}
+	refresher := NewStatsRefresher(store)
+
+	task, err := NewRefreshListingStatsTask(7)
+	require.NoError(t, err)
+	require.NoError(t, refresher.HandleRefreshListingStatsTask(context.Background(), task))
+	require.NoError(t, refresher.HandleRefreshAllStatsTask(context.Background(), NewRefreshAllStatsTask()))
+
+	require.Equal(t, []sql.NullInt32{{Int32: 7, Valid: true}, {}}, store.refreshed)
+}
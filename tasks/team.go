@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+	"github.com/weldonkipchirchir/rental_listing/mail"
+)
+
+const TypeTeamInvitationEmail = "email:team_invitation"
+
+type TeamInvitationEmailPayload struct {
+	ToEmail          string `json:"to_email"`
+	InvitedBy        string `json:"invited_by"`
+	OrganizationName string `json:"organization_name"`
+	Role             string `json:"role"`
+	InvitationLink   string `json:"invitation_link"`
+	ExpiresAt        string `json:"expires_at"`
+}
+
+func NewTeamInvitationEmailTask(payload TeamInvitationEmailPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeTeamInvitationEmail, data), nil
+}
+
+func HandleTeamInvitationEmailTask(ctx context.Context, t *asynq.Task) error {
+	var payload TeamInvitationEmailPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+
+	subject, content := teamInvitationEmail(payload)
+
+	sender := mail.NewEmailSender("Rental Listing", "weldonkipchirchir23@gmail.com", "bnylvpwgejjngcne")
+	if err := sender.SendEmail(subject, content, []string{payload.ToEmail}, nil, nil, nil); err != nil {
+		return err
+	}
+
+	log.Printf("Sent %s team invitation email to: %s", payload.OrganizationName, payload.ToEmail)
+	return nil
+}
+
+func teamInvitationEmail(p TeamInvitationEmailPayload) (subject, content string) {
+	subject = fmt.Sprintf("Join %s on Rental Listing", p.OrganizationName)
+	content = fmt.Sprintf(
+		"Hello, %s has invited you to join %s as %s. Sign in with this email address and accept the invitation here: %s. The invitation expires on %s.",
+		p.InvitedBy, p.OrganizationName, p.Role, p.InvitationLink, p.ExpiresAt)
+	return subject, content
+}
@@ -0,0 +1,22 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamInvitationEmail(t *testing.T) {
+	subject, content := teamInvitationEmail(TeamInvitationEmailPayload{
+		InvitedBy:        "host",
+		OrganizationName: "Coast Stays",
+		Role:             "manager",
+		InvitationLink:   "http://localhost:3000/invitations/abc",
+		ExpiresAt:        "2024-06-08",
+	})
+
+	require.Equal(t, "Join Coast Stays on Rental Listing", subject)
+	require.Contains(t, content, "host has invited you to join Coast Stays as manager")
+	require.Contains(t, content, "http://localhost:3000/invitations/abc")
+	require.Contains(t, content, "2024-06-08")
+}
@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/views"
+)
+
+const TypeFlushListingViews = "views:flush"
+
+// ViewBuffer holds listing views between flushes; views.RedisBuffer implements it.
+type ViewBuffer interface {
+	Pending(ctx context.Context) ([]views.ListingDay, error)
+	Take(ctx context.Context, d views.ListingDay) (views.Counts, error)
+	Restore(ctx context.Context, d views.ListingDay, counts views.Counts) error
+}
+
+// ViewStore is the subset of db.Queries used to persist listing views.
+type ViewStore interface {
+	RecordListingViews(ctx context.Context, arg db.RecordListingViewsParams) (int64, error)
+}
+
+// ViewFlusher writes buffered listing views to Postgres in one statement per listing and
+// day. Views of a listing day that fails to save are put back for the next run; views of
+// listings that no longer exist are dropped.
+type ViewFlusher struct {
+	buffer ViewBuffer
+	store  ViewStore
+}
+
+func NewViewFlusher(buffer ViewBuffer, store ViewStore) *ViewFlusher {
+	return &ViewFlusher{
+		buffer: buffer,
+		store:  store,
+	}
+}
+
+func NewFlushListingViewsTask() *asynq.Task {
+	return asynq.NewTask(TypeFlushListingViews, nil)
+}
+
+func (f *ViewFlusher) HandleFlushListingViewsTask(ctx context.Context, t *asynq.Task) error {
+	pending, err := f.buffer.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("list pending views: %w", err)
+	}
+
+	var flushed, failed int
+	for _, d := range pending {
+		counts, err := f.buffer.Take(ctx, d)
+		if err != nil {
+			return fmt.Errorf("take views of %s: %w", d, err)
+		}
+		if counts.Views == 0 {
+			continue
+		}
+
+		_, err = f.store.RecordListingViews(ctx, db.RecordListingViewsParams{
+			ListingID:   d.ListingID,
+			Day:         d.Day,
+			Views:       int32(counts.Views),
+			UniqueViews: int32(counts.UniqueViews),
+		})
+		if err != nil {
+			failed++
+			log.Printf("Failed to record views of %s: %v", d, err)
+			if err := f.buffer.Restore(ctx, d, counts); err != nil {
+				return fmt.Errorf("restore views of %s: %w", d, err)
+			}
+			continue
+		}
+		flushed++
+	}
+
+	if flushed > 0 || failed > 0 {
+		log.Printf("Flushed views of %d listing days, %d failed", flushed, failed)
+	}
+	return nil
+}
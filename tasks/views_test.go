@@ -0,0 +1,70 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	db "github.com/weldonkipchirchir/rental_listing/db/sqlc"
+	"github.com/weldonkipchirchir/rental_listing/views"
+)
+
+type fakeViewBuffer struct {
+	counts map[views.ListingDay]views.Counts
+}
+
+func (f *fakeViewBuffer) Pending(ctx context.Context) ([]views.ListingDay, error) {
+	var days []views.ListingDay
+	for d := range f.counts {
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+func (f *fakeViewBuffer) Take(ctx context.Context, d views.ListingDay) (views.Counts, error) {
+	counts := f.counts[d]
+	delete(f.counts, d)
+	return counts, nil
+}
+
+func (f *fakeViewBuffer) Restore(ctx context.Context, d views.ListingDay, counts views.Counts) error {
+	f.counts[d] = counts
+	return nil
+}
+
+type fakeViewStore struct {
+	failListing int32
+	recorded    []db.RecordListingViewsParams
+}
+
+func (f *fakeViewStore) RecordListingViews(ctx context.Context, arg db.RecordListingViewsParams) (int64, error) {
+	if arg.ListingID == f.failListing {
+		return 0, errors.New("connection reset")
+	}
+	f.recorded = append(f.recorded, arg)
+	return 1, nil
+}
+
+func TestViewFlusher(t *testing.T) {
+	day := time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)
+	buffer := &fakeViewBuffer{counts: map[views.ListingDay]views.Counts{
+		{ListingID: 1, Day: day}: {Views: 5, UniqueViews: 3},
+		{ListingID: 2, Day: day}: {Views: 2, UniqueViews: 2},
+		{ListingID: 3, Day: day}: {Views: 0, UniqueViews: 1},
+	}}
+	store := &fakeViewStore{failListing: 2}
+
+	flusher := NewViewFlusher(buffer, store)
+	require.NoError(t, flusher.HandleFlushListingViewsTask(context.Background(), NewFlushListingViewsTask()))
+
+	require.Equal(t, []db.RecordListingViewsParams{
+		{ListingID: 1, Day: day, Views: 5, UniqueViews: 3},
+	}, store.recorded)
+
+	// The failed listing day is back in the buffer for the next run.
+	require.Equal(t, map[views.ListingDay]views.Counts{
+		{ListingID: 2, Day: day}: {Views: 2, UniqueViews: 2},
+	}, buffer.counts)
+}
@@ -0,0 +1,89 @@
+// Package team defines the roles members of an organisation can have and what each role
+// is allowed to do with the organisation's listings.
+package team
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Member roles.
+const (
+	Owner      = "owner"
+	Manager    = "manager"
+	Cleaner    = "cleaner"
+	Accountant = "accountant"
+)
+
+// Roles lists every role, most privileged first.
+var Roles = []string{Owner, Manager, Cleaner, Accountant}
+
+// Permission is something a member may be allowed to do.
+type Permission string
+
+const (
+	// ViewListings shows the organisation's listings and their reviews.
+	ViewListings Permission = "view_listings"
+	// EditListings creates, edits, publishes and deletes listings, including their
+	// images, calendars and prices.
+	EditListings Permission = "edit_listings"
+	// ViewBookings shows the bookings of the organisation's listings.
+	ViewBookings Permission = "view_bookings"
+	// ApproveBookings approves, declines and changes bookings.
+	ApproveBookings Permission = "approve_bookings"
+	// Messaging writes to guests and replies to their reviews.
+	Messaging Permission = "messaging"
+	// Payouts shows the payments received for bookings.
+	Payouts Permission = "payouts"
+	// ManageTeam invites, removes and changes the roles of members.
+	ManageTeam Permission = "manage_team"
+)
+
+var permissions = map[string][]Permission{
+	Owner:      {ViewListings, EditListings, ViewBookings, ApproveBookings, Messaging, Payouts, ManageTeam},
+	Manager:    {ViewListings, EditListings, ViewBookings, ApproveBookings, Messaging},
+	Cleaner:    {ViewListings, ViewBookings},
+	Accountant: {ViewListings, ViewBookings, Payouts},
+}
+
+// InvitationTTL is how long an invitation to join an organisation can be accepted.
+const InvitationTTL = 7 * 24 * time.Hour
+
+// Valid reports whether role is a member role.
+func Valid(role string) bool {
+	_, ok := permissions[role]
+	return ok
+}
+
+// Allows reports whether a member with role has permission p.
+func Allows(role string, p Permission) bool {
+	for _, granted := range permissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesWith returns the roles that have permission p, in the order of Roles. Queries
+// scoped to an admin's listings take it to include the organisations the admin can act
+// for.
+func RolesWith(p Permission) []string {
+	var roles []string
+	for _, role := range Roles {
+		if Allows(role, p) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// NewInvitationToken returns a random token for an invitation link.
+func NewInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
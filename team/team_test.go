@@ -0,0 +1,52 @@
+package team
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role    string
+		allowed []Permission
+		denied  []Permission
+	}{
+		{Owner, []Permission{EditListings, ApproveBookings, Messaging, Payouts, ManageTeam}, nil},
+		{Manager, []Permission{EditListings, ApproveBookings, Messaging}, []Permission{Payouts, ManageTeam}},
+		{Cleaner, []Permission{ViewListings, ViewBookings}, []Permission{EditListings, ApproveBookings, Messaging, Payouts}},
+		{Accountant, []Permission{ViewBookings, Payouts}, []Permission{EditListings, ApproveBookings, Messaging, ManageTeam}},
+		{"guest", nil, []Permission{ViewListings}},
+	}
+
+	for _, tc := range tests {
+		for _, p := range tc.allowed {
+			require.True(t, Allows(tc.role, p), "%s should have %s", tc.role, p)
+		}
+		for _, p := range tc.denied {
+			require.False(t, Allows(tc.role, p), "%s should not have %s", tc.role, p)
+		}
+	}
+}
+
+func TestRolesWith(t *testing.T) {
+	require.Equal(t, []string{Owner, Manager, Cleaner, Accountant}, RolesWith(ViewBookings))
+	require.Equal(t, []string{Owner, Accountant}, RolesWith(Payouts))
+	require.Equal(t, []string{Owner}, RolesWith(ManageTeam))
+}
+
+func TestValid(t *testing.T) {
+	for _, role := range Roles {
+		require.True(t, Valid(role))
+	}
+	require.False(t, Valid("admin"))
+}
+
+func TestNewInvitationToken(t *testing.T) {
+	a, err := NewInvitationToken()
+	require.NoError(t, err)
+	b, err := NewInvitationToken()
+	require.NoError(t, err)
+	require.Len(t, a, 64)
+	require.NotEqual(t, a, b)
+}
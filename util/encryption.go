@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"io"
 )
@@ -51,3 +52,12 @@ func Decrypt(ciphertextBase64 string, key []byte) ([]byte, error) {
 
 	return ciphertext, nil
 }
+
+// SecureToken returns a random hex token of n bytes, suitable for secret URLs.
+func SecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,17 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureToken(t *testing.T) {
+	token1, err := SecureToken(32)
+	require.NoError(t, err)
+	require.Len(t, token1, 64)
+
+	token2, err := SecureToken(32)
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+}
@@ -0,0 +1,190 @@
+// Package views buffers listing page views in Redis so that the public view endpoint does
+// not write to Postgres on every hit. A visitor is counted once per dedup window, distinct
+// visitors per day are kept in a HyperLogLog, and the counts are flushed in batches.
+package views
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultWindow is how long repeat views by the same visitor are ignored.
+	DefaultWindow = 30 * time.Minute
+
+	pendingKey = "views:pending"
+
+	// dayKeyTTL keeps a day's counters around long enough to be flushed after midnight.
+	dayKeyTTL = 72 * time.Hour
+)
+
+// botAgents are lower-case substrings of user agents that are not counted as views.
+var botAgents = []string{
+	"bot", "crawl", "spider", "slurp", "fetch", "preview", "externalhit", "monitor", "headless",
+	"lighthouse", "curl", "wget", "python-requests", "python-urllib", "go-http-client",
+	"java/", "okhttp", "axios", "node-fetch", "postman", "httpie", "libwww", "scrapy",
+}
+
+// IsBot reports whether a request with the given user agent comes from a crawler, a link
+// previewer or a script. Requests without a user agent are treated as bots.
+func IsBot(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return true
+	}
+	for _, s := range botAgents {
+		if strings.Contains(ua, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// VisitorID identifies an anonymous visitor by their IP address and user agent. The
+// result is a hash, so neither is stored in Redis.
+func VisitorID(ip, userAgent string) string {
+	sum := sha256.Sum256([]byte(ip + "\x00" + userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ListingDay is the unit views are counted and flushed in.
+type ListingDay struct {
+	ListingID int32
+	Day       time.Time
+}
+
+func (d ListingDay) String() string {
+	return fmt.Sprintf("%d:%s", d.ListingID, d.Day.Format("2006-01-02"))
+}
+
+// ParseListingDay parses the form produced by ListingDay.String.
+func ParseListingDay(s string) (ListingDay, error) {
+	id, day, ok := strings.Cut(s, ":")
+	if !ok {
+		return ListingDay{}, fmt.Errorf("invalid listing day %q", s)
+	}
+	listingID, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return ListingDay{}, fmt.Errorf("invalid listing day %q: %w", s, err)
+	}
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return ListingDay{}, fmt.Errorf("invalid listing day %q: %w", s, err)
+	}
+	return ListingDay{ListingID: int32(listingID), Day: t}, nil
+}
+
+// Counts are the views of a listing day taken from the buffer. Views is the number of
+// counted views since the last flush; UniqueViews is the day's distinct visitor count so
+// far.
+type Counts struct {
+	Views       int64
+	UniqueViews int64
+}
+
+// RedisBuffer keeps view counts in Redis until they are flushed.
+type RedisBuffer struct {
+	client *redis.Client
+	window time.Duration
+}
+
+func NewRedisBuffer(client *redis.Client, window time.Duration) *RedisBuffer {
+	return &RedisBuffer{
+		client: client,
+		window: window,
+	}
+}
+
+func seenKey(listingID int32, visitor string) string {
+	return fmt.Sprintf("views:seen:%d:%s", listingID, visitor)
+}
+
+func totalKey(d ListingDay) string {
+	return "views:total:" + d.String()
+}
+
+func uniqueKey(d ListingDay) string {
+	return "views:unique:" + d.String()
+}
+
+// Record counts a view of the listing by visitor at now, unless the same visitor viewed
+// it within the dedup window. It reports whether the view was counted.
+func (b *RedisBuffer) Record(ctx context.Context, listingID int32, visitor string, now time.Time) (bool, error) {
+	first, err := b.client.SetNX(ctx, seenKey(listingID, visitor), 1, b.window).Result()
+	if err != nil || !first {
+		return false, err
+	}
+
+	d := ListingDay{ListingID: listingID, Day: now.UTC().Truncate(24 * time.Hour)}
+	_, err = b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.IncrBy(ctx, totalKey(d), 1)
+		pipe.Expire(ctx, totalKey(d), dayKeyTTL)
+		pipe.PFAdd(ctx, uniqueKey(d), visitor)
+		pipe.Expire(ctx, uniqueKey(d), dayKeyTTL)
+		pipe.SAdd(ctx, pendingKey, d.String())
+		return nil
+	})
+	return err == nil, err
+}
+
+// Pending returns the listing days with views that have not been flushed.
+func (b *RedisBuffer) Pending(ctx context.Context) ([]ListingDay, error) {
+	members, err := b.client.SMembers(ctx, pendingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]ListingDay, 0, len(members))
+	for _, m := range members {
+		d, err := ParseListingDay(m)
+		if err != nil {
+			// Drop malformed members rather than failing every flush on them.
+			b.client.SRem(ctx, pendingKey, m)
+			continue
+		}
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+// Take removes the buffered views of d and returns them. The day is unmarked before its
+// counter is read, so a view recorded meanwhile marks it again for the next flush.
+func (b *RedisBuffer) Take(ctx context.Context, d ListingDay) (Counts, error) {
+	if err := b.client.SRem(ctx, pendingKey, d.String()).Err(); err != nil {
+		return Counts{}, err
+	}
+
+	var counts Counts
+	total, err := b.client.GetDel(ctx, totalKey(d)).Int64()
+	if err != nil && err != redis.Nil {
+		return Counts{}, err
+	}
+	counts.Views = total
+
+	if counts.UniqueViews, err = b.client.PFCount(ctx, uniqueKey(d)).Result(); err != nil {
+		// Put the views back so they are not lost.
+		if rerr := b.Restore(ctx, d, counts); rerr != nil {
+			return Counts{}, fmt.Errorf("%w (restore failed: %v)", err, rerr)
+		}
+		return Counts{}, err
+	}
+	return counts, nil
+}
+
+// Restore puts views taken by Take back into the buffer after a failed flush.
+func (b *RedisBuffer) Restore(ctx context.Context, d ListingDay, counts Counts) error {
+	_, err := b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.IncrBy(ctx, totalKey(d), counts.Views)
+		pipe.Expire(ctx, totalKey(d), dayKeyTTL)
+		pipe.SAdd(ctx, pendingKey, d.String())
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,42 @@
+package views
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBot(t *testing.T) {
+	require.True(t, IsBot(""))
+	require.True(t, IsBot("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"))
+	require.True(t, IsBot("facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"))
+	require.True(t, IsBot("curl/8.4.0"))
+	require.True(t, IsBot("python-requests/2.31.0"))
+	require.True(t, IsBot("Mozilla/5.0 HeadlessChrome/120.0.0.0"))
+
+	require.False(t, IsBot("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15"))
+	require.False(t, IsBot("Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"))
+}
+
+func TestVisitorID(t *testing.T) {
+	a := VisitorID("203.0.113.7", "Mozilla/5.0")
+	require.Len(t, a, 32)
+	require.Equal(t, a, VisitorID("203.0.113.7", "Mozilla/5.0"))
+	require.NotEqual(t, a, VisitorID("203.0.113.8", "Mozilla/5.0"))
+	require.NotEqual(t, a, VisitorID("203.0.113.7", "Mozilla/5.1"))
+}
+
+func TestListingDay(t *testing.T) {
+	d := ListingDay{ListingID: 42, Day: time.Date(2024, time.June, 3, 0, 0, 0, 0, time.UTC)}
+	require.Equal(t, "42:2024-06-03", d.String())
+
+	parsed, err := ParseListingDay(d.String())
+	require.NoError(t, err)
+	require.Equal(t, d, parsed)
+
+	for _, s := range []string{"", "42", "x:2024-06-03", "42:June"} {
+		_, err := ParseListingDay(s)
+		require.Error(t, err, s)
+	}
+}
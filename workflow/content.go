@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Content is the part of a listing the host edits, which is kept for each revision.
+// Images have their own endpoints and are not part of a revision.
+type Content struct {
+	Title       string
+	Description string
+	Price       string
+	Location    string
+	BookingMode string
+}
+
+// Checklist requirements.
+const (
+	MinTitleLength       = 10
+	MinDescriptionLength = 50
+	MinPhotos            = 3
+)
+
+// Item is one requirement of the checklist.
+type Item struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+	Done    bool   `json:"done"`
+}
+
+// Checklist returns the requirements a listing must meet before it is published, given
+// its content and how many of its photos are processed.
+func Checklist(content Content, photos int) []Item {
+	price, err := strconv.ParseFloat(strings.TrimSpace(content.Price), 64)
+	return []Item{
+		{
+			Key:     "title",
+			Message: "Add a title of at least " + strconv.Itoa(MinTitleLength) + " characters",
+			Done:    len([]rune(strings.TrimSpace(content.Title))) >= MinTitleLength,
+		},
+		{
+			Key:     "description",
+			Message: "Describe the place in at least " + strconv.Itoa(MinDescriptionLength) + " characters",
+			Done:    len([]rune(strings.TrimSpace(content.Description))) >= MinDescriptionLength,
+		},
+		{
+			Key:     "price",
+			Message: "Set a nightly price",
+			Done:    err == nil && price > 0,
+		},
+		{
+			Key:     "location",
+			Message: "Add the location",
+			Done:    strings.TrimSpace(content.Location) != "",
+		},
+		{
+			Key:     "photos",
+			Message: "Add at least " + strconv.Itoa(MinPhotos) + " photos",
+			Done:    photos >= MinPhotos,
+		},
+	}
+}
+
+// Complete reports whether every item of a checklist is done.
+func Complete(items []Item) bool {
+	for _, item := range items {
+		if !item.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// Change is a field that differs between two revisions.
+type Change struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Diff returns the fields that changed from one revision to the next, in a fixed order.
+func Diff(from, to Content) []Change {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"description", from.Description, to.Description},
+		{"price", from.Price, to.Price},
+		{"location", from.Location, to.Location},
+		{"booking_mode", from.BookingMode, to.BookingMode},
+	}
+
+	changes := []Change{}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, Change{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+// NeedsReview reports whether changes to a published listing alter what a super-admin
+// reviews, so the listing goes back to review when listings need approval. Prices and
+// booking modes are left to the host.
+func NeedsReview(changes []Change) bool {
+	for _, c := range changes {
+		switch c.Field {
+		case "title", "description", "location":
+			return true
+		}
+	}
+	return false
+}
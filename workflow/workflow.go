@@ -0,0 +1,84 @@
+// Package workflow implements the lifecycle of a listing from draft to publication: the
+// states a listing moves through, the checklist it must pass before it is published and
+// the differences between revisions of its content.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Listing states.
+const (
+	Draft         = "draft"
+	PendingReview = "pending_review"
+	Published     = "published"
+	Paused        = "paused"
+	Archived      = "archived"
+)
+
+var (
+	ErrIncomplete = errors.New("listing is not ready to be published")
+	ErrNotPending = errors.New("listing is not awaiting review")
+)
+
+// TransitionError reports a change of state the host asked for that is not allowed.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move a listing from %s to %s", strings.ReplaceAll(e.From, "_", " "), strings.ReplaceAll(e.To, "_", " "))
+}
+
+// Next returns the state a listing in from moves to when its host asks for to:
+//
+//   - published submits a draft, which goes to review first when approvalRequired, or
+//     resumes a paused listing;
+//   - paused takes a published listing off the market for a while;
+//   - archived retires a listing in any other state;
+//   - draft withdraws a listing from review or publication, or restores an archived one.
+//
+// A draft must pass the checklist before it is submitted; see NeedsChecklist.
+func Next(from, to string, approvalRequired bool) (string, error) {
+	switch {
+	case to == Published && from == Draft:
+		if approvalRequired {
+			return PendingReview, nil
+		}
+		return Published, nil
+	case to == Published && from == Paused:
+		return Published, nil
+	case to == Paused && from == Published:
+		return Paused, nil
+	case to == Archived && from != Archived:
+		return Archived, nil
+	case to == Draft && from != Draft:
+		return Draft, nil
+	}
+	return "", &TransitionError{From: from, To: to}
+}
+
+// NeedsChecklist reports whether moving from one state to another publishes new content,
+// so the listing must pass the checklist first.
+func NeedsChecklist(from, to string) bool {
+	return from == Draft && (to == PendingReview || to == Published)
+}
+
+// Review returns the state a listing awaiting review moves to when a super-admin approves
+// or rejects it. Rejected listings go back to draft for the host to fix.
+func Review(from string, approve bool) (string, error) {
+	if from != PendingReview {
+		return "", ErrNotPending
+	}
+	if approve {
+		return Published, nil
+	}
+	return Draft, nil
+}
+
+// Visible reports whether guests can find and book a listing in state.
+func Visible(state string) bool {
+	return state == Published
+}
@@ -0,0 +1,103 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		from, to string
+		approval bool
+		want     string
+	}{
+		{Draft, Published, false, Published},
+		{Draft, Published, true, PendingReview},
+		{Paused, Published, true, Published},
+		{Published, Paused, false, Paused},
+		{PendingReview, Draft, true, Draft},
+		{Published, Archived, false, Archived},
+		{Archived, Draft, false, Draft},
+	}
+	for _, tt := range tests {
+		got, err := Next(tt.from, tt.to, tt.approval)
+		require.NoError(t, err, "%s to %s", tt.from, tt.to)
+		require.Equal(t, tt.want, got, "%s to %s", tt.from, tt.to)
+	}
+
+	for _, tt := range []struct{ from, to string }{
+		{PendingReview, Published},
+		{Archived, Published},
+		{Draft, Paused},
+		{Archived, Archived},
+		{Draft, Draft},
+		{Draft, PendingReview},
+	} {
+		_, err := Next(tt.from, tt.to, false)
+		var transition *TransitionError
+		require.ErrorAs(t, err, &transition, "%s to %s", tt.from, tt.to)
+	}
+
+	_, err := Next(Archived, Published, false)
+	require.EqualError(t, err, "cannot move a listing from archived to published")
+
+	require.True(t, NeedsChecklist(Draft, PendingReview))
+	require.True(t, NeedsChecklist(Draft, Published))
+	require.False(t, NeedsChecklist(Paused, Published))
+}
+
+func TestReview(t *testing.T) {
+	next, err := Review(PendingReview, true)
+	require.NoError(t, err)
+	require.Equal(t, Published, next)
+
+	next, err = Review(PendingReview, false)
+	require.NoError(t, err)
+	require.Equal(t, Draft, next)
+
+	_, err = Review(Published, true)
+	require.ErrorIs(t, err, ErrNotPending)
+
+	require.True(t, Visible(Published))
+	require.False(t, Visible(PendingReview))
+}
+
+func TestChecklist(t *testing.T) {
+	content := Content{
+		Title:       "Sea view cottage",
+		Description: "A two bedroom cottage a short walk from Diani beach, with a garden.",
+		Price:       "120.00",
+		Location:    "Diani",
+	}
+	require.True(t, Complete(Checklist(content, MinPhotos)))
+
+	items := Checklist(Content{Title: "Cottage", Price: "0"}, 1)
+	require.False(t, Complete(items))
+	var missing []string
+	for _, item := range items {
+		if !item.Done {
+			missing = append(missing, item.Key)
+		}
+	}
+	require.Equal(t, []string{"title", "description", "price", "location", "photos"}, missing)
+}
+
+func TestDiff(t *testing.T) {
+	from := Content{Title: "Cottage", Price: "100.00", BookingMode: "instant"}
+	to := Content{Title: "Sea view cottage", Price: "100.00", BookingMode: "request"}
+
+	require.Equal(t, []Change{
+		{Field: "title", From: "Cottage", To: "Sea view cottage"},
+		{Field: "booking_mode", From: "instant", To: "request"},
+	}, Diff(from, to))
+	require.Empty(t, Diff(to, to))
+}
+
+func TestNeedsReview(t *testing.T) {
+	from := Content{Title: "Cottage", Price: "100.00", BookingMode: "instant"}
+
+	require.False(t, NeedsReview(Diff(from, Content{Title: "Cottage", Price: "80.00", BookingMode: "request"})))
+	require.True(t, NeedsReview(Diff(from, Content{Title: "Sea view cottage", Price: "100.00", BookingMode: "instant"})))
+	require.True(t, NeedsReview(Diff(from, Content{Title: "Cottage", Location: "Mombasa", Price: "100.00", BookingMode: "instant"})))
+}